@@ -5,25 +5,32 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/felipepmaragno/ai-gateway/internal/api"
+	"github.com/felipepmaragno/ai-gateway/internal/archive"
 	"github.com/felipepmaragno/ai-gateway/internal/auth"
 	"github.com/felipepmaragno/ai-gateway/internal/budget"
 	"github.com/felipepmaragno/ai-gateway/internal/cache"
+	"github.com/felipepmaragno/ai-gateway/internal/circuitbreaker"
 	"github.com/felipepmaragno/ai-gateway/internal/config"
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/crypto"
+	"github.com/felipepmaragno/ai-gateway/internal/httputil"
 	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/notifications"
 	"github.com/felipepmaragno/ai-gateway/internal/provider/anthropic"
 	"github.com/felipepmaragno/ai-gateway/internal/provider/bedrock"
+	"github.com/felipepmaragno/ai-gateway/internal/provider/mistral"
 	"github.com/felipepmaragno/ai-gateway/internal/provider/ollama"
 	"github.com/felipepmaragno/ai-gateway/internal/provider/openai"
+	"github.com/felipepmaragno/ai-gateway/internal/queue"
 	"github.com/felipepmaragno/ai-gateway/internal/ratelimit"
 	"github.com/felipepmaragno/ai-gateway/internal/repository"
 	"github.com/felipepmaragno/ai-gateway/internal/router"
@@ -40,6 +47,10 @@ func main() {
 
 const version = "0.6.0"
 
+// responseCacheTTL is how long cached chat responses, including preloaded
+// ones, are served.
+const responseCacheTTL = 5 * time.Minute
+
 func run() error {
 	cfg, err := config.Load()
 	if err != nil {
@@ -61,9 +72,11 @@ func run() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	shutdownTelemetry, telemetryErr := telemetry.Init(ctx, "ai-gateway", cfg.OTLPEndpoint)
+	shutdownTelemetry, telemetryErr := telemetry.Init(ctx, "ai-gateway", cfg.OTLPEndpoint,
+		telemetry.WithSampleRatio(cfg.TraceSampleRatio),
+	)
 	if telemetryErr != nil {
-		slog.Warn("failed to initialize telemetry", "error", telemetryErr)
+		slog.Warn("failed to initialize telemetry, tracing disabled", "error", telemetryErr)
 	}
 	defer func() {
 		if shutdownTelemetry != nil {
@@ -71,6 +84,16 @@ func run() error {
 		}
 	}()
 
+	var tenantRepoOpts []repository.Option
+	if cfg.EncryptionKey != "" {
+		encryptor, encErr := crypto.NewEncryptor(cfg.EncryptionKey)
+		if encErr != nil {
+			return fmt.Errorf("create encryptor: %w", encErr)
+		}
+		tenantRepoOpts = append(tenantRepoOpts, repository.WithEncryptor(encryptor))
+		slog.Info("provider key encryption enabled")
+	}
+
 	var tenantRepo repository.TenantRepository
 	var costTracker cost.Tracker
 	var db *sql.DB
@@ -90,11 +113,33 @@ func run() error {
 			return fmt.Errorf("ping database: %w", pingErr)
 		}
 
-		tenantRepo = repository.NewPostgresTenantRepository(db)
-		costTracker = repository.NewPostgresUsageRepository(db)
+		pgTenantRepo := repository.NewPostgresTenantRepository(db, tenantRepoOpts...)
+		if encErr := pgTenantRepo.CheckEncryption(ctx); encErr != nil {
+			return fmt.Errorf("tenant secrets stored but ENCRYPTION_KEY not set: %w", encErr)
+		}
+		tenantRepo = pgTenantRepo
+		deadLetters, dlErr := newDeadLetterStore(cfg)
+		if dlErr != nil {
+			return dlErr
+		}
+		costTracker = cost.NewRetryingTracker(repository.NewPostgresUsageRepository(db), deadLetters)
 		slog.Info("using postgresql storage")
+
+		if cfg.TenantCacheTTL > 0 {
+			tenantRepo = newTenantCache(ctx, tenantRepo, cfg)
+		}
 	} else {
-		tenantRepo = repository.NewInMemoryTenantRepository()
+		var memOpts []repository.InMemoryOption
+		if cfg.MemoryMaxTenants > 0 {
+			memOpts = append(memOpts, repository.WithMaxTenants(cfg.MemoryMaxTenants))
+		}
+		if cfg.MemoryTenantLRU {
+			memOpts = append(memOpts, repository.WithLRUEviction())
+		}
+		if !cfg.MemoryDefaultTenant {
+			memOpts = append(memOpts, repository.WithoutDefaultTenant())
+		}
+		tenantRepo = repository.NewInMemoryTenantRepository(memOpts...)
 		costTracker = cost.NewInMemoryTracker()
 		slog.Info("using in-memory storage")
 	}
@@ -107,27 +152,59 @@ func run() error {
 		}
 		slog.Info("using redis rate limiter", "url", cfg.RedisURL)
 	} else {
-		rateLimiter = ratelimit.NewInMemoryRateLimiter()
+		rateLimiter = ratelimit.NewInMemoryRateLimiter(ratelimit.WithSweepInterval(cfg.RateLimitSweepInterval))
 		slog.Info("using in-memory rate limiter")
 	}
 
 	providers := make(map[string]router.Provider)
 
+	// Each provider gets its own client so the per-host connection cap
+	// applies to one upstream at a time.
+	clientConfig := httputil.DefaultConfig()
+	clientConfig.MaxConnsPerHost = cfg.ProviderMaxConnsPerHost
+	clientConfig.Timeout = cfg.ProviderTimeout
+	providerClient := func() *http.Client {
+		client := httputil.NewClient(clientConfig)
+		if len(cfg.DebugProviderTenants) > 0 || len(cfg.DebugProviderModels) > 0 {
+			client = httputil.DebugClient(client)
+		}
+		return client
+	}
+
 	if cfg.OpenAIAPIKey != "" {
-		providers["openai"] = openai.New(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL)
+		providers["openai"] = openai.New(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL,
+			openai.WithHTTPClient(providerClient()),
+			openai.WithStreamIdleTimeout(cfg.ProviderStreamIdleTimeout),
+		)
 		slog.Info("registered provider", "provider", "openai")
 	}
 
 	if cfg.OllamaBaseURL != "" {
-		providers["ollama"] = ollama.New(cfg.OllamaBaseURL)
+		providers["ollama"] = ollama.New(cfg.OllamaBaseURL,
+			ollama.WithModelAliases(cfg.OllamaModelAliases),
+			ollama.WithHTTPClient(providerClient()),
+			ollama.WithStreamIdleTimeout(cfg.ProviderStreamIdleTimeout),
+		)
 		slog.Info("registered provider", "provider", "ollama", "url", cfg.OllamaBaseURL)
 	}
 
 	if cfg.AnthropicAPIKey != "" {
-		providers["anthropic"] = anthropic.New(cfg.AnthropicAPIKey)
+		providers["anthropic"] = anthropic.New(cfg.AnthropicAPIKey,
+			anthropic.WithHTTPClient(providerClient()),
+			anthropic.WithStreamIdleTimeout(cfg.ProviderStreamIdleTimeout),
+		)
 		slog.Info("registered provider", "provider", "anthropic")
 	}
 
+	if cfg.MistralAPIKey != "" {
+		providers["mistral"] = mistral.New(cfg.MistralAPIKey,
+			mistral.WithBaseURL(cfg.MistralBaseURL),
+			mistral.WithHTTPClient(providerClient()),
+			mistral.WithStreamIdleTimeout(cfg.ProviderStreamIdleTimeout),
+		)
+		slog.Info("registered provider", "provider", "mistral")
+	}
+
 	if cfg.AWSRegion != "" {
 		bedrockProvider, bedrockErr := bedrock.New(ctx, cfg.AWSRegion)
 		if bedrockErr != nil {
@@ -148,28 +225,63 @@ func run() error {
 	}
 
 	// Create router with circuit breaker configuration
-	var providerRouter *router.Router
+	cbConfig := circuitbreaker.DefaultConfig()
+	cbConfig.LatencyThreshold = cfg.CBLatencyThreshold
+	if cbConfig.LatencyThreshold > 0 {
+		slog.Info("latency-based circuit breaking enabled", "p95_threshold", cbConfig.LatencyThreshold)
+	}
+
+	if err := api.ValidateBudgetExceededFields(cfg.BudgetExceededFields); err != nil {
+		return fmt.Errorf("invalid BUDGET_EXCEEDED_FIELDS: %w", err)
+	}
+
+	if err := router.ValidateFallbackOrder(cfg.FallbackOrder, providers); err != nil {
+		return fmt.Errorf("invalid FALLBACK_ORDER: %w", err)
+	}
+
+	if err := router.ValidateHeaderRules(cfg.HeaderRoutingRules, providers); err != nil {
+		return fmt.Errorf("invalid HEADER_ROUTING_RULES: %w", err)
+	}
+
+	routerConfig := router.Config{
+		Providers:          providers,
+		DefaultProvider:    cfg.DefaultProvider,
+		FallbackOrder:      cfg.FallbackOrder,
+		CBConfig:           cbConfig,
+		CBStateConcurrency: cfg.CBStateConcurrency,
+		CBStateFile:        cfg.CBStateFile,
+		CBPerModel:         cfg.CBPerModel,
+		HeaderRules:        cfg.HeaderRoutingRules,
+		UnknownModels:      router.UnknownModels(cfg.UnknownModelStrategy),
+		ProbeTTL:           cfg.ModelProbeTTL,
+		ProbeTimeout:       cfg.ModelsProviderTimeout,
+		Priced:             cost.NewCalculator().Priced,
+	}
 	if cfg.UseDistributedCircuitBreaker && cfg.RedisURL != "" {
-		providerRouter = router.NewWithConfig(router.Config{
-			Providers:       providers,
-			DefaultProvider: cfg.DefaultProvider,
-			RedisURL:        cfg.RedisURL,
-		})
-	} else {
-		providerRouter = router.New(providers, cfg.DefaultProvider)
+		routerConfig.RedisURL = cfg.RedisURL
 	}
+	if cfg.RoutingStrategy == "weighted" {
+		routerConfig.Strategy = newWeightedStrategy(cfg)
+		slog.Info("using weighted provider selection", "weights", cfg.RoutingWeights, "costs", cfg.ProviderCosts)
+	}
+	providerRouter := router.NewWithConfig(routerConfig)
 
+	cacheOpts := []cache.Option{
+		cache.WithMaxValueSize(cfg.CacheMaxValueBytes),
+		cache.WithCompressThreshold(cfg.CacheCompressThresholdBytes),
+		cache.WithRetries(cfg.CacheRedisRetries, cfg.CacheRedisRetryBackoff),
+	}
 	var responseCache cache.Cache
 	if cfg.RedisURL != "" {
-		responseCache, err = cache.NewRedisCache(cfg.RedisURL)
+		responseCache, err = cache.NewRedisCache(cfg.RedisURL, cacheOpts...)
 		if err != nil {
 			slog.Warn("failed to connect to redis for cache, using in-memory", "error", err)
-			responseCache = cache.NewInMemoryCache()
+			responseCache = cache.NewInMemoryCache(cacheOpts...)
 		} else {
 			slog.Info("using redis cache")
 		}
 	} else {
-		responseCache = cache.NewInMemoryCache()
+		responseCache = cache.NewInMemoryCache(cacheOpts...)
 		slog.Info("using in-memory cache")
 	}
 
@@ -185,9 +297,69 @@ func run() error {
 		}
 	}
 
+	webhooks := notifications.NewWebhookNotifier(
+		notifications.WithWebhookRetries(cfg.TenantWebhookRetries, time.Second))
+	budgetOpts = append(budgetOpts, budget.WithTenantWebhooks(webhooks))
+
+	var killSwitch budget.KillSwitch = budget.NewInMemoryKillSwitch()
+	if cfg.RedisURL != "" {
+		ks, err := budget.NewRedisKillSwitch(cfg.RedisURL, budget.DefaultKillSwitchRefresh)
+		if err != nil {
+			slog.Warn("failed to create redis kill switch, using in-memory", "error", err)
+		} else {
+			killSwitch = ks
+		}
+	}
+
 	budgetMonitor := budget.NewMonitor(costTracker, budget.DefaultThresholds(), budgetOpts...)
 	budgetMonitor.OnAlert(budget.LogAlertHandler)
 
+	var providerLimiter *ratelimit.ProviderLimiter
+	if len(cfg.ProviderRateLimits) > 0 {
+		providerLimiter = ratelimit.NewProviderLimiter(cfg.ProviderRateLimits,
+			ratelimit.WithMaxWait(cfg.ProviderRateLimitWait))
+		slog.Info("outbound provider rate limits enabled", "limits", cfg.ProviderRateLimits)
+	}
+
+	var providerCaps *budget.ProviderCaps
+	if len(cfg.ProviderDailyCostCaps) > 0 {
+		providerCaps = budget.NewProviderCaps(costTracker, cfg.ProviderDailyCostCaps)
+		slog.Info("provider daily cost caps enabled", "caps", cfg.ProviderDailyCostCaps)
+	}
+
+	var periodCost *budget.PeriodCostGauge
+	if cfg.TenantCostGaugeMaxTenants > 0 {
+		periodCost = budget.NewPeriodCostGauge(costTracker, cfg.TenantCostGaugeMaxTenants,
+			budget.WithPeriodCostRefreshInterval(cfg.TenantCostGaugeInterval))
+		defer periodCost.Stop()
+		slog.Info("tenant period cost gauge enabled", "max_tenants", cfg.TenantCostGaugeMaxTenants)
+	}
+
+	// Usage flows through the snapshotter so per-tenant totals outlive the
+	// process; the admin API keeps the plain tracker for dead-letter access.
+	handlerTracker := costTracker
+	if cfg.MetricsSnapshotInterval > 0 {
+		if db == nil {
+			slog.Warn("METRICS_SNAPSHOT_INTERVAL needs DATABASE_URL, metrics snapshots disabled")
+		} else {
+			snapshotter := cost.NewSnapshotter(costTracker, repository.NewPostgresSnapshotRepository(db),
+				cost.WithSnapshotInstance(cfg.PodName), cost.WithSnapshotInterval(cfg.MetricsSnapshotInterval),
+				cost.WithSnapshotRetention(cfg.MetricsSnapshotRetention))
+			if seedErr := snapshotter.Seed(ctx); seedErr != nil {
+				slog.Warn("failed to seed tenant metrics from snapshots", "error", seedErr)
+			}
+			defer func() {
+				stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if stopErr := snapshotter.Stop(stopCtx); stopErr != nil {
+					slog.Warn("failed to write final tenant metrics snapshot", "error", stopErr)
+				}
+			}()
+			handlerTracker = snapshotter
+			slog.Info("tenant metrics snapshots enabled", "interval", cfg.MetricsSnapshotInterval, "instance", cfg.PodName)
+		}
+	}
+
 	// Configure health checkers for readiness probe
 	var healthCheckers []api.HealthChecker
 	if cfg.RedisURL != "" {
@@ -200,30 +372,91 @@ func run() error {
 		healthCheckers = append(healthCheckers, api.NewPostgresHealthChecker(db))
 		slog.Info("added postgres health checker")
 	}
+	healthCheckers = append(healthCheckers, awsHealthCheckers(ctx, cfg)...)
+
+	archiver, archiveSampler, err := newArchiver(cfg)
+	if err != nil {
+		return err
+	}
 
 	handler := api.NewHandler(api.HandlerConfig{
-		TenantRepo:     tenantRepo,
-		RateLimiter:    rateLimiter,
-		Router:         providerRouter,
-		Cache:          responseCache,
-		CacheTTL:       5 * time.Minute,
-		CostTracker:    costTracker,
-		BudgetMonitor:  budgetMonitor,
-		HealthCheckers: healthCheckers,
+		TenantRepo:           tenantRepo,
+		RateLimiter:          rateLimiter,
+		Router:               providerRouter,
+		Cache:                responseCache,
+		CacheTTL:             responseCacheTTL,
+		CostTracker:          handlerTracker,
+		TokenEstimator:       cost.NewModelEstimator(),
+		DisableUsageEstimate: !cfg.EstimateMissingUsage,
+		EstimateTolerance:    cfg.TokenEstimateErrorThreshold,
+		BudgetMonitor:        budgetMonitor,
+		HealthCheckers:       healthCheckers,
+		ProviderLimiter:      providerLimiter,
+		ProviderCaps:         providerCaps,
+		PeriodCost:           periodCost,
+		Archiver:             archiver,
+		ArchiveSampler:       archiveSampler,
+		MaxStreamDuration:    cfg.MaxStreamDuration,
+		SSERetry:             cfg.SSERetry,
+		ErrorFormat:          api.ErrorFormat(cfg.ErrorFormat),
+		DefaultSystemPrompts: cfg.DefaultSystemPrompts,
+		DefaultMaxTokens:     cfg.DefaultMaxTokens,
+		DefaultModel:         cfg.DefaultModel,
+		ForwardHeaders:       cfg.ForwardHeaders,
+		RequestIDHeaders:     cfg.RequestIDHeaders,
+		RequestIDEchoHeader:  cfg.RequestIDEchoHeader,
+		CORSAllowedOrigins:   cfg.CORSAllowedOrigins,
+		CORSExposeHeaders:    cfg.CORSExposeHeaders,
+		MaxFallbackAttempts:  cfg.MaxFallbackAttempts,
+		PrefixModelIDs:       cfg.PrefixModelIDs,
+		OptionalProviders:    cfg.OptionalProviders,
+		ModelsCallTimeout:    cfg.ModelsProviderTimeout,
+		ModelsTimeout:        cfg.ModelsTimeout,
+		MaxBodyBytes:         cfg.MaxRequestBodyBytes,
+		DedupWindow:          cfg.RequestDedupWindow,
+		SlowThreshold:        cfg.SlowRequestThreshold,
+		RequestTimeBudget:    cfg.RequestTimeBudget,
+		MinAttemptTime:       cfg.FallbackMinRemaining,
+		MetricsLabels:        cfg.MetricsLabels,
+		FailOnUsageError:     cfg.FailOnUsageRecordError,
+		ModerationProvider:   cfg.ModerationProvider,
+		ModerationModel:      cfg.ModerationModel,
+		KillSwitch:           killSwitch,
+		BudgetExceededFields: cfg.BudgetExceededFields,
+		BudgetUpgradeURL:     cfg.BudgetUpgradeURL,
+		DebugTenants:         cfg.DebugProviderTenants,
+		DebugModels:          cfg.DebugProviderModels,
+		RetryableStatuses:    cfg.ProviderRetryableStatuses,
 	})
 
-	adminHandler := api.NewAdminHandler(tenantRepo)
-
-	mux := http.NewServeMux()
-	mux.Handle("/", handler)
+	// Preloaded requests get the handler's defaults and tenant transforms
+	// before keying, as live requests do.
+	if cfg.CachePreloadFile != "" {
+		n, preloadErr := cache.PreloadFile(ctx, responseCache, cfg.CachePreloadFile, responseCacheTTL,
+			cache.WithRequestPreparer(handler.PrepareCacheRequest),
+		)
+		if preloadErr != nil {
+			return fmt.Errorf("preload response cache: %w", preloadErr)
+		}
+		slog.Info("response cache preloaded", "path", cfg.CachePreloadFile, "entries", n)
+	}
 
+	adminOpts := []api.AdminOption{api.WithAdminCostTracker(costTracker), api.WithAdminRouter(providerRouter), api.WithAdminConfig(cfg), api.WithAdminKillSwitch(killSwitch), api.WithAdminCache(responseCache)}
+	var adminUserRepo auth.AdminUserRepository
 	if cfg.AdminAuthEnabled {
-		var adminUserRepo auth.AdminUserRepository
 		if db != nil {
 			adminUserRepo = auth.NewPostgresAdminUserRepository(db)
 		} else {
 			adminUserRepo = auth.NewInMemoryAdminUserRepository()
 		}
+		adminOpts = append(adminOpts, api.WithAdminUsers(adminUserRepo))
+	}
+	adminHandler := api.NewAdminHandler(tenantRepo, adminOpts...)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+
+	if cfg.AdminAuthEnabled {
 		authenticator := auth.NewAuthenticator(adminUserRepo)
 		rbacMiddleware := auth.NewRBACMiddleware(authenticator)
 		mux.Handle("/admin/", rbacMiddleware.RequireAuth(adminHandler))
@@ -233,39 +466,33 @@ func run() error {
 		slog.Info("admin API authentication disabled")
 	}
 
-	// Connection tracking for graceful shutdown
-	var activeConns sync.WaitGroup
-	var shuttingDown atomic.Bool
-
-	// Wrap handler to track active connections
-	trackedHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if shuttingDown.Load() {
-			// During shutdown, reject new connections with 503
-			w.Header().Set("Connection", "close")
-			http.Error(w, "Service shutting down", http.StatusServiceUnavailable)
-			return
-		}
-		activeConns.Add(1)
-		defer activeConns.Done()
-		mux.ServeHTTP(w, r)
-	})
+	// Track in-flight requests and streams for graceful shutdown
+	drainer := api.NewDrainer()
 
 	srv := &http.Server{
 		Addr:         cfg.Addr,
-		Handler:      trackedHandler,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 120 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-
-	// Start server
-	go func() {
-		slog.Info("server listening", "addr", cfg.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("server error", "error", err)
-			os.Exit(1)
-		}
-	}()
+		Handler:      drainer.Wrap(mux),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	listeners, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Start server; Shutdown closes every listener, which also removes the
+	// Unix socket file
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			slog.Info("server listening", "network", ln.Addr().Network(), "addr", ln.Addr().String())
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				slog.Error("server error", "error", err)
+				os.Exit(1)
+			}
+		}(ln)
+	}
 
 	// Wait for shutdown signal
 	quit := make(chan os.Signal, 1)
@@ -275,26 +502,21 @@ func run() error {
 	slog.Info("initiating graceful shutdown...",
 		"shutdown_timeout", cfg.ShutdownTimeout,
 		"drain_timeout", cfg.DrainTimeout,
+		"stream_drain_timeout", cfg.StreamDrainTimeout,
 	)
 
-	// Mark as shutting down - new requests will be rejected
-	shuttingDown.Store(true)
-
 	// Stop accepting new keep-alive connections
 	srv.SetKeepAlivesEnabled(false)
 
-	// Wait for active connections to drain
-	drainDone := make(chan struct{})
-	go func() {
-		activeConns.Wait()
-		close(drainDone)
-	}()
-
-	select {
-	case <-drainDone:
-		slog.Info("all active connections drained")
-	case <-time.After(cfg.DrainTimeout):
-		slog.Warn("drain timeout exceeded, proceeding with shutdown")
+	// Reject new requests and wait for in-flight ones; streams get their own
+	// grace window and are cancelled when it ends
+	if left := drainer.Drain(cfg.DrainTimeout, cfg.StreamDrainTimeout); left == (api.DrainResult{}) {
+		slog.Info("all active requests drained")
+	} else {
+		slog.Warn("drain timeout exceeded, proceeding with shutdown",
+			"requests", left.Requests,
+			"streams_cancelled", left.Streams,
+		)
 	}
 
 	// Shutdown the server
@@ -305,10 +527,38 @@ func run() error {
 		slog.Error("server forced to shutdown", "error", err)
 	}
 
+	if err := providerRouter.SaveCircuitStates(); err != nil {
+		slog.Error("failed to save circuit breaker state", "error", err)
+	}
+
 	slog.Info("server stopped gracefully")
 	return nil
 }
 
+// listen opens the TCP listener on cfg.Addr, unless it is "none", and the
+// Unix socket listener when LISTEN_SOCKET is set.
+func listen(cfg *config.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	if cfg.Addr != "none" {
+		ln, err := net.Listen("tcp", cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("listen on %s: %w", cfg.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	if cfg.ListenSocket != "" {
+		ln, err := httputil.ListenUnix(cfg.ListenSocket, cfg.ListenSocketMode)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
 func setupLogger(level, podName, namespace string) {
 	var logLevel slog.Level
 	switch level {
@@ -333,3 +583,97 @@ func setupLogger(level, podName, namespace string) {
 	)
 	slog.SetDefault(logger)
 }
+
+// awsHealthCheckers returns readiness checks for the SQS queues and SNS topic
+// when they are configured. A failure to load AWS config is logged rather than
+// fatal, since neither feature is on the request path.
+func awsHealthCheckers(ctx context.Context, cfg *config.Config) []api.HealthChecker {
+	if cfg.SQSRequestQueueURL == "" && cfg.SQSResponseQueueURL == "" && cfg.SNSTopicArn == "" {
+		return nil
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(cfg.AWSRegion))
+	if err != nil {
+		slog.Warn("failed to load aws config, skipping sqs/sns health checks", "error", err)
+		return nil
+	}
+
+	var checkers []api.HealthChecker
+	if cfg.SQSRequestQueueURL != "" || cfg.SQSResponseQueueURL != "" {
+		checkers = append(checkers, queue.NewSQSHealthChecker(awsCfg, cfg.SQSRequestQueueURL, cfg.SQSResponseQueueURL))
+		slog.Info("added sqs health checker")
+	}
+	if cfg.SNSTopicArn != "" {
+		checkers = append(checkers, notifications.NewSNSHealthChecker(awsCfg, cfg.SNSTopicArn))
+		slog.Info("added sns health checker")
+	}
+	return checkers
+}
+
+// newWeightedStrategy builds the weighted routing strategy. Factors missing
+// from ROUTING_WEIGHTS keep their default weight of 1.
+func newWeightedStrategy(cfg *config.Config) *router.WeightedStrategy {
+	weight := func(name string) float64 {
+		if w, ok := cfg.RoutingWeights[name]; ok {
+			return w
+		}
+		return 1
+	}
+	return router.NewWeightedStrategy(
+		router.WithWeights(weight("cost"), weight("latency"), weight("health")),
+		router.WithProviderCosts(cfg.ProviderCosts),
+	)
+}
+
+// newDeadLetterStore keeps usage records Postgres rejects in a file when one
+// is configured, so they survive restarts, and in memory otherwise.
+func newDeadLetterStore(cfg *config.Config) (cost.DeadLetterStore, error) {
+	if cfg.UsageDeadLetterFile == "" {
+		return cost.NewInMemoryDeadLetterStore(), nil
+	}
+	store, err := cost.NewFileDeadLetterStore(cfg.UsageDeadLetterFile)
+	if err != nil {
+		return nil, fmt.Errorf("create usage dead letter store: %w", err)
+	}
+	slog.Info("usage dead letters persisted to file", "path", cfg.UsageDeadLetterFile)
+	return store, nil
+}
+
+// newArchiver appends sampled requests to ARCHIVE_FILE. It returns a nil
+// archiver when no file is configured.
+func newArchiver(cfg *config.Config) (archive.Archiver, *archive.Sampler, error) {
+	if cfg.ArchiveFile == "" {
+		return nil, nil, nil
+	}
+	archiver, err := archive.NewFileArchiver(cfg.ArchiveFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request archive: %w", err)
+	}
+
+	var opts []archive.Option
+	for tenantID, rate := range cfg.ArchiveTenantSampleRates {
+		opts = append(opts, archive.WithTenantRate(tenantID, rate))
+	}
+	slog.Info("request archiving enabled", "path", cfg.ArchiveFile, "sample_rate", cfg.ArchiveSampleRate)
+	return archiver, archive.NewSampler(cfg.ArchiveSampleRate, opts...), nil
+}
+
+// newTenantCache wraps the tenant repository in a TTL cache. With Redis
+// available, invalidations are shared so a key revoked on one instance stops
+// working everywhere; otherwise other instances catch up when entries expire.
+func newTenantCache(ctx context.Context, repo repository.TenantRepository, cfg *config.Config) repository.TenantRepository {
+	var opts []repository.CacheOption
+	if cfg.RedisURL != "" {
+		bus, err := repository.NewRedisInvalidationBus(cfg.RedisURL)
+		if err != nil {
+			slog.Warn("failed to connect to redis for tenant invalidation, relying on TTL", "error", err)
+		} else {
+			opts = append(opts, repository.WithInvalidationBus(bus))
+		}
+	}
+
+	cached := repository.NewCachedTenantRepository(repo, cfg.TenantCacheTTL, opts...)
+	cached.Start(ctx)
+	slog.Info("tenant cache enabled", "ttl", cfg.TenantCacheTTL, "shared_invalidation", len(opts) > 0)
+	return cached
+}
@@ -1,40 +1,125 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
 	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/auth"
+	"github.com/felipepmaragno/ai-gateway/internal/budget"
+	"github.com/felipepmaragno/ai-gateway/internal/cache"
+	"github.com/felipepmaragno/ai-gateway/internal/config"
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
 	"github.com/felipepmaragno/ai-gateway/internal/crypto"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
 	"github.com/felipepmaragno/ai-gateway/internal/repository"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
 	"github.com/google/uuid"
 )
 
 type AdminHandler struct {
-	tenantRepo repository.TenantRepository
-	mux        *http.ServeMux
+	tenantRepo  repository.TenantRepository
+	costTracker cost.Tracker
+	router      *router.Router
+	config      *config.Config
+	killSwitch  budget.KillSwitch
+	cache       cache.Cache
+	users       auth.AdminUserRepository
+	mux         *http.ServeMux
 }
 
-func NewAdminHandler(tenantRepo repository.TenantRepository) *AdminHandler {
+// AdminOption configures an AdminHandler.
+type AdminOption func(*AdminHandler)
+
+// WithAdminCostTracker enables the provider stats endpoint.
+func WithAdminCostTracker(tracker cost.Tracker) AdminOption {
+	return func(h *AdminHandler) {
+		h.costTracker = tracker
+	}
+}
+
+// WithAdminConfig enables GET /admin/config, which reports cfg with secrets
+// redacted.
+func WithAdminConfig(cfg *config.Config) AdminOption {
+	return func(h *AdminHandler) {
+		h.config = cfg
+	}
+}
+
+// WithAdminRouter validates tenant provider and model references against
+// the providers the gateway serves.
+func WithAdminRouter(r *router.Router) AdminOption {
+	return func(h *AdminHandler) {
+		h.router = r
+	}
+}
+
+// WithAdminKillSwitch enables GET and POST /admin/killswitch, which read
+// and flip the global kill switch.
+func WithAdminKillSwitch(ks budget.KillSwitch) AdminOption {
+	return func(h *AdminHandler) {
+		h.killSwitch = ks
+	}
+}
+
+// WithAdminCache enables POST /admin/cache/flush, which deletes cached
+// responses.
+func WithAdminCache(c cache.Cache) AdminOption {
+	return func(h *AdminHandler) {
+		h.cache = c
+	}
+}
+
+// WithAdminUsers enables POST /admin/users, which creates admin users.
+func WithAdminUsers(repo auth.AdminUserRepository) AdminOption {
+	return func(h *AdminHandler) {
+		h.users = repo
+	}
+}
+
+func NewAdminHandler(tenantRepo repository.TenantRepository, opts ...AdminOption) *AdminHandler {
 	h := &AdminHandler{
 		tenantRepo: tenantRepo,
 		mux:        http.NewServeMux(),
 	}
 
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	h.mux.HandleFunc("GET /admin/tenants", h.listTenants)
 	h.mux.HandleFunc("POST /admin/tenants", h.createTenant)
+	h.mux.HandleFunc("POST /admin/tenants/validate", requirePermission(auth.PermissionTenantRead, h.validateTenantConfig))
 	h.mux.HandleFunc("GET /admin/tenants/{id}", h.getTenant)
 	h.mux.HandleFunc("PUT /admin/tenants/{id}", h.updateTenant)
 	h.mux.HandleFunc("DELETE /admin/tenants/{id}", h.deleteTenant)
 	h.mux.HandleFunc("POST /admin/tenants/{id}/rotate-key", h.rotateAPIKey)
+	h.mux.HandleFunc("POST /admin/tenants/{id}/rotate-webhook-secret", h.rotateWebhookSecret)
+	h.mux.HandleFunc("GET /admin/providers/stats", requirePermission(auth.PermissionUsageRead, h.providerStats))
+	h.mux.HandleFunc("POST /admin/reload", requirePermission(auth.PermissionTenantWrite, h.reloadTenants))
+	h.mux.HandleFunc("GET /admin/config", requirePermission(auth.PermissionAdminManage, h.effectiveConfig))
+	h.mux.HandleFunc("GET /admin/usage/dead-letters", requirePermission(auth.PermissionUsageRead, h.listDeadLetters))
+	h.mux.HandleFunc("POST /admin/usage/dead-letters/replay", requirePermission(auth.PermissionAdminManage, h.replayDeadLetters))
+	h.mux.HandleFunc("GET /admin/killswitch", requirePermission(auth.PermissionTenantRead, h.getKillSwitch))
+	h.mux.HandleFunc("POST /admin/killswitch", requirePermission(auth.PermissionAdminManage, h.setKillSwitch))
+	h.mux.HandleFunc("POST /admin/cache/flush", requirePermission(auth.PermissionAdminManage, h.flushCache))
+	h.mux.HandleFunc("POST /admin/users", requirePermission(auth.PermissionAdminManage, h.createUser))
 
 	return h
 }
 
 func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.mux.ServeHTTP(w, r)
+	serveJSON(h.mux, w, r, writeAdminError)
 }
 
 func (h *AdminHandler) listTenants(w http.ResponseWriter, r *http.Request) {
@@ -62,28 +147,31 @@ func (h *AdminHandler) createTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		writeAdminError(w, http.StatusBadRequest, "name is required")
+	tenant := req.tenant()
+	if errs := h.validateTenant(ctx, tenant, nil); len(errs) > 0 {
+		writeValidationErrors(w, errs)
 		return
 	}
 
 	apiKey := generateAPIKey()
-	tenant := &domain.Tenant{
-		ID:           uuid.New().String(),
-		Name:         req.Name,
-		APIKey:       apiKey,
-		APIKeyHash:   crypto.HashAPIKey(apiKey),
-		RateLimitRPM: req.RateLimitRPM,
-		BudgetUSD:    req.BudgetUSD,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-	}
-
-	if tenant.RateLimitRPM == 0 {
-		tenant.RateLimitRPM = 60
+	tenant.ID = uuid.New().String()
+	tenant.APIKey = apiKey
+	tenant.APIKeyHash = crypto.HashAPIKey(apiKey)
+	if tenant.WebhookURL != "" {
+		tenant.WebhookSecret = generateWebhookSecret()
 	}
+	tenant.CreatedAt = time.Now()
+	tenant.UpdatedAt = time.Now()
 
 	if err := h.tenantRepo.Create(ctx, tenant); err != nil {
+		if errors.Is(err, domain.ErrTenantExists) {
+			writeAdminError(w, http.StatusConflict, "tenant name or API key already in use")
+			return
+		}
+		if errors.Is(err, domain.ErrTenantLimitReached) {
+			writeAdminError(w, http.StatusInsufficientStorage, err.Error())
+			return
+		}
 		slog.Error("failed to create tenant", "error", err)
 		writeAdminError(w, http.StatusInternalServerError, "failed to create tenant")
 		return
@@ -93,7 +181,7 @@ func (h *AdminHandler) createTenant(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(tenant)
+	json.NewEncoder(w).Encode(tenantResponse{Tenant: tenant, WebhookSecret: tenant.WebhookSecret})
 }
 
 func (h *AdminHandler) getTenant(w http.ResponseWriter, r *http.Request) {
@@ -114,11 +202,15 @@ func (h *AdminHandler) updateTenant(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := r.PathValue("id")
 
-	tenant, err := h.tenantRepo.GetByID(ctx, id)
+	stored, err := h.tenantRepo.GetByID(ctx, id)
 	if err != nil {
 		writeAdminError(w, http.StatusNotFound, "tenant not found")
 		return
 	}
+	// Changes go to a copy: the in-memory repository hands out the tenant it
+	// stores, which must stay as it was if the update is rejected.
+	updated := *stored
+	tenant := &updated
 
 	var req UpdateTenantRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -126,21 +218,92 @@ func (h *AdminHandler) updateTenant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	changed := make(map[string]bool)
 	if req.Name != "" {
+		changed["name"] = true
 		tenant.Name = req.Name
 	}
 	if req.RateLimitRPM != nil {
+		changed["rate_limit_rpm"] = true
 		tenant.RateLimitRPM = *req.RateLimitRPM
 	}
 	if req.BudgetUSD != nil {
+		changed["budget_usd"] = true
 		tenant.BudgetUSD = *req.BudgetUSD
 	}
+	if req.BudgetPeriod != "" {
+		changed["budget_period"] = true
+		tenant.BudgetPeriod = req.BudgetPeriod
+	}
 	if req.Enabled != nil {
+		changed["enabled"] = true
 		tenant.Enabled = *req.Enabled
 	}
+	if req.ProviderKeys != nil {
+		changed["provider_keys"] = true
+		tenant.ProviderKeys = req.ProviderKeys
+	}
+	if req.TransformRules != nil {
+		changed["transform_rules"] = true
+		tenant.TransformRules = req.TransformRules
+	}
+	if req.PricingOverrides != nil {
+		changed["pricing_overrides"] = true
+		tenant.PricingOverrides = req.PricingOverrides
+	}
+	if req.SamplingDefaults != nil {
+		changed["sampling_defaults"] = true
+		tenant.SamplingDefaults = req.SamplingDefaults
+	}
+	var newSecret string
+	if req.WebhookURL != nil {
+		changed["webhook_url"] = true
+		tenant.WebhookURL = *req.WebhookURL
+		if tenant.WebhookURL != "" && tenant.WebhookSecret == "" {
+			newSecret = generateWebhookSecret()
+			tenant.WebhookSecret = newSecret
+		}
+	}
+	if req.DefaultModel != nil {
+		changed["default_model"] = true
+		tenant.DefaultModel = *req.DefaultModel
+	}
+	if req.MaxStreamSeconds != nil {
+		changed["max_stream_seconds"] = true
+		tenant.MaxStreamSeconds = *req.MaxStreamSeconds
+	}
+	if req.AllowedModels != nil {
+		changed["allowed_models"] = true
+		tenant.AllowedModels = req.AllowedModels
+	}
+	if req.AllowedProviders != nil {
+		changed["allowed_providers"] = true
+		tenant.AllowedProviders = req.AllowedProviders
+	}
+	if req.Scopes != nil {
+		changed["scopes"] = true
+		tenant.Scopes = req.Scopes
+	}
+	if req.DefaultProvider != nil {
+		changed["default_provider"] = true
+		tenant.DefaultProvider = *req.DefaultProvider
+	}
+	if req.FallbackProviders != nil {
+		changed["fallback_providers"] = true
+		tenant.FallbackProviders = req.FallbackProviders
+	}
+
+	if errs := h.validateTenant(ctx, tenant, changed); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return
+	}
 	tenant.UpdatedAt = time.Now()
 
 	if err := h.tenantRepo.Update(ctx, tenant); err != nil {
+		if errors.Is(err, domain.ErrTenantExists) {
+			writeAdminError(w, http.StatusConflict, fmt.Sprintf("tenant named %q already exists", tenant.Name))
+			return
+		}
 		slog.Error("failed to update tenant", "error", err)
 		writeAdminError(w, http.StatusInternalServerError, "failed to update tenant")
 		return
@@ -149,7 +312,7 @@ func (h *AdminHandler) updateTenant(w http.ResponseWriter, r *http.Request) {
 	slog.Info("tenant updated", "tenant_id", tenant.ID)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tenant)
+	json.NewEncoder(w).Encode(tenantResponse{Tenant: tenant, WebhookSecret: newSecret})
 }
 
 func (h *AdminHandler) deleteTenant(w http.ResponseWriter, r *http.Request) {
@@ -193,23 +356,531 @@ func (h *AdminHandler) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// rotateWebhookSecret replaces the secret that signs the tenant's budget
+// webhooks and returns the new one, which is not shown again.
+func (h *AdminHandler) rotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := r.PathValue("id")
+
+	tenant, err := h.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		writeAdminError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	tenant.WebhookSecret = generateWebhookSecret()
+	tenant.UpdatedAt = time.Now()
+
+	if err := h.tenantRepo.Update(ctx, tenant); err != nil {
+		slog.Error("failed to rotate webhook secret", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to rotate webhook secret")
+		return
+	}
+
+	slog.Info("webhook secret rotated", "tenant_id", tenant.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"webhook_secret": tenant.WebhookSecret,
+	})
+}
+
+// providerStatsRange is the default lookback for /admin/providers/stats.
+const providerStatsRange = 24 * time.Hour
+
+// ProviderStats is the per-provider entry returned by /admin/providers/stats.
+// The totals cover the requested range. ErrorsSinceStart does not: it counts
+// provider failures observed by this instance since it started, because
+// errors are not stored with timestamps.
+type ProviderStats struct {
+	cost.ProviderTotals
+	ErrorsSinceStart int64 `json:"errors_since_start"`
+}
+
+func (h *AdminHandler) providerStats(w http.ResponseWriter, r *http.Request) {
+	if h.costTracker == nil {
+		writeAdminError(w, http.StatusNotImplemented, "usage tracking not enabled")
+		return
+	}
+
+	until := time.Now()
+	since := until.Add(-providerStatsRange)
+	for name, dst := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, name+" must be an RFC3339 timestamp")
+			return
+		}
+		*dst = t
+	}
+	if !since.Before(until) {
+		writeAdminError(w, http.StatusBadRequest, "since must be before until")
+		return
+	}
+
+	totals, err := h.costTracker.GetProviderTotals(r.Context(), since, until)
+	if err != nil {
+		slog.Error("failed to get provider totals", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to get provider stats")
+		return
+	}
+
+	errorCounts := metrics.ProviderErrorCounts()
+	stats := make([]ProviderStats, 0, len(totals))
+	for _, t := range totals {
+		stats = append(stats, ProviderStats{ProviderTotals: t, ErrorsSinceStart: errorCounts[t.Provider]})
+		delete(errorCounts, t.Provider)
+	}
+	// Providers that have failed but have no usage records in the range.
+	for provider, n := range errorCounts {
+		stats = append(stats, ProviderStats{ProviderTotals: cost.ProviderTotals{Provider: provider}, ErrorsSinceStart: n})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"since":        since.Format(time.RFC3339),
+		"until":        until.Format(time.RFC3339),
+		"errors_since": metrics.ProviderErrorCountsSince().Format(time.RFC3339),
+		"providers":    stats,
+	})
+}
+
+// requirePermission rejects requests from an authenticated admin user that
+// lacks permission. When admin auth is disabled there is no user in the
+// context and the request is allowed, matching the rest of the admin API.
+func requirePermission(permission auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := auth.UserFromContext(r.Context()); ok && !auth.HasPermission(user.Role, permission) {
+			writeAdminError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ProviderInfo describes a registered provider in GET /admin/config.
+type ProviderInfo struct {
+	ID      string `json:"id"`
+	BaseURL string `json:"base_url,omitempty"`
+}
+
+// effectiveConfig reports the configuration the gateway is running with and
+// the providers it registered.
+func (h *AdminHandler) effectiveConfig(w http.ResponseWriter, r *http.Request) {
+	if h.config == nil {
+		writeAdminError(w, http.StatusNotImplemented, "configuration is not available")
+		return
+	}
+
+	providers := []ProviderInfo{}
+	if h.router != nil {
+		ids := h.router.ListProviders()
+		sort.Strings(ids)
+		for _, id := range ids {
+			info := ProviderInfo{ID: id}
+			if p, ok := h.router.GetProvider(id); ok {
+				if b, ok := p.(interface{ BaseURL() string }); ok {
+					info.BaseURL = b.BaseURL()
+				}
+			}
+			providers = append(providers, info)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":    h.config.Redacted(),
+		"providers": providers,
+	})
+}
+
+// deadLetterTracker is implemented by trackers that dead-letter usage records
+// they fail to persist.
+type deadLetterTracker interface {
+	DeadLetters(ctx context.Context) ([]cost.DeadLetter, error)
+	Replay(ctx context.Context) (cost.ReplayResult, error)
+}
+
+func (h *AdminHandler) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := h.costTracker.(deadLetterTracker)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "usage dead letters not enabled")
+		return
+	}
+
+	letters, err := tracker.DeadLetters(r.Context())
+	if err != nil {
+		slog.Error("failed to list usage dead letters", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to list dead letters")
+		return
+	}
+	if letters == nil {
+		letters = []cost.DeadLetter{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dead_letters": letters,
+		"count":        len(letters),
+	})
+}
+
+// replayDeadLetters writes dead-lettered usage records to the tracker again.
+// Records that still fail stay dead-lettered.
+func (h *AdminHandler) replayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	tracker, ok := h.costTracker.(deadLetterTracker)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "usage dead letters not enabled")
+		return
+	}
+
+	result, err := tracker.Replay(r.Context())
+	if err != nil {
+		slog.Error("failed to replay usage dead letters", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to replay dead letters")
+		return
+	}
+
+	slog.Info("usage dead letters replayed", "replayed", result.Replayed, "failed", result.Failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// reloadTenants drops cached tenant data on every instance so out-of-band
+// database changes, such as a revoked key, take effect immediately.
+func (h *AdminHandler) reloadTenants(w http.ResponseWriter, r *http.Request) {
+	reloader, ok := h.tenantRepo.(repository.Reloader)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "tenant repository does not cache tenants")
+		return
+	}
+
+	if err := reloader.Reload(r.Context()); err != nil {
+		slog.Error("failed to reload tenants", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to reload tenants")
+		return
+	}
+
+	slog.Info("tenant cache reloaded")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+}
+
+func (h *AdminHandler) getKillSwitch(w http.ResponseWriter, r *http.Request) {
+	if h.killSwitch == nil {
+		writeAdminError(w, http.StatusNotImplemented, "kill switch not enabled")
+		return
+	}
+
+	on, err := h.killSwitch.On(r.Context())
+	if err != nil {
+		slog.Error("failed to read kill switch", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to read kill switch")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(KillSwitchRequest{Enabled: &on, Scope: killSwitchScope(h.killSwitch)})
+}
+
+func (h *AdminHandler) setKillSwitch(w http.ResponseWriter, r *http.Request) {
+	if h.killSwitch == nil {
+		writeAdminError(w, http.StatusNotImplemented, "kill switch not enabled")
+		return
+	}
+
+	var req KillSwitchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeAdminError(w, http.StatusBadRequest, `request body must be {"enabled": true|false}`)
+		return
+	}
+
+	if err := h.killSwitch.Set(r.Context(), *req.Enabled); err != nil {
+		slog.Error("failed to set kill switch", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to set kill switch")
+		return
+	}
+
+	req.Scope = killSwitchScope(h.killSwitch)
+	slog.Warn("global kill switch changed", "enabled", *req.Enabled, "scope", req.Scope)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(req)
+}
+
+// KillSwitchRequest turns the global kill switch on or off, and is also the
+// shape of its status. Scope, set in responses, is "cluster" when the switch
+// is shared by every instance and "instance" when a change only reached the
+// instance that served it.
+type KillSwitchRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Scope   string `json:"scope,omitempty"`
+}
+
+func killSwitchScope(ks budget.KillSwitch) string {
+	if ks.Shared() {
+		return "cluster"
+	}
+	return "instance"
+}
+
+func (h *AdminHandler) flushCache(w http.ResponseWriter, r *http.Request) {
+	if h.cache == nil {
+		writeAdminError(w, http.StatusNotImplemented, "response cache not enabled")
+		return
+	}
+
+	var req CacheFlushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeAdminError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	// Refused rather than ignored, so a caller meaning to flush one tenant
+	// does not flush everyone.
+	if req.TenantID != "" {
+		writeAdminError(w, http.StatusBadRequest, "cached responses are shared by tenants and cannot be flushed per tenant; scope by model instead")
+		return
+	}
+
+	n, err := h.cache.Delete(r.Context(), req.Model)
+	if err != nil {
+		slog.Error("failed to flush response cache", "error", err, "model", req.Model, "deleted", n)
+		writeAdminError(w, http.StatusInternalServerError, "failed to flush response cache")
+		return
+	}
+
+	slog.Warn("response cache flushed", "model", req.Model, "deleted", n)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CacheFlushResponse{Deleted: n})
+}
+
+// CreateUserRequest is the body of POST /admin/users.
+type CreateUserRequest struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Role     auth.Role `json:"role"`
+}
+
+// UserResponse describes an admin user without its password hash.
+type UserResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Role      auth.Role `json:"role"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *AdminHandler) createUser(w http.ResponseWriter, r *http.Request) {
+	if h.users == nil {
+		writeAdminError(w, http.StatusNotImplemented, "admin users not enabled")
+		return
+	}
+
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	switch {
+	case req.Username == "":
+		writeAdminError(w, http.StatusBadRequest, "username is required")
+		return
+	case req.Password == "":
+		writeAdminError(w, http.StatusBadRequest, "password is required")
+		return
+	case req.Role != auth.RoleAdmin && req.Role != auth.RoleEditor && req.Role != auth.RoleViewer:
+		writeAdminError(w, http.StatusBadRequest, "role must be admin, editor or viewer")
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		slog.Error("failed to hash admin password", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to create user")
+		return
+	}
+
+	now := time.Now()
+	user := &auth.AdminUser{
+		ID:           uuid.New().String(),
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         req.Role,
+		Enabled:      true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := h.users.Create(r.Context(), user); err != nil {
+		if errors.Is(err, auth.ErrUserExists) {
+			writeAdminError(w, http.StatusConflict, fmt.Sprintf("user %q already exists", req.Username))
+			return
+		}
+		slog.Error("failed to create admin user", "error", err)
+		writeAdminError(w, http.StatusInternalServerError, "failed to create user")
+		return
+	}
+
+	slog.Info("admin user created", "user_id", user.ID, "role", user.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Enabled:   user.Enabled,
+		CreatedAt: user.CreatedAt,
+	})
+}
+
+// CacheFlushRequest scopes a cache flush. Model is a pattern in which *
+// matches any run of characters and ? any single one; empty flushes every
+// cached response.
+type CacheFlushRequest struct {
+	Model    string `json:"model,omitempty"`
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// CacheFlushResponse reports how many cached responses a flush deleted.
+type CacheFlushResponse struct {
+	Deleted int `json:"deleted"`
+}
+
 type CreateTenantRequest struct {
-	Name         string  `json:"name"`
-	RateLimitRPM int     `json:"rate_limit_rpm"`
-	BudgetUSD    float64 `json:"budget_usd"`
+	Name              string                       `json:"name"`
+	RateLimitRPM      int                          `json:"rate_limit_rpm"`
+	BudgetUSD         float64                      `json:"budget_usd"`
+	BudgetPeriod      domain.BudgetPeriod          `json:"budget_period,omitempty"`
+	AllowedModels     []string                     `json:"allowed_models,omitempty"`
+	AllowedProviders  []string                     `json:"allowed_providers,omitempty"`
+	Scopes            []string                     `json:"scopes,omitempty"`
+	DefaultProvider   string                       `json:"default_provider,omitempty"`
+	FallbackProviders []string                     `json:"fallback_providers,omitempty"`
+	ProviderKeys      map[string]string            `json:"provider_keys,omitempty"`
+	TransformRules    []domain.TransformRule       `json:"transform_rules,omitempty"`
+	PricingOverrides  map[string]domain.ModelPrice `json:"pricing_overrides,omitempty"`
+	SamplingDefaults  *domain.SamplingDefaults     `json:"sampling_defaults,omitempty"`
+	WebhookURL        string                       `json:"webhook_url,omitempty"`
+	DefaultModel      string                       `json:"default_model,omitempty"`
+	MaxStreamSeconds  int                          `json:"max_stream_seconds,omitempty"`
+}
+
+// tenant builds the tenant described by the request, without identity or
+// credentials.
+func (req CreateTenantRequest) tenant() *domain.Tenant {
+	t := &domain.Tenant{
+		Name:              req.Name,
+		RateLimitRPM:      req.RateLimitRPM,
+		BudgetUSD:         req.BudgetUSD,
+		BudgetPeriod:      req.BudgetPeriod,
+		AllowedModels:     req.AllowedModels,
+		AllowedProviders:  req.AllowedProviders,
+		Scopes:            req.Scopes,
+		DefaultProvider:   req.DefaultProvider,
+		FallbackProviders: req.FallbackProviders,
+		ProviderKeys:      req.ProviderKeys,
+		TransformRules:    req.TransformRules,
+		PricingOverrides:  req.PricingOverrides,
+		SamplingDefaults:  req.SamplingDefaults,
+		WebhookURL:        req.WebhookURL,
+		DefaultModel:      req.DefaultModel,
+		MaxStreamSeconds:  req.MaxStreamSeconds,
+	}
+	if t.RateLimitRPM == 0 {
+		t.RateLimitRPM = 60
+	}
+	return t
 }
 
 type UpdateTenantRequest struct {
-	Name         string   `json:"name,omitempty"`
-	RateLimitRPM *int     `json:"rate_limit_rpm,omitempty"`
-	BudgetUSD    *float64 `json:"budget_usd,omitempty"`
-	Enabled      *bool    `json:"enabled,omitempty"`
+	Name              string                       `json:"name,omitempty"`
+	RateLimitRPM      *int                         `json:"rate_limit_rpm,omitempty"`
+	BudgetUSD         *float64                     `json:"budget_usd,omitempty"`
+	BudgetPeriod      domain.BudgetPeriod          `json:"budget_period,omitempty"`
+	AllowedModels     []string                     `json:"allowed_models,omitempty"`
+	AllowedProviders  []string                     `json:"allowed_providers,omitempty"`
+	Scopes            []string                     `json:"scopes,omitempty"`
+	DefaultProvider   *string                      `json:"default_provider,omitempty"`
+	FallbackProviders []string                     `json:"fallback_providers,omitempty"`
+	Enabled           *bool                        `json:"enabled,omitempty"`
+	ProviderKeys      map[string]string            `json:"provider_keys,omitempty"`
+	TransformRules    []domain.TransformRule       `json:"transform_rules,omitempty"`
+	PricingOverrides  map[string]domain.ModelPrice `json:"pricing_overrides,omitempty"`
+	SamplingDefaults  *domain.SamplingDefaults     `json:"sampling_defaults,omitempty"`
+	WebhookURL        *string                      `json:"webhook_url,omitempty"`
+	DefaultModel      *string                      `json:"default_model,omitempty"`
+	MaxStreamSeconds  *int                         `json:"max_stream_seconds,omitempty"`
+}
+
+// validatePricingOverrides rejects negative prices, which would credit the
+// tenant instead of billing them.
+func validatePricingOverrides(overrides map[string]domain.ModelPrice) error {
+	for model, price := range overrides {
+		if price.InputPer1K < 0 || price.OutputPer1K < 0 {
+			return fmt.Errorf("pricing_overrides[%s]: prices must not be negative", model)
+		}
+	}
+	return nil
+}
+
+// validateSamplingDefaults applies the ranges providers accept, so a bad
+// default fails here rather than on every request.
+func validateSamplingDefaults(d *domain.SamplingDefaults) error {
+	if d == nil {
+		return nil
+	}
+	if d.Temperature != nil && (*d.Temperature < 0 || *d.Temperature > 2) {
+		return errors.New("sampling_defaults.temperature must be between 0 and 2")
+	}
+	if d.TopP != nil && (*d.TopP < 0 || *d.TopP > 1) {
+		return errors.New("sampling_defaults.top_p must be between 0 and 1")
+	}
+	if d.MaxTokens != nil && *d.MaxTokens <= 0 {
+		return errors.New("sampling_defaults.max_tokens must be positive")
+	}
+	return nil
+}
+
+// validateWebhookURL requires an absolute http or https URL, so budget
+// alerts are not sent somewhere they cannot be delivered.
+func validateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook_url must be an absolute http or https URL")
+	}
+	return nil
 }
 
 func generateAPIKey() string {
 	return "gw-" + uuid.New().String()
 }
 
+// generateWebhookSecret returns a random secret for signing a tenant's
+// webhooks.
+func generateWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// tenantResponse is a tenant as the admin API returns it. WebhookSecret is
+// only set in the response that created the secret.
+type tenantResponse struct {
+	*domain.Tenant
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
 func writeAdminError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -0,0 +1,721 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/auth"
+	"github.com/felipepmaragno/ai-gateway/internal/budget"
+	"github.com/felipepmaragno/ai-gateway/internal/cache"
+	"github.com/felipepmaragno/ai-gateway/internal/config"
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/provider/openai"
+	"github.com/felipepmaragno/ai-gateway/internal/repository"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
+)
+
+func TestAdminHandler_UnknownRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"unknown path", "GET", "/admin/nope", http.StatusNotFound},
+		{"wrong method", "PATCH", "/admin/tenants", http.StatusMethodNotAllowed},
+	}
+
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v (%q)", err, rr.Body.String())
+			}
+			if body["error"] == "" {
+				t.Error("expected admin error envelope")
+			}
+		})
+	}
+}
+
+func TestAdminHandler_KnownRouteStillServed(t *testing.T) {
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository())
+
+	req := httptest.NewRequest("GET", "/admin/tenants/default", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d (path values must still resolve)", rr.Code, http.StatusOK)
+	}
+}
+
+func TestAdminHandler_CreateTenant_BudgetPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"weekly", `{"name":"a","budget_usd":10,"budget_period":"weekly"}`, http.StatusCreated},
+		{"default", `{"name":"b","budget_usd":10}`, http.StatusCreated},
+		{"invalid", `{"name":"c","budget_usd":10,"budget_period":"hourly"}`, http.StatusBadRequest},
+	}
+
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+// conflictingTenantRepository fails every Create as a duplicate, the way a
+// unique constraint would.
+type conflictingTenantRepository struct {
+	*repository.InMemoryTenantRepository
+}
+
+func (conflictingTenantRepository) Create(ctx context.Context, tenant *domain.Tenant) error {
+	return fmt.Errorf("insert tenant: %w", domain.ErrTenantExists)
+}
+
+func TestAdminHandler_CreateTenant_Conflict(t *testing.T) {
+	post := func(handler *AdminHandler, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("repository conflict", func(t *testing.T) {
+		handler := NewAdminHandler(conflictingTenantRepository{repository.NewInMemoryTenantRepository()})
+		rr := post(handler, "POST", "/admin/tenants", `{"name":"acme"}`)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d (%s)", rr.Code, http.StatusConflict, rr.Body.String())
+		}
+	})
+
+	t.Run("duplicate names", func(t *testing.T) {
+		handler := NewAdminHandler(repository.NewInMemoryTenantRepository())
+		rr := post(handler, "POST", "/admin/tenants", `{"name":"acme"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d", rr.Code, http.StatusCreated)
+		}
+		var acme domain.Tenant
+		json.NewDecoder(rr.Body).Decode(&acme)
+
+		rr = post(handler, "POST", "/admin/tenants", `{"name":"acme"}`)
+		if rr.Code != http.StatusConflict {
+			t.Fatalf("duplicate create: status = %d, want %d", rr.Code, http.StatusConflict)
+		}
+
+		rr = post(handler, "POST", "/admin/tenants", `{"name":"globex"}`)
+		var globex domain.Tenant
+		json.NewDecoder(rr.Body).Decode(&globex)
+
+		rr = post(handler, "PUT", "/admin/tenants/"+globex.ID, `{"name":"acme"}`)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("rename to taken name: status = %d, want %d", rr.Code, http.StatusConflict)
+		}
+		if !strings.Contains(rr.Body.String(), `tenant named \"acme\" already exists`) {
+			t.Errorf("body = %s", rr.Body.String())
+		}
+		if rr := post(handler, "PUT", "/admin/tenants/"+acme.ID, `{"name":"acme"}`); rr.Code != http.StatusOK {
+			t.Errorf("keep own name: status = %d, want %d (%s)", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	})
+}
+
+func TestAdminHandler_CreateTenant_PricingOverrides(t *testing.T) {
+	repo := repository.NewInMemoryTenantRepository()
+	handler := NewAdminHandler(repo)
+
+	body := `{"name":"acme","pricing_overrides":{"gpt-4":{"input_per_1k":0.015,"output_per_1k":0.03}}}`
+	req := httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d (%s)", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var created domain.Tenant
+	json.NewDecoder(rr.Body).Decode(&created)
+	stored, err := repo.GetByID(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if got := stored.PricingOverrides["gpt-4"]; got.InputPer1K != 0.015 || got.OutputPer1K != 0.03 {
+		t.Errorf("stored override = %+v", got)
+	}
+
+	negative := `{"name":"bad","pricing_overrides":{"gpt-4":{"input_per_1k":-1,"output_per_1k":0.03}}}`
+	req = httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(negative))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("negative price status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAdminHandler_WebhookSecret(t *testing.T) {
+	repo := repository.NewInMemoryTenantRepository()
+	handler := NewAdminHandler(repo)
+
+	create := func(name string) tenantResponse {
+		body := fmt.Sprintf(`{"name":%q,"webhook_url":"https://example.com/hooks/budget"}`, name)
+		req := httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d (%s)", rr.Code, http.StatusCreated, rr.Body.String())
+		}
+		var resp tenantResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return resp
+	}
+
+	a, b := create("acme"), create("globex")
+	if !strings.HasPrefix(a.WebhookSecret, "whsec_") || a.WebhookSecret == b.WebhookSecret {
+		t.Fatalf("webhook secrets = %q, %q; want distinct per tenant", a.WebhookSecret, b.WebhookSecret)
+	}
+	stored, _ := repo.GetByID(context.Background(), a.ID)
+	if stored.WebhookSecret != a.WebhookSecret {
+		t.Errorf("stored secret = %q, want the one returned on create", stored.WebhookSecret)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/tenants/"+a.ID, nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if strings.Contains(rr.Body.String(), a.WebhookSecret) {
+		t.Errorf("GET shows the webhook secret: %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/admin/tenants/"+a.ID+"/rotate-webhook-secret", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var rotated map[string]string
+	json.NewDecoder(rr.Body).Decode(&rotated)
+	if rotated["webhook_secret"] == "" || rotated["webhook_secret"] == a.WebhookSecret {
+		t.Errorf("rotated secret = %q, want a new one", rotated["webhook_secret"])
+	}
+}
+
+func TestAdminHandler_ProviderStats(t *testing.T) {
+	tracker := cost.NewInMemoryTracker()
+	now := time.Now()
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "openai", InputTokens: 10, OutputTokens: 5, CostUSD: 0.2, LatencyMs: 100, Timestamp: now})
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "openai", InputTokens: 30, OutputTokens: 15, CostUSD: 0.4, LatencyMs: 300, Timestamp: now})
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "ollama", CostUSD: 0, LatencyMs: 50, Timestamp: now.Add(-72 * time.Hour)})
+	metrics.RecordProviderError("stats-errors-test", "timeout")
+
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminCostTracker(tracker))
+
+	req := httptest.NewRequest("GET", "/admin/providers/stats", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d (%s)", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var body struct {
+		ErrorsSince string          `json:"errors_since"`
+		Providers   []ProviderStats `json:"providers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.ErrorsSince == "" {
+		t.Error("errors_since is missing")
+	}
+
+	var openai *ProviderStats
+	for i := range body.Providers {
+		switch body.Providers[i].Provider {
+		case "stats-errors-test":
+			if body.Providers[i].ErrorsSinceStart != 1 {
+				t.Errorf("stats-errors-test errors_since_start = %d, want 1", body.Providers[i].ErrorsSinceStart)
+			}
+		case "openai":
+			openai = &body.Providers[i]
+		case "ollama":
+			if body.Providers[i].Requests != 0 {
+				t.Error("ollama usage is outside the default range")
+			}
+		}
+	}
+	if openai == nil {
+		t.Fatalf("expected openai stats, got %+v", body.Providers)
+	}
+	if openai.Requests != 2 || openai.InputTokens != 40 || openai.AvgLatencyMs != 200 {
+		t.Errorf("openai stats = %+v", *openai)
+	}
+}
+
+func TestAdminHandler_ProviderStats_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []AdminOption
+		query      string
+		user       *auth.AdminUser
+		wantStatus int
+	}{
+		{"no tracker", nil, "", nil, http.StatusNotImplemented},
+		{"bad since", []AdminOption{WithAdminCostTracker(cost.NewInMemoryTracker())}, "?since=yesterday", nil, http.StatusBadRequest},
+		{"inverted range", []AdminOption{WithAdminCostTracker(cost.NewInMemoryTracker())}, "?since=2024-02-02T00:00:00Z&until=2024-02-01T00:00:00Z", nil, http.StatusBadRequest},
+		{"viewer allowed", []AdminOption{WithAdminCostTracker(cost.NewInMemoryTracker())}, "", &auth.AdminUser{Role: auth.RoleViewer}, http.StatusOK},
+		{"unknown role forbidden", []AdminOption{WithAdminCostTracker(cost.NewInMemoryTracker())}, "", &auth.AdminUser{Role: "guest"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), tt.opts...)
+
+			req := httptest.NewRequest("GET", "/admin/providers/stats"+tt.query, nil)
+			if tt.user != nil {
+				req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandler_Reload(t *testing.T) {
+	tests := []struct {
+		name       string
+		repo       repository.TenantRepository
+		wantStatus int
+	}{
+		{"cached repository", repository.NewCachedTenantRepository(repository.NewInMemoryTenantRepository(), time.Minute), http.StatusOK},
+		{"uncached repository", repository.NewInMemoryTenantRepository(), http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(tt.repo)
+			req := httptest.NewRequest("POST", "/admin/reload", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ValidateTenant(t *testing.T) {
+	providers := map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai"},
+	}
+	repo := repository.NewInMemoryTenantRepository()
+	handler := NewAdminHandler(repo, WithAdminRouter(router.New(providers, "openai")))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantValid  bool
+		wantFields []string
+	}{
+		{"valid", `{"name":"acme","default_provider":"openai","allowed_models":["gpt-4","openai/gpt-4"]}`, true, nil},
+		{"unknown provider", `{"name":"acme","default_provider":"mistral","fallback_providers":["openai","cohere"]}`, false, []string{"default_provider", "fallback_providers"}},
+		{"unknown model", `{"name":"acme","allowed_models":["gpt-4","gpt-9"]}`, false, []string{"allowed_models"}},
+		{"unknown default model", `{"name":"acme","default_model":"gpt-9"}`, false, []string{"default_model"}},
+		{"provider outside allowed set", `{"name":"acme","allowed_providers":["mistral"],"default_provider":"openai"}`, false, []string{"default_provider", "allowed_providers"}},
+		{"unknown scope", `{"name":"acme","scopes":["usage:read","admin:all"]}`, false, []string{"scopes"}},
+		{"sampling defaults out of range", `{"name":"acme","sampling_defaults":{"temperature":3}}`, false, []string{"sampling_defaults"}},
+		{"relative webhook url", `{"name":"acme","webhook_url":"/hooks/budget"}`, false, []string{"webhook_url"}},
+		{"https webhook url", `{"name":"acme","webhook_url":"https://example.com/hooks/budget"}`, true, nil},
+		{"bad values", `{"budget_usd":-5,"rate_limit_rpm":-1,"max_stream_seconds":-1}`, false, []string{"name", "rate_limit_rpm", "budget_usd", "max_stream_seconds"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/admin/tenants/validate", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", rr.Code, rr.Body.String())
+			}
+			var resp struct {
+				Valid  bool                    `json:"valid"`
+				Errors []TenantValidationError `json:"errors"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Valid != tt.wantValid {
+				t.Errorf("valid = %v, want %v", resp.Valid, tt.wantValid)
+			}
+			var fields []string
+			for _, e := range resp.Errors {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("error fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+
+	tenants, _ := repo.List(context.Background())
+	for _, tenant := range tenants {
+		if tenant.Name == "acme" {
+			t.Error("validation must not persist the tenant")
+		}
+	}
+}
+
+func TestAdminHandler_UpdateTenant_ValidatesOnlyChangedFields(t *testing.T) {
+	modelCalls := 0
+	providers := map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			modelCalls++
+			return []domain.Model{{ID: "gpt-4", Object: "model"}}, nil
+		}},
+	}
+	repo := repository.NewInMemoryTenantRepository()
+	tenant := &domain.Tenant{ID: "t-retired", Name: "retired", APIKeyHash: "retired-hash", AllowedModels: []string{"gpt-3"}, Enabled: true}
+	if err := repo.Create(context.Background(), tenant); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	handler := NewAdminHandler(repo, WithAdminRouter(router.New(providers, "openai")))
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/admin/tenants/t-retired", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(`{"rate_limit_rpm":10}`); rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 despite the stale allowed model (%s)", rr.Code, rr.Body.String())
+	}
+	if modelCalls != 0 {
+		t.Errorf("Models() called %d times for an update that left models alone", modelCalls)
+	}
+
+	if rr := serve(`{"allowed_models":["gpt-9"]}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for an unknown model (%s)", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAdminHandler_CreateTenant_RejectsUnknownProvider(t *testing.T) {
+	providers := map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai"},
+	}
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminRouter(router.New(providers, "openai")))
+
+	req := httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(`{"name":"acme","default_provider":"mistral"}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rr.Body.String(), "unknown provider: mistral") {
+		t.Errorf("body = %s, want unknown provider error", rr.Body.String())
+	}
+}
+
+func TestAdminHandler_UsageDeadLetters(t *testing.T) {
+	store := cost.NewInMemoryDeadLetterStore()
+	store.Add(context.Background(), cost.DeadLetter{
+		ID:     "dl-1",
+		Record: cost.UsageRecord{TenantID: "t1", RequestID: "req-1", CostUSD: 0.25, Timestamp: time.Now()},
+		Error:  "database unavailable",
+	})
+	tracker := cost.NewRetryingTracker(cost.NewInMemoryTracker(), store)
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminCostTracker(tracker))
+
+	serve := func(method, path string, role auth.Role) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(auth.WithUser(req.Context(), &auth.AdminUser{Role: role}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("GET", "/admin/usage/dead-letters", auth.RoleViewer)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d (%s)", rr.Code, rr.Body.String())
+	}
+	var list struct {
+		DeadLetters []cost.DeadLetter `json:"dead_letters"`
+		Count       int               `json:"count"`
+	}
+	json.NewDecoder(rr.Body).Decode(&list)
+	if list.Count != 1 || list.DeadLetters[0].Record.RequestID != "req-1" {
+		t.Errorf("list = %+v", list)
+	}
+
+	if rr := serve("POST", "/admin/usage/dead-letters/replay", auth.RoleEditor); rr.Code != http.StatusForbidden {
+		t.Errorf("editor replay status = %d, want 403", rr.Code)
+	}
+
+	rr = serve("POST", "/admin/usage/dead-letters/replay", auth.RoleAdmin)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("replay status = %d (%s)", rr.Code, rr.Body.String())
+	}
+	var result cost.ReplayResult
+	json.NewDecoder(rr.Body).Decode(&result)
+	if result != (cost.ReplayResult{Replayed: 1}) {
+		t.Errorf("replay = %+v, want 1 replayed", result)
+	}
+	if total, _ := tracker.GetTenantTotalCost(context.Background(), "t1", time.Time{}); total != 0.25 {
+		t.Errorf("replayed cost = %v, want 0.25", total)
+	}
+
+	plain := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminCostTracker(cost.NewInMemoryTracker()))
+	req := httptest.NewRequest("GET", "/admin/usage/dead-letters", nil)
+	rr = httptest.NewRecorder()
+	plain.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("without dead letters status = %d, want 501", rr.Code)
+	}
+}
+
+func TestAdminHandler_EffectiveConfig(t *testing.T) {
+	cfg := &config.Config{
+		Addr:            ":8080",
+		OpenAIAPIKey:    "sk-live-openai-secret",
+		OpenAIBaseURL:   "https://openai.example.test/v1",
+		AnthropicAPIKey: "sk-ant-secret",
+		EncryptionKey:   "0123456789abcdef0123456789abcdef",
+		RedisURL:        "redis://:hunter2@redis:6379/0",
+		DatabaseURL:     "postgres://gateway:pgsecret@db:5432/gateway",
+		DefaultProvider: "openai",
+	}
+	providers := map[string]router.Provider{
+		"openai": openai.New(cfg.OpenAIAPIKey, cfg.OpenAIBaseURL),
+	}
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository(),
+		WithAdminConfig(cfg), WithAdminRouter(router.New(providers, "openai")))
+
+	tests := []struct {
+		name       string
+		user       *auth.AdminUser
+		wantStatus int
+	}{
+		{"admin", &auth.AdminUser{Role: auth.RoleAdmin}, http.StatusOK},
+		{"editor forbidden", &auth.AdminUser{Role: auth.RoleEditor}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/config", nil)
+			req = req.WithContext(auth.WithUser(req.Context(), tt.user))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body := rr.Body.String()
+			for _, secret := range []string{"sk-live-openai-secret", "sk-ant-secret", "0123456789abcdef", "hunter2", "pgsecret"} {
+				if strings.Contains(body, secret) {
+					t.Errorf("response leaks secret %q: %s", secret, body)
+				}
+			}
+
+			var resp struct {
+				Config    config.Config  `json:"config"`
+				Providers []ProviderInfo `json:"providers"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Config.DefaultProvider != "openai" || resp.Config.OpenAIAPIKey != "[REDACTED]" {
+				t.Errorf("unexpected config: %+v", resp.Config)
+			}
+			if len(resp.Providers) != 1 || resp.Providers[0].BaseURL != "https://openai.example.test/v1" {
+				t.Errorf("providers = %+v", resp.Providers)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_KillSwitch(t *testing.T) {
+	ks := budget.NewInMemoryKillSwitch()
+	admin := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminKillSwitch(ks))
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		KillSwitch:  ks,
+	})
+
+	setSwitch := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		admin.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/killswitch", strings.NewReader(body)))
+		return rr
+	}
+	chat := func() int {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := chat(); code != http.StatusOK {
+		t.Fatalf("status with switch off = %d, want 200", code)
+	}
+
+	if rr := setSwitch(`{"enabled": true}`); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"enabled":true`) {
+		t.Fatalf("turning switch on: %d %s", rr.Code, rr.Body.String())
+	} else if !strings.Contains(rr.Body.String(), `"scope":"instance"`) {
+		t.Errorf("in-memory switch should report that only this instance changed: %s", rr.Body.String())
+	}
+	if code := chat(); code != http.StatusServiceUnavailable {
+		t.Errorf("status with switch on = %d, want 503", code)
+	}
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/killswitch", nil))
+	if !strings.Contains(rr.Body.String(), `"enabled":true`) {
+		t.Errorf("GET /admin/killswitch = %s, want enabled", rr.Body.String())
+	}
+
+	if rr := setSwitch(`{}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("missing enabled: status = %d, want 400", rr.Code)
+	}
+
+	if rr := setSwitch(`{"enabled": false}`); rr.Code != http.StatusOK {
+		t.Fatalf("turning switch off: %d %s", rr.Code, rr.Body.String())
+	}
+	if code := chat(); code != http.StatusOK {
+		t.Errorf("status after switch off = %d, want 200", code)
+	}
+}
+
+func TestAdminHandler_FlushCache(t *testing.T) {
+	ctx := context.Background()
+	models := []string{"gpt-4", "gpt-4o", "gpt-4o-mini", "claude-3-haiku"}
+	keyFor := func(model string) string {
+		return cache.GenerateCacheKey(domain.ChatRequest{Model: model, Messages: []domain.Message{{Role: "user", Content: "hi"}}})
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		role        auth.Role
+		wantStatus  int
+		wantDeleted []string
+	}{
+		{"full flush", "", auth.RoleAdmin, http.StatusOK, models},
+		{"scoped by model", `{"model": "gpt-4o*"}`, auth.RoleAdmin, http.StatusOK, []string{"gpt-4o", "gpt-4o-mini"}},
+		{"tenant scope refused", `{"tenant_id": "t1"}`, auth.RoleAdmin, http.StatusBadRequest, nil},
+		{"invalid body", `{`, auth.RoleAdmin, http.StatusBadRequest, nil},
+		{"editor forbidden", "", auth.RoleEditor, http.StatusForbidden, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := cache.NewInMemoryCache()
+			for _, model := range models {
+				c.Set(ctx, keyFor(model), &domain.ChatResponse{ID: model}, time.Minute)
+			}
+			handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminCache(c))
+
+			req := httptest.NewRequest("POST", "/admin/cache/flush", strings.NewReader(tt.body))
+			req = req.WithContext(auth.WithUser(req.Context(), &auth.AdminUser{Role: tt.role}))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if rr.Code == http.StatusOK {
+				var resp CacheFlushResponse
+				json.NewDecoder(rr.Body).Decode(&resp)
+				if resp.Deleted != len(tt.wantDeleted) {
+					t.Errorf("deleted = %d, want %d", resp.Deleted, len(tt.wantDeleted))
+				}
+			}
+
+			deleted := make(map[string]bool)
+			for _, model := range tt.wantDeleted {
+				deleted[model] = true
+			}
+			for _, model := range models {
+				if _, ok := c.Get(ctx, keyFor(model)); ok == deleted[model] {
+					t.Errorf("%s cached = %v, want %v", model, ok, !deleted[model])
+				}
+			}
+		})
+	}
+}
+
+func TestAdminHandler_CreateUser(t *testing.T) {
+	users := auth.NewInMemoryAdminUserRepository()
+	handler := NewAdminHandler(repository.NewInMemoryTenantRepository(), WithAdminUsers(users))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/users", strings.NewReader(body))
+		req = req.WithContext(auth.WithUser(req.Context(), &auth.AdminUser{Role: auth.RoleAdmin}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"username":"ops","password":"s3cret","role":"editor"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d (%s)", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "s3cret") || strings.Contains(rr.Body.String(), "password") {
+		t.Errorf("response leaks the password: %s", rr.Body.String())
+	}
+	created, err := users.GetByUsername(context.Background(), "ops")
+	if err != nil || created.Role != auth.RoleEditor {
+		t.Fatalf("stored user = %+v, %v", created, err)
+	}
+
+	rr = post(`{"username":"ops","password":"other","role":"viewer"}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("duplicate username: status = %d, want %d (%s)", rr.Code, http.StatusConflict, rr.Body.String())
+	}
+
+	if rr := post(`{"username":"dev","password":"pw","role":"root"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("unknown role: status = %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+}
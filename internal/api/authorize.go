@@ -0,0 +1,67 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// authorizeChatWrite runs the gates shared by the endpoints that call a
+// provider on the tenant's behalf: the kill switch, the API key, the
+// chat:write scope, the budget and the rate limit, in that order. It returns
+// the tenant, or nil once it has written the rejection.
+func (h *Handler) authorizeChatWrite(w http.ResponseWriter, r *http.Request, requestID string) *domain.Tenant {
+	ctx := r.Context()
+
+	if h.killSwitch != nil {
+		on, err := h.killSwitch.On(ctx)
+		if err != nil {
+			slog.Warn("failed to read kill switch", "error", err, "request_id", requestID)
+		}
+		if on {
+			metrics.RequestsTotal.WithLabelValues("", "", "", "kill_switch").Inc()
+			writeError(w, http.StatusServiceUnavailable, "all requests are suspended by the gateway kill switch")
+			return nil
+		}
+	}
+
+	apiKey := extractAPIKey(r)
+	if apiKey == "" {
+		metrics.RequestsTotal.WithLabelValues("", "", "", "unauthorized").Inc()
+		writeError(w, http.StatusUnauthorized, "missing API key")
+		return nil
+	}
+
+	tenant, err := h.tenantRepo.GetByAPIKey(ctx, apiKey)
+	if err != nil {
+		slog.Warn("invalid API key", "error", err, "request_id", requestID)
+		metrics.RequestsTotal.WithLabelValues("", "", "", "unauthorized").Inc()
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return nil
+	}
+
+	if !tenant.HasScope(domain.ScopeChatWrite) {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "scope_denied").Inc()
+		writeScopeError(w, domain.ScopeChatWrite)
+		return nil
+	}
+
+	if h.budgetMonitor != nil {
+		exceeded, err := h.budgetMonitor.IsBudgetExceeded(ctx, tenant)
+		if err != nil {
+			slog.Error("budget check error", "error", err, "request_id", requestID)
+		} else if exceeded {
+			slog.Warn("budget exceeded", "tenant_id", tenant.ID, "request_id", requestID)
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "budget_exceeded").Inc()
+			h.writeBudgetExceeded(ctx, w, tenant)
+			return nil
+		}
+	}
+
+	if !h.checkRateLimit(w, r, tenant) {
+		return nil
+	}
+	return tenant
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// Fields a budget-exceeded response can carry besides the message.
+const (
+	BudgetFieldSpend       = "current_spend_usd"
+	BudgetFieldBudget      = "budget_usd"
+	BudgetFieldPeriod      = "budget_period"
+	BudgetFieldPeriodReset = "period_reset"
+	BudgetFieldUpgradeURL  = "upgrade_url"
+)
+
+// DefaultBudgetExceededFields are sent when none are configured. The upgrade
+// link is only ever sent when a URL is configured.
+var DefaultBudgetExceededFields = []string{
+	BudgetFieldSpend,
+	BudgetFieldBudget,
+	BudgetFieldPeriod,
+	BudgetFieldPeriodReset,
+	BudgetFieldUpgradeURL,
+}
+
+// ValidateBudgetExceededFields checks that every field is one the gateway
+// knows how to fill in.
+func ValidateBudgetExceededFields(fields []string) error {
+	for _, f := range fields {
+		switch f {
+		case BudgetFieldSpend, BudgetFieldBudget, BudgetFieldPeriod, BudgetFieldPeriodReset, BudgetFieldUpgradeURL:
+		default:
+			return fmt.Errorf("unknown budget field %q", f)
+		}
+	}
+	return nil
+}
+
+// writeBudgetExceeded answers an over-budget tenant with a 402 that explains
+// the spend, the budget and when it resets, so clients can tell the user
+// more than "budget exceeded". Fields are placed next to the message, as
+// retry_after is for rate limits.
+func (h *Handler) writeBudgetExceeded(ctx context.Context, w http.ResponseWriter, tenant *domain.Tenant) {
+	body := errorBody(errorFormatOf(w), http.StatusPaymentRequired, "budget exceeded")
+	fields := body
+	if inner, ok := body["error"].(map[string]interface{}); ok {
+		fields = inner
+	}
+
+	if len(h.budgetFields) > 0 {
+		spent, window, err := h.budgetMonitor.Spend(ctx, tenant)
+		if err != nil {
+			slog.Error("failed to read budget spend", "tenant_id", tenant.ID, "error", err)
+		}
+		period := tenant.BudgetPeriod
+		if period == "" {
+			period = domain.BudgetPeriodMonthly
+		}
+
+		for _, f := range h.budgetFields {
+			switch f {
+			case BudgetFieldSpend:
+				if err == nil {
+					fields[f] = math.Round(spent*1e6) / 1e6
+				}
+			case BudgetFieldBudget:
+				fields[f] = tenant.BudgetUSD
+			case BudgetFieldPeriod:
+				fields[f] = period
+			case BudgetFieldPeriodReset:
+				fields[f] = window.End.Format(time.RFC3339)
+			case BudgetFieldUpgradeURL:
+				if h.upgradeURL != "" {
+					fields[f] = h.upgradeURL
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPaymentRequired)
+	json.NewEncoder(w).Encode(body)
+}
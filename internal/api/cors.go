@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCORSExposeHeaders are the gateway response headers browsers may read
+// from cross-origin responses when no list is configured.
+var DefaultCORSExposeHeaders = []string{
+	"X-Request-ID",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+	"X-Cost-USD",
+}
+
+// corsPolicy answers cross-origin requests from allowed origins. A nil policy
+// leaves CORS headers off, so browsers block cross-origin calls.
+type corsPolicy struct {
+	anyOrigin     bool
+	origins       map[string]bool
+	exposeHeaders string
+}
+
+// newCORSPolicy returns nil when no origins are allowed. "*" allows any
+// origin.
+func newCORSPolicy(allowedOrigins, exposeHeaders []string) *corsPolicy {
+	if len(allowedOrigins) == 0 {
+		return nil
+	}
+	p := &corsPolicy{
+		origins:       make(map[string]bool, len(allowedOrigins)),
+		exposeHeaders: strings.Join(exposeHeaders, ", "),
+	}
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[origin] = true
+	}
+	return p
+}
+
+// apply sets the CORS response headers for r and reports whether r was a
+// preflight request, which has then been answered.
+func (p *corsPolicy) apply(w http.ResponseWriter, r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	w.Header().Add("Vary", "Origin")
+	if !p.anyOrigin && !p.origins[origin] {
+		return false
+	}
+
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			h.Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		h.Set("Access-Control-Max-Age", "600")
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	if p.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes bounds a request body, after decompression, when no
+// limit is configured.
+const DefaultMaxBodyBytes = 10 << 20
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// decodeBody undoes a gzip or deflate Content-Encoding on r's body and caps
+// what can be read from it at max bytes. The cap applies to the decompressed
+// bytes, so a small compressed body cannot expand without bound; reading
+// past it fails with *http.MaxBytesError.
+func decodeBody(w http.ResponseWriter, r *http.Request, max int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("read gzip body: %w", err)
+		}
+		r.Body = readCloser{Reader: zr, close: r.Body.Close}
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("read deflate body: %w", err)
+		}
+		r.Body = readCloser{Reader: zr, close: r.Body.Close}
+	default:
+		return fmt.Errorf("%w %q", errUnsupportedEncoding, encoding)
+	}
+	if r.Header.Get("Content-Encoding") != "" {
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, max)
+	return nil
+}
+
+// readCloser closes the underlying request body along with a decompressor.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error {
+	return rc.close()
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// requestDedup lets identical non-streaming requests from one tenant share a
+// single provider call, so a client library that silently retries a POST is
+// not billed twice. Duplicates arriving while the first is in flight wait for
+// its response; those arriving within window after a successful one get a
+// copy of it.
+type requestDedup struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+}
+
+// dedupLeaderTimeout bounds a leader's work once it no longer depends on its
+// client's connection.
+const dedupLeaderTimeout = 2 * time.Minute
+
+func newRequestDedup(window time.Duration) *requestDedup {
+	if window <= 0 {
+		return nil
+	}
+	return &requestDedup{window: window, entries: make(map[string]*dedupEntry)}
+}
+
+// dedupKey hashes everything that shapes a chat completion's response: the
+// tenant, the request after defaults and transforms, and the routing and
+// cache headers.
+func dedupKey(tenantID string, req domain.ChatRequest, r *http.Request) string {
+	data, _ := json.Marshal(struct {
+		Tenant  string             `json:"tenant"`
+		Request domain.ChatRequest `json:"request"`
+		Query   string             `json:"query"`
+		Headers []string           `json:"headers"`
+	}{
+		Tenant:  tenantID,
+		Request: req,
+		Query:   r.URL.RawQuery,
+		Headers: []string{
+			r.Header.Get("X-Provider"),
+			r.Header.Get("X-Provider-Chain"),
+			r.Header.Get("X-Skip-Cache"),
+			r.Header.Get("Cache-Control"),
+		},
+	})
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// begin registers the caller as the leader for key and returns nil, or
+// returns the entry of the request already in flight or recently completed.
+func (d *requestDedup) begin(key string) *dedupEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.entries[key]; ok {
+		return e
+	}
+	d.entries[key] = &dedupEntry{done: make(chan struct{})}
+	return nil
+}
+
+// leaderContext detaches the leader from its client: a timeout and retry
+// would otherwise cancel the provider call the retry is waiting on, and the
+// retry would be served the resulting error. The context keeps ctx's values
+// and any earlier deadline.
+func (d *requestDedup) leaderContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline := time.Now().Add(dedupLeaderTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return context.WithDeadline(context.WithoutCancel(ctx), deadline)
+}
+
+// finish publishes the leader's response to waiting duplicates. A successful
+// response is kept for the window; anything else is forgotten at once so a
+// retry after an error reaches the provider again.
+func (d *requestDedup) finish(key string, rec *dedupRecorder) {
+	d.mu.Lock()
+	e := d.entries[key]
+	e.status, e.header, e.body = rec.status, rec.Header().Clone(), rec.body
+	if e.status == 0 {
+		e.status = http.StatusOK
+	}
+	close(e.done)
+	if e.status < 200 || e.status >= 300 {
+		delete(d.entries, key)
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.entries[key] == e {
+			delete(d.entries, key)
+		}
+	})
+}
+
+// replay writes e's response to w, with requestID in place of the leader's.
+// The duplicate keeps its own X-RateLimit-* headers, which describe its
+// tenant's quota now rather than when the leader was admitted.
+func (e *dedupEntry) replay(w http.ResponseWriter, reqIDHeader, requestID string) {
+	for name, values := range e.header {
+		if strings.HasPrefix(name, "X-Ratelimit-") {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(reqIDHeader, requestID)
+	w.Header().Set("X-Deduplicated", "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// dedupRecorder passes a response through while keeping a copy of it.
+type dedupRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (w *dedupRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dedupRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body = append(w.body, p...)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *dedupRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Drainer tracks in-flight requests for graceful shutdown. Streaming
+// responses are counted separately from regular requests, so shutdown can
+// wait briefly for regular requests while giving streams their own, longer
+// grace window instead of every drain running into its timeout.
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	requests int
+	streams  int
+	changed  chan struct{}
+
+	// streamsCtx is cancelled when the stream grace window ends, which
+	// cancels every stream still running.
+	streamsCtx    context.Context
+	cancelStreams context.CancelFunc
+}
+
+// DrainResult reports what was still running when each grace window ended.
+type DrainResult struct {
+	Requests int
+	Streams  int
+}
+
+type inflightKey struct{}
+
+type inflight struct {
+	d         *Drainer
+	cancel    context.CancelFunc
+	streaming bool
+	stop      func() bool
+}
+
+func NewDrainer() *Drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Drainer{
+		changed:       make(chan struct{}),
+		streamsCtx:    ctx,
+		cancelStreams: cancel,
+	}
+}
+
+// Wrap counts requests served by next and rejects new ones with 503 once
+// draining has started.
+func (d *Drainer) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		if d.draining {
+			d.mu.Unlock()
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Service shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		d.requests++
+		d.notifyLocked()
+		d.mu.Unlock()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		in := &inflight{d: d, cancel: cancel}
+		defer d.finish(in)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, inflightKey{}, in)))
+	})
+}
+
+// markStreaming moves the request owning ctx from the regular count to the
+// stream count. It is a no-op outside a Drainer.
+func markStreaming(ctx context.Context) {
+	in, ok := ctx.Value(inflightKey{}).(*inflight)
+	if !ok {
+		return
+	}
+	d := in.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if in.streaming {
+		return
+	}
+	in.streaming = true
+	d.requests--
+	d.streams++
+	in.stop = context.AfterFunc(d.streamsCtx, in.cancel)
+	d.notifyLocked()
+}
+
+func (d *Drainer) finish(in *inflight) {
+	in.cancel()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if in.streaming {
+		in.stop()
+		d.streams--
+	} else {
+		d.requests--
+	}
+	d.notifyLocked()
+}
+
+// notifyLocked wakes goroutines waiting for the counts to change.
+func (d *Drainer) notifyLocked() {
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
+
+// Drain stops accepting requests, then waits up to requestGrace for regular
+// requests and, at the same time, up to streamGrace for streams. Streams
+// still running after streamGrace are cancelled.
+func (d *Drainer) Drain(requestGrace, streamGrace time.Duration) DrainResult {
+	d.mu.Lock()
+	d.draining = true
+	d.mu.Unlock()
+
+	var result DrainResult
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		result.Requests = d.wait(requestGrace, func() int { return d.requests })
+	}()
+	go func() {
+		defer wg.Done()
+		result.Streams = d.wait(streamGrace, func() int { return d.streams })
+		if result.Streams > 0 {
+			d.cancelStreams()
+		}
+	}()
+	wg.Wait()
+	return result
+}
+
+// wait blocks until count reaches zero or grace elapses, returning the
+// count at that point.
+func (d *Drainer) wait(grace time.Duration, count func() int) int {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for {
+		d.mu.Lock()
+		n := count()
+		changed := d.changed
+		d.mu.Unlock()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			return count()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainer_StreamsGetSeparateGrace(t *testing.T) {
+	releaseRegular := make(chan struct{})
+	streamStarted := make(chan struct{})
+	streamCancelled := make(chan struct{})
+
+	drainer := NewDrainer()
+	handler := drainer.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			markStreaming(r.Context())
+			close(streamStarted)
+			<-r.Context().Done()
+			close(streamCancelled)
+		case "/regular":
+			<-releaseRegular
+		}
+	}))
+
+	serve := func(path string) chan int {
+		status := make(chan int, 1)
+		go func() {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+			status <- rr.Code
+		}()
+		return status
+	}
+
+	streamDone := serve("/stream")
+	<-streamStarted
+	regularDone := serve("/regular")
+	time.Sleep(10 * time.Millisecond)
+
+	drained := make(chan DrainResult, 1)
+	start := time.Now()
+	go func() { drained <- drainer.Drain(time.Second, 200*time.Millisecond) }()
+	time.Sleep(20 * time.Millisecond)
+
+	// New requests are rejected while draining.
+	if code := <-serve("/regular"); code != http.StatusServiceUnavailable {
+		t.Errorf("request during drain = %d, want 503", code)
+	}
+
+	// The regular request finishes while the stream is still running.
+	close(releaseRegular)
+	if code := <-regularDone; code != http.StatusOK {
+		t.Errorf("in-flight request = %d, want 200", code)
+	}
+	select {
+	case <-streamCancelled:
+		t.Fatal("stream cancelled before its grace window ended")
+	default:
+	}
+
+	result := <-drained
+	if result != (DrainResult{Streams: 1}) {
+		t.Errorf("Drain() = %+v, want one cancelled stream", result)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 900*time.Millisecond {
+		t.Errorf("Drain() took %v, want about the stream grace window", elapsed)
+	}
+
+	select {
+	case <-streamCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not cancelled after its grace window")
+	}
+	<-streamDone
+}
+
+func TestDrainer_ReturnsOnceIdle(t *testing.T) {
+	drainer := NewDrainer()
+	handler := drainer.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		markStreaming(r.Context())
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	start := time.Now()
+	if result := drainer.Drain(time.Second, 5*time.Second); result != (DrainResult{}) {
+		t.Errorf("Drain() = %+v, want nothing left", result)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Drain() took %v after the stream finished", elapsed)
+	}
+	<-done
+
+	// Outside a Drainer, marking a stream is a no-op.
+	markStreaming(context.Background())
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ErrorFormat selects the JSON shape of error responses.
+type ErrorFormat string
+
+const (
+	// ErrorFormatOpenAI is the OpenAI-style envelope:
+	// {"error": {"message": ..., "type": "error", "code": 429}}.
+	ErrorFormatOpenAI ErrorFormat = "openai"
+
+	// ErrorFormatSimple is a flat {"code": 429, "message": ...} object.
+	ErrorFormatSimple ErrorFormat = "simple"
+)
+
+// Media types a client can list in Accept to pick an error format for its
+// own requests, overriding the configured default.
+const (
+	openAIErrorMediaType = "application/vnd.aigateway.openai-error+json"
+	simpleErrorMediaType = "application/vnd.aigateway.simple-error+json"
+)
+
+// negotiateErrorFormat returns the format the request asks for in Accept,
+// or def if it names none.
+func negotiateErrorFormat(r *http.Request, def ErrorFormat) ErrorFormat {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(part, ";")
+			switch strings.TrimSpace(mediaType) {
+			case openAIErrorMediaType:
+				return ErrorFormatOpenAI
+			case simpleErrorMediaType:
+				return ErrorFormatSimple
+			}
+		}
+	}
+	return def
+}
+
+// errorFormatWriter carries the request's error format down to writeError,
+// which only sees the ResponseWriter. Like countingWriter it forwards Flush
+// and Unwrap so streaming keeps working behind it.
+type errorFormatWriter struct {
+	http.ResponseWriter
+	format ErrorFormat
+}
+
+func (w *errorFormatWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *errorFormatWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// errorFormatOf finds the format attached to w, looking through wrapping
+// writers. Writers without one use ErrorFormatOpenAI.
+func errorFormatOf(w http.ResponseWriter) ErrorFormat {
+	for {
+		if fw, ok := w.(*errorFormatWriter); ok {
+			return fw.format
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return ErrorFormatOpenAI
+		}
+		w = u.Unwrap()
+	}
+}
+
+// errorBody builds an error response body in the given format.
+func errorBody(format ErrorFormat, status int, message string) map[string]interface{} {
+	if format == ErrorFormatSimple {
+		return map[string]interface{}{
+			"code":    status,
+			"message": message,
+		}
+	}
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    "error",
+			"code":    status,
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import "net/http"
+
+// errorWriter writes an error body in a handler's own envelope format.
+type errorWriter func(w http.ResponseWriter, status int, message string)
+
+// serveJSON dispatches r through mux, replacing the mux's plain-text 404 and
+// 405 responses with a JSON error so clients (e.g. OpenAI SDKs) can parse them.
+func serveJSON(mux *http.ServeMux, w http.ResponseWriter, r *http.Request, writeErr errorWriter) {
+	if _, pattern := mux.Handler(r); pattern != "" {
+		mux.ServeHTTP(w, r)
+		return
+	}
+
+	// No route matched. Let the mux decide between 404 and 405 (it knows
+	// which methods are registered for the path), then rewrite the body.
+	probe := &statusProbe{header: make(http.Header)}
+	mux.ServeHTTP(probe, r)
+
+	switch probe.status {
+	case http.StatusMethodNotAllowed:
+		if allow := probe.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		writeErr(w, http.StatusMethodNotAllowed, "method "+r.Method+" not allowed for "+r.URL.Path)
+	default:
+		writeErr(w, http.StatusNotFound, "unknown route "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// statusProbe captures the status and headers written by a fallback handler
+// and discards the body.
+type statusProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *statusProbe) Header() http.Header { return p.header }
+
+func (p *statusProbe) Write(b []byte) (int, error) {
+	if p.status == 0 {
+		p.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (p *statusProbe) WriteHeader(status int) {
+	if p.status == 0 {
+		p.status = status
+	}
+}
@@ -3,26 +3,43 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/archive"
 	"github.com/felipepmaragno/ai-gateway/internal/budget"
 	"github.com/felipepmaragno/ai-gateway/internal/cache"
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/httputil"
 	"github.com/felipepmaragno/ai-gateway/internal/metrics"
 	"github.com/felipepmaragno/ai-gateway/internal/ratelimit"
 	"github.com/felipepmaragno/ai-gateway/internal/repository"
 	"github.com/felipepmaragno/ai-gateway/internal/router"
 	"github.com/felipepmaragno/ai-gateway/internal/telemetry"
-	"github.com/google/uuid"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/felipepmaragno/ai-gateway/internal/transform"
 )
 
+// accountingTimeout bounds post-response work (usage recording, budget checks)
+// that runs on a context detached from the client's request.
+const accountingTimeout = 5 * time.Second
+
+// usageErrorMessage is returned in place of a response whose usage could not
+// be recorded, when the handler is configured to fail such requests.
+const usageErrorMessage = "usage could not be recorded"
+
+// DefaultMinAttemptTime is the least time left in a request's time budget
+// for another provider to be tried.
+const DefaultMinAttemptTime = time.Second
+
 type HandlerConfig struct {
 	TenantRepo     repository.TenantRepository
 	RateLimiter    ratelimit.RateLimiter
@@ -33,6 +50,176 @@ type HandlerConfig struct {
 	CostTracker    cost.Tracker
 	BudgetMonitor  *budget.Monitor
 	HealthCheckers []HealthChecker
+
+	// ProviderLimiter caps outbound requests per provider. A throttled
+	// provider is skipped in favour of the next one in the fallback chain.
+	ProviderLimiter *ratelimit.ProviderLimiter
+
+	// ProviderCaps enforces daily spend ceilings per provider. A provider over
+	// its cap is skipped like a throttled one.
+	ProviderCaps *budget.ProviderCaps
+
+	// PeriodCost publishes each tenant's current-period spend as a gauge
+	// after usage is recorded. Nil disables the gauge.
+	PeriodCost *budget.PeriodCostGauge
+
+	// Archiver receives the completed chat requests ArchiveSampler selects,
+	// with the response as sent to the client. Nil disables archiving.
+	Archiver archive.Archiver
+	// ArchiveSampler picks the requests to archive. Nil archives every
+	// request when an Archiver is set.
+	ArchiveSampler *archive.Sampler
+
+	// MaxStreamDuration caps how long a streaming response may run before the
+	// gateway cuts it off. Zero disables the limit. A tenant's
+	// max_stream_seconds overrides it.
+	MaxStreamDuration time.Duration
+
+	// SSERetry is sent as the SSE retry field at the start of every stream,
+	// telling clients how long to wait before reconnecting. Zero omits it.
+	SSERetry time.Duration
+
+	// ErrorFormat is the shape of error responses for clients that do not
+	// ask for one in Accept. Defaults to ErrorFormatOpenAI.
+	ErrorFormat ErrorFormat
+
+	// DefaultSystemPrompts maps model name to a system prompt injected when
+	// the request carries no system message of its own.
+	DefaultSystemPrompts map[string]string
+
+	// DefaultMaxTokens maps model name to the max_tokens sent when neither
+	// the request nor the tenant's sampling defaults set one, so a model
+	// gets the same limit whichever provider serves it. The "*" entry
+	// applies to models not listed.
+	DefaultMaxTokens map[string]int
+
+	// TokenEstimator fills in usage when a provider reports zero tokens for a
+	// response that has content. Defaults to cost.DefaultEstimator.
+	TokenEstimator cost.TokenEstimator
+
+	// DisableUsageEstimate bills missing usage as reported (zero) instead of
+	// estimating it. Missing usage is still logged and counted.
+	DisableUsageEstimate bool
+
+	// EstimateTolerance is how far, as a fraction of the provider's count,
+	// a token estimate for a stream may stray from the usage the provider
+	// reported before it is recorded in aigateway_token_estimate_error. Zero
+	// records every difference.
+	EstimateTolerance float64
+
+	// CORSAllowedOrigins lists the origins browsers may call the API from;
+	// "*" allows any origin. Empty disables CORS.
+	CORSAllowedOrigins []string
+
+	// CORSExposeHeaders lists the response headers cross-origin callers may
+	// read. Nil uses DefaultCORSExposeHeaders.
+	CORSExposeHeaders []string
+
+	// RequestIDHeaders lists the inbound headers a request ID is read from,
+	// in order; a traceparent header contributes its trace ID. Nil reads
+	// DefaultRequestIDHeader. Requests without one get a generated ID.
+	RequestIDHeaders []string
+
+	// RequestIDEchoHeader names the response header echoing the request
+	// ID. Empty uses DefaultRequestIDHeader.
+	RequestIDEchoHeader string
+
+	// ForwardHeaders lists inbound request headers copied onto the outbound
+	// provider request. Authorization and other credentials are never copied.
+	ForwardHeaders []string
+
+	// MaxFallbackAttempts caps how many providers a single request calls
+	// before giving up. Throttled providers that were skipped do not count.
+	// Zero tries every provider in the fallback chain.
+	MaxFallbackAttempts int
+
+	// RequestTimeBudget bounds the wall-clock time a chat completion may
+	// spend across all provider attempts; a deadline on the request context
+	// applies as well, whichever comes first. No fallback is started once
+	// less than MinAttemptTime (DefaultMinAttemptTime if zero) is left.
+	// Zero disables the budget.
+	RequestTimeBudget time.Duration
+	MinAttemptTime    time.Duration
+
+	// PrefixModelIDs lists models as "provider/model" so identical model IDs
+	// from different providers stay distinguishable. Prefixed model names are
+	// accepted in requests regardless of this setting.
+	PrefixModelIDs bool
+
+	// OptionalProviders lists providers whose failures are reported by
+	// /health but do not mark the gateway degraded. All others are required.
+	OptionalProviders []string
+
+	// MetricsLabels are added to every series served on /metrics, e.g. the
+	// cluster and region, so scrapes from several deployments can be
+	// aggregated without clashing.
+	MetricsLabels map[string]string
+
+	// FailOnUsageError answers with a 500 instead of the response when its
+	// usage cannot be recorded, for deployments that must bill every
+	// response they serve. The provider call has already been paid for, so
+	// this trades availability for billing integrity; streams have already
+	// been sent and end with an error event. By default the failure is only
+	// logged.
+	FailOnUsageError bool
+
+	// RetryableStatuses overrides, per provider, which upstream HTTP statuses
+	// fall through to the next provider. Other statuses are returned to the
+	// client. Providers not listed use DefaultRetryableStatuses.
+	RetryableStatuses map[string][]int
+
+	// BudgetExceededFields lists the details added to a budget-exceeded
+	// response (see DefaultBudgetExceededFields, used when nil). Empty sends
+	// the message alone.
+	BudgetExceededFields []string
+
+	// BudgetUpgradeURL is sent as upgrade_url with budget-exceeded responses,
+	// e.g. a billing page.
+	BudgetUpgradeURL string
+
+	// ModelsCallTimeout bounds each provider's model listing, and
+	// ModelsTimeout the whole of GET /v1/models; providers that miss either
+	// are left out and reported as unavailable. Zero uses
+	// DefaultModelsCallTimeout and DefaultModelsTimeout.
+	ModelsCallTimeout time.Duration
+	ModelsTimeout     time.Duration
+
+	// KillSwitch, while on, rejects every chat completion and moderation
+	// with a 503 before any tenant or budget check.
+	KillSwitch budget.KillSwitch
+
+	// MaxBodyBytes caps a request body after any gzip or deflate
+	// Content-Encoding is undone; larger bodies get a 413. Zero uses
+	// DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// DedupWindow, when positive, lets identical non-streaming requests
+	// from one tenant share one provider call: a duplicate of a request in
+	// flight waits for its response, and one arriving within DedupWindow
+	// of a success gets a copy of it. Zero disables deduplication.
+	DedupWindow time.Duration
+
+	// DefaultModel is used for requests that name no model and whose
+	// tenant has no default_model of its own. It may be provider-prefixed.
+	DefaultModel string
+
+	// SlowThreshold, when positive, logs a warning with the request's
+	// provider, model, tokens and fallbacks for every chat completion
+	// taking longer, and counts it in aigateway_slow_requests_total.
+	SlowThreshold time.Duration
+
+	// ModerationProvider serves POST /v1/moderations and must implement
+	// router.Moderator; empty uses DefaultModerationProvider.
+	// ModerationModel is sent when a request names no model; empty leaves
+	// the choice to the provider.
+	ModerationProvider string
+	ModerationModel    string
+
+	// DebugTenants and DebugModels mark requests from these tenants, or for
+	// these models, for provider debug logging. The provider clients must
+	// come from httputil.DebugClient for anything to be logged.
+	DebugTenants []string
+	DebugModels  []string
 }
 
 type Handler struct {
@@ -45,6 +232,43 @@ type Handler struct {
 	costTracker    cost.Tracker
 	budgetMonitor  *budget.Monitor
 	healthCheckers []HealthChecker
+	providerLimit  *ratelimit.ProviderLimiter
+	providerCaps   *budget.ProviderCaps
+	periodCost     *budget.PeriodCostGauge
+	archiver       archive.Archiver
+	archiveSampler *archive.Sampler
+	systemPrompts  map[string]string
+	maxTokens      map[string]int
+	maxStreamDur   time.Duration
+	sseRetry       time.Duration
+	errorFormat    ErrorFormat
+	estimator      cost.TokenEstimator
+	estimateUsage  bool
+	estimateTol    float64
+	forwardHeaders []string
+	reqIDHeaders   []string
+	reqIDHeader    string
+	cors           *corsPolicy
+	maxAttempts    int
+	timeBudget     time.Duration
+	minAttempt     time.Duration
+	failOnUsage    bool
+	prefixModels   bool
+	optional       map[string]bool
+	debugTenants   map[string]bool
+	debugModels    map[string]bool
+	budgetFields   []string
+	modelsEach     time.Duration
+	modelsTimeout  time.Duration
+	upgradeURL     string
+	maxBody        int64
+	killSwitch     budget.KillSwitch
+	dedup          *requestDedup
+	defaultModel   string
+	slowThreshold  time.Duration
+	modProvider    string
+	modModel       string
+	retry          retryPolicy
 	mux            *http.ServeMux
 }
 
@@ -54,11 +278,68 @@ func NewHandler(cfg HandlerConfig) *Handler {
 		cacheTTL = 5 * time.Minute
 	}
 
+	minAttempt := cfg.MinAttemptTime
+	if minAttempt == 0 {
+		minAttempt = DefaultMinAttemptTime
+	}
+
+	archiveSampler := cfg.ArchiveSampler
+	if archiveSampler == nil {
+		archiveSampler = archive.NewSampler(1)
+	}
+
+	estimator := cfg.TokenEstimator
+	if estimator == nil {
+		estimator = cost.DefaultEstimator
+	}
+
 	costCalc := cfg.CostCalculator
 	if costCalc == nil {
 		costCalc = cost.NewCalculator()
 	}
 
+	reqIDHeaders := cfg.RequestIDHeaders
+	if reqIDHeaders == nil {
+		reqIDHeaders = []string{DefaultRequestIDHeader}
+	}
+	reqIDHeader := cfg.RequestIDEchoHeader
+	if reqIDHeader == "" {
+		reqIDHeader = DefaultRequestIDHeader
+	}
+
+	modelsEach := cfg.ModelsCallTimeout
+	if modelsEach == 0 {
+		modelsEach = DefaultModelsCallTimeout
+	}
+	modelsTimeout := cfg.ModelsTimeout
+	if modelsTimeout == 0 {
+		modelsTimeout = DefaultModelsTimeout
+	}
+	maxBody := cfg.MaxBodyBytes
+	if maxBody == 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
+
+	budgetFields := cfg.BudgetExceededFields
+	if budgetFields == nil {
+		budgetFields = DefaultBudgetExceededFields
+	}
+
+	exposeHeaders := cfg.CORSExposeHeaders
+	if exposeHeaders == nil {
+		exposeHeaders = append([]string{reqIDHeader}, DefaultCORSExposeHeaders[1:]...)
+	}
+
+	modProvider := cfg.ModerationProvider
+	if modProvider == "" {
+		modProvider = DefaultModerationProvider
+	}
+
+	errorFormat := cfg.ErrorFormat
+	if errorFormat == "" {
+		errorFormat = ErrorFormatOpenAI
+	}
+
 	h := &Handler{
 		tenantRepo:     cfg.TenantRepo,
 		rateLimiter:    cfg.RateLimiter,
@@ -69,132 +350,305 @@ func NewHandler(cfg HandlerConfig) *Handler {
 		costTracker:    cfg.CostTracker,
 		budgetMonitor:  cfg.BudgetMonitor,
 		healthCheckers: cfg.HealthCheckers,
+		providerLimit:  cfg.ProviderLimiter,
+		providerCaps:   cfg.ProviderCaps,
+		periodCost:     cfg.PeriodCost,
+		archiver:       cfg.Archiver,
+		archiveSampler: archiveSampler,
+		systemPrompts:  cfg.DefaultSystemPrompts,
+		maxTokens:      cfg.DefaultMaxTokens,
+		maxStreamDur:   cfg.MaxStreamDuration,
+		sseRetry:       cfg.SSERetry,
+		errorFormat:    errorFormat,
+		estimator:      estimator,
+		estimateUsage:  !cfg.DisableUsageEstimate,
+		estimateTol:    cfg.EstimateTolerance,
+		forwardHeaders: cfg.ForwardHeaders,
+		reqIDHeaders:   reqIDHeaders,
+		reqIDHeader:    reqIDHeader,
+		cors:           newCORSPolicy(cfg.CORSAllowedOrigins, exposeHeaders),
+		maxAttempts:    cfg.MaxFallbackAttempts,
+		timeBudget:     cfg.RequestTimeBudget,
+		minAttempt:     minAttempt,
+		failOnUsage:    cfg.FailOnUsageError,
+		prefixModels:   cfg.PrefixModelIDs,
+		optional:       setOf(cfg.OptionalProviders),
+		debugTenants:   setOf(cfg.DebugTenants),
+		debugModels:    setOf(cfg.DebugModels),
+		budgetFields:   budgetFields,
+		modelsEach:     modelsEach,
+		modelsTimeout:  modelsTimeout,
+		upgradeURL:     cfg.BudgetUpgradeURL,
+		maxBody:        maxBody,
+		killSwitch:     cfg.KillSwitch,
+		dedup:          newRequestDedup(cfg.DedupWindow),
+		defaultModel:   cfg.DefaultModel,
+		slowThreshold:  cfg.SlowThreshold,
+		modProvider:    modProvider,
+		modModel:       cfg.ModerationModel,
+		retry:          newRetryPolicy(cfg.RetryableStatuses),
 		mux:            http.NewServeMux(),
 	}
 
 	h.mux.HandleFunc("POST /v1/chat/completions", h.handleChatCompletions)
+	h.mux.HandleFunc("POST /v1/moderations", h.handleModerations)
 	h.mux.HandleFunc("GET /v1/models", h.handleListModels)
 	h.mux.HandleFunc("GET /v1/usage", h.handleUsage)
+	h.mux.HandleFunc("GET /v1/usage/tags", h.handleUsageByTag)
 	h.mux.HandleFunc("GET /health", h.handleHealth)
 	h.mux.HandleFunc("GET /health/live", h.handleHealthLive)
 	h.mux.HandleFunc("GET /health/ready", h.handleHealthReady)
-	h.mux.Handle("GET /metrics", promhttp.Handler())
+	h.mux.Handle("GET /metrics", metrics.Handler(cfg.MetricsLabels))
 
 	return h
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.mux.ServeHTTP(w, r)
+	if h.cors.apply(w, r) {
+		return
+	}
+	w = &errorFormatWriter{ResponseWriter: w, format: negotiateErrorFormat(r, h.errorFormat)}
+	if err := decodeBody(w, r, h.maxBody); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errUnsupportedEncoding) {
+			status = http.StatusUnsupportedMediaType
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	serveJSON(h.mux, w, r, writeError)
 }
 
 func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if len(h.forwardHeaders) > 0 {
+		r = r.WithContext(httputil.WithForwardedHeaders(r.Context(), httputil.FilterHeaders(r.Header, h.forwardHeaders)))
+	}
+
+	body := &countingBody{ReadCloser: r.Body}
+	r.Body = body
+	cw := &countingWriter{ResponseWriter: w}
+	w = cw
+
 	ctx := r.Context()
 	start := time.Now()
 
 	ctx, span := telemetry.StartSpan(ctx, "chat.completions")
 	defer span.End()
 
-	requestID := r.Header.Get("X-Request-ID")
-	if requestID == "" {
-		requestID = uuid.New().String()
-	}
+	requestID := h.requestIDFrom(r)
 
 	traceID := telemetry.GetTraceID(ctx)
 
-	apiKey := extractAPIKey(r)
-	if apiKey == "" {
-		metrics.RequestsTotal.WithLabelValues("", "", "", "unauthorized").Inc()
-		writeError(w, http.StatusUnauthorized, "missing API key")
+	tenant := h.authorizeChatWrite(w, r, requestID)
+	if tenant == nil {
 		return
 	}
 
-	tenant, err := h.tenantRepo.GetByAPIKey(ctx, apiKey)
-	if err != nil {
-		slog.Warn("invalid API key", "error", err, "request_id", requestID)
-		metrics.RequestsTotal.WithLabelValues("", "", "", "unauthorized").Inc()
-		writeError(w, http.StatusUnauthorized, "invalid API key")
+	var req domain.ChatRequest
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "bad_request").Inc()
+		var tooLarge *http.MaxBytesError
+		if errors.As(decodeErr, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
-
-	if h.budgetMonitor != nil {
-		exceeded, budgetErr := h.budgetMonitor.IsBudgetExceeded(ctx, tenant)
-		if budgetErr != nil {
-			slog.Error("budget check error", "error", budgetErr, "request_id", requestID)
-		} else if exceeded {
-			slog.Warn("budget exceeded", "tenant_id", tenant.ID, "request_id", requestID)
-			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "budget_exceeded").Inc()
-			writeError(w, http.StatusPaymentRequired, "budget exceeded")
-			return
+	if req.Model == "" {
+		req.Model = tenant.DefaultModel
+		if req.Model == "" {
+			req.Model = h.defaultModel
 		}
 	}
+	defer func() { metrics.RecordPayloadSizes(req.Model, body.n, cw.n) }()
 
-	allowed, remaining, resetAt, err := h.rateLimiter.Allow(ctx, tenant.ID, tenant.RateLimitRPM)
+	tags, err := cost.ParseTags(r.Header.Get(cost.TagsHeader))
 	if err != nil {
-		slog.Error("rate limiter error", "error", err, "request_id", requestID)
-		writeError(w, http.StatusInternalServerError, "internal error")
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "bad_request").Inc()
+		writeError(w, http.StatusBadRequest, "invalid X-Tags header: "+err.Error())
 		return
 	}
 
-	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(tenant.RateLimitRPM))
-	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
-	w.Header().Set("X-RateLimit-Reset", resetAt.Format(time.RFC3339))
+	providerHint, skipCache, err := requestOverrides(h.router, r)
+	if err != nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if !allowed {
-		slog.Warn("rate limit exceeded", "tenant_id", tenant.ID, "request_id", requestID)
-		metrics.RecordRateLimitHit(tenant.ID)
-		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "rate_limited").Inc()
-		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+	// A "provider/model" name pins the request to that provider; the prefix
+	// is stripped so the provider sees its own model ID.
+	pinned := false
+	if providerID, model, ok := h.router.SplitModel(req.Model); ok {
+		if providerHint != "" && providerHint != providerID {
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+			writeError(w, http.StatusBadRequest, "model prefix conflicts with X-Provider header")
+			return
+		}
+		providerHint, req.Model, pinned = providerID, model, true
+	}
+	if providerHint != "" && !tenant.ProviderAllowed(providerHint) {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, providerHint, req.Model, "provider_not_allowed").Inc()
+		writeError(w, http.StatusForbidden, "provider not allowed for this tenant: "+providerHint)
 		return
 	}
+	ctx = router.WithAllowedProviders(ctx, tenant.AllowedProviders)
+	ctx = router.WithRequestHeaders(ctx, r.Header)
+	if h.debugTenants[tenant.ID] || h.debugModels[req.Model] {
+		ctx = httputil.WithDebugLogging(ctx)
+	}
 
-	var req domain.ChatRequest
-	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "bad_request").Inc()
-		writeError(w, http.StatusBadRequest, "invalid request body")
+	chain, err := parseProviderChain(h.router, r.Header.Get("X-Provider-Chain"))
+	if err != nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+		writeError(w, http.StatusBadRequest, "invalid X-Provider-Chain header: "+err.Error())
 		return
 	}
+	if len(chain) > 0 {
+		if providerHint != "" {
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+			writeError(w, http.StatusBadRequest, "X-Provider-Chain cannot be combined with X-Provider or a provider-prefixed model")
+			return
+		}
+		for _, id := range chain {
+			if !tenant.ProviderAllowed(id) {
+				metrics.RequestsTotal.WithLabelValues(tenant.ID, id, req.Model, "provider_not_allowed").Inc()
+				writeError(w, http.StatusForbidden, "provider not allowed for this tenant: "+id)
+				return
+			}
+		}
+		ctx = router.WithProviderChain(ctx, chain)
+	}
 
-	providerHint := r.Header.Get("X-Provider")
-	skipCache := r.Header.Get("X-Skip-Cache") == "true"
+	// Applied before cache key generation so cached responses stay
+	// consistent with what the provider actually saw.
+	transformer, err := h.prepareRequest(&req, tenant)
+	if err != nil {
+		slog.Error("invalid tenant transform rules", "error", err, "tenant_id", tenant.ID, "request_id", requestID)
+		writeError(w, http.StatusInternalServerError, "invalid tenant configuration")
+		return
+	}
 
 	if req.Stream {
+		var cacheKey string
+		if h.cache != nil && !skipCache {
+			cacheKey = cache.GenerateCacheKey(req)
+			if cached, ok := h.getCached(ctx, r, cacheKey); ok && replayable(cached) {
+				telemetry.AddCacheAttribute(span, true)
+				h.replayCachedStream(w, r, cached, req, tenant, transformer, requestID, traceID, start, tags)
+				return
+			}
+			metrics.RecordCacheMiss(tenant.ID)
+		}
+
 		provider, selectErr := h.router.SelectProvider(ctx, providerHint, req.Model)
+		if selectErr == nil {
+			provider, selectErr = h.routeAroundCap(ctx, provider, providerHint, req.Model, pinned)
+		}
+		if errors.Is(selectErr, budget.ErrProviderCapReached) {
+			slog.Warn("provider cost cap reached", "provider", provider.ID(), "request_id", requestID)
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "provider_capped").Inc()
+			writeError(w, http.StatusServiceUnavailable, "provider daily cost cap reached")
+			return
+		}
+		if errors.Is(selectErr, domain.ErrCircuitBreakerOpen) {
+			slog.Warn("provider circuits open", "provider", providerHint, "request_id", requestID)
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "circuit_open").Inc()
+			h.writeCircuitOpenError(ctx, w, providerHint, req.Model)
+			return
+		}
+		if errors.Is(selectErr, domain.ErrUnknownModel) {
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "unknown_model").Inc()
+			writeError(w, http.StatusBadRequest, "no provider serves model "+strconv.Quote(req.Model))
+			return
+		}
+		if errors.Is(selectErr, domain.ErrRouteUnavailable) {
+			slog.Warn("header-routed provider unavailable", "error", selectErr, "request_id", requestID)
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "route_unavailable").Inc()
+			writeError(w, http.StatusServiceUnavailable, selectErr.Error())
+			return
+		}
 		if selectErr != nil {
 			slog.Error("provider selection failed", "error", selectErr, "request_id", requestID)
 			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "no_provider").Inc()
 			writeError(w, http.StatusBadGateway, "no provider available")
 			return
 		}
-		h.handleStreamingResponse(w, r, provider, req, tenant, requestID, traceID, start)
+		h.handleStreamingResponse(w, r, provider, req, tenant, transformer, requestID, traceID, start, cacheKey, tags)
 		return
 	}
 
+	if h.dedup != nil {
+		key := dedupKey(tenant.ID, req, r)
+		if entry := h.dedup.begin(key); entry != nil {
+			select {
+			case <-entry.done:
+			case <-ctx.Done():
+				return
+			}
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "deduplicated").Inc()
+			slog.Info("duplicate request served from earlier response",
+				"request_id", requestID,
+				"tenant_id", tenant.ID,
+				"model", req.Model,
+				"status", entry.status,
+			)
+			entry.replay(w, h.reqIDHeader, requestID)
+			return
+		}
+		rec := &dedupRecorder{ResponseWriter: w}
+		w = rec
+		defer h.dedup.finish(key, rec)
+
+		// Duplicates wait on this request's provider call, so it must
+		// outlive a client that gives up and retries.
+		var cancel context.CancelFunc
+		ctx, cancel = h.dedup.leaderContext(ctx)
+		defer cancel()
+	}
+
 	var cacheKey string
 	if h.cache != nil && !skipCache {
 		cacheKey = cache.GenerateCacheKey(req)
-		if cached, ok := h.cache.Get(ctx, cacheKey); ok {
+		if cached, ok := h.getCached(ctx, r, cacheKey); ok {
+			// A hit is billed the usage stored with the response, as a
+			// replayed stream is, so both paths cost the same.
+			costUSD, usageErr := h.recordUsage(ctx, tenant, req, "cache", requestID, cached.Usage, tags)
+			if usageErr != nil && h.failOnUsage {
+				metrics.RequestsTotal.WithLabelValues(tenant.ID, "cache", req.Model, "usage_error").Inc()
+				writeError(w, http.StatusInternalServerError, usageErrorMessage)
+				return
+			}
+
 			latency := time.Since(start).Milliseconds()
-			cached.Gateway = &domain.Gateway{
-				Provider:  "cache",
-				LatencyMs: latency,
-				CostUSD:   0,
-				CacheHit:  true,
-				RequestID: requestID,
-				TraceID:   traceID,
+			cached.Gateway = nil
+			if gatewayMetaEnabled(r) {
+				cached.Gateway = &domain.Gateway{
+					Provider:  "cache",
+					LatencyMs: latency,
+					CostUSD:   costUSD,
+					CacheHit:  true,
+					RequestID: requestID,
+					TraceID:   traceID,
+				}
 			}
 			metrics.RecordCacheHit(tenant.ID)
 			metrics.RecordRequest(tenant.ID, "cache", req.Model, "success", float64(latency)/1000)
+			metrics.RecordTokens(tenant.ID, "cache", req.Model, cached.Usage.PromptTokens, cached.Usage.CompletionTokens)
+			metrics.RecordCost(tenant.ID, "cache", req.Model, costUSD)
 			telemetry.AddCacheAttribute(span, true)
 			slog.Info("cache hit",
 				"request_id", requestID,
 				"tenant_id", tenant.ID,
 				"model", req.Model,
 				"latency_ms", latency,
+				"cost_usd", costUSD,
 			)
 			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Request-ID", requestID)
+			w.Header().Set(h.reqIDHeader, requestID)
 			w.Header().Set("X-Cache", "HIT")
-			json.NewEncoder(w).Encode(cached)
+			json.NewEncoder(w).Encode(transformer.TransformResponse(cached))
 			return
 		}
 		metrics.RecordCacheMiss(tenant.ID)
@@ -202,7 +656,24 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 
 	telemetry.AddCacheAttribute(span, false)
 
-	providers, err := h.router.SelectProviderWithFallback(ctx, providerHint, req.Model)
+	providers, err := h.selectProviders(ctx, providerHint, req.Model, pinned)
+	if errors.Is(err, domain.ErrCircuitBreakerOpen) {
+		slog.Warn("provider circuits open", "provider", providerHint, "request_id", requestID)
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "circuit_open").Inc()
+		h.writeCircuitOpenError(ctx, w, providerHint, req.Model)
+		return
+	}
+	if errors.Is(err, domain.ErrUnknownModel) {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "unknown_model").Inc()
+		writeError(w, http.StatusBadRequest, "no provider serves model "+strconv.Quote(req.Model))
+		return
+	}
+	if errors.Is(err, domain.ErrRouteUnavailable) {
+		slog.Warn("header-routed provider unavailable", "error", err, "request_id", requestID)
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "route_unavailable").Inc()
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
 	if err != nil {
 		slog.Error("provider selection failed", "error", err, "request_id", requestID)
 		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "no_provider").Inc()
@@ -213,67 +684,154 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 	var resp *domain.ChatResponse
 	var lastErr error
 	var usedProvider router.Provider
+	var retried []string
+	attempts := 0
+	capped := false
+	nonRetryable := false
+	outOfTime := false
+
+	attemptCtx := ctx
+	deadline, budgeted := h.attemptDeadline(ctx, start)
+	if budgeted {
+		var cancel context.CancelFunc
+		attemptCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
 
 	for _, provider := range providers {
-		resp, lastErr = provider.ChatCompletion(ctx, req)
+		if h.maxAttempts > 0 && attempts >= h.maxAttempts {
+			capped = true
+			break
+		}
+		if budgeted && attempts > 0 && time.Until(deadline) < h.minAttempt {
+			outOfTime = true
+			break
+		}
+
+		if lastErr = h.waitForProvider(attemptCtx, provider.ID()); lastErr != nil {
+			slog.Warn("provider throttled, trying fallback",
+				"provider", provider.ID(),
+				"error", lastErr,
+				"request_id", requestID,
+			)
+			continue
+		}
+		if lastErr = h.checkProviderCap(ctx, provider.ID()); lastErr != nil {
+			slog.Warn("provider cost cap reached, trying fallback",
+				"provider", provider.ID(),
+				"request_id", requestID,
+			)
+			continue
+		}
+
+		attempts++
+		attemptStart := time.Now()
+		resp, lastErr = provider.ChatCompletion(attemptCtx, req.Clone())
 		if lastErr == nil {
-			h.router.RecordSuccess(provider.ID())
+			h.router.RecordLatency(provider.ID(), req.Model, time.Since(attemptStart))
+			h.router.RecordSuccess(provider.ID(), req.Model)
 			usedProvider = provider
 			break
 		}
+		// An attempt cut short by the time budget says nothing about the
+		// provider's health.
+		if attemptCtx.Err() == nil || ctx.Err() != nil {
+			h.router.RecordFailure(provider.ID(), req.Model)
+		}
+		metrics.RecordProviderError(provider.ID(), "request_failed")
+		if !h.retry.retryable(provider.ID(), lastErr) {
+			slog.Warn("provider failed with a non-retryable error",
+				"provider", provider.ID(),
+				"error", lastErr,
+				"request_id", requestID,
+			)
+			nonRetryable = true
+			break
+		}
 		slog.Warn("provider failed, trying fallback",
 			"provider", provider.ID(),
 			"error", lastErr,
 			"request_id", requestID,
 		)
-		h.router.RecordFailure(provider.ID())
-		metrics.RecordProviderError(provider.ID(), "request_failed")
+		retried = append(retried, provider.ID())
 	}
 
 	if resp == nil {
-		slog.Error("all providers failed", "error", lastErr, "request_id", requestID)
-		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "provider_error").Inc()
+		slog.Error("all providers failed", "error", lastErr, "attempts", attempts, "request_id", requestID)
+		h.logIfSlow(slowRequest{
+			requestID: requestID,
+			traceID:   traceID,
+			tenantID:  tenant.ID,
+			model:     req.Model,
+			failed:    true,
+			attempts:  attempts,
+			fallback:  len(retried) > 0,
+			retried:   retried,
+			latency:   time.Since(start),
+		})
 		telemetry.AddErrorAttribute(span, lastErr)
+		if errors.Is(lastErr, ratelimit.ErrProviderThrottled) {
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "provider_throttled").Inc()
+			writeError(w, http.StatusServiceUnavailable, "upstream capacity exhausted, retry later")
+			return
+		}
+		if errors.Is(lastErr, budget.ErrProviderCapReached) {
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "provider_capped").Inc()
+			writeError(w, http.StatusServiceUnavailable, "provider daily cost cap reached")
+			return
+		}
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "provider_error").Inc()
+		if capped {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("gave up after %d provider attempts: %v", attempts, lastErr))
+			return
+		}
+		if outOfTime || (budgeted && attemptCtx.Err() != nil && ctx.Err() == nil) {
+			slog.Warn("request time budget exhausted", "attempts", attempts, "error", lastErr, "request_id", requestID)
+			if errors.Is(lastErr, context.DeadlineExceeded) {
+				writeError(w, http.StatusGatewayTimeout, fmt.Sprintf("request time budget exhausted after %d provider attempts", attempts))
+				return
+			}
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("request time budget exhausted after %d provider attempts: %v", attempts, lastErr))
+			return
+		}
+		if nonRetryable {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("provider error: %v", lastErr))
+			return
+		}
 		writeError(w, http.StatusBadGateway, fmt.Sprintf("all providers failed: %v", lastErr))
 		return
 	}
 
-	if h.cache != nil && cacheKey != "" {
-		if err := h.cache.Set(ctx, cacheKey, resp, h.cacheTTL); err != nil {
-			slog.Warn("failed to cache response", "error", err, "request_id", requestID)
-		}
-	}
+	h.reconcileMissingUsage(req, resp, usedProvider.ID(), requestID)
 
-	costUSD := h.costCalculator.Calculate(req.Model, resp.Usage)
-
-	if h.costTracker != nil {
-		record := cost.UsageRecord{
-			TenantID:     tenant.ID,
-			RequestID:    requestID,
-			Model:        req.Model,
-			Provider:     usedProvider.ID(),
-			InputTokens:  resp.Usage.PromptTokens,
-			OutputTokens: resp.Usage.CompletionTokens,
-			CostUSD:      costUSD,
-			Timestamp:    time.Now(),
-		}
-		if err := h.costTracker.Record(ctx, record); err != nil {
-			slog.Warn("failed to record usage", "error", err, "request_id", requestID)
-		}
+	costUSD, usageErr := h.recordUsage(ctx, tenant, req, usedProvider.ID(), requestID, resp.Usage, tags)
+	if usageErr != nil && h.failOnUsage {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, usedProvider.ID(), req.Model, "usage_error").Inc()
+		writeError(w, http.StatusInternalServerError, usageErrorMessage)
+		return
+	}
 
-		if h.budgetMonitor != nil {
-			_, _ = h.budgetMonitor.Check(ctx, tenant)
+	if h.cache != nil && cacheKey != "" {
+		if err := h.cache.Set(ctx, cacheKey, resp, h.cacheTTL); errors.Is(err, cache.ErrTooLarge) {
+			slog.Debug("response too large to cache", "request_id", requestID)
+		} else if err != nil {
+			slog.Warn("failed to cache response", "error", err, "request_id", requestID)
 		}
 	}
 
 	latency := time.Since(start).Milliseconds()
-	resp.Gateway = &domain.Gateway{
-		Provider:  usedProvider.ID(),
-		LatencyMs: latency,
-		CostUSD:   costUSD,
-		CacheHit:  false,
-		RequestID: requestID,
-		TraceID:   traceID,
+	if gatewayMetaEnabled(r) {
+		resp.Gateway = &domain.Gateway{
+			Provider:         usedProvider.ID(),
+			LatencyMs:        latency,
+			CostUSD:          costUSD,
+			CacheHit:         false,
+			RequestID:        requestID,
+			TraceID:          traceID,
+			Attempts:         attempts,
+			Fallback:         usedProvider.ID() != providers[0].ID(),
+			RetriedProviders: retried,
+		}
 	}
 
 	metrics.RecordRequest(tenant.ID, usedProvider.ID(), req.Model, "success", float64(latency)/1000)
@@ -290,19 +848,107 @@ func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request)
 		"tenant_id", tenant.ID,
 		"provider", usedProvider.ID(),
 		"model", req.Model,
+		"attempts", attempts,
 		"latency_ms", latency,
 		"cost_usd", costUSD,
 		"tokens_input", resp.Usage.PromptTokens,
 		"tokens_output", resp.Usage.CompletionTokens,
 	)
+	h.logIfSlow(slowRequest{
+		requestID: requestID,
+		traceID:   traceID,
+		tenantID:  tenant.ID,
+		provider:  usedProvider.ID(),
+		model:     req.Model,
+		attempts:  attempts,
+		fallback:  usedProvider.ID() != providers[0].ID(),
+		retried:   retried,
+		usage:     resp.Usage,
+		latency:   time.Since(start),
+	})
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Request-ID", requestID)
+	w.Header().Set(h.reqIDHeader, requestID)
 	w.Header().Set("X-Cache", "MISS")
-	json.NewEncoder(w).Encode(resp)
+	sent := transformer.TransformResponse(resp)
+	json.NewEncoder(w).Encode(sent)
+
+	h.archiveRequest(ctx, r, archive.Record{
+		RequestID: requestID,
+		TenantID:  tenant.ID,
+		Provider:  usedProvider.ID(),
+		Request:   req,
+		Response:  sent,
+		LatencyMs: latency,
+	})
 }
 
-func (h *Handler) handleStreamingResponse(w http.ResponseWriter, r *http.Request, provider router.Provider, req domain.ChatRequest, tenant *domain.Tenant, requestID string, traceID string, start time.Time) {
+// archiveRequest hands a completed request to the archiver when the sampler
+// selects it. It runs after the response is written, on a detached context,
+// so archiving neither delays nor fails the request.
+func (h *Handler) archiveRequest(ctx context.Context, r *http.Request, record archive.Record) {
+	if h.archiver == nil {
+		return
+	}
+	reason, ok := h.archiveSampler.ShouldArchive(r, record.TenantID, record.RequestID)
+	if !ok {
+		return
+	}
+
+	archiveCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	record.Timestamp = time.Now()
+	if err := h.archiver.Archive(archiveCtx, record); err != nil {
+		slog.Warn("failed to archive request", "error", err, "request_id", record.RequestID)
+		return
+	}
+	metrics.RecordArchived(record.TenantID, reason)
+}
+
+// reconcileMissingUsage counts and logs a response whose provider reported
+// no usage for its content and, unless estimation is disabled, fills the
+// missing counts in from an estimate. Streams and plain responses share it so
+// both bill the same way.
+func (h *Handler) reconcileMissingUsage(req domain.ChatRequest, resp *domain.ChatResponse, providerID, requestID string) {
+	if !cost.MissingUsage(&req, resp) {
+		return
+	}
+	metrics.RecordMissingUsage(providerID, req.Model)
+	msg := "provider reported no usage"
+	if h.estimateUsage && cost.ReconcileUsage(h.estimator, &req, resp) {
+		msg = "provider reported no usage, using estimate"
+	}
+	slog.Warn(msg,
+		"request_id", requestID,
+		"provider", providerID,
+		"model", req.Model,
+		"tokens_input", resp.Usage.PromptTokens,
+		"tokens_output", resp.Usage.CompletionTokens,
+	)
+}
+
+// synthesizeUsageChunk builds the final stream_options.include_usage chunk for
+// providers that do not send one, carrying the usage the stream is billed at.
+func synthesizeUsageChunk(req domain.ChatRequest, last domain.StreamChunk, usage domain.Usage) domain.StreamChunk {
+	created := last.Created
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+	model := last.Model
+	if model == "" {
+		model = req.Model
+	}
+	return domain.StreamChunk{
+		ID:      last.ID,
+		Object:  "chat.completion.chunk",
+		Created: created,
+		Model:   model,
+		Choices: []domain.Choice{},
+		Usage:   &usage,
+	}
+}
+
+func (h *Handler) handleStreamingResponse(w http.ResponseWriter, r *http.Request, provider router.Provider, req domain.ChatRequest, tenant *domain.Tenant, transformer transform.Transformer, requestID string, traceID string, start time.Time, cacheKey string, tags map[string]string) {
 	ctx := r.Context()
 
 	ctx, span := telemetry.StartSpan(ctx, "chat.completions.stream")
@@ -310,6 +956,14 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, r *http.Request
 
 	metrics.IncrementActiveStreams()
 	defer metrics.DecrementActiveStreams()
+	markStreaming(ctx)
+
+	if err := h.waitForProvider(ctx, provider.ID()); err != nil {
+		slog.Warn("provider throttled", "provider", provider.ID(), "error", err, "request_id", requestID)
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, provider.ID(), req.Model, "provider_throttled").Inc()
+		writeError(w, http.StatusServiceUnavailable, "upstream capacity exhausted, retry later")
+		return
+	}
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -317,32 +971,103 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	maxStreamDur := h.streamDurationLimit(tenant)
+	h.extendStreamWriteDeadline(w, maxStreamDur, requestID)
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Request-ID", requestID)
+	w.Header().Set(h.reqIDHeader, requestID)
+
+	// streamCtx is handed to the provider so that hitting the limit also
+	// cancels its reader goroutine and upstream connection.
+	streamCtx := ctx
+	if maxStreamDur > 0 {
+		var cancel context.CancelFunc
+		streamCtx, cancel = context.WithTimeout(ctx, maxStreamDur)
+		defer cancel()
+	}
 
-	chunks, errs := provider.ChatCompletionStream(ctx, req)
+	streamStart := time.Now()
+	chunks, errs := provider.ChatCompletionStream(streamCtx, req)
+
+	sse := &sseWriter{w: w}
+	if h.sseRetry > 0 {
+		sse.retry(h.sseRetry)
+	}
+
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	var lastChunk domain.StreamChunk
+	var captured streamCapture
+	sentUsage := false
+	roles := roleDeltas{}
+	// A stream's latency for the circuit breaker is its time to first chunk;
+	// the full duration grows with the output and would trip it on long
+	// replies.
+	latencyRecorded := false
+	recordLatency := func() {
+		if !latencyRecorded {
+			latencyRecorded = true
+			h.router.RecordLatency(provider.ID(), req.Model, time.Since(streamStart))
+		}
+	}
 
 	for {
 		select {
 		case chunk, ok := <-chunks:
 			if !ok {
+				recordLatency()
+				tail := domain.StreamChunk{ID: lastChunk.ID, Object: "chat.completion.chunk", Created: lastChunk.Created, Model: lastChunk.Model}
+				if transformer.Flush(&tail) {
+					if first, ok := roles.apply(&tail); ok {
+						sse.json(first)
+					}
+					sse.json(tail)
+				}
+
+				// The usage goes into the cache with the response so that
+				// replays record it rather than estimating it again.
+				resp := captured.response(req)
+				if captured.usage != nil {
+					h.checkEstimate(provider.ID(), req, resp)
+				}
+				h.reconcileMissingUsage(req, resp, provider.ID(), requestID)
+				if includeUsage && !sentUsage {
+					sse.json(synthesizeUsageChunk(req, lastChunk, resp.Usage))
+				}
+				costUSD, usageErr := h.recordUsage(ctx, tenant, req, provider.ID(), requestID, resp.Usage, tags)
+				if usageErr != nil && h.failOnUsage {
+					metrics.RequestsTotal.WithLabelValues(tenant.ID, provider.ID(), req.Model, "usage_error").Inc()
+					writeStreamError(sse, http.StatusInternalServerError, usageErrorMessage)
+					sse.data("[DONE]")
+					flusher.Flush()
+					h.router.RecordSuccess(provider.ID(), req.Model)
+					return
+				}
+				if cacheKey != "" && captured.cacheable() {
+					if err := h.cache.Set(ctx, cacheKey, resp, h.cacheTTL); err != nil && !errors.Is(err, cache.ErrTooLarge) {
+						slog.Warn("failed to cache stream", "error", err, "request_id", requestID)
+					}
+				}
+
 				latency := time.Since(start).Milliseconds()
-				gatewayData := domain.Gateway{
-					Provider:  provider.ID(),
-					LatencyMs: latency,
-					CostUSD:   0,
-					CacheHit:  false,
-					RequestID: requestID,
-					TraceID:   traceID,
+				if gatewayMetaEnabled(r) {
+					gatewayData := domain.Gateway{
+						Provider:  provider.ID(),
+						LatencyMs: latency,
+						CostUSD:   costUSD,
+						CacheHit:  false,
+						RequestID: requestID,
+						TraceID:   traceID,
+					}
+					sse.json(map[string]interface{}{"x_gateway": gatewayData})
 				}
-				gatewayJSON, _ := json.Marshal(map[string]interface{}{"x_gateway": gatewayData})
-				w.Write([]byte("data: " + string(gatewayJSON) + "\n\n"))
-				w.Write([]byte("data: [DONE]\n\n"))
+				sse.data("[DONE]")
 				flusher.Flush()
 
 				metrics.RecordRequest(tenant.ID, provider.ID(), req.Model, "success", float64(latency)/1000)
+				metrics.RecordTokens(tenant.ID, provider.ID(), req.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+				metrics.RecordCost(tenant.ID, provider.ID(), req.Model, costUSD)
 				telemetry.AddRequestAttributes(span, tenant.ID, provider.ID(), req.Model, requestID)
 
 				slog.Info("streaming request completed",
@@ -352,54 +1077,385 @@ func (h *Handler) handleStreamingResponse(w http.ResponseWriter, r *http.Request
 					"provider", provider.ID(),
 					"model", req.Model,
 					"latency_ms", latency,
+					"cost_usd", costUSD,
 				)
-				h.router.RecordSuccess(provider.ID())
+				h.logIfSlow(slowRequest{
+					requestID: requestID,
+					traceID:   traceID,
+					tenantID:  tenant.ID,
+					provider:  provider.ID(),
+					model:     req.Model,
+					stream:    true,
+					attempts:  1,
+					usage:     resp.Usage,
+					latency:   time.Since(start),
+				})
+				h.router.RecordSuccess(provider.ID(), req.Model)
+				h.archiveRequest(ctx, r, archive.Record{
+					RequestID: requestID,
+					TenantID:  tenant.ID,
+					Provider:  provider.ID(),
+					Stream:    true,
+					Request:   req,
+					Response:  transformer.TransformResponse(resp),
+					LatencyMs: latency,
+				})
 				return
 			}
 
-			data, _ := json.Marshal(chunk)
-			w.Write([]byte("data: " + string(data) + "\n\n"))
+			recordLatency()
+			captured.observe(chunk)
+			transformer.TransformChunk(&chunk)
+			if first, ok := roles.apply(&chunk); ok {
+				sse.json(first)
+			}
+			if chunk.Usage != nil {
+				sentUsage = true
+			}
+			lastChunk = chunk
+			sse.json(chunk)
 			flusher.Flush()
 
 		case err, ok := <-errs:
 			if ok && err != nil {
 				slog.Error("streaming error", "error", err, "request_id", requestID)
 				metrics.RecordProviderError(provider.ID(), "stream_error")
-				h.router.RecordFailure(provider.ID())
+				h.router.RecordFailure(provider.ID(), req.Model)
 				telemetry.AddErrorAttribute(span, err)
+
+				// A rate limit before the first chunk is passed on, with the
+				// provider's Retry-After, while headers can still be set.
+				var statusErr *domain.ProviderStatusError
+				if !sse.started() && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests {
+					writeStreamRateLimitError(sse, statusErr.RetryAfter)
+					sse.data("[DONE]")
+					flusher.Flush()
+				}
+				return
+			}
+
+		case <-streamCtx.Done():
+			if ctx.Err() != nil {
+				// Client went away; nothing left to write to.
 				return
 			}
 
-		case <-ctx.Done():
+			slog.Warn("stream exceeded max duration",
+				"request_id", requestID,
+				"tenant_id", tenant.ID,
+				"provider", provider.ID(),
+				"max_duration", maxStreamDur,
+			)
+			metrics.RecordProviderError(provider.ID(), "stream_timeout")
+			metrics.RequestsTotal.WithLabelValues(tenant.ID, provider.ID(), req.Model, "stream_timeout").Inc()
+			telemetry.AddErrorAttribute(span, streamCtx.Err())
+
+			writeStreamError(sse, http.StatusGatewayTimeout, "stream exceeded maximum duration")
+			sse.data("[DONE]")
+			flusher.Flush()
 			return
 		}
 	}
 }
 
-func (h *Handler) handleListModels(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+// writeStreamError writes an OpenAI-style error object as an SSE data frame.
+// Used once headers are already sent and writeError is no longer an option.
+func writeStreamError(sse *sseWriter, status int, message string) {
+	sse.json(errorBody(errorFormatOf(sse.w), status, message))
+}
 
-	var allModels []domain.Model
+// writeStreamRateLimitError reports an upstream rate limit in an SSE error
+// frame. A known retryAfter is sent, in whole seconds, as a Retry-After
+// header and as retry_after in the frame.
+func writeStreamRateLimitError(sse *sseWriter, retryAfter time.Duration) {
+	body := errorBody(errorFormatOf(sse.w), http.StatusTooManyRequests, "provider rate limit exceeded, retry later")
+	if retryAfter > 0 {
+		seconds := int((retryAfter + time.Second - 1) / time.Second)
+		sse.w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		if inner, ok := body["error"].(map[string]interface{}); ok {
+			inner["retry_after"] = seconds
+		} else {
+			body["retry_after"] = seconds
+		}
+	}
+	sse.json(body)
+}
 
-	for _, providerID := range h.router.ListProviders() {
-		provider, ok := h.router.GetProvider(providerID)
-		if !ok {
-			continue
+// writeCircuitOpenError answers a request that reached no provider because
+// the circuits of every candidate, or of the hinted provider, are open. It is
+// a 503 rather than a 502 since no upstream actually failed, with a
+// Retry-After of when the first circuit admits a trial request.
+func (h *Handler) writeCircuitOpenError(ctx context.Context, w http.ResponseWriter, providerHint, model string) {
+	var ids []string
+	message := "all provider circuits are open, retry later"
+	if providerHint != "" {
+		ids = []string{providerHint}
+		message = "provider circuit is open, retry later: " + providerHint
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(h.router.CircuitRetryAfter(ctx, model, ids...))))
+	writeError(w, http.StatusServiceUnavailable, message)
+}
+
+// selectProviders returns the providers to try in order. A pinned request
+// only ever goes to the hinted provider, since the model name it carries is
+// specific to that provider.
+func (h *Handler) selectProviders(ctx context.Context, providerHint, model string, pinned bool) ([]router.Provider, error) {
+	if !pinned {
+		return h.router.SelectProviderWithFallback(ctx, providerHint, model)
+	}
+	provider, err := h.router.SelectProvider(ctx, providerHint, model)
+	if err != nil {
+		return nil, err
+	}
+	return []router.Provider{provider}, nil
+}
+
+// recordUsage prices usage for the tenant and records it with the cost
+// tracker, then refreshes the tenant's budget state. It returns the cost.
+func (h *Handler) recordUsage(ctx context.Context, tenant *domain.Tenant, req domain.ChatRequest, providerID, requestID string, usage domain.Usage, tags map[string]string) (float64, error) {
+	costUSD := h.costCalculator.CalculateForTenant(tenant, req.Model, usage)
+	if h.costTracker == nil {
+		return costUSD, nil
+	}
+
+	record := cost.UsageRecord{
+		TenantID:     tenant.ID,
+		RequestID:    requestID,
+		Model:        req.Model,
+		Provider:     providerID,
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		CostUSD:      costUSD,
+		Timestamp:    time.Now(),
+		Metadata:     req.Metadata,
+		Tags:         tags,
+	}
+	// The provider has already billed us, so accounting must finish even
+	// if the client disconnects now and cancels the request context.
+	acctCtx, cancel := detachedContext(ctx)
+	defer cancel()
+	recordErr := h.costTracker.Record(acctCtx, record)
+	if recordErr != nil {
+		slog.Warn("failed to record usage", "error", recordErr, "request_id", requestID)
+	}
+
+	if h.budgetMonitor != nil {
+		_, _ = h.budgetMonitor.Check(acctCtx, tenant)
+	}
+	if h.periodCost != nil {
+		if err := h.periodCost.Observe(acctCtx, tenant, costUSD); err != nil {
+			slog.Warn("failed to update tenant period cost", "error", err, "request_id", requestID)
 		}
+	}
+	return costUSD, recordErr
+}
 
-		models, err := provider.Models(ctx)
-		if err != nil {
-			slog.Warn("failed to get models from provider", "provider", providerID, "error", err)
+// checkEstimate compares what the estimator makes of resp with the usage the
+// provider reported, which stays authoritative. Prompt and completion counts
+// that diverge by more than h.estimateTol are recorded to help calibrate the
+// estimator.
+func (h *Handler) checkEstimate(providerID string, req domain.ChatRequest, resp *domain.ChatResponse) {
+	prompt, completion := cost.EstimateError(cost.EstimateUsage(h.estimator, &req, resp), resp.Usage)
+	if math.Abs(prompt) > h.estimateTol {
+		metrics.RecordTokenEstimateError(providerID, req.Model, "prompt", prompt)
+	}
+	if math.Abs(completion) > h.estimateTol {
+		metrics.RecordTokenEstimateError(providerID, req.Model, "completion", completion)
+	}
+}
+
+// getCached looks up key, honouring a Cache-Control: max-age request
+// directive by treating older entries as misses.
+func (h *Handler) getCached(ctx context.Context, r *http.Request, key string) (*domain.ChatResponse, bool) {
+	if maxAge, ok := requestMaxAge(r); ok {
+		return cache.GetFresh(ctx, h.cache, key, maxAge)
+	}
+	return h.cache.Get(ctx, key)
+}
+
+// requestMaxAge reads the max-age directive of the request's Cache-Control
+// header. Malformed values are ignored, as HTTP caches do.
+func requestMaxAge(r *http.Request) (time.Duration, bool) {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
 			continue
 		}
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+// requestOverrides reads the provider hint and cache bypass from the
+// X-Provider and X-Skip-Cache headers, falling back to the provider and
+// skip_cache query parameters for clients that cannot set headers. Headers
+// win when both are set.
+func requestOverrides(rt *router.Router, r *http.Request) (string, bool, error) {
+	q := r.URL.Query()
+
+	providerHint := r.Header.Get("X-Provider")
+	if providerHint == "" && q.Has("provider") {
+		providerHint = q.Get("provider")
+		if _, ok := rt.GetProvider(providerHint); !ok {
+			return "", false, fmt.Errorf("invalid provider query parameter: unknown provider %q", providerHint)
+		}
+	}
+
+	if v := r.Header.Get("X-Skip-Cache"); v != "" {
+		return providerHint, v == "true", nil
+	}
+	if !q.Has("skip_cache") {
+		return providerHint, false, nil
+	}
+	skipCache, err := strconv.ParseBool(q.Get("skip_cache"))
+	if err != nil {
+		return "", false, fmt.Errorf("invalid skip_cache query parameter: %q", q.Get("skip_cache"))
+	}
+	return providerHint, skipCache, nil
+}
+
+// parseProviderChain reads an X-Provider-Chain header: comma-separated IDs of
+// registered providers, each listed once, to try in that order.
+func parseProviderChain(rt *router.Router, header string) ([]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	var chain []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		id := strings.TrimSpace(part)
+		if id == "" {
+			return nil, errors.New("empty provider ID")
+		}
+		if _, ok := rt.GetProvider(id); !ok {
+			return nil, fmt.Errorf("unknown provider %q", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("provider %q listed more than once", id)
+		}
+		seen[id] = true
+		chain = append(chain, id)
+	}
+	return chain, nil
+}
+
+// modelCapabilities maps the values accepted by /v1/models?supports= to the
+// capability they require.
+var modelCapabilities = map[string]func(*domain.ModelCapabilities) bool{
+	"streaming": func(c *domain.ModelCapabilities) bool { return c.Streaming },
+	"tools":     func(c *domain.ModelCapabilities) bool { return c.Tools },
+	"vision":    func(c *domain.ModelCapabilities) bool { return c.Vision },
+}
+
+// parseSupports reads the supports query parameter, which may be repeated or
+// comma-separated. A model must have every listed capability.
+func parseSupports(q url.Values) ([]string, error) {
+	var supports []string
+	for _, v := range q["supports"] {
+		for _, capability := range strings.Split(v, ",") {
+			capability = strings.TrimSpace(capability)
+			if capability == "" {
+				continue
+			}
+			if _, ok := modelCapabilities[capability]; !ok {
+				return nil, fmt.Errorf("unknown capability %q", capability)
+			}
+			supports = append(supports, capability)
+		}
+	}
+	return supports, nil
+}
+
+// modelSupports reports whether m has every capability. Models whose
+// capabilities are unknown never match a filter.
+func modelSupports(m domain.Model, supports []string) bool {
+	if len(supports) == 0 {
+		return true
+	}
+	if m.Capabilities == nil {
+		return false
+	}
+	for _, capability := range supports {
+		if !modelCapabilities[capability](m.Capabilities) {
+			return false
+		}
+	}
+	return true
+}
+
+// listedModelAllowed applies a tenant's provider and model allow-lists to a
+// listed model, which may be named bare or provider-prefixed in the list.
+func listedModelAllowed(tenant *domain.Tenant, providerID, modelID string) bool {
+	if tenant == nil {
+		return true
+	}
+	if !tenant.ProviderAllowed(providerID) {
+		return false
+	}
+	if len(tenant.AllowedModels) == 0 {
+		return true
+	}
+	bare := strings.TrimPrefix(modelID, providerID+"/")
+	for _, allowed := range tenant.AllowedModels {
+		if allowed == bare || allowed == providerID+"/"+bare {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) handleListModels(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	supports, err := parseSupports(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Listing does not require a key, but a caller that sends one only sees
+	// the models its tenant may use.
+	var tenant *domain.Tenant
+	if apiKey := extractAPIKey(r); apiKey != "" && h.tenantRepo != nil {
+		tenant, _ = h.tenantRepo.GetByAPIKey(ctx, apiKey)
+	}
+	if tenant != nil {
+		if !tenant.HasScope(domain.ScopeModelsRead) {
+			writeScopeError(w, domain.ScopeModelsRead)
+			return
+		}
+		if !h.checkRateLimit(w, r, tenant) {
+			return
+		}
+	}
 
-		allModels = append(allModels, models...)
+	allModels := []domain.Model{}
+
+	byProvider, unavailable := h.fetchModels(ctx)
+	for _, providerID := range h.router.ListProviders() {
+		for _, m := range byProvider[providerID] {
+			if h.prefixModels {
+				m.ID = providerID + "/" + m.ID
+				m.Provider = providerID
+			}
+			if !modelSupports(m, supports) || !listedModelAllowed(tenant, providerID, m.ID) {
+				continue
+			}
+			allModels = append(allModels, m)
+		}
 	}
 
 	resp := domain.ModelsResponse{
 		Object: "list",
 		Data:   allModels,
 	}
+	if len(unavailable) > 0 {
+		resp.Unavailable = unavailable
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -420,43 +1476,153 @@ func (h *Handler) handleUsage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !tenant.HasScope(domain.ScopeUsageRead) {
+		writeScopeError(w, domain.ScopeUsageRead)
+		return
+	}
+
+	if !h.checkRateLimit(w, r, tenant) {
+		return
+	}
+
 	if h.costTracker == nil {
 		writeError(w, http.StatusNotImplemented, "usage tracking not enabled")
 		return
 	}
 
-	startOfMonth := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -time.Now().Day()+1)
-	records, err := h.costTracker.GetTenantUsage(ctx, tenant.ID, startOfMonth)
+	now := time.Now()
+	window := budget.PeriodWindow(tenant.BudgetPeriod, now)
+	records, err := h.costTracker.GetTenantUsage(ctx, tenant.ID, window.Start)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to get usage")
 		return
 	}
 
-	totalCost, _ := h.costTracker.GetTenantTotalCost(ctx, tenant.ID, startOfMonth)
+	totalCost, _ := h.costTracker.GetTenantTotalCost(ctx, tenant.ID, window.Start)
+
+	// With a tag filter the request count and spend describe only the tagged
+	// traffic; budget usage still reflects the tenant's whole spend.
+	requestCount := len(records)
+	periodCost := totalCost
+	tagFilter := r.URL.Query().Get("tag")
+	if tagFilter != "" {
+		key, value, ok := strings.Cut(tagFilter, ":")
+		if !ok || key == "" {
+			writeError(w, http.StatusBadRequest, "tag must be key:value")
+			return
+		}
+		totals, err := h.costTracker.GetTenantTagTotals(ctx, tenant.ID, key, window.Start)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to get usage")
+			return
+		}
+		requestCount, periodCost = 0, 0
+		for _, t := range totals {
+			if t.Value == value {
+				requestCount, periodCost = int(t.Requests), t.CostUSD
+			}
+		}
+	}
+
+	period := tenant.BudgetPeriod
+	if period == "" {
+		period = domain.BudgetPeriodMonthly
+	}
 
 	resp := map[string]interface{}{
-		"tenant_id":       tenant.ID,
-		"period_start":    startOfMonth.Format(time.RFC3339),
-		"period_end":      time.Now().Format(time.RFC3339),
-		"total_cost_usd":  totalCost,
-		"budget_usd":      tenant.BudgetUSD,
-		"budget_used_pct": 0.0,
-		"request_count":   len(records),
+		"tenant_id":          tenant.ID,
+		"period":             period,
+		"period_start":       window.Start.Format(time.RFC3339),
+		"period_end":         now.Format(time.RFC3339),
+		"total_cost_usd":     periodCost,
+		"projected_cost_usd": window.ProjectCost(periodCost, now),
+		"budget_usd":         tenant.BudgetUSD,
+		"budget_used_pct":    0.0,
+		"alert_level":        "none",
+		"request_count":      requestCount,
+	}
+	if tagFilter != "" {
+		resp["tag"] = tagFilter
 	}
 
 	if tenant.BudgetUSD > 0 {
 		resp["budget_used_pct"] = (totalCost / tenant.BudgetUSD) * 100
+
+		thresholds := budget.DefaultThresholds()
+		if h.budgetMonitor != nil {
+			thresholds = h.budgetMonitor.Thresholds()
+		}
+		if level := thresholds.Level(totalCost / tenant.BudgetUSD); level != "" {
+			resp["alert_level"] = level
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleUsageByTag breaks the tenant's spend in the current budget period
+// down by the values of one tag key.
+func (h *Handler) handleUsageByTag(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	apiKey := extractAPIKey(r)
+	if apiKey == "" {
+		writeError(w, http.StatusUnauthorized, "missing API key")
+		return
+	}
+
+	tenant, err := h.tenantRepo.GetByAPIKey(ctx, apiKey)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+
+	if !tenant.HasScope(domain.ScopeUsageRead) {
+		writeScopeError(w, domain.ScopeUsageRead)
+		return
+	}
+
+	if !h.checkRateLimit(w, r, tenant) {
+		return
+	}
+
+	if h.costTracker == nil {
+		writeError(w, http.StatusNotImplemented, "usage tracking not enabled")
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "key is required")
+		return
+	}
+
+	window := budget.PeriodWindow(tenant.BudgetPeriod, time.Now())
+	totals, err := h.costTracker.GetTenantTagTotals(ctx, tenant.ID, key, window.Start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to get usage")
+		return
+	}
+	if totals == nil {
+		totals = []cost.TagTotals{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tenant_id":    tenant.ID,
+		"key":          key,
+		"period_start": window.Start.Format(time.RFC3339),
+		"breakdown":    totals,
+	})
+}
+
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	providers := make(map[string]string)
-	allHealthy := true
+	requiredHealthy := true
+	var optionalDown []string
 
 	for _, providerID := range h.router.ListProviders() {
 		provider, ok := h.router.GetProvider(providerID)
@@ -466,7 +1632,11 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 		if err := provider.HealthCheck(ctx); err != nil {
 			providers[providerID] = "unhealthy"
-			allHealthy = false
+			if h.optional[providerID] {
+				optionalDown = append(optionalDown, providerID)
+			} else {
+				requiredHealthy = false
+			}
 		} else {
 			providers[providerID] = "ok"
 		}
@@ -474,7 +1644,7 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 	status := "healthy"
 	httpStatus := http.StatusOK
-	if !allHealthy {
+	if !requiredHealthy {
 		status = "degraded"
 	}
 
@@ -484,6 +1654,10 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"providers":        providers,
 		"circuit_breakers": h.router.CircuitBreakerStates(),
 	}
+	if len(optionalDown) > 0 {
+		sort.Strings(optionalDown)
+		resp["optional_unhealthy"] = optionalDown
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(httpStatus)
@@ -536,6 +1710,200 @@ func (h *Handler) handleHealthReady(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(status)
 }
 
+// detachedContext returns a context that keeps ctx's values (trace span,
+// request-scoped data) but is not canceled when ctx is, bounded by
+// accountingTimeout instead.
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), accountingTimeout)
+}
+
+// waitForProvider applies the outbound per-provider rate limit, if configured.
+func (h *Handler) waitForProvider(ctx context.Context, providerID string) error {
+	if h.providerLimit == nil {
+		return nil
+	}
+	return h.providerLimit.Wait(ctx, providerID)
+}
+
+// attemptDeadline returns when provider attempts for a request started at
+// start must be done by: the end of the time budget or ctx's deadline,
+// whichever is earlier. ok is false when there is neither.
+func (h *Handler) attemptDeadline(ctx context.Context, start time.Time) (deadline time.Time, ok bool) {
+	if h.timeBudget > 0 {
+		deadline, ok = start.Add(h.timeBudget), true
+	}
+	if d, has := ctx.Deadline(); has && (!ok || d.Before(deadline)) {
+		deadline, ok = d, true
+	}
+	return deadline, ok
+}
+
+func (h *Handler) checkProviderCap(ctx context.Context, providerID string) error {
+	if h.providerCaps == nil {
+		return nil
+	}
+	return h.providerCaps.Check(ctx, providerID)
+}
+
+// routeAroundCap swaps a capped streaming provider for the first uncapped one
+// in the fallback chain. Pinned requests are not rerouted and get
+// ErrProviderCapReached along with the capped provider.
+func (h *Handler) routeAroundCap(ctx context.Context, provider router.Provider, providerHint, model string, pinned bool) (router.Provider, error) {
+	capErr := h.checkProviderCap(ctx, provider.ID())
+	if capErr == nil || pinned {
+		return provider, capErr
+	}
+	providers, err := h.router.SelectProviderWithFallback(ctx, providerHint, model)
+	if err != nil {
+		return provider, capErr
+	}
+	for _, p := range providers {
+		if h.checkProviderCap(ctx, p.ID()) == nil {
+			return p, nil
+		}
+	}
+	return provider, capErr
+}
+
+// streamDeadlineGrace leaves time after MaxStreamDuration fires to write the
+// error frame and [DONE] before the connection deadline is hit.
+const streamDeadlineGrace = 5 * time.Second
+
+// extendStreamWriteDeadline lifts the server's WriteTimeout for a streaming
+// response, which would otherwise truncate long generations. The deadline is
+// pushed to the stream's maximum duration (plus grace), or cleared when it
+// has none.
+func (h *Handler) extendStreamWriteDeadline(w http.ResponseWriter, maxStreamDur time.Duration, requestID string) {
+	var deadline time.Time
+	if maxStreamDur > 0 {
+		deadline = time.Now().Add(maxStreamDur + streamDeadlineGrace)
+	}
+	if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.Warn("failed to extend stream write deadline", "error", err, "request_id", requestID)
+	}
+}
+
+// streamDurationLimit returns how long the tenant's streams may run: its
+// max_stream_seconds when set, otherwise MaxStreamDuration.
+func (h *Handler) streamDurationLimit(tenant *domain.Tenant) time.Duration {
+	if tenant.MaxStreamSeconds > 0 {
+		return time.Duration(tenant.MaxStreamSeconds) * time.Second
+	}
+	return h.maxStreamDur
+}
+
+// prepareRequest applies the gateway and tenant defaults and the tenant's
+// transform rules to req, returning the transformer for the response.
+func (h *Handler) prepareRequest(req *domain.ChatRequest, tenant *domain.Tenant) (transform.Transformer, error) {
+	_, name, _ := h.router.SplitModel(req.Model)
+	applyDefaultSystemPrompt(req, name, h.systemPrompts)
+	applySamplingDefaults(req, tenant.SamplingDefaults)
+	applyDefaultMaxTokens(req, name, h.maxTokens)
+
+	transformer, err := transform.New(tenant.TransformRules)
+	if err != nil {
+		return nil, err
+	}
+	transformer.TransformRequest(req)
+	return transformer, nil
+}
+
+// PrepareCacheRequest changes req the way a live request from tenantID is
+// changed before its cache key is generated, so preloaded responses are
+// stored under the key matching requests look up. An empty tenantID applies
+// only the gateway-wide defaults.
+func (h *Handler) PrepareCacheRequest(ctx context.Context, tenantID string, req *domain.ChatRequest) error {
+	tenant := &domain.Tenant{}
+	if tenantID != "" {
+		var err error
+		if tenant, err = h.tenantRepo.GetByID(ctx, tenantID); err != nil {
+			return fmt.Errorf("get tenant %s: %w", tenantID, err)
+		}
+	}
+	_, err := h.prepareRequest(req, tenant)
+	return err
+}
+
+// applyDefaultSystemPrompt prepends the model's default system prompt when the
+// request has none. A client-supplied system message is never overridden.
+// Prompts are keyed by name, the model without any provider prefix.
+func applyDefaultSystemPrompt(req *domain.ChatRequest, name string, prompts map[string]string) {
+	prompt, ok := prompts[name]
+	if !ok || prompt == "" {
+		return
+	}
+
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			return
+		}
+	}
+
+	messages := make([]domain.Message, 0, len(req.Messages)+1)
+	messages = append(messages, domain.Message{Role: "system", Content: prompt})
+	req.Messages = append(messages, req.Messages...)
+}
+
+// applySamplingDefaults fills in the tenant's default sampling parameters
+// the request leaves unset. Each default is copied so the request never
+// shares a pointer with the tenant.
+func applySamplingDefaults(req *domain.ChatRequest, defaults *domain.SamplingDefaults) {
+	if defaults == nil {
+		return
+	}
+	if req.Temperature == nil && defaults.Temperature != nil {
+		v := *defaults.Temperature
+		req.Temperature = &v
+	}
+	if req.TopP == nil && defaults.TopP != nil {
+		v := *defaults.TopP
+		req.TopP = &v
+	}
+	if req.MaxTokens == nil && defaults.MaxTokens != nil {
+		v := *defaults.MaxTokens
+		req.MaxTokens = &v
+	}
+}
+
+// applyDefaultMaxTokens sets max_tokens from defaults when the request has
+// none. Without it, providers that require the field fill in their own
+// default while others let the model run to its maximum. Defaults are keyed
+// by name, the model without any provider prefix (gpt-4o for openai/gpt-4o),
+// with "*" for every other model.
+func applyDefaultMaxTokens(req *domain.ChatRequest, name string, defaults map[string]int) {
+	if req.MaxTokens != nil {
+		return
+	}
+	n, ok := defaults[name]
+	if !ok {
+		n, ok = defaults["*"]
+	}
+	if ok && n > 0 {
+		req.MaxTokens = &n
+	}
+}
+
+// gatewayMetaEnabled reports whether the response should carry gateway
+// metadata. Clients opt out with X-Gateway-Meta: false; anything else,
+// including an unparsable value, keeps it.
+func gatewayMetaEnabled(r *http.Request) bool {
+	enabled, err := strconv.ParseBool(r.Header.Get("X-Gateway-Meta"))
+	return err != nil || enabled
+}
+
+// writeScopeError rejects a key that is valid but lacks the endpoint's scope.
+func writeScopeError(w http.ResponseWriter, scope string) {
+	writeError(w, http.StatusForbidden, "API key lacks required scope: "+scope)
+}
+
+func setOf(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
 func extractAPIKey(r *http.Request) string {
 	auth := r.Header.Get("Authorization")
 	if strings.HasPrefix(auth, "Bearer ") {
@@ -544,14 +1912,10 @@ func extractAPIKey(r *http.Request) string {
 	return ""
 }
 
+// writeError writes an error response in the request's error format (see
+// ErrorFormat).
 func writeError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error": map[string]interface{}{
-			"message": message,
-			"type":    "error",
-			"code":    status,
-		},
-	})
+	json.NewEncoder(w).Encode(errorBody(errorFormatOf(w), status, message))
 }
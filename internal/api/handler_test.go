@@ -2,19 +2,37 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/archive"
+	"github.com/felipepmaragno/ai-gateway/internal/budget"
 	"github.com/felipepmaragno/ai-gateway/internal/cache"
+	"github.com/felipepmaragno/ai-gateway/internal/circuitbreaker"
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/httputil"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/provider/openai"
 	"github.com/felipepmaragno/ai-gateway/internal/ratelimit"
 	"github.com/felipepmaragno/ai-gateway/internal/router"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // =============================================================================
@@ -89,6 +107,7 @@ func (m *MockRateLimiter) Allow(ctx context.Context, tenantID string, limit int)
 type MockCache struct {
 	GetFunc func(ctx context.Context, key string) (*domain.ChatResponse, bool)
 	SetFunc func(ctx context.Context, key string, resp *domain.ChatResponse, ttl time.Duration) error
+	DeleteFunc func(ctx context.Context, pattern string) (int, error)
 }
 
 func (m *MockCache) Get(ctx context.Context, key string) (*domain.ChatResponse, bool) {
@@ -105,6 +124,13 @@ func (m *MockCache) Set(ctx context.Context, key string, resp *domain.ChatRespon
 	return nil
 }
 
+func (m *MockCache) Delete(ctx context.Context, pattern string) (int, error) {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(ctx, pattern)
+	}
+	return 0, nil
+}
+
 // MockProvider implements router.Provider for testing
 type MockProvider struct {
 	IDValue                   string
@@ -182,6 +208,14 @@ func (m *MockCostTracker) GetTenantUsage(ctx context.Context, tenantID string, s
 	return nil, nil
 }
 
+func (m *MockCostTracker) GetProviderTotals(ctx context.Context, since, until time.Time) ([]cost.ProviderTotals, error) {
+	return nil, nil
+}
+
+func (m *MockCostTracker) GetTenantTagTotals(ctx context.Context, tenantID, key string, since time.Time) ([]cost.TagTotals, error) {
+	return nil, nil
+}
+
 // =============================================================================
 // Test Helpers
 // =============================================================================
@@ -532,6 +566,63 @@ func TestHandleHealth(t *testing.T) {
 	}
 }
 
+func TestHandleHealth_OptionalProviders(t *testing.T) {
+	healthy := &MockProvider{IDValue: "openai"}
+	down := func(id string) *MockProvider {
+		return &MockProvider{IDValue: id, HealthCheckFunc: func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}}
+	}
+
+	tests := []struct {
+		name         string
+		optional     []string
+		wantStatus   string
+		wantOptional []string
+	}{
+		{"required provider down", nil, "degraded", nil},
+		{"optional provider down", []string{"ollama"}, "healthy", []string{"ollama"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := map[string]router.Provider{
+				"openai": healthy,
+				"ollama": down("ollama"),
+			}
+			handler := NewHandler(HandlerConfig{
+				Router:            router.New(providers, "openai"),
+				OptionalProviders: tt.optional,
+			})
+
+			req := httptest.NewRequest("GET", "/health", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var resp struct {
+				Status            string            `json:"status"`
+				Providers         map[string]string `json:"providers"`
+				OptionalUnhealthy []string          `json:"optional_unhealthy"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if rr.Code != http.StatusOK {
+				t.Errorf("status code = %d, want 200", rr.Code)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", resp.Status, tt.wantStatus)
+			}
+			if resp.Providers["ollama"] != "unhealthy" {
+				t.Errorf("ollama = %q, want it reported unhealthy", resp.Providers["ollama"])
+			}
+			if strings.Join(resp.OptionalUnhealthy, ",") != strings.Join(tt.wantOptional, ",") {
+				t.Errorf("optional_unhealthy = %v, want %v", resp.OptionalUnhealthy, tt.wantOptional)
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Tests for List Models
 // =============================================================================
@@ -592,6 +683,294 @@ func TestHandleListModels(t *testing.T) {
 	}
 }
 
+func TestHandleListModels_Capabilities(t *testing.T) {
+	handler, _, _, _, provider := setupTestHandler(t)
+	provider.ModelsFunc = func(ctx context.Context) ([]domain.Model, error) {
+		return []domain.Model{
+			{ID: "gpt-4o", Object: "model", Capabilities: &domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true, MaxContext: 128000}},
+			{ID: "legacy", Object: "model"},
+		}, nil
+	}
+
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `"capabilities":{"streaming":true,"tools":true,"vision":true,"max_context":128000}`) {
+		t.Errorf("expected capabilities in response, got %s", body)
+	}
+	if strings.Count(body, "capabilities") != 1 {
+		t.Errorf("models without capabilities should omit the field, got %s", body)
+	}
+}
+
+func TestHandleListModels_PrefixModelIDs(t *testing.T) {
+	providers := map[string]router.Provider{
+		"bedrock": &MockProvider{IDValue: "bedrock", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			return []domain.Model{{ID: "claude-3-haiku", Object: "model"}}, nil
+		}},
+	}
+	handler := NewHandler(HandlerConfig{
+		Router:         router.New(providers, "bedrock"),
+		PrefixModelIDs: true,
+	})
+
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp domain.ModelsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].ID != "bedrock/claude-3-haiku" || resp.Data[0].Provider != "bedrock" {
+		t.Errorf("expected prefixed model, got %+v", resp.Data)
+	}
+}
+
+func TestHandleListModels_SupportsFilter(t *testing.T) {
+	providers := map[string]router.Provider{
+		"anthropic": &MockProvider{IDValue: "anthropic", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			return []domain.Model{
+				{ID: "claude-3-opus", Capabilities: &domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true}},
+				{ID: "claude-3-5-haiku", Capabilities: &domain.ModelCapabilities{Streaming: true, Tools: true}},
+			}, nil
+		}},
+		"ollama": &MockProvider{IDValue: "ollama", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			return []domain.Model{{ID: "llama3.2"}}, nil
+		}},
+	}
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			tenant := createTestTenant()
+			tenant.AllowedModels = []string{"claude-3-5-haiku", "llama3.2"}
+			return tenant, nil
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: tenantRepo,
+		Router:     router.New(providers, "anthropic"),
+	})
+
+	tests := []struct {
+		name       string
+		query      string
+		apiKey     bool
+		wantStatus int
+		wantIDs    []string
+	}{
+		{"no filter", "", false, http.StatusOK, []string{"claude-3-opus", "claude-3-5-haiku", "llama3.2"}},
+		{"tools", "?supports=tools", false, http.StatusOK, []string{"claude-3-opus", "claude-3-5-haiku"}},
+		{"tools and vision", "?supports=tools,vision", false, http.StatusOK, []string{"claude-3-opus"}},
+		{"repeated param", "?supports=tools&supports=vision", false, http.StatusOK, []string{"claude-3-opus"}},
+		{"tenant allow-list", "?supports=tools", true, http.StatusOK, []string{"claude-3-5-haiku"}},
+		{"no match", "?supports=vision", true, http.StatusOK, []string{}},
+		{"unknown capability", "?supports=telepathy", false, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/models"+tt.query, nil)
+			if tt.apiKey {
+				req.Header.Set("Authorization", "Bearer sk-test-key")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp domain.ModelsResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var ids []string
+			for _, m := range resp.Data {
+				ids = append(ids, m.ID)
+			}
+			sort.Strings(ids)
+			want := append([]string(nil), tt.wantIDs...)
+			sort.Strings(want)
+			if strings.Join(ids, ",") != strings.Join(want, ",") {
+				t.Errorf("models = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   ErrorFormat
+		accept   string
+		wantBody string
+	}{
+		{"default is openai", "", "", `{"error":{"code":401,"message":"missing API key","type":"error"}}`},
+		{"configured simple", ErrorFormatSimple, "", `{"code":401,"message":"missing API key"}`},
+		{"accept selects simple", ErrorFormatOpenAI, "application/json, application/vnd.aigateway.simple-error+json", `{"code":401,"message":"missing API key"}`},
+		{"accept selects openai", ErrorFormatSimple, "application/vnd.aigateway.openai-error+json;q=0.9", `{"error":{"code":401,"message":"missing API key","type":"error"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:  &MockTenantRepository{},
+				RateLimiter: &MockRateLimiter{},
+				Router:      router.New(map[string]router.Provider{}, ""),
+				ErrorFormat: tt.format,
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want 401", rec.Code)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.wantBody {
+				t.Errorf("body = %s, want %s", got, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("unknown routes use the format", func(t *testing.T) {
+		handler := NewHandler(HandlerConfig{ErrorFormat: ErrorFormatSimple})
+		req := httptest.NewRequest("GET", "/v1/unknown", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		want := `{"code":404,"message":"unknown route GET /v1/unknown"}`
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestCORS(t *testing.T) {
+	newHandler := func(exposeHeaders []string) *Handler {
+		return NewHandler(HandlerConfig{
+			Router:             router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+			CORSAllowedOrigins: []string{"https://app.example.com"},
+			CORSExposeHeaders:  exposeHeaders,
+		})
+	}
+
+	t.Run("cross-origin response exposes gateway headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+		newHandler(nil).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Access-Control-Allow-Origin = %q", got)
+		}
+		want := "X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-Cost-USD"
+		if got := rr.Header().Get("Access-Control-Expose-Headers"); got != want {
+			t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("configured expose list", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rr := httptest.NewRecorder()
+		newHandler([]string{"X-Request-ID"}).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+			t.Errorf("Access-Control-Expose-Headers = %q, want X-Request-ID", got)
+		}
+	})
+
+	t.Run("other origins and same-origin requests get no CORS headers", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.example.com", ""} {
+			req := httptest.NewRequest("GET", "/health", nil)
+			if origin != "" {
+				req.Header.Set("Origin", origin)
+			}
+			rr := httptest.NewRecorder()
+			newHandler(nil).ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+				t.Errorf("origin %q: Access-Control-Allow-Origin = %q, want none", origin, got)
+			}
+			if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "" {
+				t.Errorf("origin %q: Access-Control-Expose-Headers = %q, want none", origin, got)
+			}
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/v1/chat/completions", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		rr := httptest.NewRecorder()
+		newHandler(nil).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want 204", rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "authorization, content-type" {
+			t.Errorf("Access-Control-Allow-Headers = %q", got)
+		}
+	})
+}
+
+func TestUnknownRoutes_ReturnJSONErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"unknown path", "GET", "/v1/does-not-exist", http.StatusNotFound, ""},
+		{"wrong method", "GET", "/v1/chat/completions", http.StatusMethodNotAllowed, "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _, _, _, _ := setupTestHandler(t)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			if tt.wantAllow != "" && !strings.Contains(rr.Header().Get("Allow"), tt.wantAllow) {
+				t.Errorf("Allow = %q, want it to contain %q", rr.Header().Get("Allow"), tt.wantAllow)
+			}
+
+			var body struct {
+				Error struct {
+					Message string `json:"message"`
+					Code    int    `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v (%q)", err, rr.Body.String())
+			}
+			if body.Error.Code != tt.wantStatus {
+				t.Errorf("error.code = %d, want %d", body.Error.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Tests for Usage Endpoint
 // =============================================================================
@@ -683,6 +1062,128 @@ func TestHandleUsage(t *testing.T) {
 	}
 }
 
+func TestHandleUsage_BudgetFields(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			tenant := createTestTenant()
+			tenant.BudgetUSD = 10
+			tenant.BudgetPeriod = domain.BudgetPeriodDaily
+			return tenant, nil
+		},
+	}
+	costTracker := &MockCostTracker{
+		GetTenantTotalCostFunc: func(ctx context.Context, tenantID string, since time.Time) (float64, error) {
+			return 9.7, nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: ratelimit.NewInMemoryRateLimiter(),
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		Cache:       cache.NewInMemoryCache(),
+		CostTracker: costTracker,
+	})
+
+	req := httptest.NewRequest("GET", "/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+
+	if body["period"] != "daily" {
+		t.Errorf("period = %v, want daily", body["period"])
+	}
+	if body["alert_level"] != "critical" {
+		t.Errorf("alert_level = %v, want critical", body["alert_level"])
+	}
+	projected, ok := body["projected_cost_usd"].(float64)
+	if !ok || projected < 9.7 {
+		t.Errorf("projected_cost_usd = %v, want >= total cost", body["projected_cost_usd"])
+	}
+	if _, ok := body["budget_used_pct"]; !ok {
+		t.Error("expected budget_used_pct to be kept")
+	}
+}
+
+func TestHandleUsage_Tags(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{ID: "resp-123", Model: req.Model, Usage: domain.Usage{PromptTokens: 1000, CompletionTokens: 0}}, nil
+		},
+	}
+	tracker := cost.NewInMemoryTracker()
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: ratelimit.NewInMemoryRateLimiter(),
+		Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		CostTracker: tracker,
+	})
+
+	do := func(method, path, tags string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		if tags != "" {
+			req.Header.Set("X-Tags", tags)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	chat, _ := json.Marshal(createChatRequest("gpt-4", false))
+	for _, tags := range []string{"project=search,team=ml", "project=search", "project=chat", ""} {
+		if rr := do("POST", "/v1/chat/completions", tags, chat); rr.Code != http.StatusOK {
+			t.Fatalf("chat with tags %q: status = %d (%s)", tags, rr.Code, rr.Body.String())
+		}
+	}
+
+	if rr := do("POST", "/v1/chat/completions", "project", chat); rr.Code != http.StatusBadRequest {
+		t.Errorf("malformed X-Tags: status = %d, want 400", rr.Code)
+	}
+
+	var usage map[string]interface{}
+	rr := do("GET", "/v1/usage?tag=project:search", "", nil)
+	json.Unmarshal(rr.Body.Bytes(), &usage)
+	if usage["request_count"] != float64(2) {
+		t.Errorf("filtered request_count = %v, want 2", usage["request_count"])
+	}
+	if spent, _ := usage["total_cost_usd"].(float64); math.Abs(spent-0.06) > 1e-9 {
+		t.Errorf("filtered total_cost_usd = %v, want 0.06", usage["total_cost_usd"])
+	}
+
+	var breakdown struct {
+		Breakdown []cost.TagTotals `json:"breakdown"`
+	}
+	rr = do("GET", "/v1/usage/tags?key=project", "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("breakdown status = %d (%s)", rr.Code, rr.Body.String())
+	}
+	json.Unmarshal(rr.Body.Bytes(), &breakdown)
+	if len(breakdown.Breakdown) != 2 || breakdown.Breakdown[0].Value != "chat" || breakdown.Breakdown[1].Requests != 2 {
+		t.Errorf("breakdown = %+v", breakdown.Breakdown)
+	}
+
+	if rr := do("GET", "/v1/usage/tags", "", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("missing key: status = %d, want 400", rr.Code)
+	}
+}
+
 // =============================================================================
 // Tests for Helper Functions
 // =============================================================================
@@ -715,56 +1216,2928 @@ func TestExtractAPIKey(t *testing.T) {
 	}
 }
 
-func TestWriteError(t *testing.T) {
+func TestHandleChatCompletions_QueryOverrides(t *testing.T) {
 	tests := []struct {
-		name       string
-		status     int
-		message    string
-		wantStatus int
+		name         string
+		query        string
+		headers      map[string]string
+		warmCache    bool
+		wantStatus   int
+		wantProvider string
+		wantCacheHit bool
 	}{
-		{"bad request", http.StatusBadRequest, "invalid input", http.StatusBadRequest},
-		{"unauthorized", http.StatusUnauthorized, "missing token", http.StatusUnauthorized},
-		{"internal error", http.StatusInternalServerError, "something went wrong", http.StatusInternalServerError},
+		{name: "provider query", query: "?provider=ollama", wantStatus: http.StatusOK, wantProvider: "ollama"},
+		{name: "provider header wins", query: "?provider=ollama", headers: map[string]string{"X-Provider": "openai"}, wantStatus: http.StatusOK, wantProvider: "openai"},
+		{name: "unknown provider", query: "?provider=nope", wantStatus: http.StatusBadRequest},
+		{name: "cached without override", warmCache: true, wantStatus: http.StatusOK, wantProvider: "cache", wantCacheHit: true},
+		{name: "skip_cache query", query: "?skip_cache=true", warmCache: true, wantStatus: http.StatusOK, wantProvider: "openai"},
+		{name: "skip cache header wins", query: "?skip_cache=true", headers: map[string]string{"X-Skip-Cache": "false"}, warmCache: true, wantStatus: http.StatusOK, wantProvider: "cache", wantCacheHit: true},
+		{name: "invalid skip_cache", query: "?skip_cache=maybe", wantStatus: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			rr := httptest.NewRecorder()
-
-			writeError(rr, tt.status, tt.message)
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{
+					GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+						return createTestTenant(), nil
+					},
+				},
+				RateLimiter: &MockRateLimiter{
+					AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+						return true, 99, time.Now().Add(time.Minute), nil
+					},
+				},
+				Router: router.New(map[string]router.Provider{
+					"openai": &MockProvider{IDValue: "openai"},
+					"ollama": &MockProvider{IDValue: "ollama"},
+				}, "openai"),
+				Cache: cache.NewInMemoryCache(),
+			})
 
-			if rr.Code != tt.wantStatus {
-				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			send := func(query string, headers map[string]string) *httptest.ResponseRecorder {
+				body, _ := json.Marshal(createChatRequest("gpt-4", false))
+				req := httptest.NewRequest("POST", "/v1/chat/completions"+query, bytes.NewReader(body))
+				req.Header.Set("Authorization", "Bearer sk-test-key")
+				for k, v := range headers {
+					req.Header.Set(k, v)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec
 			}
 
-			contentType := rr.Header().Get("Content-Type")
-			if contentType != "application/json" {
-				t.Errorf("Content-Type = %q, want application/json", contentType)
+			if tt.warmCache {
+				if rec := send("", nil); rec.Code != http.StatusOK {
+					t.Fatalf("warm-up status = %d (%s)", rec.Code, rec.Body.String())
+				}
 			}
 
-			var resp map[string]interface{}
-			json.Unmarshal(rr.Body.Bytes(), &resp)
-
-			errObj, ok := resp["error"].(map[string]interface{})
-			if !ok {
-				t.Fatal("response should contain error object")
+			rec := send(tt.query, tt.headers)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
 			}
-
-			if errObj["message"] != tt.message {
-				t.Errorf("error message = %q, want %q", errObj["message"], tt.message)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp domain.ChatResponse
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if resp.Gateway == nil || resp.Gateway.Provider != tt.wantProvider || resp.Gateway.CacheHit != tt.wantCacheHit {
+				t.Errorf("x_gateway = %+v, want provider %s, cache_hit %v", resp.Gateway, tt.wantProvider, tt.wantCacheHit)
 			}
 		})
 	}
 }
 
-// =============================================================================
-// Benchmark Tests
-// =============================================================================
-
-func BenchmarkHandleChatCompletions(b *testing.B) {
-	tenantRepo := &MockTenantRepository{
-		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
-			return createTestTenant(), nil
+func TestHandleChatCompletions_CacheMaxAge(t *testing.T) {
+	calls := 0
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{
+			AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			},
+		},
+		Router: router.New(map[string]router.Provider{
+			"openai": &MockProvider{
+				IDValue: "openai",
+				ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+					calls++
+					return &domain.ChatResponse{ID: "fresh", Model: req.Model}, nil
+				},
+			},
+		}, "openai"),
+		Cache: cache.NewInMemoryCache(),
+	})
+
+	send := func(cacheControl string) string {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		if cacheControl != "" {
+			req.Header.Set("Cache-Control", cacheControl)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+		}
+		return rec.Header().Get("X-Cache")
+	}
+
+	send("")
+	time.Sleep(10 * time.Millisecond)
+
+	tests := []struct {
+		cacheControl string
+		wantHit      bool
+	}{
+		{"", true},
+		{"max-age=3600", true},
+		{"no-transform, max-age=0", false},
+		{"max-age=bogus", true},
+	}
+	for _, tt := range tests {
+		before := calls
+		hit := send(tt.cacheControl) == "HIT"
+		if hit != tt.wantHit {
+			t.Errorf("Cache-Control %q: hit = %v, want %v", tt.cacheControl, hit, tt.wantHit)
+		}
+		if !tt.wantHit && calls != before+1 {
+			t.Errorf("Cache-Control %q: provider calls = %d, want %d", tt.cacheControl, calls, before+1)
+		}
+	}
+}
+
+func TestHandleChatCompletions_AllCircuitsOpen(t *testing.T) {
+	rt := router.New(map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai"},
+		"ollama": &MockProvider{IDValue: "ollama"},
+	}, "openai")
+	for _, id := range []string{"openai", "ollama"} {
+		for i := 0; i < 5; i++ {
+			rt.RecordFailure(id, "")
+		}
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{},
+		Router:      rt,
+	})
+
+	for _, stream := range []bool{false, true} {
+		body, _ := json.Marshal(createChatRequest("gpt-4", stream))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("stream=%v: status = %d, want 503 (%s)", stream, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Retry-After"); got != "30" {
+			t.Errorf("stream=%v: Retry-After = %q, want 30", stream, got)
+		}
+		if !strings.Contains(rec.Body.String(), "all provider circuits are open") {
+			t.Errorf("stream=%v: body = %s", stream, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleChatCompletions_ProviderThrottled(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	providers := map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai"},
+		"ollama": &MockProvider{IDValue: "ollama"},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:      tenantRepo,
+		RateLimiter:     rateLimiter,
+		Router:          router.New(providers, "openai"),
+		ProviderLimiter: ratelimit.NewProviderLimiter(map[string]int{"openai": 1, "ollama": 1}),
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("X-Skip-Cache", "true")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	wantProviders := []string{"openai", "ollama"}
+	for i, want := range wantProviders {
+		rec := send()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200 (%s)", i+1, rec.Code, rec.Body.String())
+		}
+		var resp domain.ChatResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Gateway == nil || resp.Gateway.Provider != want {
+			t.Errorf("request %d: provider = %+v, want %s", i+1, resp.Gateway, want)
+		}
+	}
+
+	rec := send()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 once every provider is throttled", rec.Code)
+	}
+}
+
+func TestHandleChatCompletions_MaxFallbackAttempts(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	calls := 0
+	failing := func(id string) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				calls++
+				return nil, errors.New(id + " unavailable")
+			},
+		}
+	}
+	providers := map[string]router.Provider{
+		"openai":    failing("openai"),
+		"anthropic": failing("anthropic"),
+		"ollama":    failing("ollama"),
+	}
+
+	tests := []struct {
+		name      string
+		max       int
+		wantCalls int
+		wantBody  string
+	}{
+		{"capped", 2, 2, "gave up after 2 provider attempts"},
+		{"unlimited", 0, 3, "all providers failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:          tenantRepo,
+				RateLimiter:         rateLimiter,
+				Router:              router.New(providers, "openai"),
+				MaxFallbackAttempts: tt.max,
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadGateway {
+				t.Errorf("status = %d, want 502", rec.Code)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_PrefixedModel(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var calls []string
+	var sentModel string
+	newProvider := func(id string, fail bool) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				calls = append(calls, id)
+				sentModel = req.Model
+				if fail {
+					return nil, errors.New(id + " unavailable")
+				}
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		model        string
+		hint         string
+		bedrockFails bool
+		wantStatus   int
+		wantCalls    []string
+		wantModel    string
+	}{
+		{"prefix pins provider", "bedrock/claude-3-haiku", "", false, http.StatusOK, []string{"bedrock"}, "claude-3-haiku"},
+		{"unprefixed uses default", "claude-3-haiku", "", false, http.StatusOK, []string{"anthropic"}, "claude-3-haiku"},
+		{"pinned provider does not fall back", "bedrock/claude-3-haiku", "", true, http.StatusBadGateway, []string{"bedrock"}, "claude-3-haiku"},
+		{"matching X-Provider is allowed", "bedrock/claude-3-haiku", "bedrock", false, http.StatusOK, []string{"bedrock"}, "claude-3-haiku"},
+		{"conflicting X-Provider is rejected", "bedrock/claude-3-haiku", "anthropic", false, http.StatusBadRequest, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, sentModel = nil, ""
+			providers := map[string]router.Provider{
+				"bedrock":   newProvider("bedrock", tt.bedrockFails),
+				"anthropic": newProvider("anthropic", false),
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:  tenantRepo,
+				RateLimiter: rateLimiter,
+				Router:      router.New(providers, "anthropic"),
+			})
+
+			body, _ := json.Marshal(createChatRequest(tt.model, false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			if tt.hint != "" {
+				req.Header.Set("X-Provider", tt.hint)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("provider calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if sentModel != tt.wantModel {
+				t.Errorf("provider saw model %q, want %q", sentModel, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_FallbackGetsUnmodifiedRequest(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	mutating := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			req.Stream = true
+			req.Messages[0].Content = "rewritten"
+			*req.MaxTokens = 1
+			req.Metadata["leaked"] = "yes"
+			return nil, errors.New("openai unavailable")
+		},
+	}
+
+	tests := []struct {
+		name    string
+		primary router.Provider
+	}{
+		{"openai provider", openai.New("test-key", upstream.URL)},
+		{"provider that mutates its request", mutating},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got domain.ChatRequest
+			fallback := &MockProvider{
+				IDValue: "anthropic",
+				ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+					got = req
+					return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+				},
+			}
+			providers := map[string]router.Provider{"openai": tt.primary, "anthropic": fallback}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:  tenantRepo,
+				RateLimiter: rateLimiter,
+				Router:      router.New(providers, "openai"),
+			})
+
+			chatReq := createChatRequest("gpt-4", false)
+			maxTokens := 100
+			chatReq.MaxTokens = &maxTokens
+			chatReq.Metadata = map[string]string{"feature": "search"}
+			body, _ := json.Marshal(chatReq)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+			if got.Stream {
+				t.Error("fallback provider saw Stream=true")
+			}
+			if got.Messages[0].Content != chatReq.Messages[0].Content {
+				t.Errorf("fallback saw message %q, want %q", got.Messages[0].Content, chatReq.Messages[0].Content)
+			}
+			if got.MaxTokens == nil || *got.MaxTokens != 100 {
+				t.Errorf("fallback saw max_tokens %v, want 100", got.MaxTokens)
+			}
+			if _, leaked := got.Metadata["leaked"]; leaked {
+				t.Error("fallback saw metadata added by the failed provider")
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_RoutesAroundCappedProvider(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	newProvider := func(id string) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+			ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				chunks := make(chan domain.StreamChunk, 1)
+				errs := make(chan error, 1)
+				chunks <- domain.StreamChunk{ID: id, Object: "chat.completion.chunk", Model: req.Model}
+				close(chunks)
+				return chunks, errs
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		caps       map[string]float64
+		stream     bool
+		wantStatus int
+		wantBody   string
+	}{
+		{"non-streaming skips capped primary", map[string]float64{"openai": 10}, false, http.StatusOK, `"provider":"anthropic"`},
+		{"streaming skips capped primary", map[string]float64{"openai": 10}, true, http.StatusOK, `"id":"anthropic"`},
+		{"all providers capped", map[string]float64{"openai": 10, "anthropic": 10}, false, http.StatusServiceUnavailable, "cost cap reached"},
+		{"streaming with all providers capped", map[string]float64{"openai": 10, "anthropic": 10}, true, http.StatusServiceUnavailable, "cost cap reached"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := cost.NewInMemoryTracker()
+			tracker.Record(context.Background(), cost.UsageRecord{Provider: "openai", CostUSD: 25, Timestamp: time.Now()})
+			tracker.Record(context.Background(), cost.UsageRecord{Provider: "anthropic", CostUSD: 25, Timestamp: time.Now()})
+
+			providers := map[string]router.Provider{
+				"openai":    newProvider("openai"),
+				"anthropic": newProvider("anthropic"),
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:   tenantRepo,
+				RateLimiter:  rateLimiter,
+				Router:       router.New(providers, "openai"),
+				ProviderCaps: budget.NewProviderCaps(tracker, tt.caps),
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", tt.stream))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %q does not contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_RetryableStatuses(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		status     int
+		overrides  map[string][]int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"default retries 503", 503, nil, http.StatusOK, 2},
+		{"default does not retry 400", 400, nil, http.StatusBadGateway, 1},
+		{"override retries 400", 400, map[string][]int{"openai": {400}}, http.StatusOK, 2},
+		{"override stops 503", 503, map[string][]int{"openai": {429}}, http.StatusBadGateway, 1},
+		{"override for other provider keeps defaults", 503, map[string][]int{"anthropic": {429}}, http.StatusOK, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			providers := map[string]router.Provider{
+				"openai": &MockProvider{IDValue: "openai", ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+					calls++
+					return nil, &domain.ProviderStatusError{Provider: "openai", StatusCode: tt.status, Body: "upstream said no"}
+				}},
+				"anthropic": &MockProvider{IDValue: "anthropic", ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+					calls++
+					return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+				}},
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:        tenantRepo,
+				RateLimiter:       rateLimiter,
+				Router:            router.New(providers, "openai"),
+				RetryableStatuses: tt.overrides,
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("provider calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_TenantAllowedProviders(t *testing.T) {
+	tenant := createTestTenant()
+	tenant.AllowedProviders = []string{"bedrock", "anthropic"}
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return tenant, nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var called []string
+	newProvider := func(id string, fail bool) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				called = append(called, id)
+				if fail {
+					return nil, errors.New(id + " unavailable")
+				}
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+		}
+	}
+	providers := map[string]router.Provider{
+		"anthropic": newProvider("anthropic", true),
+		"bedrock":   newProvider("bedrock", false),
+		"ollama":    newProvider("ollama", false),
+		"openai":    newProvider("openai", false),
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(providers, "openai"),
+	})
+
+	tests := []struct {
+		name       string
+		header     string
+		model      string
+		wantStatus int
+		wantCalled []string
+	}{
+		{"routes and falls back within the set", "", "gpt-4", http.StatusOK, []string{"anthropic", "bedrock"}},
+		{"allowed hint", "bedrock", "gpt-4", http.StatusOK, []string{"bedrock"}},
+		{"hint outside the set", "openai", "gpt-4", http.StatusForbidden, nil},
+		{"prefix outside the set", "", "ollama/llama3", http.StatusForbidden, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = nil
+			body, _ := json.Marshal(createChatRequest(tt.model, false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			if tt.header != "" {
+				req.Header.Set("X-Provider", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !reflect.DeepEqual(called, tt.wantCalled) {
+				t.Errorf("called providers = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_PreloadedCacheHit(t *testing.T) {
+	responseCache := cache.NewInMemoryCache()
+	preload := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "Hello, world!"}]}, "response": {"id": "faq-1", "object": "chat.completion", "model": "gpt-4", "choices": [{"message": {"role": "assistant", "content": "Hi from the cache"}}]}}`
+	if _, err := cache.Preload(context.Background(), responseCache, strings.NewReader(preload), time.Minute); err != nil {
+		t.Fatalf("Preload() error = %v", err)
+	}
+
+	provider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			t.Error("provider called for a preloaded request")
+			return nil, errors.New("unexpected call")
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{
+			AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			},
+		},
+		Router: router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		Cache:  responseCache,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("X-Cache = %q, want HIT", rec.Header().Get("X-Cache"))
+	}
+	var resp domain.ChatResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.ID != "faq-1" || resp.Choices[0].Message.Content != "Hi from the cache" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleChatCompletions_GatewayMeta(t *testing.T) {
+	provider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+		},
+		ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+			chunks := make(chan domain.StreamChunk, 1)
+			errs := make(chan error, 1)
+			chunks <- domain.StreamChunk{ID: "chunk", Object: "chat.completion.chunk", Model: req.Model}
+			close(chunks)
+			return chunks, errs
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{
+			AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			},
+		},
+		Router: router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		Cache:  cache.NewInMemoryCache(),
+	})
+
+	send := func(stream bool, meta string) string {
+		body, _ := json.Marshal(createChatRequest("gpt-4", stream))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		if meta != "" {
+			req.Header.Set("X-Gateway-Meta", meta)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d (%s)", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	// The first call misses the cache; the empty trace ID and unset flags
+	// are omitted rather than sent as empty values.
+	var resp map[string]json.RawMessage
+	json.Unmarshal([]byte(send(false, "")), &resp)
+	var meta map[string]any
+	if err := json.Unmarshal(resp["x_gateway"], &meta); err != nil {
+		t.Fatalf("x_gateway missing by default: %v", err)
+	}
+	if meta["provider"] != "openai" || meta["request_id"] == nil {
+		t.Errorf("x_gateway = %v, want provider and request_id", meta)
+	}
+	for _, field := range []string{"trace_id", "cache_hit", "fallback"} {
+		if _, ok := meta[field]; ok {
+			t.Errorf("x_gateway has empty field %q: %v", field, meta)
+		}
+	}
+
+	// The second call is a cache hit.
+	if body := send(false, "false"); strings.Contains(body, "x_gateway") {
+		t.Errorf("cached response with X-Gateway-Meta: false has metadata: %s", body)
+	}
+	if body := send(false, "true"); !strings.Contains(body, `"cache_hit":true`) || !strings.Contains(body, "cost_usd") {
+		t.Errorf("cache hit with X-Gateway-Meta: true should keep metadata with the stored usage's cost: %s", body)
+	}
+
+	if body := send(true, ""); !strings.Contains(body, "x_gateway") {
+		t.Errorf("stream missing x_gateway event by default: %s", body)
+	}
+	if body := send(true, "false"); strings.Contains(body, "x_gateway") {
+		t.Errorf("stream with X-Gateway-Meta: false has metadata: %s", body)
+	}
+}
+
+func TestHandleChatCompletions_ProviderChain(t *testing.T) {
+	tenant := createTestTenant()
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return tenant, nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var called []string
+	failing := map[string]bool{"ollama": true}
+	newProvider := func(id string) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				called = append(called, id)
+				if failing[id] {
+					return nil, errors.New(id + " unavailable")
+				}
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+		}
+	}
+	providers := map[string]router.Provider{
+		"anthropic": newProvider("anthropic"),
+		"ollama":    newProvider("ollama"),
+		"openai":    newProvider("openai"),
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(providers, "openai"),
+	})
+
+	tests := []struct {
+		name       string
+		chain      string
+		provider   string
+		model      string
+		allowed    []string
+		wantStatus int
+		wantCalled []string
+	}{
+		{"chain overrides routing", "ollama, anthropic", "", "gpt-4", nil, http.StatusOK, []string{"ollama", "anthropic"}},
+		{"chain is not extended with other providers", "ollama", "", "gpt-4", nil, http.StatusBadGateway, []string{"ollama"}},
+		{"unknown provider", "anthropic,cohere", "", "gpt-4", nil, http.StatusBadRequest, nil},
+		{"duplicate provider", "anthropic,anthropic", "", "gpt-4", nil, http.StatusBadRequest, nil},
+		{"empty entry", "anthropic,,openai", "", "gpt-4", nil, http.StatusBadRequest, nil},
+		{"combined with X-Provider", "anthropic", "openai", "gpt-4", nil, http.StatusBadRequest, nil},
+		{"combined with prefixed model", "anthropic", "", "openai/gpt-4", nil, http.StatusBadRequest, nil},
+		{"provider outside tenant set", "openai,anthropic", "", "gpt-4", []string{"anthropic"}, http.StatusForbidden, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = nil
+			tenant.AllowedProviders = tt.allowed
+			body, _ := json.Marshal(createChatRequest(tt.model, false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			req.Header.Set("X-Provider-Chain", tt.chain)
+			if tt.provider != "" {
+				req.Header.Set("X-Provider", tt.provider)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if !reflect.DeepEqual(called, tt.wantCalled) {
+				t.Errorf("called providers = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	tenants := map[string]*domain.Tenant{
+		"sk-full":   createTestTenant(),
+		"sk-usage":  {ID: "usage-only", Enabled: true, RateLimitRPM: 100, Scopes: []string{domain.ScopeUsageRead}},
+		"sk-models": {ID: "models-only", Enabled: true, RateLimitRPM: 100, Scopes: []string{domain.ScopeModelsRead}},
+	}
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			if tenant, ok := tenants[apiKey]; ok {
+				return tenant, nil
+			}
+			return nil, domain.ErrTenantNotFound
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	provider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		CostTracker: cost.NewInMemoryTracker(),
+	})
+
+	chatBody, _ := json.Marshal(createChatRequest("gpt-4", false))
+	tests := []struct {
+		key        string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"sk-full", "POST", "/v1/chat/completions", http.StatusOK},
+		{"sk-full", "GET", "/v1/usage", http.StatusOK},
+		{"sk-full", "GET", "/v1/models", http.StatusOK},
+		{"sk-usage", "POST", "/v1/chat/completions", http.StatusForbidden},
+		{"sk-usage", "GET", "/v1/usage", http.StatusOK},
+		{"sk-usage", "GET", "/v1/usage/tags?key=project", http.StatusOK},
+		{"sk-usage", "GET", "/v1/models", http.StatusForbidden},
+		{"sk-models", "POST", "/v1/chat/completions", http.StatusForbidden},
+		{"sk-models", "GET", "/v1/usage", http.StatusForbidden},
+		{"sk-models", "GET", "/v1/usage/tags?key=project", http.StatusForbidden},
+		{"sk-models", "GET", "/v1/models", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(chatBody))
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(rr.Body.String(), "lacks required scope") {
+				t.Errorf("body = %s, want scope error", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_GatewayReportsFallback(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	newProvider := func(id string, fail bool) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				if fail {
+					return nil, errors.New(id + " unavailable")
+				}
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		failing      map[string]bool
+		wantProvider string
+		wantAttempts int
+		wantFallback bool
+		wantRetried  []string
+	}{
+		{"primary succeeds", nil, "openai", 1, false, nil},
+		{"two fallbacks", map[string]bool{"openai": true, "anthropic": true}, "ollama", 3, true, []string{"openai", "anthropic"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := map[string]router.Provider{
+				"openai":    newProvider("openai", tt.failing["openai"]),
+				"anthropic": newProvider("anthropic", tt.failing["anthropic"]),
+				"ollama":    newProvider("ollama", false),
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:  tenantRepo,
+				RateLimiter: rateLimiter,
+				Router:      router.New(providers, "openai"),
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var resp domain.ChatResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			gw := resp.Gateway
+			if gw == nil {
+				t.Fatal("expected gateway metadata")
+			}
+			if gw.Provider != tt.wantProvider || gw.Attempts != tt.wantAttempts || gw.Fallback != tt.wantFallback {
+				t.Errorf("gateway = %+v, want provider=%s attempts=%d fallback=%v", gw, tt.wantProvider, tt.wantAttempts, tt.wantFallback)
+			}
+			if strings.Join(gw.RetriedProviders, ",") != strings.Join(tt.wantRetried, ",") {
+				t.Errorf("retried_providers = %v, want %v", gw.RetriedProviders, tt.wantRetried)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_RecordsUsageAfterClientCancel(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	defer cancelReq()
+
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			// Client goes away right after the provider answers.
+			cancelReq()
+			return &domain.ChatResponse{
+				ID:     "resp-123",
+				Object: "chat.completion",
+				Model:  req.Model,
+				Usage:  domain.Usage{PromptTokens: 10, CompletionTokens: 20},
+			}, nil
+		},
+	}
+
+	var recorded []cost.UsageRecord
+	costTracker := &MockCostTracker{
+		RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			recorded = append(recorded, record)
+			return nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		CostTracker: costTracker,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body)).WithContext(reqCtx)
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if len(recorded) != 1 {
+		t.Fatalf("expected usage to be recorded once after cancel, got %d", len(recorded))
+	}
+	if recorded[0].InputTokens != 10 || recorded[0].OutputTokens != 20 {
+		t.Errorf("unexpected usage record: %+v", recorded[0])
+	}
+}
+
+func TestHandleChatCompletions_EstimatesZeroUsage(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{
+				ID:      "resp-123",
+				Object:  "chat.completion",
+				Model:   req.Model,
+				Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "Hi there, how can I help?"}}},
+			}, nil
+		},
+	}
+
+	var recorded []cost.UsageRecord
+	costTracker := &MockCostTracker{
+		RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			recorded = append(recorded, record)
+			return nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		CostTracker: costTracker,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("expected one usage record, got %d", len(recorded))
+	}
+	// "Hello, world!" is 13 chars and the reply 25, at 4 chars per token.
+	if recorded[0].InputTokens != 4 || recorded[0].OutputTokens != 7 {
+		t.Errorf("expected estimated usage 4/7, got %d/%d", recorded[0].InputTokens, recorded[0].OutputTokens)
+	}
+	if recorded[0].CostUSD <= 0 {
+		t.Errorf("expected non-zero cost from estimated usage, got %f", recorded[0].CostUSD)
+	}
+}
+
+func TestHandleChatCompletions_MissingUsageWithoutEstimate(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{
+				ID:      "resp-123",
+				Object:  "chat.completion",
+				Model:   req.Model,
+				Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "Hi there"}}},
+			}, nil
+		},
+	}
+
+	var recorded []cost.UsageRecord
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		CostTracker: &MockCostTracker{
+			RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+				recorded = append(recorded, record)
+				return nil
+			},
+		},
+		DisableUsageEstimate: true,
+	})
+
+	missing := metrics.MissingUsage.WithLabelValues("openai", "missing-usage-model")
+	before := testutil.ToFloat64(missing)
+
+	body, _ := json.Marshal(createChatRequest("missing-usage-model", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := testutil.ToFloat64(missing) - before; got != 1 {
+		t.Errorf("missing usage metric increased by %v, want 1", got)
+	}
+	if len(recorded) != 1 || recorded[0].InputTokens != 0 || recorded[0].OutputTokens != 0 {
+		t.Errorf("expected usage recorded as reported (zero), got %+v", recorded)
+	}
+}
+
+func TestHandleChatCompletions_RecordsMetadata(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var recorded []cost.UsageRecord
+	costTracker := &MockCostTracker{
+		RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			recorded = append(recorded, record)
+			return nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		CostTracker: costTracker,
+	})
+
+	chatReq := createChatRequest("gpt-4", false)
+	chatReq.Metadata = map[string]string{"feature": "search"}
+	body, _ := json.Marshal(chatReq)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if len(recorded) != 1 {
+		t.Fatalf("expected one usage record, got %d", len(recorded))
+	}
+	if recorded[0].Metadata["feature"] != "search" {
+		t.Errorf("expected metadata on usage record, got %v", recorded[0].Metadata)
+	}
+}
+
+func TestHandleChatCompletions_TenantPricingOverride(t *testing.T) {
+	tenant := createTestTenant()
+	tenant.PricingOverrides = map[string]domain.ModelPrice{
+		"gpt-4": {InputPer1K: 0.015, OutputPer1K: 0.03},
+	}
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return tenant, nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{ID: "resp-123", Model: req.Model, Usage: domain.Usage{PromptTokens: 1000, CompletionTokens: 1000}}, nil
+		},
+	}
+
+	var recorded []cost.UsageRecord
+	costTracker := &MockCostTracker{
+		RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			recorded = append(recorded, record)
+			return nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		CostTracker: costTracker,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if len(recorded) != 1 {
+		t.Fatalf("expected one usage record, got %d", len(recorded))
+	}
+	// List price would be 0.03 + 0.06.
+	if want := 0.015 + 0.03; math.Abs(recorded[0].CostUSD-want) > 1e-9 {
+		t.Errorf("expected discounted cost %f, got %f", want, recorded[0].CostUSD)
+	}
+}
+
+func TestHandleChatCompletions_ForwardHeaders(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var outbound http.Header
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			httpReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://provider.local", nil)
+			httputil.ApplyForwardedHeaders(httpReq)
+			outbound = httpReq.Header
+			return &domain.ChatResponse{ID: "resp-123", Model: req.Model, Usage: domain.Usage{PromptTokens: 1, CompletionTokens: 1}}, nil
+		},
+	}
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:     tenantRepo,
+		RateLimiter:    rateLimiter,
+		Router:         router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+		ForwardHeaders: []string{"X-Session-ID", "Authorization"},
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	req.Header.Set("X-Session-ID", "sess-42")
+	req.Header.Set("X-Not-Allowed", "secret")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if outbound.Get("X-Session-ID") != "sess-42" {
+		t.Errorf("expected X-Session-ID to be forwarded, got %q", outbound.Get("X-Session-ID"))
+	}
+	if outbound.Get("X-Not-Allowed") != "" {
+		t.Error("expected header outside the allow-list not to be forwarded")
+	}
+	if outbound.Get("Authorization") != "" {
+		t.Error("expected Authorization never to be forwarded")
+	}
+}
+
+func TestHandleChatCompletions_TenantTransformRules(t *testing.T) {
+	handler, repo, rl, c, p := setupTestHandler(t)
+
+	maxTemp := 1.0
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		tenant := createTestTenant()
+		tenant.TransformRules = []domain.TransformRule{
+			{Type: "rename_model", From: "gpt-4", To: "gpt-4o-mini"},
+			{Type: "clamp_param", Field: "temperature", Max: &maxTemp},
+			{Type: "rename_model", Stage: "response", From: "gpt-4o-mini", To: "gpt-4"},
+		}
+		return tenant, nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+	c.GetFunc = func(ctx context.Context, key string) (*domain.ChatResponse, bool) {
+		return nil, false
+	}
+
+	var providerReq domain.ChatRequest
+	p.ChatCompletionFunc = func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+		providerReq = req
+		return &domain.ChatResponse{ID: "resp-123", Object: "chat.completion", Model: req.Model}, nil
+	}
+
+	chatReq := createChatRequest("gpt-4", false)
+	temp := 1.7
+	chatReq.Temperature = &temp
+	body, _ := json.Marshal(chatReq)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if providerReq.Model != "gpt-4o-mini" {
+		t.Errorf("provider model = %q, want %q", providerReq.Model, "gpt-4o-mini")
+	}
+	if providerReq.Temperature == nil || *providerReq.Temperature != 1.0 {
+		t.Errorf("provider temperature = %v, want 1.0", providerReq.Temperature)
+	}
+
+	var resp domain.ChatResponse
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.Model != "gpt-4" {
+		t.Errorf("response model = %q, want %q", resp.Model, "gpt-4")
+	}
+}
+
+// histogramSample returns the count and sum of a histogram series by model.
+func histogramSample(t *testing.T, name, model string) (uint64, float64) {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "model" && l.GetValue() == model {
+					return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+				}
+			}
+		}
+	}
+	return 0, 0
+}
+
+func TestHandleChatCompletions_PayloadSizeMetrics(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		model := "size-test-sync"
+		if stream {
+			model = "size-test-stream"
+		}
+		t.Run(model, func(t *testing.T) {
+			handler, repo, rl, _, p := setupTestHandler(t)
+			repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			}
+			rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			}
+			p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				chunks := make(chan domain.StreamChunk, 3)
+				errs := make(chan error, 1)
+				for i := 0; i < 3; i++ {
+					chunks <- domain.StreamChunk{ID: "chunk", Object: "chat.completion.chunk", Model: req.Model,
+						Choices: []domain.Choice{{Delta: &domain.Delta{Content: "hello"}}}}
+				}
+				close(chunks)
+				close(errs)
+				return chunks, errs
+			}
+
+			body, _ := json.Marshal(createChatRequest(model, stream))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			req.Header.Set("X-Skip-Cache", "true")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+
+			count, sum := histogramSample(t, "aigateway_request_bytes", model)
+			if count != 1 || sum != float64(len(body)) {
+				t.Errorf("request bytes count=%d sum=%v, want 1 observation of %d", count, sum, len(body))
+			}
+			count, sum = histogramSample(t, "aigateway_response_bytes", model)
+			if count != 1 || sum != float64(rec.Body.Len()) {
+				t.Errorf("response bytes count=%d sum=%v, want 1 observation of %d", count, sum, rec.Body.Len())
+			}
+			if stream && strings.Count(rec.Body.String(), "hello") != 3 {
+				t.Errorf("expected all chunks streamed, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_StreamIncludeUsage(t *testing.T) {
+	tests := []struct {
+		name         string
+		includeUsage bool
+		wantUsage    bool
+	}{
+		{"requested", true, true},
+		{"not requested", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repo, rl, _, p := setupTestHandler(t)
+
+			repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			}
+			rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			}
+			p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				chunks := make(chan domain.StreamChunk, 2)
+				errs := make(chan error, 1)
+				for _, text := range []string{"Hello", " there, how are you?"} {
+					chunks <- domain.StreamChunk{
+						ID: "chatcmpl-1", Object: "chat.completion.chunk", Created: 1700000000, Model: req.Model,
+						Choices: []domain.Choice{{Delta: &domain.Delta{Content: text}}},
+					}
+				}
+				close(chunks)
+				return chunks, errs
+			}
+
+			chatReq := createChatRequest("gpt-4", true)
+			if tt.includeUsage {
+				chatReq.StreamOptions = &domain.StreamOptions{IncludeUsage: true}
+			}
+			body, _ := json.Marshal(chatReq)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			var usageChunk *domain.StreamChunk
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				data, ok := strings.CutPrefix(line, "data: ")
+				if !ok || data == "[DONE]" {
+					continue
+				}
+				var chunk domain.StreamChunk
+				if err := json.Unmarshal([]byte(data), &chunk); err == nil && chunk.Usage != nil {
+					usageChunk = &chunk
+				}
+			}
+
+			if !tt.wantUsage {
+				if usageChunk != nil {
+					t.Fatalf("unexpected usage chunk: %+v", usageChunk)
+				}
+				return
+			}
+			if usageChunk == nil {
+				t.Fatalf("expected a usage chunk, got %q", rec.Body.String())
+			}
+			if len(usageChunk.Choices) != 0 {
+				t.Errorf("expected no choices on usage chunk, got %d", len(usageChunk.Choices))
+			}
+			if usageChunk.ID != "chatcmpl-1" || usageChunk.Created != 1700000000 {
+				t.Errorf("usage chunk id/created = %s/%d", usageChunk.ID, usageChunk.Created)
+			}
+			u := usageChunk.Usage
+			if u.PromptTokens == 0 || u.CompletionTokens == 0 || u.TotalTokens != u.PromptTokens+u.CompletionTokens {
+				t.Errorf("unexpected usage: %+v", u)
+			}
+			if !strings.Contains(rec.Body.String(), `"choices":[],"usage"`) {
+				t.Errorf("expected choices to serialize as an empty array")
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_RedactsOutput(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			handler, repo, rl, _, p := setupTestHandler(t)
+
+			repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				tenant := createTestTenant()
+				tenant.TransformRules = []domain.TransformRule{
+					{Type: "redact", Stage: "response", Pattern: `sk-[A-Za-z0-9]{8,}`},
+				}
+				return tenant, nil
+			}
+			rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			}
+			p.ChatCompletionFunc = func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{
+					{Message: &domain.Message{Role: "assistant", Content: "use sk-abcdef123456 to log in"}},
+				}}, nil
+			}
+			p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				chunks := make(chan domain.StreamChunk, 3)
+				errs := make(chan error, 1)
+				for _, text := range []string{"use sk-abc", "def123456", " to log in"} {
+					chunks <- domain.StreamChunk{ID: "resp", Object: "chat.completion.chunk", Model: req.Model,
+						Choices: []domain.Choice{{Delta: &domain.Delta{Content: text}}}}
+				}
+				close(chunks)
+				return chunks, errs
+			}
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", stream))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			out := rec.Body.String()
+			if strings.Contains(out, "sk-abc") || strings.Contains(out, "def123456") {
+				t.Fatalf("secret leaked: %q", out)
+			}
+			if !strings.Contains(out, "[REDACTED]") {
+				t.Errorf("expected redaction marker, got %q", out)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_StreamRoleDelta(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []domain.Delta
+		want   []domain.Delta
+	}{
+		{
+			name:   "role synthesized for providers that omit it",
+			deltas: []domain.Delta{{Content: "Hello"}, {Content: " world"}},
+			want:   []domain.Delta{{Role: "assistant"}, {Content: "Hello"}, {Content: " world"}},
+		},
+		{
+			name:   "provider role kept on first chunk only",
+			deltas: []domain.Delta{{Role: "assistant", Content: "Hello"}, {Role: "assistant", Content: " world"}},
+			want:   []domain.Delta{{Role: "assistant", Content: "Hello"}, {Content: " world"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repo, _, _, p := setupTestHandler(t)
+			repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			}
+			p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				chunks := make(chan domain.StreamChunk, len(tt.deltas))
+				errs := make(chan error, 1)
+				for _, d := range tt.deltas {
+					d := d
+					chunks <- domain.StreamChunk{ID: "chatcmpl-1", Object: "chat.completion.chunk", Model: req.Model,
+						Choices: []domain.Choice{{Delta: &d}}}
+				}
+				close(chunks)
+				return chunks, errs
+			}
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", true))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			var got []domain.Delta
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				var chunk domain.StreamChunk
+				data, ok := strings.CutPrefix(line, "data: ")
+				if !ok || json.Unmarshal([]byte(data), &chunk) != nil || len(chunk.Choices) == 0 {
+					continue
+				}
+				got = append(got, *chunk.Choices[0].Delta)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deltas = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_StreamEventIDs(t *testing.T) {
+	handler, repo, rl, _, p := setupTestHandler(t)
+	handler.sseRetry = 2500 * time.Millisecond
+
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		return createTestTenant(), nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+	p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+		chunks := make(chan domain.StreamChunk, 2)
+		errs := make(chan error, 1)
+		for _, text := range []string{"Hello", " world"} {
+			chunks <- domain.StreamChunk{ID: "chatcmpl-1", Object: "chat.completion.chunk", Model: req.Model,
+				Choices: []domain.Choice{{Delta: &domain.Delta{Content: text}}}}
+		}
+		close(chunks)
+		return chunks, errs
+	}
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", true))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	frames := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	if frames[0] != "retry: 2500" {
+		t.Fatalf("first frame = %q, want retry: 2500", frames[0])
+	}
+
+	// The role announcement, two chunks, the gateway metadata and [DONE].
+	events := frames[1:]
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %d: %q", len(events), rec.Body.String())
+	}
+	for i, frame := range events {
+		id, data, ok := strings.Cut(frame, "\n")
+		if want := fmt.Sprintf("id: %d", i+1); !ok || id != want {
+			t.Errorf("event %d id line = %q, want %q", i, id, want)
+		}
+		if !strings.HasPrefix(data, "data: ") {
+			t.Errorf("event %d data line = %q", i, data)
+		}
+	}
+	if !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("expected stream to end with [DONE], got %q", rec.Body.String())
+	}
+
+	handler.sseRetry = 0
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	handler.ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "retry:") {
+		t.Errorf("retry field sent with SSERetry unset: %q", rec.Body.String())
+	}
+}
+
+func TestHandleChatCompletions_StreamRateLimited(t *testing.T) {
+	tests := []struct {
+		name           string
+		retryAfter     time.Duration
+		wantHeader     string
+		wantRetryAfter float64
+	}{
+		{"with retry-after", 1500 * time.Millisecond, "2", 2},
+		{"without retry-after", 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, repo, rl, _, p := setupTestHandler(t)
+			repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			}
+			rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				return true, 99, time.Now().Add(time.Minute), nil
+			}
+			p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				errs := make(chan error, 1)
+				errs <- &domain.ProviderStatusError{Provider: "openai", StatusCode: http.StatusTooManyRequests, RetryAfter: tt.retryAfter}
+				return make(chan domain.StreamChunk), errs
+			}
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", true))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Retry-After"); got != tt.wantHeader {
+				t.Errorf("Retry-After header = %q, want %q", got, tt.wantHeader)
+			}
+
+			frame, _, _ := strings.Cut(rec.Body.String(), "\n\n")
+			_, data, _ := strings.Cut(frame, "data: ")
+			var event struct {
+				Error struct {
+					Code       int     `json:"code"`
+					RetryAfter float64 `json:"retry_after"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("first event %q: %v", frame, err)
+			}
+			if event.Error.Code != http.StatusTooManyRequests || event.Error.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("error frame = %+v, want code 429 retry_after %v", event.Error, tt.wantRetryAfter)
+			}
+			if !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+				t.Errorf("expected stream to end with [DONE], got %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_MaxStreamDuration(t *testing.T) {
+	handler, repo, rl, _, p := setupTestHandler(t)
+	handler.maxStreamDur = 50 * time.Millisecond
+
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		return createTestTenant(), nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+
+	providerDone := make(chan struct{})
+	p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+		chunks := make(chan domain.StreamChunk)
+		errs := make(chan error, 1)
+		go func() {
+			defer close(providerDone)
+			defer close(chunks)
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case chunks <- domain.StreamChunk{ID: "chunk", Object: "chat.completion.chunk", Model: req.Model}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return chunks, errs
+	}
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", true))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(rec, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream was not cut off at max duration")
+	}
+
+	select {
+	case <-providerDone:
+	case <-time.After(time.Second):
+		t.Fatal("provider goroutine was not canceled")
+	}
+
+	out := rec.Body.String()
+	if !strings.Contains(out, "stream exceeded maximum duration") {
+		t.Errorf("expected SSE error frame, got %q", out)
+	}
+	if !strings.HasSuffix(out, "data: [DONE]\n\n") {
+		t.Errorf("expected stream to end with [DONE], got %q", out)
+	}
+}
+
+func TestHandleChatCompletions_StreamOutlivesWriteTimeout(t *testing.T) {
+	handler, repo, rl, _, p := setupTestHandler(t)
+	handler.maxStreamDur = time.Minute
+
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		return createTestTenant(), nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+
+	p.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+		chunks := make(chan domain.StreamChunk)
+		errs := make(chan error, 1)
+		go func() {
+			defer close(chunks)
+			for i := 0; i < 6; i++ {
+				time.Sleep(50 * time.Millisecond)
+				chunks <- domain.StreamChunk{ID: "chunk", Object: "chat.completion.chunk", Model: req.Model}
+			}
+		}()
+		return chunks, errs
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", true))
+	req, _ := http.NewRequest("POST", server.URL+"/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("stream was cut off after %d bytes: %v", len(out), err)
+	}
+	if !strings.HasSuffix(string(out), "data: [DONE]\n\n") {
+		t.Errorf("expected stream to complete with [DONE], got %q", out)
+	}
+}
+
+func TestApplyDefaultSystemPrompt(t *testing.T) {
+	prompts := map[string]string{"llama3": "Answer in Markdown."}
+
+	tests := []struct {
+		name         string
+		model        string
+		messages     []domain.Message
+		wantMessages int
+		wantSystem   string
+	}{
+		{
+			name:         "injects when absent",
+			model:        "llama3",
+			messages:     []domain.Message{{Role: "user", Content: "hi"}},
+			wantMessages: 2,
+			wantSystem:   "Answer in Markdown.",
+		},
+		{
+			name:  "keeps client system message",
+			model: "llama3",
+			messages: []domain.Message{
+				{Role: "system", Content: "Be terse."},
+				{Role: "user", Content: "hi"},
+			},
+			wantMessages: 2,
+			wantSystem:   "Be terse.",
+		},
+		{
+			name:         "no default for model",
+			model:        "gpt-4",
+			messages:     []domain.Message{{Role: "user", Content: "hi"}},
+			wantMessages: 1,
+			wantSystem:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := domain.ChatRequest{Model: tt.model, Messages: tt.messages}
+			applyDefaultSystemPrompt(&req, tt.model, prompts)
+
+			if len(req.Messages) != tt.wantMessages {
+				t.Fatalf("len(Messages) = %d, want %d", len(req.Messages), tt.wantMessages)
+			}
+			var gotSystem string
+			if req.Messages[0].Role == "system" {
+				gotSystem = req.Messages[0].Content
+			}
+			if gotSystem != tt.wantSystem {
+				t.Errorf("system prompt = %q, want %q", gotSystem, tt.wantSystem)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_DefaultSystemPromptBeforeCacheKey(t *testing.T) {
+	handler, repo, rl, c, p := setupTestHandler(t)
+	handler.systemPrompts = map[string]string{"gpt-4": "Answer in Markdown."}
+
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		return createTestTenant(), nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+
+	var cacheKey string
+	c.GetFunc = func(ctx context.Context, key string) (*domain.ChatResponse, bool) {
+		cacheKey = key
+		return nil, false
+	}
+
+	var providerReq domain.ChatRequest
+	p.ChatCompletionFunc = func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+		providerReq = req
+		return &domain.ChatResponse{ID: "resp-123", Object: "chat.completion", Model: req.Model}, nil
+	}
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if len(providerReq.Messages) != 2 || providerReq.Messages[0].Role != "system" {
+		t.Errorf("expected injected system message, got %+v", providerReq.Messages)
+	}
+
+	withPrompt := createChatRequest("gpt-4", false)
+	applyDefaultSystemPrompt(&withPrompt, withPrompt.Model, handler.systemPrompts)
+	if cacheKey != cache.GenerateCacheKey(withPrompt) {
+		t.Error("cache key should be generated after system prompt injection")
+	}
+}
+
+func TestApplySamplingDefaults(t *testing.T) {
+	temp, topP, maxTokens := 0.2, 0.9, 256
+	defaults := &domain.SamplingDefaults{Temperature: &temp, TopP: &topP, MaxTokens: &maxTokens}
+
+	clientTemp, clientMax := 0.0, 1024
+	req := domain.ChatRequest{Temperature: &clientTemp, MaxTokens: &clientMax}
+	applySamplingDefaults(&req, defaults)
+
+	if *req.Temperature != 0 {
+		t.Errorf("temperature = %v, want client's 0", *req.Temperature)
+	}
+	if *req.MaxTokens != 1024 {
+		t.Errorf("max_tokens = %v, want client's 1024", *req.MaxTokens)
+	}
+	if req.TopP == nil || *req.TopP != 0.9 {
+		t.Errorf("top_p = %v, want default 0.9", req.TopP)
+	}
+	if req.TopP == defaults.TopP {
+		t.Error("request shares the tenant's top_p pointer")
+	}
+
+	req = domain.ChatRequest{}
+	applySamplingDefaults(&req, nil)
+	if req.Temperature != nil || req.TopP != nil || req.MaxTokens != nil {
+		t.Errorf("nil defaults changed the request: %+v", req)
+	}
+}
+
+func TestHandleChatCompletions_SamplingDefaultsBeforeCacheKey(t *testing.T) {
+	handler, repo, rl, c, p := setupTestHandler(t)
+
+	temp := 0.2
+	repo.GetByAPIKeyFunc = func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+		tenant := createTestTenant()
+		tenant.SamplingDefaults = &domain.SamplingDefaults{Temperature: &temp}
+		return tenant, nil
+	}
+	rl.AllowFunc = func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+		return true, 99, time.Now().Add(time.Minute), nil
+	}
+
+	var cacheKey string
+	c.GetFunc = func(ctx context.Context, key string) (*domain.ChatResponse, bool) {
+		cacheKey = key
+		return nil, false
+	}
+
+	var providerReq domain.ChatRequest
+	p.ChatCompletionFunc = func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+		providerReq = req
+		return &domain.ChatResponse{ID: "resp-123", Object: "chat.completion", Model: req.Model}, nil
+	}
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if providerReq.Temperature == nil || *providerReq.Temperature != 0.2 {
+		t.Errorf("provider temperature = %v, want tenant default 0.2", providerReq.Temperature)
+	}
+
+	explicit := createChatRequest("gpt-4", false)
+	explicit.Temperature = &temp
+	if cacheKey != cache.GenerateCacheKey(explicit) {
+		t.Error("cache key should be generated after sampling defaults are applied")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		message    string
+		wantStatus int
+	}{
+		{"bad request", http.StatusBadRequest, "invalid input", http.StatusBadRequest},
+		{"unauthorized", http.StatusUnauthorized, "missing token", http.StatusUnauthorized},
+		{"internal error", http.StatusInternalServerError, "something went wrong", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			writeError(rr, tt.status, tt.message)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+
+			contentType := rr.Header().Get("Content-Type")
+			if contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", contentType)
+			}
+
+			var resp map[string]interface{}
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+
+			errObj, ok := resp["error"].(map[string]interface{})
+			if !ok {
+				t.Fatal("response should contain error object")
+			}
+
+			if errObj["message"] != tt.message {
+				t.Errorf("error message = %q, want %q", errObj["message"], tt.message)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Benchmark Tests
+// =============================================================================
+
+func BenchmarkHandleChatCompletions(b *testing.B) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+	mockCache := &MockCache{
+		GetFunc: func(ctx context.Context, key string) (*domain.ChatResponse, bool) {
+			return nil, false
+		},
+	}
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{
+				ID:     "resp-123",
+				Object: "chat.completion",
+				Model:  req.Model,
+				Usage:  domain.Usage{PromptTokens: 10, CompletionTokens: 20},
+			}, nil
+		},
+	}
+
+	providers := map[string]router.Provider{"openai": mockProvider}
+	r := router.New(providers, "openai")
+
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:  tenantRepo,
+		RateLimiter: rateLimiter,
+		Router:      r,
+		Cache:       mockCache,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+	}
+}
+
+func TestRateLimitHeaders_AllTenantEndpoints(t *testing.T) {
+	resetAt := time.Now().Add(30 * time.Second)
+	allowed := true
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return createTestTenant(), nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{
+			AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				if !allowed {
+					return false, 0, resetAt, nil
+				}
+				return true, 42, resetAt, nil
+			},
+		},
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		CostTracker: cost.NewInMemoryTracker(),
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	endpoints := []struct {
+		method string
+		path   string
+		body   []byte
+	}{
+		{"POST", "/v1/chat/completions", body},
+		{"GET", "/v1/models", nil},
+		{"GET", "/v1/usage", nil},
+		{"GET", "/v1/usage/tags?key=team", nil},
+	}
+	send := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, ep := range endpoints {
+		rec := send(ep.method, ep.path, ep.body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %s status = %d (%s)", ep.method, ep.path, rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-RateLimit-Limit") != "100" || rec.Header().Get("X-RateLimit-Remaining") != "42" ||
+			rec.Header().Get("X-RateLimit-Reset") != resetAt.Format(time.RFC3339) {
+			t.Errorf("%s %s rate limit headers = %v", ep.method, ep.path, rec.Header())
+		}
+		if rec.Header().Get("Retry-After") != "" {
+			t.Errorf("%s %s set Retry-After on an allowed request", ep.method, ep.path)
+		}
+	}
+
+	allowed = false
+	for _, ep := range endpoints {
+		rec := send(ep.method, ep.path, ep.body)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("%s %s status = %d, want 429", ep.method, ep.path, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "30" {
+			t.Errorf("%s %s Retry-After = %q, want 30", ep.method, ep.path, got)
+		}
+		if rec.Header().Get("X-RateLimit-Remaining") != "0" {
+			t.Errorf("%s %s X-RateLimit-Remaining = %q", ep.method, ep.path, rec.Header().Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	// Anonymous model listing is not rate limited.
+	req := httptest.NewRequest("GET", "/v1/models", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("anonymous /v1/models status = %d, headers = %v", rec.Code, rec.Header())
+	}
+}
+
+func TestHandleChatCompletions_CustomRequestIDHeaders(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:          tenantRepo,
+		RateLimiter:         &MockRateLimiter{},
+		Router:              router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		RequestIDHeaders:    []string{"X-Correlation-ID", "traceparent"},
+		RequestIDEchoHeader: "X-Correlation-ID",
+	})
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"first candidate", map[string]string{"X-Correlation-ID": "corr-1", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "corr-1"},
+		{"traceparent trace ID", map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"default header not read", map[string]string{"X-Request-ID": "ignored"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("X-Request-ID") != "" {
+				t.Errorf("X-Request-ID should not be set, got %q", rec.Header().Get("X-Request-ID"))
+			}
+			got := rec.Header().Get("X-Correlation-ID")
+			if tt.want == "" {
+				if got == "" || got == "ignored" {
+					t.Errorf("X-Correlation-ID = %q, want a generated ID", got)
+				}
+			} else if got != tt.want {
+				t.Errorf("X-Correlation-ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_BudgetExceededDetails(t *testing.T) {
+	tenant := createTestTenant()
+	tenant.BudgetUSD = 50
+	tenant.BudgetPeriod = domain.BudgetPeriodDaily
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return tenant, nil
+		},
+	}
+	tracker := &MockCostTracker{
+		GetTenantTotalCostFunc: func(ctx context.Context, tenantID string, since time.Time) (float64, error) {
+			return 51.25, nil
+		},
+	}
+	newHandler := func(fields []string, upgradeURL string) *Handler {
+		return NewHandler(HandlerConfig{
+			TenantRepo:           tenantRepo,
+			RateLimiter:          &MockRateLimiter{},
+			Router:               router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+			CostTracker:          tracker,
+			BudgetMonitor:        budget.NewMonitor(tracker, budget.DefaultThresholds()),
+			BudgetExceededFields: fields,
+			BudgetUpgradeURL:     upgradeURL,
+		})
+	}
+	send := func(h *Handler) map[string]interface{} {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPaymentRequired {
+			t.Fatalf("expected 402, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp map[string]map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp["error"]
+	}
+
+	got := send(newHandler(nil, "https://example.com/billing"))
+	reset := budget.PeriodWindow(domain.BudgetPeriodDaily, time.Now()).End.Format(time.RFC3339)
+	want := map[string]interface{}{
+		"message":           "budget exceeded",
+		"current_spend_usd": 51.25,
+		"budget_usd":        50.0,
+		"budget_period":     "daily",
+		"period_reset":      reset,
+		"upgrade_url":       "https://example.com/billing",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+
+	got = send(newHandler([]string{BudgetFieldBudget}, ""))
+	if got["budget_usd"] != 50.0 {
+		t.Errorf("budget_usd = %v, want 50", got["budget_usd"])
+	}
+	for _, k := range []string{"current_spend_usd", "period_reset", "upgrade_url"} {
+		if _, ok := got[k]; ok {
+			t.Errorf("%s should not be sent: %v", k, got)
+		}
+	}
+
+	got = send(newHandler([]string{}, "https://example.com/billing"))
+	if len(got) != 3 {
+		t.Errorf("expected only message, type and code, got %v", got)
+	}
+}
+
+func TestHandleListModels_SlowProvider(t *testing.T) {
+	models := func(ids ...string) []domain.Model {
+		var out []domain.Model
+		for _, id := range ids {
+			out = append(out, domain.Model{ID: id, Object: "model"})
+		}
+		return out
+	}
+	providers := map[string]router.Provider{
+		"openai": &MockProvider{IDValue: "openai", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			return models("gpt-4"), nil
+		}},
+		"ollama": &MockProvider{IDValue: "ollama", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+		"anthropic": &MockProvider{IDValue: "anthropic", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			return nil, errors.New("upstream unavailable")
+		}},
+		"bedrock": &MockProvider{IDValue: "bedrock", ModelsFunc: func(ctx context.Context) ([]domain.Model, error) {
+			// Ignores its context, so only the overall deadline ends the wait.
+			time.Sleep(time.Second)
+			return models("claude-3-haiku"), nil
+		}},
+	}
+	handler := NewHandler(HandlerConfig{
+		Router:            router.New(providers, "openai"),
+		ModelsCallTimeout: 50 * time.Millisecond,
+		ModelsTimeout:     200 * time.Millisecond,
+	})
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/models", nil))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("listing took %v, want it bounded by the overall timeout", elapsed)
+	}
+
+	var resp domain.ModelsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].ID != "gpt-4" {
+		t.Errorf("data = %+v, want only gpt-4", resp.Data)
+	}
+	want := map[string]string{"ollama": "timeout", "anthropic": "error", "bedrock": "timeout"}
+	if !reflect.DeepEqual(resp.Unavailable, want) {
+		t.Errorf("unavailable = %v, want %v", resp.Unavailable, want)
+	}
+}
+
+func TestHandleChatCompletions_StreamCacheReplaysUsage(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage *domain.Usage
+	}{
+		{"provider reported", &domain.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19}},
+		{"estimated", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []cost.UsageRecord
+			calls := 0
+			provider := &MockProvider{IDValue: "openai"}
+			provider.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+				calls++
+				chunks := make(chan domain.StreamChunk, 3)
+				errs := make(chan error, 1)
+				base := domain.StreamChunk{ID: "chatcmpl-1", Object: "chat.completion.chunk", Created: 1700000000, Model: req.Model}
+				first, last := base, base
+				first.Choices = []domain.Choice{{Delta: &domain.Delta{Content: "Hello there"}}}
+				last.Choices = []domain.Choice{{Delta: &domain.Delta{}, FinishReason: "stop"}}
+				chunks <- first
+				chunks <- last
+				if tt.usage != nil {
+					usage := base
+					usage.Choices = []domain.Choice{}
+					usage.Usage = tt.usage
+					chunks <- usage
+				}
+				close(chunks)
+				return chunks, errs
+			}
+
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return createTestTenant(), nil
+				}},
+				RateLimiter: &MockRateLimiter{},
+				Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+				Cache:       cache.NewInMemoryCache(),
+				CacheTTL:    time.Minute,
+				CostTracker: &MockCostTracker{RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+					records = append(records, record)
+					return nil
+				}},
+			})
+
+			chatReq := createChatRequest("gpt-4", true)
+			chatReq.StreamOptions = &domain.StreamOptions{IncludeUsage: true}
+			body, _ := json.Marshal(chatReq)
+			var bodies []string
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+				req.Header.Set("Authorization", "Bearer sk-test-key")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d, body = %s", i, rec.Code, rec.Body.String())
+				}
+				bodies = append(bodies, rec.Body.String())
+				if i == 1 && rec.Header().Get("X-Cache") != "HIT" {
+					t.Errorf("replay X-Cache = %q, want HIT", rec.Header().Get("X-Cache"))
+				}
+			}
+
+			if calls != 1 {
+				t.Errorf("provider called %d times, want 1", calls)
+			}
+			if len(records) != 2 {
+				t.Fatalf("recorded %d usage records, want 2", len(records))
+			}
+			orig, replay := records[0], records[1]
+			if orig.InputTokens == 0 || orig.OutputTokens == 0 {
+				t.Fatalf("original stream recorded no usage: %+v", orig)
+			}
+			if tt.usage != nil && (orig.InputTokens != tt.usage.PromptTokens || orig.OutputTokens != tt.usage.CompletionTokens) {
+				t.Errorf("original usage = %d/%d, want the provider's %d/%d", orig.InputTokens, orig.OutputTokens, tt.usage.PromptTokens, tt.usage.CompletionTokens)
+			}
+			if replay.InputTokens != orig.InputTokens || replay.OutputTokens != orig.OutputTokens || replay.CostUSD != orig.CostUSD {
+				t.Errorf("replay recorded %d/%d $%v, want %d/%d $%v", replay.InputTokens, replay.OutputTokens, replay.CostUSD, orig.InputTokens, orig.OutputTokens, orig.CostUSD)
+			}
+			if replay.Provider != "cache" {
+				t.Errorf("replay provider = %q, want cache", replay.Provider)
+			}
+			if !strings.Contains(bodies[1], `"content":"Hello there"`) || !strings.HasSuffix(strings.TrimSpace(bodies[1]), "data: [DONE]") {
+				t.Errorf("unexpected replay body: %s", bodies[1])
+			}
+			wantUsage := fmt.Sprintf(`"usage":{"prompt_tokens":%d,"completion_tokens":%d`, orig.InputTokens, orig.OutputTokens)
+			if !strings.Contains(bodies[1], wantUsage) {
+				t.Errorf("replay body missing %s: %s", wantUsage, bodies[1])
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_CompressedBody(t *testing.T) {
+	chatBody, _ := json.Marshal(createChatRequest("gpt-4", false))
+	compress := func(encoding string, data []byte) []byte {
+		var buf bytes.Buffer
+		var zw io.WriteCloser
+		if encoding == "deflate" {
+			zw = zlib.NewWriter(&buf)
+		} else {
+			zw = gzip.NewWriter(&buf)
+		}
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+	// Pads the request with whitespace, which compresses to almost nothing.
+	bomb := append(bytes.Repeat([]byte(" "), 1<<20), chatBody...)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		wantStatus int
+	}{
+		{"gzip", "gzip", compress("gzip", chatBody), http.StatusOK},
+		{"deflate", "deflate", compress("deflate", chatBody), http.StatusOK},
+		{"uncompressed", "", chatBody, http.StatusOK},
+		{"decompressed size over limit", "gzip", compress("gzip", bomb), http.StatusRequestEntityTooLarge},
+		{"corrupt gzip", "gzip", chatBody, http.StatusBadRequest},
+		{"unsupported encoding", "br", chatBody, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return createTestTenant(), nil
+				}},
+				RateLimiter:  &MockRateLimiter{},
+				Router:       router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+				MaxBodyBytes: 64 << 10,
+			})
+			if len(tt.body) > 64<<10 {
+				t.Fatalf("compressed body is %d bytes, want it under the limit", len(tt.body))
+			}
+
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_StreamTokenEstimateError(t *testing.T) {
+	chatReq := createChatRequest("estimate-check-model", true)
+	promptEstimate := 0
+	for _, m := range chatReq.Messages {
+		promptEstimate += cost.DefaultEstimator.EstimateTokens(chatReq.Model, m.Content)
+	}
+	// "Hello there" estimates to 3 completion tokens; the provider reports
+	// 6, so only the completion estimate is off by more than the tolerance.
+	reported := domain.Usage{PromptTokens: promptEstimate, CompletionTokens: 6, TotalTokens: promptEstimate + 6}
+
+	var recorded []cost.UsageRecord
+	provider := &MockProvider{IDValue: "openai"}
+	provider.ChatCompletionStreamFunc = func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+		chunks := make(chan domain.StreamChunk, 2)
+		chunks <- domain.StreamChunk{ID: "chatcmpl-1", Model: req.Model, Choices: []domain.Choice{{Delta: &domain.Delta{Content: "Hello there"}, FinishReason: "stop"}}}
+		chunks <- domain.StreamChunk{ID: "chatcmpl-1", Model: req.Model, Choices: []domain.Choice{}, Usage: &reported}
+		close(chunks)
+		return chunks, make(chan error)
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		CostTracker: &MockCostTracker{RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			recorded = append(recorded, record)
+			return nil
+		}},
+		EstimateTolerance: 0.2,
+	})
+
+	body, _ := json.Marshal(chatReq)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	observed := map[string]float64{}
+	families, _ := prometheus.DefaultGatherer.Gather()
+	for _, mf := range families {
+		if mf.GetName() != "aigateway_token_estimate_error" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["model"] == chatReq.Model && m.GetHistogram().GetSampleCount() > 0 {
+				observed[labels["kind"]] = m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	if len(observed) != 1 || observed["completion"] != -0.5 {
+		t.Errorf("estimate errors = %v, want only completion at -0.5", observed)
+	}
+	if len(recorded) != 1 || recorded[0].InputTokens != reported.PromptTokens || recorded[0].OutputTokens != reported.CompletionTokens {
+		t.Errorf("recorded usage = %+v, want the provider's %+v", recorded, reported)
+	}
+}
+
+func TestHandleChatCompletions_DedupRapidDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	provider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			n := calls.Add(1)
+			started <- struct{}{}
+			<-release
+			if req.Messages[0].Content == "fail" {
+				return nil, errors.New("provider down")
+			}
+			return &domain.ChatResponse{
+				ID:      fmt.Sprintf("resp-%d", n),
+				Object:  "chat.completion",
+				Model:   req.Model,
+				Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}, FinishReason: "stop"}},
+				Usage:   domain.Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30},
+			}, nil
+		},
+	}
+	tracker := cost.NewInMemoryTracker()
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		CostTracker: tracker,
+		DedupWindow: time.Minute,
+	})
+
+	send := func(content string) *httptest.ResponseRecorder {
+		chatReq := createChatRequest("gpt-4", false)
+		chatReq.Messages[0].Content = content
+		body, _ := json.Marshal(chatReq)
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("X-Skip-Cache", "true")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	sendConcurrently := func(content string, n int) []*httptest.ResponseRecorder {
+		recs := make([]*httptest.ResponseRecorder, n)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[0] = send(content)
+		}()
+		<-started
+		for i := 1; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				recs[i] = send(content)
+			}(i)
+		}
+		// Duplicates either wait on the request in flight or, if they
+		// arrive after it completes, reuse its response; both must avoid
+		// a second provider call.
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		release = make(chan struct{})
+		return recs
+	}
+
+	recs := sendConcurrently("hello", 3)
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("provider calls = %d, want 1", got)
+	}
+	for i, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i, rec.Code)
+		}
+		var resp domain.ChatResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.ID != "resp-1" {
+			t.Errorf("request %d: response ID = %q, want resp-1", i, resp.ID)
+		}
+		if dup := rec.Header().Get("X-Deduplicated") == "true"; dup != (i > 0) {
+			t.Errorf("request %d: X-Deduplicated = %v, want %v", i, dup, i > 0)
+		}
+	}
+	if records := tracker.GetAllRecords(); len(records) != 1 {
+		t.Errorf("usage records = %d, want 1", len(records))
+	}
+
+	// A retry after the response completed reuses it too.
+	close(release)
+	if rec := send("hello"); rec.Header().Get("X-Deduplicated") != "true" || calls.Load() != 1 {
+		t.Errorf("late duplicate: X-Deduplicated = %q, provider calls = %d", rec.Header().Get("X-Deduplicated"), calls.Load())
+	}
+
+	// Different content is a different request.
+	send("goodbye")
+	<-started
+	if got := calls.Load(); got != 2 {
+		t.Errorf("provider calls after distinct request = %d, want 2", got)
+	}
+
+	// Failures are not reused, so a retry reaches the provider again.
+	if rec := send("fail"); rec.Code == http.StatusOK {
+		t.Fatalf("failing request: status = %d", rec.Code)
+	}
+	<-started
+	send("fail")
+	<-started
+	if got := calls.Load(); got != 4 {
+		t.Errorf("provider calls after retried failure = %d, want 4", got)
+	}
+}
+
+func TestHandleChatCompletions_DefaultModel(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        string
+		tenantModel  string
+		globalModel  string
+		wantProvider string
+		wantModel    string
+	}{
+		{"global default", "", "", "gpt-4o-mini", "openai", "gpt-4o-mini"},
+		{"tenant default wins", "", "gpt-4o", "gpt-4o-mini", "openai", "gpt-4o"},
+		{"prefixed default pins provider", "", "", "anthropic/claude-3-haiku", "anthropic", "claude-3-haiku"},
+		{"explicit model kept", "gpt-4", "gpt-4o", "gpt-4o-mini", "openai", "gpt-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotProvider, gotModel string
+			newProvider := func(id string) *MockProvider {
+				return &MockProvider{
+					IDValue: id,
+					ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+						gotProvider, gotModel = id, req.Model
+						return &domain.ChatResponse{ID: "resp", Model: req.Model, Usage: domain.Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30}}, nil
+					},
+				}
+			}
+			tenant := createTestTenant()
+			tenant.DefaultModel = tt.tenantModel
+			tracker := cost.NewInMemoryTracker()
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return tenant, nil
+				}},
+				RateLimiter: &MockRateLimiter{},
+				Router: router.New(map[string]router.Provider{
+					"openai":    newProvider("openai"),
+					"anthropic": newProvider("anthropic"),
+				}, "openai"),
+				CostTracker:  tracker,
+				DefaultModel: tt.globalModel,
+			})
+
+			chatReq := createChatRequest(tt.model, false)
+			body, _ := json.Marshal(chatReq)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body.String())
+			}
+			if gotProvider != tt.wantProvider || gotModel != tt.wantModel {
+				t.Errorf("provider saw %s/%s, want %s/%s", gotProvider, gotModel, tt.wantProvider, tt.wantModel)
+			}
+			records := tracker.GetAllRecords()
+			if len(records) != 1 || records[0].Model != tt.wantModel {
+				t.Errorf("usage records = %+v, want one for %s", records, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_UnknownModelRejected(t *testing.T) {
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router: router.NewWithConfig(router.Config{
+			Providers:       map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}},
+			DefaultProvider: "openai",
+			UnknownModels:   router.UnknownModelsReject,
+		}),
+	})
+
+	for _, stream := range []bool{false, true} {
+		body, _ := json.Marshal(createChatRequest("no-such-model", stream))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "no-such-model") {
+			t.Errorf("stream=%v: status = %d, body %s; want 400 naming the model", stream, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleChatCompletions_TimeBudgetStopsFallback(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		},
+	}
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	provider := func(id string, call func(ctx context.Context) error) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				mu.Lock()
+				calls = append(calls, id)
+				mu.Unlock()
+				return nil, call(ctx)
+			},
+		}
+	}
+	slowFailure := func(ctx context.Context) error {
+		time.Sleep(80 * time.Millisecond)
+		return errors.New("upstream 503")
+	}
+	hang := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	fail := func(ctx context.Context) error { return errors.New("upstream 503") }
+
+	tests := []struct {
+		name       string
+		budget     time.Duration
+		first      func(ctx context.Context) error
+		wantStatus int
+		wantCalls  []string
+		wantBody   string
+	}{
+		{"slow failure leaves too little time", 100 * time.Millisecond, slowFailure, http.StatusBadGateway, []string{"openai"}, "request time budget exhausted after 1 provider attempts: upstream 503"},
+		{"attempt cut off by the budget", 100 * time.Millisecond, hang, http.StatusGatewayTimeout, []string{"openai"}, "request time budget exhausted"},
+		{"budget left for fallback", time.Second, fail, http.StatusBadGateway, []string{"openai", "anthropic", "ollama"}, "all providers failed"},
+		{"no budget", 0, slowFailure, http.StatusBadGateway, []string{"openai", "anthropic", "ollama"}, "all providers failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			providers := map[string]router.Provider{
+				"openai":    provider("openai", tt.first),
+				"anthropic": provider("anthropic", fail),
+				"ollama":    provider("ollama", fail),
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:        tenantRepo,
+				RateLimiter:       rateLimiter,
+				Router:            router.New(providers, "openai"),
+				RequestTimeBudget: tt.budget,
+				MinAttemptTime:    50 * time.Millisecond,
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("provider calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", rec.Body.String(), tt.wantBody)
+			}
+			if tt.budget > 0 && time.Since(start) > tt.budget+200*time.Millisecond {
+				t.Errorf("request took %v, want about the %v budget at most", time.Since(start), tt.budget)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_FailOnUsageError(t *testing.T) {
+	tenantRepo := &MockTenantRepository{
+		GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
 		},
 	}
 	rateLimiter := &MockRateLimiter{
@@ -772,42 +4145,705 @@ func BenchmarkHandleChatCompletions(b *testing.B) {
 			return true, 99, time.Now().Add(time.Minute), nil
 		},
 	}
-	mockCache := &MockCache{
-		GetFunc: func(ctx context.Context, key string) (*domain.ChatResponse, bool) {
-			return nil, false
+	tracker := &MockCostTracker{
+		RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			return errors.New("database unavailable")
 		},
 	}
-	mockProvider := &MockProvider{
+	streaming := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+			chunks := make(chan domain.StreamChunk, 1)
+			chunks <- domain.StreamChunk{ID: "chunk-1", Choices: []domain.Choice{{Delta: &domain.Delta{Content: "Hi"}}}}
+			close(chunks)
+			return chunks, make(chan error)
+		},
+	}
+
+	tests := []struct {
+		name       string
+		fail       bool
+		stream     bool
+		wantStatus int
+		wantError  bool
+	}{
+		{"logged by default", false, false, http.StatusOK, false},
+		{"fails the request", true, false, http.StatusInternalServerError, true},
+		{"stream logged by default", false, true, http.StatusOK, false},
+		{"stream ends with an error event", true, true, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:       tenantRepo,
+				RateLimiter:      rateLimiter,
+				Router:           router.New(map[string]router.Provider{"openai": streaming}, "openai"),
+				CostTracker:      tracker,
+				FailOnUsageError: tt.fail,
+			})
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", tt.stream))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			req.Header.Set("X-Skip-Cache", "true")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := strings.Contains(rec.Body.String(), usageErrorMessage); got != tt.wantError {
+				t.Errorf("body = %s, want usage error %v", rec.Body.String(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_DefaultMaxTokens(t *testing.T) {
+	rateLimiter := &MockRateLimiter{
+		AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, 99, time.Now().Add(time.Minute), nil
+		},
+	}
+
+	var sent *int
+	provider := &MockProvider{
+		IDValue: "anthropic",
+		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			sent = req.MaxTokens
+			return &domain.ChatResponse{ID: "resp-1", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "Hi"}}}}, nil
+		},
+	}
+	tenantMax := 256
+	requestMax := 64
+
+	tests := []struct {
+		name      string
+		model     string
+		defaults  map[string]int
+		request   *int
+		tenantMax *int
+		want      int
+	}{
+		{"model default", "claude-3-5-sonnet", map[string]int{"claude-3-5-sonnet": 1024, "*": 2048}, nil, nil, 1024},
+		{"wildcard default", "claude-3-haiku", map[string]int{"claude-3-5-sonnet": 1024, "*": 2048}, nil, nil, 2048},
+		{"provider-prefixed model", "anthropic/claude-3-5-sonnet", map[string]int{"claude-3-5-sonnet": 1024, "*": 2048}, nil, nil, 1024},
+		{"request wins", "claude-3-5-sonnet", map[string]int{"*": 2048}, &requestMax, nil, 64},
+		{"tenant default wins", "claude-3-5-sonnet", map[string]int{"*": 2048}, nil, &tenantMax, 256},
+		{"no default configured", "claude-3-5-sonnet", nil, nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			tenantRepo := &MockTenantRepository{
+				GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					tenant := createTestTenant()
+					if tt.tenantMax != nil {
+						tenant.SamplingDefaults = &domain.SamplingDefaults{MaxTokens: tt.tenantMax}
+					}
+					return tenant, nil
+				},
+			}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo:       tenantRepo,
+				RateLimiter:      rateLimiter,
+				Router:           router.New(map[string]router.Provider{"anthropic": provider}, "anthropic"),
+				DefaultMaxTokens: tt.defaults,
+			})
+
+			chatReq := createChatRequest(tt.model, false)
+			chatReq.MaxTokens = tt.request
+			body, _ := json.Marshal(chatReq)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			req.Header.Set("X-Skip-Cache", "true")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+			}
+			got := 0
+			if sent != nil {
+				got = *sent
+			}
+			if got != tt.want {
+				t.Errorf("max_tokens = %d, want %d (0 = unset)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_DedupLeaderDisconnects(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	provider := &MockProvider{
 		IDValue: "openai",
 		ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+			calls.Add(1)
+			started <- struct{}{}
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 			return &domain.ChatResponse{
-				ID:     "resp-123",
-				Object: "chat.completion",
-				Model:  req.Model,
-				Usage:  domain.Usage{PromptTokens: 10, CompletionTokens: 20},
+				ID:      "resp-1",
+				Object:  "chat.completion",
+				Model:   req.Model,
+				Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}, FinishReason: "stop"}},
+				Usage:   domain.Usage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30},
 			}, nil
 		},
 	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		DedupWindow: time.Minute,
+	})
+
+	send := func(ctx context.Context) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("X-Skip-Cache", "true")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
 
-	providers := map[string]router.Provider{"openai": mockProvider}
-	r := router.New(providers, "openai")
+	leaderCtx, disconnect := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		send(leaderCtx)
+	}()
+	<-started
+
+	retryDone := make(chan *httptest.ResponseRecorder)
+	go func() { retryDone <- send(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+
+	// The first client times out and drops its connection while the retry
+	// is waiting on its provider call.
+	disconnect()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	rec := <-retryDone
+	<-leaderDone
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Deduplicated") != "true" {
+		t.Fatalf("retry: status = %d, X-Deduplicated = %q, want the leader's 200 (%s)",
+			rec.Code, rec.Header().Get("X-Deduplicated"), rec.Body.String())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("provider calls = %d, want 1", n)
+	}
+}
 
+func TestRateLimit_AfterScopeAndBudgetChecks(t *testing.T) {
+	var calls int
+	tenant := createTestTenant()
+	tracker := &MockCostTracker{
+		GetTenantTotalCostFunc: func(ctx context.Context, tenantID string, since time.Time) (float64, error) {
+			return tenant.BudgetUSD + 1, nil
+		},
+	}
 	handler := NewHandler(HandlerConfig{
-		TenantRepo:  tenantRepo,
-		RateLimiter: rateLimiter,
-		Router:      r,
-		Cache:       mockCache,
+		TenantRepo: &MockTenantRepository{
+			GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				return tenant, nil
+			},
+		},
+		RateLimiter: &MockRateLimiter{
+			AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+				calls++
+				return true, 99, time.Now().Add(time.Minute), nil
+			},
+		},
+		Router:        router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		CostTracker:   tracker,
+		BudgetMonitor: budget.NewMonitor(tracker, budget.DefaultThresholds()),
 	})
 
 	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPaymentRequired {
+		t.Fatalf("over-budget status = %d, want 402", rec.Code)
+	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
+	tenant.Scopes = []string{domain.ScopeModelsRead}
+	req = httptest.NewRequest("GET", "/v1/usage", nil)
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("scope-denied status = %d, want 403", rec.Code)
+	}
+
+	if calls != 0 {
+		t.Errorf("rate limiter called %d times for rejected requests, want 0", calls)
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("rejected request carries rate limit headers: %v", rec.Header())
+	}
+}
+
+func TestHandleChatCompletions_CacheHitRecordsStoredUsage(t *testing.T) {
+	var records []cost.UsageRecord
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		Cache:       cache.NewInMemoryCache(),
+		CacheTTL:    time.Minute,
+		CostTracker: &MockCostTracker{RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+			records = append(records, record)
+			return nil
+		}},
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", false))
+	var gateways []*domain.Gateway
+	for i := 0; i < 2; i++ {
 		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
 		req.Header.Set("Authorization", "Bearer sk-test-key")
-		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, body = %s", i, rec.Code, rec.Body.String())
+		}
+		var resp domain.ChatResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		gateways = append(gateways, resp.Gateway)
+	}
 
-		rr := httptest.NewRecorder()
-		handler.ServeHTTP(rr, req)
+	if len(records) != 2 {
+		t.Fatalf("recorded %d usage records, want 2", len(records))
+	}
+	orig, hit := records[0], records[1]
+	if hit.Provider != "cache" || hit.InputTokens != orig.InputTokens || hit.OutputTokens != orig.OutputTokens || hit.CostUSD != orig.CostUSD {
+		t.Errorf("cache hit recorded %+v, want the original's usage and cost %+v", hit, orig)
+	}
+	if orig.CostUSD == 0 || gateways[1] == nil || !gateways[1].CacheHit || gateways[1].CostUSD != orig.CostUSD {
+		t.Errorf("cache hit x_gateway = %+v, want cost %v", gateways[1], orig.CostUSD)
+	}
+}
+
+func TestHandleChatCompletions_HeaderRuleProviderUnavailable(t *testing.T) {
+	tenant := createTestTenant()
+	tenant.AllowedProviders = []string{"openai"}
+	var called []string
+	newProvider := func(id string) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				called = append(called, id)
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "hi"}}}}, nil
+			},
+		}
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return tenant, nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router: router.NewWithConfig(router.Config{
+			Providers:       map[string]router.Provider{"openai": newProvider("openai"), "bedrock": newProvider("bedrock")},
+			DefaultProvider: "openai",
+			CBConfig:        circuitbreaker.DefaultConfig(),
+			HeaderRules:     []router.HeaderRule{{Header: "X-Region", Value: "eu", Provider: "bedrock"}},
+		}),
+	})
+
+	for _, stream := range []bool{false, true} {
+		body, _ := json.Marshal(createChatRequest("gpt-4", stream))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("X-Region", "eu")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("stream=%v: status = %d, want 503 (%s)", stream, rec.Code, rec.Body.String())
+		}
+	}
+	if len(called) != 0 {
+		t.Errorf("providers called = %v, want none", called)
+	}
+}
+
+func TestHandler_PrepareCacheRequest(t *testing.T) {
+	temp := 0.2
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{
+			GetByIDFunc: func(ctx context.Context, id string) (*domain.Tenant, error) {
+				tenant := createTestTenant()
+				tenant.SamplingDefaults = &domain.SamplingDefaults{Temperature: &temp}
+				return tenant, nil
+			},
+		},
+		Router:           router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		DefaultMaxTokens: map[string]int{"gpt-4": 512},
+	})
+
+	req := createChatRequest("gpt-4", false)
+	if err := handler.PrepareCacheRequest(context.Background(), "test-tenant", &req); err != nil {
+		t.Fatalf("PrepareCacheRequest() error = %v", err)
+	}
+	if req.MaxTokens == nil || *req.MaxTokens != 512 {
+		t.Errorf("max_tokens = %v, want the DEFAULT_MAX_TOKENS value 512", req.MaxTokens)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want the tenant default 0.2", req.Temperature)
+	}
+}
+
+func TestHandleChatCompletions_StreamMissingUsage(t *testing.T) {
+	tests := []struct {
+		name         string
+		estimate     bool
+		includeUsage bool
+		wantEstimate bool
+	}{
+		{"estimated without include_usage", true, false, true},
+		{"estimated with include_usage", true, true, true},
+		{"estimation disabled", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := &MockProvider{
+				IDValue: "openai",
+				ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+					chunks := make(chan domain.StreamChunk, 1)
+					errs := make(chan error, 1)
+					chunks <- domain.StreamChunk{
+						ID: "chatcmpl-1", Object: "chat.completion.chunk", Model: req.Model,
+						Choices: []domain.Choice{{Delta: &domain.Delta{Content: "Hi there, streamed"}, FinishReason: "stop"}},
+					}
+					close(chunks)
+					return chunks, errs
+				},
+			}
+
+			var recorded []cost.UsageRecord
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return createTestTenant(), nil
+				}},
+				RateLimiter: &MockRateLimiter{},
+				Router:      router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+				CostTracker: &MockCostTracker{RecordFunc: func(ctx context.Context, record cost.UsageRecord) error {
+					recorded = append(recorded, record)
+					return nil
+				}},
+				DisableUsageEstimate: !tt.estimate,
+			})
+
+			missing := metrics.MissingUsage.WithLabelValues("openai", "stream-missing-usage-model")
+			before := testutil.ToFloat64(missing)
+
+			chatReq := createChatRequest("stream-missing-usage-model", true)
+			if tt.includeUsage {
+				chatReq.StreamOptions = &domain.StreamOptions{IncludeUsage: true}
+			}
+			body, _ := json.Marshal(chatReq)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if got := testutil.ToFloat64(missing) - before; got != 1 {
+				t.Errorf("missing usage metric increased by %v, want 1", got)
+			}
+			if len(recorded) != 1 {
+				t.Fatalf("expected one usage record, got %d", len(recorded))
+			}
+			if estimated := recorded[0].OutputTokens > 0; estimated != tt.wantEstimate {
+				t.Errorf("recorded output tokens = %d, want estimated = %v", recorded[0].OutputTokens, tt.wantEstimate)
+			}
+
+			if !tt.includeUsage {
+				return
+			}
+			var usage *domain.Usage
+			for _, line := range strings.Split(rec.Body.String(), "\n") {
+				data, ok := strings.CutPrefix(line, "data: ")
+				if !ok || data == "[DONE]" {
+					continue
+				}
+				var chunk domain.StreamChunk
+				if err := json.Unmarshal([]byte(data), &chunk); err == nil && chunk.Usage != nil {
+					usage = chunk.Usage
+				}
+			}
+			if usage == nil {
+				t.Fatalf("expected a usage chunk, got %q", rec.Body.String())
+			}
+			if usage.CompletionTokens != recorded[0].OutputTokens {
+				t.Errorf("usage chunk completion tokens = %d, billed %d", usage.CompletionTokens, recorded[0].OutputTokens)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_StreamRecordsLatency(t *testing.T) {
+	mockProvider := &MockProvider{
+		IDValue: "openai",
+		ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+			chunks := make(chan domain.StreamChunk, 1)
+			errs := make(chan error, 1)
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				chunks <- domain.StreamChunk{
+					ID: "chatcmpl-1", Object: "chat.completion.chunk", Model: req.Model,
+					Choices: []domain.Choice{{Delta: &domain.Delta{Content: "slow"}, FinishReason: "stop"}},
+				}
+				close(chunks)
+			}()
+			return chunks, errs
+		},
+	}
+
+	cbConfig := circuitbreaker.DefaultConfig()
+	cbConfig.LatencyThreshold = time.Millisecond
+	cbConfig.LatencyMinSamples = 1
+	r := router.NewWithConfig(router.Config{
+		Providers:       map[string]router.Provider{"openai": mockProvider},
+		DefaultProvider: "openai",
+		CBConfig:        cbConfig,
+	})
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      r,
+	})
+
+	body, _ := json.Marshal(createChatRequest("gpt-4", true))
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer sk-test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if _, err := r.SelectProvider(context.Background(), "openai", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("expected a slow first chunk to open the circuit, got %v", err)
+	}
+}
+
+func TestHandler_StreamDurationLimit(t *testing.T) {
+	handler, _, _, _, _ := setupTestHandler(t)
+	handler.maxStreamDur = 10 * time.Minute
+
+	tests := []struct {
+		name    string
+		seconds int
+		want    time.Duration
+	}{
+		{"gateway default", 0, 10 * time.Minute},
+		{"shorter override", 30, 30 * time.Second},
+		{"longer override", 1800, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant := createTestTenant()
+			tenant.MaxStreamSeconds = tt.seconds
+			if got := handler.streamDurationLimit(tenant); got != tt.want {
+				t.Errorf("streamDurationLimit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDefaultMaxTokens_StripsProviderPrefix(t *testing.T) {
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:       &MockTenantRepository{},
+		Router:           router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		DefaultMaxTokens: map[string]int{"gpt-4o": 16384, "*": 1024},
+	})
+
+	for model, want := range map[string]int{"openai/gpt-4o": 16384, "gpt-4o": 16384, "openai/gpt-4o-mini": 1024} {
+		req := createChatRequest(model, false)
+		if _, err := handler.prepareRequest(&req, createTestTenant()); err != nil {
+			t.Fatalf("prepareRequest(%s) error = %v", model, err)
+		}
+		if req.MaxTokens == nil || *req.MaxTokens != want {
+			t.Errorf("%s: max_tokens = %v, want %d", model, req.MaxTokens, want)
+		}
+	}
+}
+
+func TestPrepareRequest_DefaultsMatchSameModelName(t *testing.T) {
+	handler := NewHandler(HandlerConfig{
+		TenantRepo:           &MockTenantRepository{},
+		Router:               router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		DefaultSystemPrompts: map[string]string{"gpt-4o": "Answer in Markdown."},
+		DefaultMaxTokens:     map[string]int{"gpt-4o": 16384},
+	})
+
+	prefixed := createChatRequest("openai/gpt-4o", false)
+	if err := handler.PrepareCacheRequest(context.Background(), "", &prefixed); err != nil {
+		t.Fatalf("PrepareCacheRequest() error = %v", err)
+	}
+	if len(prefixed.Messages) != 2 || prefixed.Messages[0].Content != "Answer in Markdown." {
+		t.Errorf("expected default system prompt for openai/gpt-4o, got %+v", prefixed.Messages)
+	}
+	if prefixed.MaxTokens == nil || *prefixed.MaxTokens != 16384 {
+		t.Errorf("max_tokens = %v, want 16384", prefixed.MaxTokens)
+	}
+
+	bare := createChatRequest("gpt-4o", false)
+	if err := handler.PrepareCacheRequest(context.Background(), "", &bare); err != nil {
+		t.Fatalf("PrepareCacheRequest() error = %v", err)
+	}
+	if !reflect.DeepEqual(bare.Messages, prefixed.Messages) || *bare.MaxTokens != *prefixed.MaxTokens {
+		t.Errorf("prefixed and bare requests prepared differently: %+v vs %+v", prefixed, bare)
+	}
+}
+
+type recordingArchiver struct {
+	mu      sync.Mutex
+	records []archive.Record
+}
+
+func (a *recordingArchiver) Archive(ctx context.Context, record archive.Record) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.records = append(a.records, record)
+	return nil
+}
+
+func TestHandleChatCompletions_ArchivesSampledRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream bool
+		force  bool
+		rate   float64
+		want   bool
+	}{
+		{"sampled", false, false, 1, true},
+		{"not sampled", false, false, 0, false},
+		{"forced", false, true, 0, true},
+		{"sampled stream", true, false, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProvider := &MockProvider{
+				IDValue: "openai",
+				ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+					return &domain.ChatResponse{ID: "resp-1", Model: req.Model, Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "Hi"}}}}, nil
+				},
+				ChatCompletionStreamFunc: func(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+					chunks := make(chan domain.StreamChunk, 1)
+					errs := make(chan error, 1)
+					chunks <- domain.StreamChunk{
+						ID: "resp-1", Object: "chat.completion.chunk", Model: req.Model,
+						Choices: []domain.Choice{{Delta: &domain.Delta{Content: "Hi"}, FinishReason: "stop"}},
+					}
+					close(chunks)
+					return chunks, errs
+				},
+			}
+			archiver := &recordingArchiver{}
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return createTestTenant(), nil
+				}},
+				RateLimiter:    &MockRateLimiter{},
+				Router:         router.New(map[string]router.Provider{"openai": mockProvider}, "openai"),
+				Archiver:       archiver,
+				ArchiveSampler: archive.NewSampler(tt.rate),
+			})
+
+			reason := archive.ReasonSampled
+			if tt.force {
+				reason = archive.ReasonForced
+			}
+			archived := metrics.RequestsArchived.WithLabelValues("tenant-123", reason)
+			before := testutil.ToFloat64(archived)
+
+			body, _ := json.Marshal(createChatRequest("gpt-4", tt.stream))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			if tt.force {
+				req.Header.Set(archive.ForceHeader, "true")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+			}
+			if !tt.want {
+				if len(archiver.records) != 0 {
+					t.Errorf("archived %d records, want none", len(archiver.records))
+				}
+				return
+			}
+			if len(archiver.records) != 1 {
+				t.Fatalf("archived %d records, want 1", len(archiver.records))
+			}
+			record := archiver.records[0]
+			if record.TenantID != "tenant-123" || record.Provider != "openai" || record.Stream != tt.stream {
+				t.Errorf("record = %+v", record)
+			}
+			if record.Response == nil || record.Response.Choices[0].Message.Content != "Hi" {
+				t.Errorf("record response = %+v, want the completion", record.Response)
+			}
+			if got := testutil.ToFloat64(archived) - before; got != 1 {
+				t.Errorf("%s archive count increased by %v, want 1", reason, got)
+			}
+		})
+	}
+}
+
+func TestHandleChatCompletions_DedupKeepsOwnRateLimitHeaders(t *testing.T) {
+	var remaining atomic.Int32
+	remaining.Store(100)
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{AllowFunc: func(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
+			return true, int(remaining.Add(-1)), time.Now().Add(time.Minute), nil
+		}},
+		Router:      router.New(map[string]router.Provider{"openai": &MockProvider{IDValue: "openai"}}, "openai"),
+		DedupWindow: time.Minute,
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(createChatRequest("gpt-4", false))
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		req.Header.Set("X-Skip-Cache", "true")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send()
+	second := send()
+
+	if second.Header().Get("X-Deduplicated") != "true" {
+		t.Fatal("expected the second request to be deduplicated")
+	}
+	if got := first.Header().Get("X-RateLimit-Remaining"); got != "99" {
+		t.Errorf("first X-RateLimit-Remaining = %q, want 99", got)
+	}
+	if got := second.Header().Get("X-RateLimit-Remaining"); got != "98" {
+		t.Errorf("replayed X-RateLimit-Remaining = %q, want 98", got)
 	}
 }
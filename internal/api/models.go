@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
+)
+
+// Defaults for listing models across providers.
+const (
+	DefaultModelsCallTimeout = 5 * time.Second
+	DefaultModelsTimeout     = 10 * time.Second
+)
+
+type providerModels struct {
+	id     string
+	models []domain.Model
+	err    error
+}
+
+// fetchModels asks every provider for its models at once, giving each up to
+// h.modelsEach to answer. Providers that fail, or have not answered when
+// h.modelsTimeout runs out, are returned in unavailable ("error" or
+// "timeout") so one slow provider cannot hold up the whole list.
+func (h *Handler) fetchModels(ctx context.Context) (models map[string][]domain.Model, unavailable map[string]string) {
+	return fetchModels(ctx, h.router, h.modelsEach, h.modelsTimeout)
+}
+
+func fetchModels(ctx context.Context, rt *router.Router, each, total time.Duration) (models map[string][]domain.Model, unavailable map[string]string) {
+	ctx, cancel := context.WithTimeout(ctx, total)
+	defer cancel()
+
+	ids := rt.ListProviders()
+	// Buffered so a provider that ignores its context does not leak a
+	// blocked goroutine once we stop waiting.
+	results := make(chan providerModels, len(ids))
+	pending := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		provider, ok := rt.GetProvider(id)
+		if !ok {
+			continue
+		}
+		pending[id] = true
+		go func(id string) {
+			pctx, cancel := context.WithTimeout(ctx, each)
+			defer cancel()
+			m, err := provider.Models(pctx)
+			if err == nil && pctx.Err() != nil {
+				err = pctx.Err()
+			}
+			results <- providerModels{id: id, models: m, err: err}
+		}(id)
+	}
+
+	models = make(map[string][]domain.Model, len(pending))
+	unavailable = make(map[string]string)
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.id)
+			if r.err != nil {
+				reason := "error"
+				if errors.Is(r.err, context.DeadlineExceeded) {
+					reason = "timeout"
+				}
+				slog.Warn("failed to get models from provider", "provider", r.id, "reason", reason, "error", r.err)
+				unavailable[r.id] = reason
+				continue
+			}
+			models[r.id] = r.models
+		case <-ctx.Done():
+			for id := range pending {
+				slog.Warn("timed out getting models from provider", "provider", id)
+				unavailable[id] = "timeout"
+			}
+			return models, unavailable
+		}
+	}
+	return models, unavailable
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
+)
+
+// DefaultModerationProvider serves POST /v1/moderations when no provider is
+// configured.
+const DefaultModerationProvider = "openai"
+
+// handleModerations serves OpenAI-compatible content moderation. It passes
+// the same gates as chat completions, kill switch and chat:write included,
+// and each call is recorded as usage with estimated input tokens so it
+// counts towards request totals and, for priced moderation models, spend.
+func (h *Handler) handleModerations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	start := time.Now()
+	requestID := h.requestIDFrom(r)
+
+	tenant := h.authorizeChatWrite(w, r, requestID)
+	if tenant == nil {
+		return
+	}
+
+	var req domain.ModerationRequest
+	if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "bad_request").Inc()
+		var tooLarge *http.MaxBytesError
+		if errors.As(decodeErr, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Input == nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+		writeError(w, http.StatusBadRequest, "input is required")
+		return
+	}
+	if req.Model == "" {
+		req.Model = h.modModel
+	}
+
+	tags, err := cost.ParseTags(r.Header.Get(cost.TagsHeader))
+	if err != nil {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", req.Model, "bad_request").Inc()
+		writeError(w, http.StatusBadRequest, "invalid X-Tags header: "+err.Error())
+		return
+	}
+
+	provider, ok := h.router.GetProvider(h.modProvider)
+	moderator, canModerate := provider.(router.Moderator)
+	if !ok || !canModerate {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, h.modProvider, req.Model, "no_provider").Inc()
+		writeError(w, http.StatusNotImplemented, "moderation not available")
+		return
+	}
+	if !tenant.ProviderAllowed(h.modProvider) {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, h.modProvider, req.Model, "provider_not_allowed").Inc()
+		writeError(w, http.StatusForbidden, "provider not allowed for this tenant: "+h.modProvider)
+		return
+	}
+
+	resp, err := moderator.Moderate(ctx, req)
+	if err != nil {
+		slog.Error("moderation failed", "error", err, "provider", h.modProvider, "request_id", requestID)
+		metrics.RecordProviderError(h.modProvider, "request_failed")
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, h.modProvider, req.Model, "provider_error").Inc()
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("provider error: %v", err))
+		return
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	usage := domain.Usage{PromptTokens: h.estimator.EstimateTokens(model, strings.Join(req.Texts(), "\n"))}
+	usage.TotalTokens = usage.PromptTokens
+	costUSD, usageErr := h.recordUsage(ctx, tenant, domain.ChatRequest{Model: model}, h.modProvider, requestID, usage, tags)
+	if usageErr != nil && h.failOnUsage {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, h.modProvider, model, "usage_error").Inc()
+		writeError(w, http.StatusInternalServerError, usageErrorMessage)
+		return
+	}
+
+	latency := time.Since(start).Milliseconds()
+	metrics.RecordRequest(tenant.ID, h.modProvider, model, "success", float64(latency)/1000)
+	metrics.RecordTokens(tenant.ID, h.modProvider, model, usage.PromptTokens, 0)
+	metrics.RecordCost(tenant.ID, h.modProvider, model, costUSD)
+	slog.Info("moderation completed",
+		"request_id", requestID,
+		"tenant_id", tenant.ID,
+		"provider", h.modProvider,
+		"model", model,
+		"latency_ms", latency,
+		"cost_usd", costUSD,
+		"tokens_input", usage.PromptTokens,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(h.reqIDHeader, requestID)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/budget"
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
+)
+
+// moderatingProvider is a MockProvider that also implements router.Moderator.
+type moderatingProvider struct {
+	MockProvider
+	ModerateFunc func(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error)
+}
+
+func (m *moderatingProvider) Moderate(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error) {
+	return m.ModerateFunc(ctx, req)
+}
+
+func TestHandleModerations(t *testing.T) {
+	var gotReq domain.ModerationRequest
+	provider := &moderatingProvider{
+		MockProvider: MockProvider{IDValue: "openai"},
+		ModerateFunc: func(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error) {
+			gotReq = req
+			if req.Input == "fail" {
+				return nil, errors.New("upstream unavailable")
+			}
+			return &domain.ModerationResponse{
+				ID:    "modr-1",
+				Model: req.Model,
+				Results: []domain.ModerationResult{{
+					Flagged:        true,
+					Categories:     map[string]bool{"violence": true},
+					CategoryScores: map[string]float64{"violence": 0.9},
+				}},
+			}, nil
+		},
+	}
+	readOnly := createTestTenant()
+	readOnly.Scopes = []string{domain.ScopeUsageRead}
+	tenants := map[string]*domain.Tenant{"sk-test-key": createTestTenant(), "sk-read-only": readOnly}
+
+	newHandler := func(p router.Provider, tracker cost.Tracker) *Handler {
+		return NewHandler(HandlerConfig{
+			TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+				if tenant, ok := tenants[apiKey]; ok {
+					return tenant, nil
+				}
+				return nil, domain.ErrTenantNotFound
+			}},
+			RateLimiter:     &MockRateLimiter{},
+			Router:          router.New(map[string]router.Provider{"openai": p}, "openai"),
+			CostTracker:     tracker,
+			ModerationModel: "omni-moderation-latest",
+		})
+	}
+
+	tests := []struct {
+		name       string
+		provider   router.Provider
+		apiKey     string
+		body       string
+		wantStatus int
+	}{
+		{"moderated", provider, "sk-test-key", `{"input": "I will hurt you"}`, http.StatusOK},
+		{"missing API key", provider, "", `{"input": "hi"}`, http.StatusUnauthorized},
+		{"invalid API key", provider, "sk-wrong", `{"input": "hi"}`, http.StatusUnauthorized},
+		{"missing chat:write scope", provider, "sk-read-only", `{"input": "hi"}`, http.StatusForbidden},
+		{"missing input", provider, "sk-test-key", `{"model": "omni-moderation-latest"}`, http.StatusBadRequest},
+		{"provider error", provider, "sk-test-key", `{"input": "fail"}`, http.StatusBadGateway},
+		{"provider cannot moderate", &MockProvider{IDValue: "openai"}, "sk-test-key", `{"input": "hi"}`, http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := cost.NewInMemoryTracker()
+			handler := newHandler(tt.provider, tracker)
+
+			req := httptest.NewRequest("POST", "/v1/moderations", strings.NewReader(tt.body))
+			if tt.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if records := tracker.GetAllRecords(); len(records) != 0 {
+					t.Errorf("usage recorded for a failed moderation: %+v", records)
+				}
+				return
+			}
+
+			var resp struct {
+				ID      string `json:"id"`
+				Model   string `json:"model"`
+				Results []struct {
+					Flagged        bool               `json:"flagged"`
+					Categories     map[string]bool    `json:"categories"`
+					CategoryScores map[string]float64 `json:"category_scores"`
+				} `json:"results"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.ID != "modr-1" || resp.Model != "omni-moderation-latest" || len(resp.Results) != 1 {
+				t.Fatalf("response = %s", rec.Body.String())
+			}
+			if r := resp.Results[0]; !r.Flagged || !r.Categories["violence"] || r.CategoryScores["violence"] != 0.9 {
+				t.Errorf("result = %+v", r)
+			}
+			if gotReq.Model != "omni-moderation-latest" {
+				t.Errorf("provider got model %q, want the configured default", gotReq.Model)
+			}
+			if rec.Header().Get(DefaultRequestIDHeader) == "" {
+				t.Error("response has no request ID")
+			}
+
+			records := tracker.GetAllRecords()
+			if len(records) != 1 || records[0].Provider != "openai" || records[0].Model != "omni-moderation-latest" || records[0].InputTokens == 0 {
+				t.Errorf("usage records = %+v, want one with estimated input tokens", records)
+			}
+		})
+	}
+}
+
+func TestHandleModerations_KillSwitch(t *testing.T) {
+	ks := budget.NewInMemoryKillSwitch()
+	moderated := false
+	provider := &moderatingProvider{
+		MockProvider: MockProvider{IDValue: "openai"},
+		ModerateFunc: func(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error) {
+			moderated = true
+			return &domain.ModerationResponse{ID: "modr-1", Model: req.Model}, nil
+		},
+	}
+	handler := NewHandler(HandlerConfig{
+		TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+			return createTestTenant(), nil
+		}},
+		RateLimiter: &MockRateLimiter{},
+		Router:      router.New(map[string]router.Provider{"openai": provider}, "openai"),
+		KillSwitch:  ks,
+	})
+	moderate := func() int {
+		req := httptest.NewRequest("POST", "/v1/moderations", strings.NewReader(`{"input": "hi"}`))
+		req.Header.Set("Authorization", "Bearer sk-test-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	ks.Set(context.Background(), true)
+	if code := moderate(); code != http.StatusServiceUnavailable {
+		t.Errorf("status with switch on = %d, want 503", code)
+	}
+	if moderated {
+		t.Error("provider called while the kill switch is on")
+	}
+
+	ks.Set(context.Background(), false)
+	if code := moderate(); code != http.StatusOK {
+		t.Errorf("status with switch off = %d, want 200", code)
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// checkRateLimit counts the request against tenant's rate limit and sets the
+// X-RateLimit-* headers, plus Retry-After on 429. Handlers call it once the
+// key, scope and budget gates have passed, so requests those reject do not
+// use up the tenant's quota. It reports whether the handler may go on; when
+// it returns false the response has been written.
+func (h *Handler) checkRateLimit(w http.ResponseWriter, r *http.Request, tenant *domain.Tenant) bool {
+	if h.rateLimiter == nil {
+		return true
+	}
+
+	allowed, remaining, resetAt, err := h.rateLimiter.Allow(r.Context(), tenant.ID, tenant.RateLimitRPM)
+	if err != nil {
+		slog.Error("rate limiter error", "error", err, "path", r.URL.Path)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return false
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(tenant.RateLimitRPM))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", resetAt.Format(time.RFC3339))
+
+	if !allowed {
+		slog.Warn("rate limit exceeded", "tenant_id", tenant.ID, "path", r.URL.Path)
+		metrics.RecordRateLimitHit(tenant.ID)
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "", "", "rate_limited").Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(time.Until(resetAt))))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// retryAfterSeconds rounds d up to whole seconds, never less than one.
+func retryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRequestIDHeader carries the request ID in both directions when no
+// other header is configured.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// requestIDFrom returns the value of the first configured request ID header
+// present on r, or a new UUID when none is. A W3C traceparent header yields
+// its trace ID.
+func (h *Handler) requestIDFrom(r *http.Request) string {
+	for _, name := range h.reqIDHeaders {
+		v := r.Header.Get(name)
+		if strings.EqualFold(name, "traceparent") {
+			v = traceIDFromTraceparent(v)
+		}
+		if v != "" {
+			return v
+		}
+	}
+	return uuid.New().String()
+}
+
+// traceIDFromTraceparent extracts the trace ID from a traceparent value
+// ("00-<trace-id>-<parent-id>-<flags>"), or returns "" if it is malformed.
+func traceIDFromTraceparent(v string) string {
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || strings.Trim(parts[1], "0") == "" {
+		return ""
+	}
+	return parts[1]
+}
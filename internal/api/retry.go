@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// DefaultRetryableStatuses are the upstream statuses that move a request on
+// to the next provider unless overridden for a provider.
+var DefaultRetryableStatuses = []int{408, 429, 500, 502, 503, 504}
+
+// retryPolicy decides whether a provider failure should fall through to the
+// next provider or be returned to the client.
+type retryPolicy struct {
+	defaults  map[int]bool
+	overrides map[string]map[int]bool
+}
+
+func newRetryPolicy(overrides map[string][]int) retryPolicy {
+	p := retryPolicy{
+		defaults:  statusSet(DefaultRetryableStatuses),
+		overrides: make(map[string]map[int]bool, len(overrides)),
+	}
+	for id, statuses := range overrides {
+		p.overrides[id] = statusSet(statuses)
+	}
+	return p
+}
+
+// retryable reports whether err from providerID is worth trying elsewhere.
+// Errors without an upstream status, such as timeouts and connection
+// failures, are always retryable.
+func (p retryPolicy) retryable(providerID string, err error) bool {
+	var statusErr *domain.ProviderStatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	set, ok := p.overrides[providerID]
+	if !ok {
+		set = p.defaults
+	}
+	return set[statusErr.StatusCode]
+}
+
+func statusSet(statuses []int) map[int]bool {
+	set := make(map[int]bool, len(statuses))
+	for _, s := range statuses {
+		set[s] = true
+	}
+	return set
+}
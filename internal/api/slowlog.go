@@ -0,0 +1,53 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// slowRequest is what the slow-request log records about a finished chat
+// completion.
+type slowRequest struct {
+	requestID string
+	traceID   string
+	tenantID  string
+	provider  string
+	model     string
+	stream    bool
+	failed    bool
+	attempts  int
+	fallback  bool
+	retried   []string
+	usage     domain.Usage
+	latency   time.Duration
+}
+
+// logIfSlow logs s at warn level, and counts it, when it took longer than
+// the slow-request threshold. It carries enough context to investigate the
+// request without finding its other log lines.
+func (h *Handler) logIfSlow(s slowRequest) {
+	if h.slowThreshold <= 0 || s.latency <= h.slowThreshold {
+		return
+	}
+
+	metrics.RecordSlowRequest(s.provider, s.model)
+	slog.Warn("slow request",
+		"request_id", s.requestID,
+		"trace_id", s.traceID,
+		"tenant_id", s.tenantID,
+		"provider", s.provider,
+		"model", s.model,
+		"stream", s.stream,
+		"failed", s.failed,
+		"attempts", s.attempts,
+		"fallback", s.fallback,
+		"retried_providers", s.retried,
+		"tokens_input", s.usage.PromptTokens,
+		"tokens_output", s.usage.CompletionTokens,
+		"latency_ms", s.latency.Milliseconds(),
+		"threshold_ms", h.slowThreshold.Milliseconds(),
+	)
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/router"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandleChatCompletions_SlowRequestLog(t *testing.T) {
+	delayed := func(id string, delay time.Duration, err error) *MockProvider {
+		return &MockProvider{
+			IDValue: id,
+			ChatCompletionFunc: func(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+				time.Sleep(delay)
+				if err != nil {
+					return nil, err
+				}
+				return &domain.ChatResponse{ID: "resp", Model: req.Model, Usage: domain.Usage{PromptTokens: 11, CompletionTokens: 22, TotalTokens: 33}}, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		providers map[string]router.Provider
+		model     string
+		wantSlow  bool
+		wantLog   []string
+	}{
+		{
+			name:      "slow provider",
+			providers: map[string]router.Provider{"openai": delayed("openai", 30*time.Millisecond, nil)},
+			model:     "slow-log-a",
+			wantSlow:  true,
+			wantLog:   []string{"provider=openai", "model=slow-log-a", "tokens_input=11", "tokens_output=22", "fallback=false", "failed=false"},
+		},
+		{
+			name: "slow after fallback",
+			providers: map[string]router.Provider{
+				"openai":    delayed("openai", 0, errors.New("upstream 500")),
+				"anthropic": delayed("anthropic", 30*time.Millisecond, nil),
+			},
+			model:    "slow-log-b",
+			wantSlow: true,
+			wantLog:  []string{"provider=anthropic", "fallback=true", "attempts=2", "retried_providers=[openai]"},
+		},
+		{
+			name:      "fast provider",
+			providers: map[string]router.Provider{"openai": delayed("openai", 0, nil)},
+			model:     "slow-log-c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			prev := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+			defer slog.SetDefault(prev)
+
+			r := router.New(tt.providers, "openai")
+			handler := NewHandler(HandlerConfig{
+				TenantRepo: &MockTenantRepository{GetByAPIKeyFunc: func(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+					return createTestTenant(), nil
+				}},
+				RateLimiter:   &MockRateLimiter{},
+				Router:        r,
+				SlowThreshold: 20 * time.Millisecond,
+			})
+
+			body, _ := json.Marshal(createChatRequest(tt.model, false))
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer sk-test-key")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (%s)", rec.Code, rec.Body.String())
+			}
+
+			var slowLine string
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if strings.Contains(line, `msg="slow request"`) {
+					slowLine = line
+				}
+			}
+			if (slowLine != "") != tt.wantSlow {
+				t.Fatalf("slow request logged = %v, want %v\n%s", slowLine != "", tt.wantSlow, logs.String())
+			}
+			if tt.wantSlow && !strings.Contains(slowLine, "level=WARN") {
+				t.Errorf("slow request not logged at warn level: %s", slowLine)
+			}
+			for _, want := range tt.wantLog {
+				if !strings.Contains(slowLine, want) {
+					t.Errorf("slow request log missing %q: %s", want, slowLine)
+				}
+			}
+
+			var count float64
+			for _, id := range []string{"openai", "anthropic"} {
+				count += testutil.ToFloat64(metrics.SlowRequests.WithLabelValues(id, tt.model))
+			}
+			if want := map[bool]float64{true: 1}[tt.wantSlow]; count != want {
+				t.Errorf("aigateway_slow_requests_total = %v, want %v", count, want)
+			}
+		})
+	}
+}
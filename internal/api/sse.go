@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// sseWriter writes server-sent events. Every event gets the next id so
+// EventSource clients track how far they got; the gateway does not resume
+// streams, but the ids let clients tell a reconnect from a fresh stream.
+type sseWriter struct {
+	w         http.ResponseWriter
+	lastID    int
+	reconnect time.Duration
+}
+
+// retry sets how long the client waits before reconnecting after the
+// connection drops. It is sent ahead of the first event, so response
+// headers can still change until the stream produces something.
+func (s *sseWriter) retry(d time.Duration) {
+	s.reconnect = d
+}
+
+// started reports whether any event has been written.
+func (s *sseWriter) started() bool {
+	return s.lastID > 0
+}
+
+func (s *sseWriter) data(payload string) {
+	if !s.started() && s.reconnect > 0 {
+		fmt.Fprintf(s.w, "retry: %d\n\n", s.reconnect.Milliseconds())
+	}
+	s.lastID++
+	fmt.Fprintf(s.w, "id: %d\ndata: %s\n\n", s.lastID, payload)
+}
+
+func (s *sseWriter) json(v interface{}) {
+	data, _ := json.Marshal(v)
+	s.data(string(data))
+}
+
+// roleDeltas tracks which choices of a stream have announced their role.
+type roleDeltas map[int]bool
+
+// apply makes a stream look like OpenAI's, whose first delta for each choice
+// carries role "assistant" and later deltas do not. Providers such as Ollama,
+// Anthropic and Bedrock never send the role, so for choices whose first delta
+// lacks one apply returns a chunk announcing it, to be sent before chunk.
+func (seen roleDeltas) apply(chunk *domain.StreamChunk) (domain.StreamChunk, bool) {
+	var announce []domain.Choice
+	for i := range chunk.Choices {
+		c := &chunk.Choices[i]
+		if c.Delta == nil {
+			continue
+		}
+		if seen[c.Index] {
+			c.Delta.Role = ""
+			continue
+		}
+		seen[c.Index] = true
+		if c.Delta.Role == "" {
+			announce = append(announce, domain.Choice{Index: c.Index, Delta: &domain.Delta{Role: "assistant"}})
+		}
+	}
+	if len(announce) == 0 {
+		return domain.StreamChunk{}, false
+	}
+	return domain.StreamChunk{
+		ID:      chunk.ID,
+		Object:  "chat.completion.chunk",
+		Created: chunk.Created,
+		Model:   chunk.Model,
+		Choices: announce,
+	}, true
+}
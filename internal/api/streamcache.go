@@ -0,0 +1,164 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/transform"
+)
+
+// streamCapture rebuilds the response a stream delivered, before tenant
+// transforms, so it can be priced and cached like a non-streamed one.
+type streamCapture struct {
+	id          string
+	created     int64
+	model       string
+	content     strings.Builder
+	finish      string
+	usage       *domain.Usage
+	unsupported bool
+}
+
+func (c *streamCapture) observe(chunk domain.StreamChunk) {
+	if c.id == "" {
+		c.id, c.created, c.model = chunk.ID, chunk.Created, chunk.Model
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 || choice.Logprobs != nil {
+			c.unsupported = true
+		}
+		if choice.Delta != nil {
+			c.content.WriteString(choice.Delta.Content)
+			if len(choice.Delta.ToolCalls) > 0 {
+				c.unsupported = true
+			}
+		}
+		if choice.FinishReason != "" {
+			c.finish = choice.FinishReason
+		}
+	}
+	if chunk.Usage != nil {
+		u := *chunk.Usage
+		c.usage = &u
+	}
+}
+
+// cacheable reports whether the stream finished with plain text in a single
+// choice, the only shape response() can reproduce faithfully.
+func (c *streamCapture) cacheable() bool {
+	return !c.unsupported && c.finish != "" && c.content.Len() > 0
+}
+
+// response returns the stream as a chat completion, carrying the usage the
+// provider reported, if any.
+func (c *streamCapture) response(req domain.ChatRequest) *domain.ChatResponse {
+	resp := &domain.ChatResponse{
+		ID:      c.id,
+		Object:  "chat.completion",
+		Created: c.created,
+		Model:   c.model,
+		Choices: []domain.Choice{{
+			Message:      &domain.Message{Role: "assistant", Content: c.content.String()},
+			FinishReason: c.finish,
+		}},
+	}
+	if resp.Created == 0 {
+		resp.Created = time.Now().Unix()
+	}
+	if resp.Model == "" {
+		resp.Model = req.Model
+	}
+	if c.usage != nil {
+		resp.Usage = *c.usage
+	}
+	return resp
+}
+
+// replayable reports whether a cached response can be sent as a stream.
+func replayable(resp *domain.ChatResponse) bool {
+	for _, c := range resp.Choices {
+		if c.Message == nil || c.Logprobs != nil {
+			return false
+		}
+	}
+	return len(resp.Choices) > 0
+}
+
+// replayCachedStream answers a streaming request from the cache. The usage
+// stored with the response is recorded as is, so a replay costs the tenant
+// what the original stream did without estimating tokens again, the same as
+// a non-streaming cache hit.
+func (h *Handler) replayCachedStream(w http.ResponseWriter, r *http.Request, cached *domain.ChatResponse, req domain.ChatRequest, tenant *domain.Tenant, transformer transform.Transformer, requestID string, traceID string, start time.Time, tags map[string]string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	usage := cached.Usage
+	resp := transformer.TransformResponse(cached)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set(h.reqIDHeader, requestID)
+	w.Header().Set("X-Cache", "HIT")
+
+	sse := &sseWriter{w: w}
+	if h.sseRetry > 0 {
+		sse.retry(h.sseRetry)
+	}
+
+	chunk := func(choices ...domain.Choice) domain.StreamChunk {
+		return domain.StreamChunk{ID: resp.ID, Object: "chat.completion.chunk", Created: resp.Created, Model: resp.Model, Choices: choices}
+	}
+	for _, c := range resp.Choices {
+		sse.json(chunk(domain.Choice{Index: c.Index, Delta: &domain.Delta{Role: "assistant", Content: c.Message.Content}}))
+		sse.json(chunk(domain.Choice{Index: c.Index, Delta: &domain.Delta{}, FinishReason: c.FinishReason}))
+	}
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		final := chunk()
+		final.Choices = []domain.Choice{}
+		final.Usage = &usage
+		sse.json(final)
+	}
+
+	costUSD, usageErr := h.recordUsage(r.Context(), tenant, req, "cache", requestID, usage, tags)
+	if usageErr != nil && h.failOnUsage {
+		metrics.RequestsTotal.WithLabelValues(tenant.ID, "cache", req.Model, "usage_error").Inc()
+		writeStreamError(sse, http.StatusInternalServerError, usageErrorMessage)
+		sse.data("[DONE]")
+		flusher.Flush()
+		return
+	}
+
+	latency := time.Since(start).Milliseconds()
+	if gatewayMetaEnabled(r) {
+		sse.json(map[string]interface{}{"x_gateway": domain.Gateway{
+			Provider:  "cache",
+			LatencyMs: latency,
+			CostUSD:   costUSD,
+			CacheHit:  true,
+			RequestID: requestID,
+			TraceID:   traceID,
+		}})
+	}
+	sse.data("[DONE]")
+	flusher.Flush()
+
+	metrics.RecordCacheHit(tenant.ID)
+	metrics.RecordRequest(tenant.ID, "cache", req.Model, "success", float64(latency)/1000)
+	metrics.RecordTokens(tenant.ID, "cache", req.Model, usage.PromptTokens, usage.CompletionTokens)
+	metrics.RecordCost(tenant.ID, "cache", req.Model, costUSD)
+	slog.Info("stream cache hit",
+		"request_id", requestID,
+		"tenant_id", tenant.ID,
+		"model", req.Model,
+		"latency_ms", latency,
+		"cost_usd", costUSD,
+	)
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/transform"
+)
+
+// TenantValidationError describes one problem with a tenant configuration.
+type TenantValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validateTenant checks t for values the gateway would reject or misbehave
+// on. changed names the JSON fields an update set; only checks involving one
+// of them run, so an update is not rejected for a value it left alone. nil
+// checks everything. Provider and model references are only checked when the
+// handler has a router; models are skipped if any provider fails to list them
+// in time, since an incomplete list would flag valid models as unknown.
+func (h *AdminHandler) validateTenant(ctx context.Context, t *domain.Tenant, changed map[string]bool) []TenantValidationError {
+	var errs []TenantValidationError
+	add := func(field, message string) {
+		errs = append(errs, TenantValidationError{Field: field, Message: message})
+	}
+	touched := func(fields ...string) bool {
+		if changed == nil {
+			return true
+		}
+		for _, f := range fields {
+			if changed[f] {
+				return true
+			}
+		}
+		return false
+	}
+
+	if touched("name") && t.Name == "" {
+		add("name", "name is required")
+	}
+	if touched("rate_limit_rpm") && t.RateLimitRPM < 0 {
+		add("rate_limit_rpm", "rate_limit_rpm must not be negative")
+	}
+	if touched("budget_usd") && t.BudgetUSD < 0 {
+		add("budget_usd", "budget_usd must not be negative")
+	}
+	if touched("budget_period") && !t.BudgetPeriod.Valid() {
+		add("budget_period", "budget_period must be daily, weekly or monthly")
+	}
+	if touched("max_stream_seconds") && t.MaxStreamSeconds < 0 {
+		add("max_stream_seconds", "max_stream_seconds must not be negative")
+	}
+	if touched("transform_rules") {
+		if err := transform.Validate(t.TransformRules); err != nil {
+			add("transform_rules", err.Error())
+		}
+	}
+	if touched("pricing_overrides") {
+		if err := validatePricingOverrides(t.PricingOverrides); err != nil {
+			add("pricing_overrides", err.Error())
+		}
+	}
+	if touched("sampling_defaults") {
+		if err := validateSamplingDefaults(t.SamplingDefaults); err != nil {
+			add("sampling_defaults", err.Error())
+		}
+	}
+	if touched("webhook_url") {
+		if err := validateWebhookURL(t.WebhookURL); err != nil {
+			add("webhook_url", err.Error())
+		}
+	}
+	if touched("scopes") {
+		for _, s := range t.Scopes {
+			if !domain.ValidScope(s) {
+				add("scopes", "unknown scope: "+s)
+			}
+		}
+	}
+	if touched("default_provider", "allowed_providers") && t.DefaultProvider != "" && !t.ProviderAllowed(t.DefaultProvider) {
+		add("default_provider", "default_provider is not in allowed_providers: "+t.DefaultProvider)
+	}
+	if touched("fallback_providers", "allowed_providers") {
+		for _, id := range t.FallbackProviders {
+			if !t.ProviderAllowed(id) {
+				add("fallback_providers", "fallback provider is not in allowed_providers: "+id)
+			}
+		}
+	}
+
+	if h.router == nil {
+		return errs
+	}
+
+	if touched("default_provider") && t.DefaultProvider != "" {
+		if _, ok := h.router.GetProvider(t.DefaultProvider); !ok {
+			add("default_provider", "unknown provider: "+t.DefaultProvider)
+		}
+	}
+	if touched("fallback_providers") {
+		for _, id := range t.FallbackProviders {
+			if _, ok := h.router.GetProvider(id); !ok {
+				add("fallback_providers", "unknown provider: "+id)
+			}
+		}
+	}
+	if touched("allowed_providers") {
+		for _, id := range t.AllowedProviders {
+			if _, ok := h.router.GetProvider(id); !ok {
+				add("allowed_providers", "unknown provider: "+id)
+			}
+		}
+	}
+
+	checkModels := touched("allowed_models") && len(t.AllowedModels) > 0
+	checkDefault := touched("default_model") && t.DefaultModel != ""
+	if checkModels || checkDefault {
+		if known, ok := h.knownModels(ctx); ok {
+			for _, model := range t.AllowedModels {
+				if checkModels && !known[model] {
+					add("allowed_models", "unknown model: "+model)
+				}
+			}
+			if checkDefault && !known[t.DefaultModel] {
+				add("default_model", "unknown model: "+t.DefaultModel)
+			}
+		}
+	}
+
+	return errs
+}
+
+// knownModels returns every model ID the router's providers serve, both bare
+// and provider-prefixed. ok is false if any provider could not be listed
+// within the configured models timeouts.
+func (h *AdminHandler) knownModels(ctx context.Context) (map[string]bool, bool) {
+	each, total := DefaultModelsCallTimeout, DefaultModelsTimeout
+	if h.config != nil {
+		if h.config.ModelsProviderTimeout > 0 {
+			each = h.config.ModelsProviderTimeout
+		}
+		if h.config.ModelsTimeout > 0 {
+			total = h.config.ModelsTimeout
+		}
+	}
+
+	byProvider, unavailable := fetchModels(ctx, h.router, each, total)
+	if len(unavailable) > 0 {
+		ids := make([]string, 0, len(unavailable))
+		for id := range unavailable {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		slog.Warn("skipping model validation, providers did not list models", "providers", ids)
+		return nil, false
+	}
+
+	known := make(map[string]bool)
+	for id, models := range byProvider {
+		for _, m := range models {
+			known[m.ID] = true
+			known[id+"/"+m.ID] = true
+		}
+	}
+	return known, true
+}
+
+func (h *AdminHandler) validateTenantConfig(w http.ResponseWriter, r *http.Request) {
+	var req CreateTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	errs := h.validateTenant(r.Context(), req.tenant(), nil)
+	if errs == nil {
+		errs = []TenantValidationError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"valid":  len(errs) == 0,
+		"errors": errs,
+	})
+}
+
+func writeValidationErrors(w http.ResponseWriter, errs []TenantValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  errs[0].Message,
+		"errors": errs,
+	})
+}
@@ -0,0 +1,71 @@
+// Package archive keeps copies of completed chat requests and their
+// responses for offline analysis. Archiving every request is expensive at
+// scale, so a Sampler keeps a configurable fraction, globally or per tenant,
+// and lets operators force a specific request through with a header when
+// debugging.
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// Record is one archived request with the response the provider returned.
+type Record struct {
+	RequestID string               `json:"request_id"`
+	TenantID  string               `json:"tenant_id"`
+	Provider  string               `json:"provider"`
+	Stream    bool                 `json:"stream"`
+	Request   domain.ChatRequest   `json:"request"`
+	Response  *domain.ChatResponse `json:"response"`
+	LatencyMs int64                `json:"latency_ms"`
+	Timestamp time.Time            `json:"timestamp"`
+}
+
+// Archiver stores archived records.
+type Archiver interface {
+	Archive(ctx context.Context, record Record) error
+}
+
+// FileArchiver appends records to a JSON lines file.
+type FileArchiver struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileArchiver uses the file at path, creating it if needed.
+func NewFileArchiver(path string) (*FileArchiver, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open archive file: %w", err)
+	}
+	f.Close()
+	return &FileArchiver{path: path}, nil
+}
+
+func (a *FileArchiver) Archive(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode archive record: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open archive file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write archive record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package archive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestFileArchiver_AppendsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	a, err := NewFileArchiver(path)
+	if err != nil {
+		t.Fatalf("NewFileArchiver() error = %v", err)
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{"req-1", "req-2"} {
+		record := Record{
+			RequestID: id,
+			TenantID:  "t1",
+			Provider:  "openai",
+			Request:   domain.ChatRequest{Model: "gpt-4o", Messages: []domain.Message{{Role: "user", Content: "Hi"}}},
+			Response:  &domain.ChatResponse{ID: "resp-" + id},
+			Timestamp: time.Now(),
+		}
+		if err := a.Archive(ctx, record); err != nil {
+			t.Fatalf("Archive(%s) error = %v", id, err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	defer f.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, record.RequestID)
+	}
+	if len(ids) != 2 || ids[0] != "req-1" || ids[1] != "req-2" {
+		t.Errorf("archived request IDs = %v, want [req-1 req-2]", ids)
+	}
+}
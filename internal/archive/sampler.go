@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+// ForceHeader archives a request regardless of the sampling rate when set to
+// "true" or "1".
+const ForceHeader = "X-Force-Archive"
+
+// Reasons a request is archived, used as the metric's reason label.
+const (
+	ReasonSampled = "sampled"
+	ReasonForced  = "forced"
+)
+
+// Sampler makes the archive decision for a request.
+type Sampler struct {
+	rate        float64
+	tenantRates map[string]float64
+}
+
+// Option configures a Sampler.
+type Option func(*Sampler)
+
+// WithTenantRate overrides the global rate for a single tenant.
+func WithTenantRate(tenantID string, rate float64) Option {
+	return func(s *Sampler) {
+		s.tenantRates[tenantID] = clampRate(rate)
+	}
+}
+
+// NewSampler creates a sampler that archives the given fraction of requests.
+// Rates are clamped to [0, 1].
+func NewSampler(rate float64, opts ...Option) *Sampler {
+	s := &Sampler{
+		rate:        clampRate(rate),
+		tenantRates: make(map[string]float64),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// ShouldArchive reports whether the request should be archived, and why. The
+// decision is a pure function of the request ID, so retries and replays of
+// the same request are sampled the same way; requests without an ID are
+// sampled at random.
+func (s *Sampler) ShouldArchive(r *http.Request, tenantID, requestID string) (string, bool) {
+	if forced(r) {
+		return ReasonForced, true
+	}
+
+	rate := s.rateFor(tenantID)
+	if rate <= 0 {
+		return "", false
+	}
+
+	if rate >= 1 || sample(requestID) < rate {
+		return ReasonSampled, true
+	}
+	return "", false
+}
+
+func (s *Sampler) rateFor(tenantID string) float64 {
+	if rate, ok := s.tenantRates[tenantID]; ok {
+		return rate
+	}
+	return s.rate
+}
+
+func forced(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+	v := strings.TrimSpace(r.Header.Get(ForceHeader))
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+// sample maps a request ID onto [0, 1).
+func sample(requestID string) float64 {
+	if requestID == "" {
+		return rand.Float64()
+	}
+	sum := sha256.Sum256([]byte(requestID))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+func clampRate(rate float64) float64 {
+	if math.IsNaN(rate) {
+		return 0
+	}
+	return math.Max(0, math.Min(1, rate))
+}
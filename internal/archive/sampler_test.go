@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSampler_ShouldArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampler  *Sampler
+		tenantID string
+		want     bool
+	}{
+		{"rate zero", NewSampler(0), "t1", false},
+		{"rate one", NewSampler(1), "t1", true},
+		{"tenant override enables", NewSampler(0, WithTenantRate("t1", 1)), "t1", true},
+		{"tenant override disables", NewSampler(1, WithTenantRate("t1", 0)), "t1", false},
+		{"other tenant uses global", NewSampler(0, WithTenantRate("t1", 1)), "t2", false},
+		{"rate above one is clamped", NewSampler(5), "t1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+			if _, got := tt.sampler.ShouldArchive(req, tt.tenantID, "req-1"); got != tt.want {
+				t.Errorf("ShouldArchive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampler_DeterministicPerRequestID(t *testing.T) {
+	s := NewSampler(0.5)
+	req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+
+	archived := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("req-%d", i)
+		_, first := s.ShouldArchive(req, "t1", id)
+		for j := 0; j < 3; j++ {
+			if _, again := s.ShouldArchive(req, "t1", id); again != first {
+				t.Fatalf("decision for %s changed between calls", id)
+			}
+		}
+		if first {
+			archived++
+		}
+	}
+
+	// Roughly half should be archived; allow generous slack.
+	if archived < 400 || archived > 600 {
+		t.Errorf("archived %d of 1000 at rate 0.5", archived)
+	}
+}
+
+func TestSampler_ForceHeader(t *testing.T) {
+	s := NewSampler(0)
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"true", true},
+		{"1", true},
+		{"TRUE", true},
+		{"false", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+		if tt.value != "" {
+			req.Header.Set(ForceHeader, tt.value)
+		}
+		reason, got := s.ShouldArchive(req, "t1", "req-1")
+		if got != tt.want {
+			t.Errorf("%s=%q: ShouldArchive() = %v, want %v", ForceHeader, tt.value, got, tt.want)
+		}
+		if got && reason != ReasonForced {
+			t.Errorf("%s=%q: reason = %q, want %q", ForceHeader, tt.value, reason, ReasonForced)
+		}
+	}
+}
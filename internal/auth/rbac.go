@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -18,6 +19,7 @@ var (
 	ErrUnauthorized    = errors.New("unauthorized")
 	ErrForbidden       = errors.New("forbidden")
 	ErrUserNotFound    = errors.New("user not found")
+	ErrUserExists      = errors.New("user already exists")
 	ErrInvalidPassword = errors.New("invalid password")
 )
 
@@ -267,6 +269,9 @@ func (r *PostgresAdminUserRepository) Create(ctx context.Context, user *AdminUse
 		user.UpdatedAt,
 	)
 
+	if repository.IsUniqueViolation(err) {
+		return fmt.Errorf("insert user %q: %w", user.Username, ErrUserExists)
+	}
 	if err != nil {
 		return fmt.Errorf("insert user: %w", err)
 	}
@@ -395,6 +400,11 @@ func (r *InMemoryAdminUserRepository) GetByID(ctx context.Context, id string) (*
 }
 
 func (r *InMemoryAdminUserRepository) Create(ctx context.Context, user *AdminUser) error {
+	for _, u := range r.users {
+		if u.ID == user.ID || u.Username == user.Username {
+			return ErrUserExists
+		}
+	}
 	r.users[user.ID] = user
 	return nil
 }
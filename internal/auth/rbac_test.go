@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -283,6 +284,14 @@ func TestInMemoryAdminUserRepository(t *testing.T) {
 		t.Fatalf("Create() error = %v", err)
 	}
 
+	// Duplicate ID or username
+	if err := repo.Create(ctx, &AdminUser{ID: "other", Username: "newuser"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Create() duplicate username error = %v, want %v", err, ErrUserExists)
+	}
+	if err := repo.Create(ctx, &AdminUser{ID: "new-user", Username: "other"}); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Create() duplicate ID error = %v, want %v", err, ErrUserExists)
+	}
+
 	// Get by ID
 	got, err := repo.GetByID(ctx, "new-user")
 	if err != nil {
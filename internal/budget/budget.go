@@ -8,6 +8,8 @@ import (
 
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/felipepmaragno/ai-gateway/internal/notifications"
 )
 
 type AlertLevel string
@@ -18,6 +20,21 @@ const (
 	AlertLevelExceeded AlertLevel = "exceeded"
 )
 
+// Severity returns the numeric value exported on the alert level gauge.
+// An empty level (no alert) is 0.
+func (l AlertLevel) Severity() int {
+	switch l {
+	case AlertLevelWarning:
+		return 1
+	case AlertLevelCritical:
+		return 2
+	case AlertLevelExceeded:
+		return 3
+	default:
+		return 0
+	}
+}
+
 type Alert struct {
 	TenantID   string
 	Level      AlertLevel
@@ -35,6 +52,7 @@ type Monitor struct {
 	alertHandlers []AlertHandler
 	thresholds    Thresholds
 	deduplicator  AlertDeduplicator
+	webhooks      *notifications.WebhookNotifier
 }
 
 type Thresholds struct {
@@ -49,6 +67,21 @@ func DefaultThresholds() Thresholds {
 	}
 }
 
+// Level returns the alert level for a spend ratio (spent / budget), or an
+// empty level when usage is below the warning threshold.
+func (t Thresholds) Level(ratio float64) AlertLevel {
+	switch {
+	case ratio >= 1.0:
+		return AlertLevelExceeded
+	case ratio >= t.Critical:
+		return AlertLevelCritical
+	case ratio >= t.Warning:
+		return AlertLevelWarning
+	default:
+		return ""
+	}
+}
+
 // MonitorOption configures a Monitor.
 type MonitorOption func(*Monitor)
 
@@ -78,6 +111,11 @@ func NewMonitor(tracker cost.Tracker, thresholds Thresholds, opts ...MonitorOpti
 	return m
 }
 
+// Thresholds returns the alert thresholds the monitor was created with.
+func (m *Monitor) Thresholds() Thresholds {
+	return m.thresholds
+}
+
 func (m *Monitor) OnAlert(handler AlertHandler) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -89,30 +127,32 @@ func (m *Monitor) Check(ctx context.Context, tenant *domain.Tenant) (*Alert, err
 		return nil, nil
 	}
 
-	startOfMonth := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -time.Now().Day()+1)
-	currentCost, err := m.tracker.GetTenantTotalCost(ctx, tenant.ID, startOfMonth)
+	window := PeriodWindow(tenant.BudgetPeriod, time.Now())
+	currentCost, err := m.tracker.GetTenantTotalCost(ctx, tenant.ID, window.Start)
 	if err != nil {
 		return nil, err
 	}
 
 	percentage := currentCost / tenant.BudgetUSD
 
-	var level AlertLevel
-	switch {
-	case percentage >= 1.0:
-		level = AlertLevelExceeded
-	case percentage >= m.thresholds.Critical:
-		level = AlertLevelCritical
-	case percentage >= m.thresholds.Warning:
-		level = AlertLevelWarning
-	default:
+	level := m.thresholds.Level(percentage)
+	if level == "" {
 		// Usage dropped below warning threshold, clear alert state
 		m.deduplicator.ClearAlert(ctx, tenant.ID)
+		metrics.SetBudgetAlertLevel(tenant.ID, 0)
 		return nil, nil
 	}
 
+	metrics.SetBudgetAlertLevel(tenant.ID, level.Severity())
+
 	// Check if we should send this alert (deduplication)
-	if !m.deduplicator.ShouldAlert(ctx, tenant.ID, level) {
+	if !m.deduplicator.ShouldAlert(ctx, tenant.ID, level, window.End) {
+		slog.Debug("budget alert suppressed by deduplicator",
+			"tenant_id", tenant.ID,
+			"level", level,
+			"percentage", percentage*100,
+		)
+		metrics.RecordBudgetAlertSuppressed(string(level))
 		return nil, nil
 	}
 
@@ -133,6 +173,9 @@ func (m *Monitor) Check(ctx context.Context, tenant *domain.Tenant) (*Alert, err
 	for _, handler := range handlers {
 		handler(*alert)
 	}
+	if m.webhooks != nil && tenant.WebhookURL != "" {
+		go m.postWebhook(tenant, *alert, window.End)
+	}
 
 	return alert, nil
 }
@@ -142,8 +185,7 @@ func (m *Monitor) IsBudgetExceeded(ctx context.Context, tenant *domain.Tenant) (
 		return false, nil
 	}
 
-	startOfMonth := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -time.Now().Day()+1)
-	currentCost, err := m.tracker.GetTenantTotalCost(ctx, tenant.ID, startOfMonth)
+	currentCost, _, err := m.Spend(ctx, tenant)
 	if err != nil {
 		return false, err
 	}
@@ -151,6 +193,17 @@ func (m *Monitor) IsBudgetExceeded(ctx context.Context, tenant *domain.Tenant) (
 	return currentCost >= tenant.BudgetUSD, nil
 }
 
+// Spend returns what tenant has spent in its current budget window, and the
+// window itself.
+func (m *Monitor) Spend(ctx context.Context, tenant *domain.Tenant) (float64, Window, error) {
+	window := PeriodWindow(tenant.BudgetPeriod, time.Now())
+	currentCost, err := m.tracker.GetTenantTotalCost(ctx, tenant.ID, window.Start)
+	if err != nil {
+		return 0, window, err
+	}
+	return currentCost, window, nil
+}
+
 func LogAlertHandler(alert Alert) {
 	slog.Warn("budget alert",
 		"tenant_id", alert.TenantID,
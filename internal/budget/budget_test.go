@@ -7,10 +7,13 @@ import (
 
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 type mockTracker struct {
-	costs map[string]float64
+	costs     map[string]float64
+	lastSince time.Time
 }
 
 func newMockTracker() *mockTracker {
@@ -23,6 +26,7 @@ func (m *mockTracker) Record(ctx context.Context, record cost.UsageRecord) error
 }
 
 func (m *mockTracker) GetTenantTotalCost(ctx context.Context, tenantID string, since time.Time) (float64, error) {
+	m.lastSince = since
 	return m.costs[tenantID], nil
 }
 
@@ -30,6 +34,34 @@ func (m *mockTracker) GetTenantUsage(ctx context.Context, tenantID string, since
 	return nil, nil
 }
 
+func (m *mockTracker) GetProviderTotals(ctx context.Context, since, until time.Time) ([]cost.ProviderTotals, error) {
+	return nil, nil
+}
+
+func (m *mockTracker) GetTenantTagTotals(ctx context.Context, tenantID, key string, since time.Time) ([]cost.TagTotals, error) {
+	return nil, nil
+}
+
+func TestThresholds_Level(t *testing.T) {
+	th := DefaultThresholds()
+
+	tests := []struct {
+		ratio float64
+		want  AlertLevel
+	}{
+		{0.5, ""},
+		{0.8, AlertLevelWarning},
+		{0.96, AlertLevelCritical},
+		{1.2, AlertLevelExceeded},
+	}
+
+	for _, tt := range tests {
+		if got := th.Level(tt.ratio); got != tt.want {
+			t.Errorf("Level(%v) = %q, want %q", tt.ratio, got, tt.want)
+		}
+	}
+}
+
 func TestDefaultThresholds(t *testing.T) {
 	th := DefaultThresholds()
 
@@ -189,6 +221,71 @@ func TestMonitor_Check_NoRepeatAlerts(t *testing.T) {
 	}
 }
 
+func TestMonitor_Check_SuppressedAlertMetrics(t *testing.T) {
+	metrics.BudgetAlertsSuppressed.Reset()
+	metrics.BudgetAlertLevel.Reset()
+
+	tracker := newMockTracker()
+	tracker.costs["tenant1"] = 96.0
+
+	monitor := NewMonitor(tracker, DefaultThresholds(), WithDeduplicator(NewInMemoryDeduplicator()))
+	tenant := &domain.Tenant{ID: "tenant1", BudgetUSD: 100.0}
+
+	monitor.Check(context.Background(), tenant)
+	if got := testutil.ToFloat64(metrics.BudgetAlertsSuppressed.WithLabelValues("critical")); got != 0 {
+		t.Errorf("suppressed after first alert = %v, want 0", got)
+	}
+
+	monitor.Check(context.Background(), tenant)
+	monitor.Check(context.Background(), tenant)
+	if got := testutil.ToFloat64(metrics.BudgetAlertsSuppressed.WithLabelValues("critical")); got != 2 {
+		t.Errorf("suppressed after repeats = %v, want 2", got)
+	}
+
+	if got := testutil.ToFloat64(metrics.BudgetAlertLevel.WithLabelValues("tenant1")); got != 2 {
+		t.Errorf("alert level gauge = %v, want 2", got)
+	}
+}
+
+func TestMonitor_Check_AlertLevelGaugeClears(t *testing.T) {
+	metrics.BudgetAlertLevel.Reset()
+
+	tracker := newMockTracker()
+	tracker.costs["tenant1"] = 85.0
+
+	monitor := NewMonitor(tracker, DefaultThresholds())
+	tenant := &domain.Tenant{ID: "tenant1", BudgetUSD: 100.0}
+
+	monitor.Check(context.Background(), tenant)
+	if got := testutil.ToFloat64(metrics.BudgetAlertLevel.WithLabelValues("tenant1")); got != 1 {
+		t.Errorf("alert level gauge = %v, want 1", got)
+	}
+
+	tracker.costs["tenant1"] = 10.0
+	monitor.Check(context.Background(), tenant)
+	if got := testutil.ToFloat64(metrics.BudgetAlertLevel.WithLabelValues("tenant1")); got != 0 {
+		t.Errorf("alert level gauge after drop = %v, want 0", got)
+	}
+}
+
+func TestAlertLevel_Severity(t *testing.T) {
+	tests := []struct {
+		level AlertLevel
+		want  int
+	}{
+		{"", 0},
+		{AlertLevelWarning, 1},
+		{AlertLevelCritical, 2},
+		{AlertLevelExceeded, 3},
+	}
+
+	for _, tt := range tests {
+		if got := tt.level.Severity(); got != tt.want {
+			t.Errorf("AlertLevel(%q).Severity() = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
+
 func TestMonitor_OnAlert(t *testing.T) {
 	tracker := newMockTracker()
 	tracker.costs["tenant1"] = 85.0
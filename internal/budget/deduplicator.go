@@ -16,7 +16,9 @@ type AlertDeduplicator interface {
 	// ShouldAlert checks if an alert should be sent for the given tenant and level.
 	// Returns true if this is a new alert that should be dispatched.
 	// Returns false if this alert was already sent (by this or another instance).
-	ShouldAlert(ctx context.Context, tenantID string, level AlertLevel) bool
+	// periodEnd is the end of the tenant's budget window; an alert recorded in
+	// one window never suppresses the same alert in the next.
+	ShouldAlert(ctx context.Context, tenantID string, level AlertLevel, periodEnd time.Time) bool
 
 	// ClearAlert removes the alert state for a tenant (e.g., when usage drops below threshold).
 	ClearAlert(ctx context.Context, tenantID string)
@@ -26,26 +28,31 @@ type AlertDeduplicator interface {
 // Suitable for single-instance deployments.
 type InMemoryDeduplicator struct {
 	mu         sync.RWMutex
-	lastAlerts map[string]AlertLevel
+	lastAlerts map[string]sentAlert
+}
+
+type sentAlert struct {
+	level     AlertLevel
+	periodEnd time.Time
 }
 
 // NewInMemoryDeduplicator creates a new in-memory alert deduplicator.
 func NewInMemoryDeduplicator() *InMemoryDeduplicator {
 	return &InMemoryDeduplicator{
-		lastAlerts: make(map[string]AlertLevel),
+		lastAlerts: make(map[string]sentAlert),
 	}
 }
 
-func (d *InMemoryDeduplicator) ShouldAlert(ctx context.Context, tenantID string, level AlertLevel) bool {
+func (d *InMemoryDeduplicator) ShouldAlert(ctx context.Context, tenantID string, level AlertLevel, periodEnd time.Time) bool {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	lastLevel, exists := d.lastAlerts[tenantID]
-	if exists && lastLevel == level {
+	last, exists := d.lastAlerts[tenantID]
+	if exists && last.level == level && last.periodEnd.Equal(periodEnd) {
 		return false
 	}
 
-	d.lastAlerts[tenantID] = level
+	d.lastAlerts[tenantID] = sentAlert{level: level, periodEnd: periodEnd}
 	return true
 }
 
@@ -64,7 +71,7 @@ type RedisDeduplicator struct {
 
 // NewRedisDeduplicator creates a new Redis-backed alert deduplicator.
 // lockTTL determines how long an alert is considered "sent" before it can be re-sent.
-// Recommended: 1 hour for budget alerts (they reset monthly).
+// The effective TTL never outlives the tenant's budget window.
 func NewRedisDeduplicator(redisURL string, lockTTL time.Duration) (*RedisDeduplicator, error) {
 	opts, err := redis.ParseURL(redisURL)
 	if err != nil {
@@ -95,8 +102,20 @@ func NewRedisDeduplicatorWithClient(client *redis.Client, lockTTL time.Duration)
 	}
 }
 
-func (d *RedisDeduplicator) alertKey(tenantID string, level AlertLevel) string {
-	return fmt.Sprintf("budget:alert:%s:%s", tenantID, level)
+func (d *RedisDeduplicator) alertKey(tenantID string, level AlertLevel, periodEnd time.Time) string {
+	return fmt.Sprintf("budget:alert:%s:%s:%d", tenantID, level, periodEnd.Unix())
+}
+
+// ttl caps lockTTL at the time remaining in the budget window.
+func (d *RedisDeduplicator) ttl(periodEnd time.Time) time.Duration {
+	remaining := time.Until(periodEnd)
+	if remaining <= 0 {
+		return time.Second
+	}
+	if d.lockTTL > 0 && d.lockTTL < remaining {
+		return d.lockTTL
+	}
+	return remaining
 }
 
 func (d *RedisDeduplicator) tenantKeyPattern(tenantID string) string {
@@ -105,12 +124,12 @@ func (d *RedisDeduplicator) tenantKeyPattern(tenantID string) string {
 
 // ShouldAlert uses Redis SETNX for atomic check-and-set.
 // Only one instance will successfully set the key and return true.
-func (d *RedisDeduplicator) ShouldAlert(ctx context.Context, tenantID string, level AlertLevel) bool {
-	key := d.alertKey(tenantID, level)
+func (d *RedisDeduplicator) ShouldAlert(ctx context.Context, tenantID string, level AlertLevel, periodEnd time.Time) bool {
+	key := d.alertKey(tenantID, level, periodEnd)
 
 	// Try to acquire the "lock" for this alert
 	// SETNX returns true only if the key didn't exist
-	acquired, err := d.client.SetNX(ctx, key, time.Now().Unix(), d.lockTTL).Result()
+	acquired, err := d.client.SetNX(ctx, key, time.Now().Unix(), d.ttl(periodEnd)).Result()
 	if err != nil {
 		// On Redis error, allow the alert (fail open)
 		return true
@@ -9,27 +9,29 @@ import (
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 )
 
+var testPeriodEnd = time.Now().Add(24 * time.Hour)
+
 func TestInMemoryDeduplicator_ShouldAlert(t *testing.T) {
 	ctx := context.Background()
 	d := NewInMemoryDeduplicator()
 
 	// First alert should be allowed
-	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, testPeriodEnd) {
 		t.Error("First alert should be allowed")
 	}
 
 	// Same alert should be deduplicated
-	if d.ShouldAlert(ctx, "tenant1", AlertLevelWarning) {
+	if d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, testPeriodEnd) {
 		t.Error("Same alert should be deduplicated")
 	}
 
 	// Different level should be allowed
-	if !d.ShouldAlert(ctx, "tenant1", AlertLevelCritical) {
+	if !d.ShouldAlert(ctx, "tenant1", AlertLevelCritical, testPeriodEnd) {
 		t.Error("Different level should be allowed")
 	}
 
 	// Different tenant should be allowed
-	if !d.ShouldAlert(ctx, "tenant2", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "tenant2", AlertLevelWarning, testPeriodEnd) {
 		t.Error("Different tenant should be allowed")
 	}
 }
@@ -39,17 +41,46 @@ func TestInMemoryDeduplicator_ClearAlert(t *testing.T) {
 	d := NewInMemoryDeduplicator()
 
 	// Set an alert
-	d.ShouldAlert(ctx, "tenant1", AlertLevelWarning)
+	d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, testPeriodEnd)
 
 	// Clear it
 	d.ClearAlert(ctx, "tenant1")
 
 	// Should be able to alert again
-	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, testPeriodEnd) {
 		t.Error("After clear, should be able to alert again")
 	}
 }
 
+func TestInMemoryDeduplicator_NewPeriod(t *testing.T) {
+	ctx := context.Background()
+	d := NewInMemoryDeduplicator()
+
+	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, testPeriodEnd) {
+		t.Fatal("First alert should be allowed")
+	}
+
+	// The same level in the next window is a new alert
+	next := testPeriodEnd.Add(24 * time.Hour)
+	if !d.ShouldAlert(ctx, "tenant1", AlertLevelWarning, next) {
+		t.Error("Alert in a new period should be allowed")
+	}
+}
+
+func TestRedisDeduplicator_TTLCappedAtPeriodEnd(t *testing.T) {
+	d := NewRedisDeduplicatorWithClient(nil, 24*time.Hour)
+
+	if ttl := d.ttl(time.Now().Add(time.Hour)); ttl > time.Hour {
+		t.Errorf("ttl = %v, want <= 1h", ttl)
+	}
+	if ttl := d.ttl(time.Now().Add(48 * time.Hour)); ttl != 24*time.Hour {
+		t.Errorf("ttl = %v, want lockTTL 24h", ttl)
+	}
+	if ttl := d.ttl(time.Now().Add(-time.Minute)); ttl <= 0 {
+		t.Errorf("ttl = %v, want positive for an elapsed period", ttl)
+	}
+}
+
 func getRedisURL(t *testing.T) string {
 	url := os.Getenv("REDIS_URL")
 	if url == "" {
@@ -70,17 +101,17 @@ func TestRedisDeduplicator_ShouldAlert(t *testing.T) {
 	defer d.ClearAlert(ctx, "redis-tenant1")
 
 	// First alert should be allowed
-	if !d.ShouldAlert(ctx, "redis-tenant1", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "redis-tenant1", AlertLevelWarning, testPeriodEnd) {
 		t.Error("First alert should be allowed")
 	}
 
 	// Same alert should be deduplicated
-	if d.ShouldAlert(ctx, "redis-tenant1", AlertLevelWarning) {
+	if d.ShouldAlert(ctx, "redis-tenant1", AlertLevelWarning, testPeriodEnd) {
 		t.Error("Same alert should be deduplicated")
 	}
 
 	// Different level should be allowed
-	if !d.ShouldAlert(ctx, "redis-tenant1", AlertLevelCritical) {
+	if !d.ShouldAlert(ctx, "redis-tenant1", AlertLevelCritical, testPeriodEnd) {
 		t.Error("Different level should be allowed")
 	}
 }
@@ -96,17 +127,17 @@ func TestRedisDeduplicator_ClearAlert(t *testing.T) {
 	defer d.Close()
 
 	// Set alerts at multiple levels
-	d.ShouldAlert(ctx, "redis-tenant2", AlertLevelWarning)
-	d.ShouldAlert(ctx, "redis-tenant2", AlertLevelCritical)
+	d.ShouldAlert(ctx, "redis-tenant2", AlertLevelWarning, testPeriodEnd)
+	d.ShouldAlert(ctx, "redis-tenant2", AlertLevelCritical, testPeriodEnd)
 
 	// Clear all alerts for tenant
 	d.ClearAlert(ctx, "redis-tenant2")
 
 	// Should be able to alert again at both levels
-	if !d.ShouldAlert(ctx, "redis-tenant2", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "redis-tenant2", AlertLevelWarning, testPeriodEnd) {
 		t.Error("After clear, should be able to alert warning again")
 	}
-	if !d.ShouldAlert(ctx, "redis-tenant2", AlertLevelCritical) {
+	if !d.ShouldAlert(ctx, "redis-tenant2", AlertLevelCritical, testPeriodEnd) {
 		t.Error("After clear, should be able to alert critical again")
 	}
 }
@@ -123,12 +154,12 @@ func TestRedisDeduplicator_TTLExpiry(t *testing.T) {
 	defer d.Close()
 
 	// Set an alert
-	if !d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning, testPeriodEnd) {
 		t.Error("First alert should be allowed")
 	}
 
 	// Should be deduplicated immediately
-	if d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning) {
+	if d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning, testPeriodEnd) {
 		t.Error("Same alert should be deduplicated")
 	}
 
@@ -136,7 +167,7 @@ func TestRedisDeduplicator_TTLExpiry(t *testing.T) {
 	time.Sleep(1100 * time.Millisecond)
 
 	// Should be able to alert again after TTL
-	if !d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning) {
+	if !d.ShouldAlert(ctx, "redis-tenant3", AlertLevelWarning, testPeriodEnd) {
 		t.Error("After TTL expiry, should be able to alert again")
 	}
 }
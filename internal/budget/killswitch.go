@@ -0,0 +1,161 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KillSwitch stops all spend across every tenant while it is on, e.g.
+// during an incident. Unlike a budget it ignores who is asking.
+type KillSwitch interface {
+	// On reports whether the switch is on.
+	On(ctx context.Context) (bool, error)
+
+	// Set turns the switch on or off.
+	Set(ctx context.Context, on bool) error
+
+	// Shared reports whether a change reaches every instance, or only the
+	// one it was made on.
+	Shared() bool
+}
+
+// InMemoryKillSwitch is a KillSwitch local to one instance.
+type InMemoryKillSwitch struct {
+	mu sync.RWMutex
+	on bool
+}
+
+// NewInMemoryKillSwitch creates a kill switch that starts off.
+func NewInMemoryKillSwitch() *InMemoryKillSwitch {
+	return &InMemoryKillSwitch{}
+}
+
+func (k *InMemoryKillSwitch) On(ctx context.Context) (bool, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.on, nil
+}
+
+func (k *InMemoryKillSwitch) Set(ctx context.Context, on bool) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.on = on
+	return nil
+}
+
+func (k *InMemoryKillSwitch) Shared() bool {
+	return false
+}
+
+const killSwitchKey = "budget:killswitch"
+
+// DefaultKillSwitchRefresh is how stale an instance's view of a shared kill
+// switch may be.
+const DefaultKillSwitchRefresh = time.Second
+
+// RedisKillSwitch shares the switch across instances through Redis. Each
+// instance re-reads it at most once per refresh interval, so flipping it
+// takes effect everywhere within that interval.
+type RedisKillSwitch struct {
+	client  *redis.Client
+	refresh time.Duration
+
+	mu     sync.Mutex
+	on     bool
+	readAt time.Time
+	// reading is set while one caller reads Redis; the others answer from
+	// the last state instead of waiting for it.
+	reading bool
+	// version counts Sets, so a read that started before one does not
+	// overwrite the state it wrote.
+	version uint64
+}
+
+// NewRedisKillSwitch creates a kill switch stored in Redis, re-read at most
+// once per refresh.
+func NewRedisKillSwitch(redisURL string, refresh time.Duration) (*RedisKillSwitch, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("redis connection failed: %w", err)
+	}
+
+	ks := NewRedisKillSwitchWithClient(client, refresh)
+	if _, err := ks.On(ctx); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewRedisKillSwitchWithClient creates a kill switch with an existing Redis
+// client.
+func NewRedisKillSwitchWithClient(client *redis.Client, refresh time.Duration) *RedisKillSwitch {
+	return &RedisKillSwitch{client: client, refresh: refresh}
+}
+
+// On reports the last state read from Redis. Once it is older than the
+// refresh interval one caller re-reads it, without holding the lock, while
+// the others keep answering from the last state. If Redis cannot be read the
+// switch is treated as off until the next refresh, so a Redis outage does
+// not stop all traffic; the error is returned for the caller to log.
+func (k *RedisKillSwitch) On(ctx context.Context) (bool, error) {
+	k.mu.Lock()
+	if k.reading || time.Since(k.readAt) < k.refresh {
+		on := k.on
+		k.mu.Unlock()
+		return on, nil
+	}
+	k.reading = true
+	version := k.version
+	k.mu.Unlock()
+
+	n, err := k.client.Exists(ctx, killSwitchKey).Result()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.reading = false
+	if k.version != version {
+		return k.on, nil
+	}
+	k.readAt = time.Now()
+	if err != nil {
+		k.on = false
+		return false, fmt.Errorf("read kill switch: %w", err)
+	}
+	k.on = n > 0
+	return k.on, nil
+}
+
+func (k *RedisKillSwitch) Set(ctx context.Context, on bool) error {
+	var err error
+	if on {
+		err = k.client.Set(ctx, killSwitchKey, time.Now().Unix(), 0).Err()
+	} else {
+		err = k.client.Del(ctx, killSwitchKey).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("set kill switch: %w", err)
+	}
+
+	k.mu.Lock()
+	k.on, k.readAt = on, time.Now()
+	k.version++
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *RedisKillSwitch) Shared() bool {
+	return true
+}
@@ -0,0 +1,96 @@
+package budget
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisKillSwitch_SharedAcrossInstances(t *testing.T) {
+	redisURL := getRedisURL(t)
+	ctx := context.Background()
+
+	a, err := NewRedisKillSwitch(redisURL, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewRedisKillSwitch() error = %v", err)
+	}
+	b, err := NewRedisKillSwitch(redisURL, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewRedisKillSwitch() error = %v", err)
+	}
+	defer a.Set(ctx, false)
+
+	if err := a.Set(ctx, true); err != nil {
+		t.Fatalf("Set(true) error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if on, err := b.On(ctx); err != nil || !on {
+		t.Errorf("other instance On() = %v, %v; want true", on, err)
+	}
+
+	if err := a.Set(ctx, false); err != nil {
+		t.Fatalf("Set(false) error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if on, _ := b.On(ctx); on {
+		t.Error("other instance still sees the switch on after it was turned off")
+	}
+}
+
+func TestRedisKillSwitch_OnDoesNotWaitForSlowRead(t *testing.T) {
+	// A server that accepts connections and never answers stands in for a
+	// Redis that hangs.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), ReadTimeout: 2 * time.Second, MaxRetries: -1})
+	defer client.Close()
+	ks := NewRedisKillSwitchWithClient(client, time.Millisecond)
+
+	go ks.On(context.Background())
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan bool)
+	go func() {
+		on, _ := ks.On(context.Background())
+		done <- on
+	}()
+	select {
+	case on := <-done:
+		if on {
+			t.Error("On() = true, want the last state (off)")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("On() waited for another caller's Redis read")
+	}
+}
+
+func TestKillSwitch_Shared(t *testing.T) {
+	if NewInMemoryKillSwitch().Shared() {
+		t.Error("in-memory kill switch reports itself shared")
+	}
+	if !NewRedisKillSwitchWithClient(redis.NewClient(&redis.Options{}), time.Second).Shared() {
+		t.Error("redis kill switch reports itself local")
+	}
+}
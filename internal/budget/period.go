@@ -0,0 +1,58 @@
+package budget
+
+import (
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// Window is the half-open interval [Start, End) a budget is measured over.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Elapsed returns the fraction of the window that has passed at now, in [0, 1].
+func (w Window) Elapsed(now time.Time) float64 {
+	total := w.End.Sub(w.Start)
+	if total <= 0 {
+		return 1
+	}
+	f := float64(now.Sub(w.Start)) / float64(total)
+	switch {
+	case f < 0:
+		return 0
+	case f > 1:
+		return 1
+	}
+	return f
+}
+
+// ProjectCost linearly extrapolates spend so far to the end of the window.
+// Very early in a window the projection is unreliable, so spend is returned
+// unchanged until at least one percent of the window has elapsed.
+func (w Window) ProjectCost(spent float64, now time.Time) float64 {
+	elapsed := w.Elapsed(now)
+	if elapsed < 0.01 {
+		return spent
+	}
+	return spent / elapsed
+}
+
+// PeriodWindow returns the budget window containing now. Windows are computed
+// in UTC; weeks start on Monday. An empty period is treated as monthly.
+func PeriodWindow(period domain.BudgetPeriod, now time.Time) Window {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case domain.BudgetPeriodDaily:
+		return Window{Start: day, End: day.AddDate(0, 0, 1)}
+	case domain.BudgetPeriodWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return Window{Start: start, End: start.AddDate(0, 0, 7)}
+	default:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return Window{Start: start, End: start.AddDate(0, 1, 0)}
+	}
+}
@@ -0,0 +1,153 @@
+package budget
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// DefaultPeriodCostRefreshInterval is how often tracked tenants' spend is
+// re-read so the gauge resets when a budget period rolls over.
+const DefaultPeriodCostRefreshInterval = time.Minute
+
+// PeriodCostGauge publishes each tenant's spend in its current budget period
+// as aigateway_tenant_period_cost_usd. Tenants are tracked from their first
+// observed request, up to maxTenants; later tenants get no series, which
+// keeps the metric's cardinality bounded. Spend is read from the tracker when
+// a tenant is first seen and on every refresh; in between, observed costs
+// are added in memory so requests do not each query the tracker.
+type PeriodCostGauge struct {
+	tracker    cost.Tracker
+	maxTenants int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	tenants map[string]*periodSpend
+
+	refreshInterval time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+}
+
+// periodSpend is a tracked tenant's spend in the window starting at start.
+type periodSpend struct {
+	period domain.BudgetPeriod
+	start  time.Time
+	spent  float64
+}
+
+// PeriodCostGaugeOption configures a PeriodCostGauge.
+type PeriodCostGaugeOption func(*PeriodCostGauge)
+
+// WithPeriodCostRefreshInterval sets how often tracked tenants are refreshed
+// in the background. Zero or a negative interval disables the refresh.
+func WithPeriodCostRefreshInterval(d time.Duration) PeriodCostGaugeOption {
+	return func(g *PeriodCostGauge) {
+		g.refreshInterval = d
+	}
+}
+
+// NewPeriodCostGauge creates a gauge that tracks at most maxTenants tenants.
+func NewPeriodCostGauge(tracker cost.Tracker, maxTenants int, opts ...PeriodCostGaugeOption) *PeriodCostGauge {
+	g := &PeriodCostGauge{
+		tracker:         tracker,
+		maxTenants:      maxTenants,
+		now:             time.Now,
+		tenants:         make(map[string]*periodSpend),
+		refreshInterval: DefaultPeriodCostRefreshInterval,
+		stop:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if g.refreshInterval > 0 {
+		go g.run()
+	}
+	return g
+}
+
+// Stop ends the background refresh.
+func (g *PeriodCostGauge) Stop() {
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// Observe adds costUSD to the tenant's gauge after its usage was recorded.
+// The first observation of a tenant reads its spend from the tracker, which
+// already includes costUSD. A tenant not yet tracked is ignored once the cap
+// is reached.
+func (g *PeriodCostGauge) Observe(ctx context.Context, tenant *domain.Tenant, costUSD float64) error {
+	window := PeriodWindow(tenant.BudgetPeriod, g.now())
+
+	g.mu.Lock()
+	s, ok := g.tenants[tenant.ID]
+	if ok && s.period == tenant.BudgetPeriod {
+		if !s.start.Equal(window.Start) {
+			// The period rolled over since the last read.
+			s.start, s.spent = window.Start, 0
+		}
+		s.spent += costUSD
+		metrics.SetTenantPeriodCost(tenant.ID, s.spent)
+		g.mu.Unlock()
+		return nil
+	}
+	if !ok && len(g.tenants) >= g.maxTenants {
+		g.mu.Unlock()
+		return nil
+	}
+	g.tenants[tenant.ID] = &periodSpend{period: tenant.BudgetPeriod}
+	g.mu.Unlock()
+
+	return g.update(ctx, tenant.ID, tenant.BudgetPeriod)
+}
+
+// Refresh re-reads the spend of every tracked tenant.
+func (g *PeriodCostGauge) Refresh(ctx context.Context) {
+	g.mu.Lock()
+	tenants := make(map[string]domain.BudgetPeriod, len(g.tenants))
+	for id, s := range g.tenants {
+		tenants[id] = s.period
+	}
+	g.mu.Unlock()
+
+	for id, period := range tenants {
+		if err := g.update(ctx, id, period); err != nil {
+			slog.Warn("failed to refresh tenant period cost", "tenant_id", id, "error", err)
+		}
+	}
+}
+
+// update reads the tenant's spend in its current window from the tracker.
+func (g *PeriodCostGauge) update(ctx context.Context, tenantID string, period domain.BudgetPeriod) error {
+	window := PeriodWindow(period, g.now())
+	spent, err := g.tracker.GetTenantTotalCost(ctx, tenantID, window.Start)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.tenants[tenantID]; ok {
+		s.start, s.spent = window.Start, spent
+	}
+	metrics.SetTenantPeriodCost(tenantID, spent)
+	return nil
+}
+
+func (g *PeriodCostGauge) run() {
+	ticker := time.NewTicker(g.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			g.Refresh(context.Background())
+		}
+	}
+}
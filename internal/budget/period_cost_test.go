@@ -0,0 +1,98 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPeriodCostGauge_Observe(t *testing.T) {
+	ctx := context.Background()
+	tracker := cost.NewInMemoryTracker()
+	gauge := NewPeriodCostGauge(tracker, 2, WithPeriodCostRefreshInterval(0))
+	now := time.Now()
+
+	tenants := []*domain.Tenant{
+		{ID: "gauge-a", BudgetPeriod: domain.BudgetPeriodMonthly},
+		{ID: "gauge-b", BudgetPeriod: domain.BudgetPeriodMonthly},
+		{ID: "gauge-c", BudgetPeriod: domain.BudgetPeriodMonthly},
+	}
+	for _, tenant := range tenants {
+		tracker.Record(ctx, cost.UsageRecord{TenantID: tenant.ID, CostUSD: 1.5, Timestamp: now})
+		if err := gauge.Observe(ctx, tenant, 1.5); err != nil {
+			t.Fatalf("Observe(%s) error = %v", tenant.ID, err)
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues("gauge-a")); got != 1.5 {
+		t.Errorf("gauge-a = %v, want 1.5", got)
+	}
+
+	// Later observations add in memory without reading the tracker, so a cost
+	// the tracker never saw still moves the gauge until the next refresh.
+	if err := gauge.Observe(ctx, tenants[0], 2); err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues("gauge-a")); got != 3.5 {
+		t.Errorf("gauge-a after second request = %v, want 3.5", got)
+	}
+	gauge.Refresh(ctx)
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues("gauge-a")); got != 1.5 {
+		t.Errorf("gauge-a after refresh = %v, want 1.5", got)
+	}
+
+	// The third tenant is over the series cap.
+	if _, ok := gauge.tenants["gauge-c"]; ok || len(gauge.tenants) != 2 {
+		t.Errorf("tracked tenants = %v, want gauge-a and gauge-b", gauge.tenants)
+	}
+}
+
+func TestPeriodCostGauge_RefreshResetsOnNewPeriod(t *testing.T) {
+	ctx := context.Background()
+	tracker := cost.NewInMemoryTracker()
+	gauge := NewPeriodCostGauge(tracker, 10, WithPeriodCostRefreshInterval(0))
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+	gauge.now = func() time.Time { return now }
+
+	tenant := &domain.Tenant{ID: "gauge-daily", BudgetPeriod: domain.BudgetPeriodDaily}
+	tracker.Record(ctx, cost.UsageRecord{TenantID: tenant.ID, CostUSD: 4, Timestamp: now})
+	if err := gauge.Observe(ctx, tenant, 4); err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues(tenant.ID)); got != 4 {
+		t.Fatalf("gauge = %v, want 4", got)
+	}
+
+	now = now.Add(2 * time.Hour)
+	gauge.Refresh(ctx)
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues(tenant.ID)); got != 0 {
+		t.Errorf("gauge after day rollover = %v, want 0", got)
+	}
+}
+
+func TestPeriodCostGauge_ObserveResetsOnNewPeriod(t *testing.T) {
+	ctx := context.Background()
+	tracker := cost.NewInMemoryTracker()
+	gauge := NewPeriodCostGauge(tracker, 10, WithPeriodCostRefreshInterval(0))
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+	gauge.now = func() time.Time { return now }
+
+	tenant := &domain.Tenant{ID: "gauge-daily-observe", BudgetPeriod: domain.BudgetPeriodDaily}
+	tracker.Record(ctx, cost.UsageRecord{TenantID: tenant.ID, CostUSD: 4, Timestamp: now})
+	if err := gauge.Observe(ctx, tenant, 4); err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := gauge.Observe(ctx, tenant, 1); err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TenantPeriodCost.WithLabelValues(tenant.ID)); got != 1 {
+		t.Errorf("gauge after day rollover = %v, want 1", got)
+	}
+}
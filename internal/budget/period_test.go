@@ -0,0 +1,121 @@
+package budget
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestPeriodWindow(t *testing.T) {
+	// Wednesday 2024-02-28 15:30 UTC
+	now := time.Date(2024, 2, 28, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		period    domain.BudgetPeriod
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "daily",
+			period:    domain.BudgetPeriodDaily,
+			now:       now,
+			wantStart: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "weekly starts on monday",
+			period:    domain.BudgetPeriodWeekly,
+			now:       now,
+			wantStart: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "weekly on sunday belongs to previous week",
+			period:    domain.BudgetPeriodWeekly,
+			now:       time.Date(2024, 3, 3, 23, 59, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 2, 26, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "monthly",
+			period:    domain.BudgetPeriodMonthly,
+			now:       now,
+			wantStart: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "empty defaults to monthly",
+			period:    "",
+			now:       time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "non-UTC input is normalized",
+			period:    domain.BudgetPeriodDaily,
+			now:       time.Date(2024, 2, 28, 22, 0, 0, 0, time.FixedZone("UTC-5", -5*3600)),
+			wantStart: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := PeriodWindow(tt.period, tt.now)
+			if !w.Start.Equal(tt.wantStart) {
+				t.Errorf("Start = %v, want %v", w.Start, tt.wantStart)
+			}
+			if !w.End.Equal(tt.wantEnd) {
+				t.Errorf("End = %v, want %v", w.End, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestWindow_ProjectCost(t *testing.T) {
+	// February 2024 has 29 days; noon on the 15th is exactly mid-period.
+	monthly := PeriodWindow(domain.BudgetPeriodMonthly, time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC))
+	daily := PeriodWindow(domain.BudgetPeriodDaily, time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name   string
+		window Window
+		spent  float64
+		now    time.Time
+		want   float64
+	}{
+		{"monthly mid-period doubles spend", monthly, 40, time.Date(2024, 2, 15, 12, 0, 0, 0, time.UTC), 80},
+		{"daily quarter elapsed", daily, 2.5, time.Date(2024, 2, 10, 6, 0, 0, 0, time.UTC), 10},
+		{"window finished", daily, 7, time.Date(2024, 2, 11, 3, 0, 0, 0, time.UTC), 7},
+		{"too early to project", daily, 1, daily.Start.Add(time.Minute), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.window.ProjectCost(tt.spent, tt.now)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ProjectCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonitor_UsesTenantPeriod(t *testing.T) {
+	tracker := newMockTracker()
+	monitor := NewMonitor(tracker, DefaultThresholds())
+
+	tenant := &domain.Tenant{ID: "daily-tenant", BudgetUSD: 10, BudgetPeriod: domain.BudgetPeriodDaily}
+	if _, err := monitor.IsBudgetExceeded(context.Background(), tenant); err != nil {
+		t.Fatalf("IsBudgetExceeded() error = %v", err)
+	}
+
+	want := PeriodWindow(domain.BudgetPeriodDaily, time.Now()).Start
+	if !tracker.lastSince.Equal(want) {
+		t.Errorf("tracker queried since %v, want %v", tracker.lastSince, want)
+	}
+}
@@ -0,0 +1,129 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// ErrProviderCapReached is returned when a provider has spent its daily cost
+// cap and must not receive more traffic until the next UTC day.
+var ErrProviderCapReached = errors.New("provider daily cost cap reached")
+
+// DefaultCapRefreshInterval is how long provider spend is cached between
+// tracker queries.
+const DefaultCapRefreshInterval = 10 * time.Second
+
+// ProviderCaps enforces a daily spend ceiling per provider, across all
+// tenants, using the usage tracker's per-provider totals. Spend is cached for
+// the refresh interval, so a cap can be overshot by what the gateway spends
+// within one interval. Providers without a cap are never blocked.
+type ProviderCaps struct {
+	tracker cost.Tracker
+	caps    map[string]float64
+	refresh time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	spent    map[string]float64
+	loadedAt time.Time
+	window   Window
+}
+
+// ProviderCapsOption configures ProviderCaps.
+type ProviderCapsOption func(*ProviderCaps)
+
+// WithCapRefreshInterval sets how often provider spend is re-read from the
+// tracker. Zero reads it on every check.
+func WithCapRefreshInterval(d time.Duration) ProviderCapsOption {
+	return func(p *ProviderCaps) {
+		p.refresh = d
+	}
+}
+
+// NewProviderCaps creates caps from a map of provider ID to daily USD limit.
+func NewProviderCaps(tracker cost.Tracker, caps map[string]float64, opts ...ProviderCapsOption) *ProviderCaps {
+	p := &ProviderCaps{
+		tracker: tracker,
+		caps:    make(map[string]float64, len(caps)),
+		refresh: DefaultCapRefreshInterval,
+		now:     time.Now,
+	}
+	for id, limit := range caps {
+		p.caps[id] = limit
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Check returns ErrProviderCapReached if the provider has spent its daily
+// cap. If spend cannot be read the provider is allowed, since a tracker
+// outage should not take every capped provider offline.
+func (p *ProviderCaps) Check(ctx context.Context, providerID string) error {
+	limit, ok := p.caps[providerID]
+	if !ok {
+		return nil
+	}
+
+	spent, err := p.spend(ctx)
+	if err != nil {
+		slog.Warn("failed to read provider spend, allowing request", "provider", providerID, "error", err)
+		return nil
+	}
+	if spent[providerID] >= limit {
+		return ErrProviderCapReached
+	}
+	return nil
+}
+
+// Remaining returns the USD left today for each capped provider.
+func (p *ProviderCaps) Remaining(ctx context.Context) (map[string]float64, error) {
+	spent, err := p.spend(ctx)
+	if err != nil {
+		return nil, err
+	}
+	remaining := make(map[string]float64, len(p.caps))
+	for id, limit := range p.caps {
+		remaining[id] = max(limit-spent[id], 0)
+	}
+	return remaining, nil
+}
+
+// spend returns today's per-provider spend, reloading it from the tracker
+// when the cache is stale or the day has rolled over.
+func (p *ProviderCaps) spend(ctx context.Context) (map[string]float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	window := PeriodWindow(domain.BudgetPeriodDaily, now)
+	if p.spent != nil && window.Start.Equal(p.window.Start) && now.Sub(p.loadedAt) < p.refresh {
+		return p.spent, nil
+	}
+
+	totals, err := p.tracker.GetProviderTotals(ctx, window.Start, window.End)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make(map[string]float64, len(totals))
+	for _, t := range totals {
+		spent[t.Provider] = t.CostUSD
+	}
+	for id, limit := range p.caps {
+		metrics.SetProviderCostCapRemaining(id, max(limit-spent[id], 0))
+	}
+
+	p.spent = spent
+	p.loadedAt = now
+	p.window = window
+	return spent, nil
+}
@@ -0,0 +1,69 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProviderCaps_Check(t *testing.T) {
+	tracker := cost.NewInMemoryTracker()
+	now := time.Now()
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "openai", CostUSD: 12, Timestamp: now})
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "anthropic", CostUSD: 4, Timestamp: now})
+	// Yesterday's spend does not count against today's cap.
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "anthropic", CostUSD: 100, Timestamp: now.AddDate(0, 0, -1)})
+
+	caps := NewProviderCaps(tracker, map[string]float64{"openai": 10, "anthropic": 10}, WithCapRefreshInterval(0))
+
+	if err := caps.Check(context.Background(), "openai"); !errors.Is(err, ErrProviderCapReached) {
+		t.Errorf("openai: expected ErrProviderCapReached, got %v", err)
+	}
+	if err := caps.Check(context.Background(), "anthropic"); err != nil {
+		t.Errorf("anthropic: unexpected error %v", err)
+	}
+	if err := caps.Check(context.Background(), "ollama"); err != nil {
+		t.Errorf("uncapped provider: unexpected error %v", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.ProviderCostCapRemaining.WithLabelValues("anthropic")); got != 6 {
+		t.Errorf("anthropic remaining gauge = %v, want 6", got)
+	}
+	if got := testutil.ToFloat64(metrics.ProviderCostCapRemaining.WithLabelValues("openai")); got != 0 {
+		t.Errorf("openai remaining gauge = %v, want 0", got)
+	}
+}
+
+func TestProviderCaps_CachesSpend(t *testing.T) {
+	tracker := cost.NewInMemoryTracker()
+	caps := NewProviderCaps(tracker, map[string]float64{"openai": 10}, WithCapRefreshInterval(time.Minute))
+	now := time.Now()
+	caps.now = func() time.Time { return now }
+
+	if err := caps.Check(context.Background(), "openai"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tracker.Record(context.Background(), cost.UsageRecord{Provider: "openai", CostUSD: 20, Timestamp: now})
+
+	if err := caps.Check(context.Background(), "openai"); err != nil {
+		t.Errorf("expected cached spend within refresh interval, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := caps.Check(context.Background(), "openai"); !errors.Is(err, ErrProviderCapReached) {
+		t.Errorf("expected cap reached after refresh, got %v", err)
+	}
+
+	remaining, err := caps.Remaining(context.Background())
+	if err != nil {
+		t.Fatalf("Remaining() error = %v", err)
+	}
+	if remaining["openai"] != 0 {
+		t.Errorf("remaining = %v, want 0", remaining["openai"])
+	}
+}
@@ -0,0 +1,53 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/notifications"
+)
+
+// webhookTimeout bounds one alert's delivery, retries included.
+const webhookTimeout = time.Minute
+
+// WithTenantWebhooks sends each alert to the tenant's WebhookURL, if it has
+// one, through n, signed with the tenant's own WebhookSecret. Alerts
+// suppressed by the deduplicator are not sent.
+func WithTenantWebhooks(n *notifications.WebhookNotifier) MonitorOption {
+	return func(m *Monitor) {
+		m.webhooks = n
+	}
+}
+
+// postWebhook delivers alert to the tenant's webhook. The notification ID is
+// the same for every instance and retry that sends this alert in this budget
+// window. A tenant without a signing secret gets nothing, since it could not
+// tell the delivery from a forgery.
+func (m *Monitor) postWebhook(tenant *domain.Tenant, alert Alert, periodEnd time.Time) {
+	if tenant.WebhookSecret == "" {
+		slog.Warn("budget webhook skipped: tenant has no webhook secret", "tenant_id", tenant.ID)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	n := notifications.Notification{
+		ID:       fmt.Sprintf("budget:%s:%s:%d", alert.TenantID, alert.Level, periodEnd.Unix()),
+		Type:     notifications.NotificationType("budget_" + string(alert.Level)),
+		TenantID: alert.TenantID,
+		Message:  fmt.Sprintf("Budget usage at %.0f%%", alert.Percentage),
+		Data: map[string]interface{}{
+			"usage_pct":  alert.Percentage,
+			"budget_usd": alert.Budget,
+			"spent_usd":  alert.CurrentUse,
+			"period_end": periodEnd.Format(time.RFC3339),
+		},
+	}
+	if err := m.webhooks.Post(ctx, tenant.WebhookURL, tenant.WebhookSecret, n); err != nil {
+		slog.Warn("budget webhook failed", "tenant_id", alert.TenantID, "level", alert.Level, "error", err)
+	}
+}
@@ -0,0 +1,109 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/notifications"
+)
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+func TestMonitor_TenantWebhook(t *testing.T) {
+	secret := "whsec-test"
+	received := make(chan delivery, 10)
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- delivery{header: r.Header.Clone(), body: body}
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	tracker := newMockTracker()
+	tracker.costs["tenant-1"] = 85
+	webhooks := notifications.NewWebhookNotifier(notifications.WithWebhookRetries(2, time.Millisecond))
+	monitor := NewMonitor(tracker, DefaultThresholds(), WithTenantWebhooks(webhooks))
+	tenant := &domain.Tenant{ID: "tenant-1", BudgetUSD: 100, WebhookURL: srv.URL, WebhookSecret: secret}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := monitor.Check(ctx, tenant); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+
+	var got []delivery
+	for len(got) < 2 {
+		select {
+		case d := <-received:
+			got = append(got, d)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d webhook attempts, want 2 (one retried)", len(got))
+		}
+	}
+	select {
+	case <-received:
+		t.Fatal("deduplicated alert was delivered again")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first, retry := got[0], got[1]
+	if id := first.header.Get(notifications.WebhookDeliveryHeader); id == "" || id != retry.header.Get(notifications.WebhookDeliveryHeader) {
+		t.Errorf("delivery IDs = %q, %q; want the same non-empty ID on retry", id, retry.header.Get(notifications.WebhookDeliveryHeader))
+	}
+	if sig := retry.header.Get(notifications.WebhookSignatureHeader); sig != notifications.SignWebhook([]byte(secret), retry.body) {
+		t.Errorf("signature %q does not match the body", sig)
+	}
+
+	var n notifications.Notification
+	if err := json.Unmarshal(retry.body, &n); err != nil {
+		t.Fatalf("decode webhook body: %v", err)
+	}
+	if n.Type != notifications.NotificationBudgetWarning || n.TenantID != "tenant-1" {
+		t.Errorf("notification = %s for %s, want budget_warning for tenant-1", n.Type, n.TenantID)
+	}
+	if n.Data["spent_usd"] != 85.0 || n.Data["budget_usd"] != 100.0 {
+		t.Errorf("notification data = %v", n.Data)
+	}
+}
+
+func TestMonitor_TenantWebhookNotConfigured(t *testing.T) {
+	called := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called <- struct{}{}
+	}))
+	defer srv.Close()
+
+	tracker := newMockTracker()
+	monitor := NewMonitor(tracker, DefaultThresholds(), WithTenantWebhooks(notifications.NewWebhookNotifier()))
+
+	for _, tenant := range []*domain.Tenant{
+		{ID: "tenant-1", BudgetUSD: 100},
+		{ID: "tenant-2", BudgetUSD: 100, WebhookURL: srv.URL},
+	} {
+		tracker.costs[tenant.ID] = 120
+		if _, err := monitor.Check(context.Background(), tenant); err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+	}
+	select {
+	case <-called:
+		t.Fatal("webhook sent for a tenant without a webhook URL or secret")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
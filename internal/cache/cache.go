@@ -19,68 +19,133 @@ import (
 type Cache interface {
 	Get(ctx context.Context, key string) (*domain.ChatResponse, bool)
 	Set(ctx context.Context, key string, resp *domain.ChatResponse, ttl time.Duration) error
+
+	// Delete removes the entries for requests whose model matches pattern,
+	// and every entry if pattern is empty. It returns how many were removed.
+	Delete(ctx context.Context, pattern string) (int, error)
+}
+
+// Entry is a cached response and when it was stored.
+type Entry struct {
+	Response *domain.ChatResponse
+	// StoredAt is zero when the backend does not know the entry's age.
+	StoredAt time.Time
+}
+
+// EntryGetter is implemented by backends that record when each response was
+// stored.
+type EntryGetter interface {
+	GetEntry(ctx context.Context, key string) (Entry, bool)
+}
+
+// GetFresh returns the response stored under key only if it was stored no
+// more than maxAge ago, as for a Cache-Control: max-age request directive.
+// Entries whose age is unknown are treated as misses.
+func GetFresh(ctx context.Context, c Cache, key string, maxAge time.Duration) (*domain.ChatResponse, bool) {
+	getter, ok := c.(EntryGetter)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := getter.GetEntry(ctx, key)
+	if !ok || entry.StoredAt.IsZero() || time.Since(entry.StoredAt) > maxAge {
+		return nil, false
+	}
+	return entry.Response, true
 }
 
 // GenerateCacheKey creates a unique cache key from a chat request.
-// The key is a SHA-256 hash of the model, messages, temperature, and max_tokens.
+// The key is a SHA-256 hash of the model, messages and sampling parameters,
+// prefixed with the model so entries can be deleted by model.
 func GenerateCacheKey(req domain.ChatRequest) string {
 	data, _ := json.Marshal(struct {
 		Model       string           `json:"model"`
 		Messages    []domain.Message `json:"messages"`
 		Temperature *float64         `json:"temperature,omitempty"`
+		TopP        *float64         `json:"top_p,omitempty"`
+		Stop        []string         `json:"stop,omitempty"`
 		MaxTokens   *int             `json:"max_tokens,omitempty"`
+		Logprobs    bool             `json:"logprobs,omitempty"`
+		TopLogprobs *int             `json:"top_logprobs,omitempty"`
+		Parallel    *bool            `json:"parallel_tool_calls,omitempty"`
+		Tools       []domain.Tool    `json:"tools,omitempty"`
 	}{
 		Model:       req.Model,
 		Messages:    req.Messages,
 		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
 		MaxTokens:   req.MaxTokens,
+		Logprobs:    req.Logprobs,
+		TopLogprobs: req.TopLogprobs,
+		Parallel:    req.ParallelToolCalls,
+		Tools:       req.Tools,
 	})
 
 	hash := sha256.Sum256(data)
-	return "cache:" + hex.EncodeToString(hash[:])
+	return keyPrefix + req.Model + ":" + hex.EncodeToString(hash[:])
 }
 
 type InMemoryCache struct {
 	mu    sync.RWMutex
 	items map[string]*cacheItem
+	opts  options
 }
 
 type cacheItem struct {
 	response  *domain.ChatResponse
+	storedAt  time.Time
 	expiresAt time.Time
 }
 
-func NewInMemoryCache() *InMemoryCache {
+func NewInMemoryCache(opts ...Option) *InMemoryCache {
 	c := &InMemoryCache{
 		items: make(map[string]*cacheItem),
+		opts:  applyOptions(opts),
 	}
 	go c.cleanup()
 	return c
 }
 
 func (c *InMemoryCache) Get(ctx context.Context, key string) (*domain.ChatResponse, bool) {
+	entry, ok := c.GetEntry(ctx, key)
+	return entry.Response, ok
+}
+
+func (c *InMemoryCache) GetEntry(ctx context.Context, key string) (Entry, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	item, ok := c.items[key]
 	if !ok {
-		return nil, false
+		return Entry{}, false
 	}
 
 	if time.Now().After(item.expiresAt) {
-		return nil, false
+		return Entry{}, false
 	}
 
-	return item.response, true
+	return Entry{Response: item.response, StoredAt: item.storedAt}, true
 }
 
 func (c *InMemoryCache) Set(ctx context.Context, key string, resp *domain.ChatResponse, ttl time.Duration) error {
+	if c.opts.maxValueSize > 0 {
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		if err := c.opts.checkSize(len(data)); err != nil {
+			return err
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	now := time.Now()
 	c.items[key] = &cacheItem{
 		response:  resp,
-		expiresAt: time.Now().Add(ttl),
+		storedAt:  now,
+		expiresAt: now.Add(ttl),
 	}
 
 	return nil
@@ -104,15 +169,19 @@ func (c *InMemoryCache) cleanup() {
 
 type RedisCache struct {
 	client *redis.Client
+	opts   options
 }
 
-func NewRedisCache(redisURL string) (*RedisCache, error) {
-	opts, err := redis.ParseURL(redisURL)
+func NewRedisCache(redisURL string, opts ...Option) (*RedisCache, error) {
+	redisOpts, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, err
 	}
 
-	client := redis.NewClient(opts)
+	// Retries are the cache's own (see WithRetries); go-redis would
+	// otherwise retry each attempt again underneath them.
+	redisOpts.MaxRetries = -1
+	client := redis.NewClient(redisOpts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -121,30 +190,41 @@ func NewRedisCache(redisURL string) (*RedisCache, error) {
 		return nil, err
 	}
 
-	return &RedisCache{client: client}, nil
+	return &RedisCache{client: client, opts: applyOptions(opts)}, nil
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string) (*domain.ChatResponse, bool) {
-	data, err := c.client.Get(ctx, key).Bytes()
+	entry, ok := c.GetEntry(ctx, key)
+	return entry.Response, ok
+}
+
+func (c *RedisCache) GetEntry(ctx context.Context, key string) (Entry, bool) {
+	var data []byte
+	err := c.opts.retry(ctx, "get", func() (err error) {
+		data, err = c.client.Get(ctx, key).Bytes()
+		return err
+	})
 	if err != nil {
-		return nil, false
+		return Entry{}, false
 	}
 
-	var resp domain.ChatResponse
-	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, false
+	entry, err := decodeValue(data)
+	if err != nil {
+		return Entry{}, false
 	}
 
-	return &resp, true
+	return entry, true
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, resp *domain.ChatResponse, ttl time.Duration) error {
-	data, err := json.Marshal(resp)
+	data, err := c.opts.encodeValue(resp, time.Now())
 	if err != nil {
 		return err
 	}
 
-	return c.client.Set(ctx, key, data, ttl).Err()
+	return c.opts.retry(ctx, "set", func() error {
+		return c.client.Set(ctx, key, data, ttl).Err()
+	})
 }
 
 func (c *RedisCache) Close() error {
@@ -67,6 +67,51 @@ func TestInMemoryCache_Expiration(t *testing.T) {
 	}
 }
 
+func TestGetFresh(t *testing.T) {
+	c := NewInMemoryCache()
+	ctx := context.Background()
+
+	c.Set(ctx, "key", &domain.ChatResponse{ID: "cached"}, time.Hour)
+	c.items["key"].storedAt = time.Now().Add(-10 * time.Minute)
+
+	if _, ok := c.Get(ctx, "key"); !ok {
+		t.Fatal("Get() missed an entry within its TTL")
+	}
+	if resp, ok := GetFresh(ctx, c, "key", 15*time.Minute); !ok || resp.ID != "cached" {
+		t.Errorf("GetFresh(15m) = %v, %v; want hit", resp, ok)
+	}
+	if _, ok := GetFresh(ctx, c, "key", 5*time.Minute); ok {
+		t.Error("GetFresh(5m) hit an entry stored 10 minutes ago")
+	}
+	if _, ok := GetFresh(ctx, c, "missing", time.Hour); ok {
+		t.Error("GetFresh() hit a missing key")
+	}
+}
+
+func TestDecodeValue_StoredAt(t *testing.T) {
+	storedAt := time.UnixMilli(time.Now().UnixMilli())
+	data, err := options{}.encodeValue(&domain.ChatResponse{ID: "cached"}, storedAt)
+	if err != nil {
+		t.Fatalf("encodeValue() error = %v", err)
+	}
+	entry, err := decodeValue(data)
+	if err != nil {
+		t.Fatalf("decodeValue() error = %v", err)
+	}
+	if entry.Response.ID != "cached" || !entry.StoredAt.Equal(storedAt) {
+		t.Errorf("entry = %+v, want ID cached stored at %v", entry, storedAt)
+	}
+
+	// Values written before store times were recorded have no age.
+	entry, err = decodeValue([]byte(`{"id":"legacy","object":"chat.completion"}`))
+	if err != nil {
+		t.Fatalf("decodeValue() error = %v", err)
+	}
+	if entry.Response.ID != "legacy" || !entry.StoredAt.IsZero() {
+		t.Errorf("legacy entry = %+v", entry)
+	}
+}
+
 func TestGenerateCacheKey_Deterministic(t *testing.T) {
 	req := domain.ChatRequest{
 		Model: "gpt-4",
@@ -106,6 +151,91 @@ func TestGenerateCacheKey_DifferentForDifferentRequests(t *testing.T) {
 	}
 }
 
+func TestGenerateCacheKey_IncludesLogprobs(t *testing.T) {
+	base := domain.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+	}
+
+	withLogprobs := base
+	withLogprobs.Logprobs = true
+
+	topN := 5
+	withTop := withLogprobs
+	withTop.TopLogprobs = &topN
+
+	keys := map[string]bool{
+		GenerateCacheKey(base):         true,
+		GenerateCacheKey(withLogprobs): true,
+		GenerateCacheKey(withTop):      true,
+	}
+	if len(keys) != 3 {
+		t.Error("expected logprobs and top_logprobs to change the cache key")
+	}
+}
+
+func TestGenerateCacheKey_IncludesTopPAndStop(t *testing.T) {
+	base := domain.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+	}
+
+	low, high := 0.1, 0.9
+	withLow := base
+	withLow.TopP = &low
+	withHigh := base
+	withHigh.TopP = &high
+	withStop := base
+	withStop.Stop = []string{"\n"}
+
+	keys := map[string]bool{
+		GenerateCacheKey(base):     true,
+		GenerateCacheKey(withLow):  true,
+		GenerateCacheKey(withHigh): true,
+		GenerateCacheKey(withStop): true,
+	}
+	if len(keys) != 4 {
+		t.Error("expected top_p and stop to change the cache key")
+	}
+}
+
+func TestGenerateCacheKey_IncludesParallelToolCalls(t *testing.T) {
+	base := domain.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+	}
+
+	on, off := true, false
+	withOn := base
+	withOn.ParallelToolCalls = &on
+	withOff := base
+	withOff.ParallelToolCalls = &off
+
+	keys := map[string]bool{
+		GenerateCacheKey(base):    true,
+		GenerateCacheKey(withOn):  true,
+		GenerateCacheKey(withOff): true,
+	}
+	if len(keys) != 3 {
+		t.Error("expected parallel_tool_calls to change the cache key")
+	}
+}
+
+func TestGenerateCacheKey_IgnoresStoreAndMetadata(t *testing.T) {
+	base := domain.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+	}
+
+	withMeta := base
+	withMeta.Store = true
+	withMeta.Metadata = map[string]string{"feature": "search"}
+
+	if GenerateCacheKey(base) != GenerateCacheKey(withMeta) {
+		t.Error("expected store and metadata not to change the cache key")
+	}
+}
+
 func TestGenerateCacheKey_IncludesModel(t *testing.T) {
 	req1 := domain.ChatRequest{
 		Model: "gpt-4",
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const keyPrefix = "cache:"
+
+// keyModel returns the model a key was generated for, or "" for a key
+// without one, such as those written before keys carried the model.
+func keyModel(key string) string {
+	rest := strings.TrimPrefix(key, keyPrefix)
+	i := strings.LastIndexByte(rest, ':')
+	if i < 0 {
+		return ""
+	}
+	return rest[:i]
+}
+
+// modelMatcher compiles a model pattern in which * matches any run of
+// characters, / included, and ? any single one. An empty pattern matches
+// every key, including those without a model.
+func modelMatcher(pattern string) func(key string) bool {
+	if pattern == "" {
+		return func(string) bool { return true }
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	re := regexp.MustCompile("^" + expr + "$")
+	return func(key string) bool {
+		model := keyModel(key)
+		return model != "" && re.MatchString(model)
+	}
+}
+
+// redisMatch returns the SCAN pattern covering keys for models matching
+// pattern. Redis globs also treat [ and \ specially, so those are escaped.
+func redisMatch(pattern string) string {
+	if pattern == "" {
+		return keyPrefix + "*"
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(pattern)
+	return keyPrefix + escaped + ":*"
+}
+
+func (c *InMemoryCache) Delete(ctx context.Context, pattern string) (int, error) {
+	match := modelMatcher(pattern)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key := range c.items {
+		if match(key) {
+			delete(c.items, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// deleteBatch is how many keys RedisCache.Delete scans for, and deletes, at
+// a time.
+const deleteBatch = 500
+
+// Delete scans for matching keys rather than flushing the database, which
+// other gateway state shares.
+func (c *RedisCache) Delete(ctx context.Context, pattern string) (int, error) {
+	match := modelMatcher(pattern)
+
+	n := 0
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		deleted, err := c.client.Del(ctx, batch...).Result()
+		n += int(deleted)
+		batch = batch[:0]
+		return err
+	}
+
+	iter := c.client.Scan(ctx, 0, redisMatch(pattern), deleteBatch).Iterator()
+	for iter.Next(ctx) {
+		if key := iter.Val(); match(key) {
+			batch = append(batch, key)
+		}
+		if len(batch) == deleteBatch {
+			if err := flush(); err != nil {
+				return n, fmt.Errorf("delete cache keys: %w", err)
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, fmt.Errorf("scan cache keys: %w", err)
+	}
+	if err := flush(); err != nil {
+		return n, fmt.Errorf("delete cache keys: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func keyFor(model string) string {
+	return GenerateCacheKey(domain.ChatRequest{Model: model, Messages: []domain.Message{{Role: "user", Content: "hi"}}})
+}
+
+func TestInMemoryCache_Delete(t *testing.T) {
+	models := []string{"gpt-4", "gpt-4o", "gpt-4o-mini", "claude-3-haiku", "meta-llama/Llama-3-8b", "llama3:8b"}
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    []string
+	}{
+		{"everything", "", models},
+		{"exact model", "gpt-4", []string{"gpt-4"}},
+		{"prefix", "gpt-4o*", []string{"gpt-4o", "gpt-4o-mini"}},
+		{"star spans slashes", "meta-llama*", []string{"meta-llama/Llama-3-8b"}},
+		{"model with colon", "llama3:?b", []string{"llama3:8b"}},
+		{"no match", "mistral-*", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewInMemoryCache()
+			for _, model := range models {
+				c.Set(ctx, keyFor(model), &domain.ChatResponse{ID: model}, time.Minute)
+			}
+
+			n, err := c.Delete(ctx, tt.pattern)
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if n != len(tt.want) {
+				t.Errorf("Delete() = %d, want %d", n, len(tt.want))
+			}
+
+			var gone []string
+			for _, model := range models {
+				if _, ok := c.Get(ctx, keyFor(model)); !ok {
+					gone = append(gone, model)
+				}
+			}
+			sort.Strings(gone)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if len(gone) != len(want) {
+				t.Fatalf("deleted %v, want %v", gone, want)
+			}
+			for i := range want {
+				if gone[i] != want[i] {
+					t.Fatalf("deleted %v, want %v", gone, want)
+				}
+			}
+		})
+	}
+}
+
+func TestInMemoryCache_DeleteAllIncludesLegacyKeys(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache()
+	c.Set(ctx, "cache:0123abcd", &domain.ChatResponse{}, time.Minute)
+
+	if n, _ := c.Delete(ctx, "*"); n != 0 {
+		t.Errorf("Delete(\"*\") = %d, want legacy key without a model kept", n)
+	}
+	if n, _ := c.Delete(ctx, ""); n != 1 {
+		t.Errorf("Delete(\"\") = %d, want 1", n)
+	}
+}
+
+func TestRedisCache_Delete(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set, skipping Redis cache tests")
+	}
+
+	c, err := NewRedisCache(url)
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	keep := keyFor("flush-test-keep")
+	defer c.client.Del(ctx, keep)
+	c.Set(ctx, keep, &domain.ChatResponse{}, time.Minute)
+	for _, model := range []string{"flush-test-a", "flush-test-b", "flush-test-[c]"} {
+		c.Set(ctx, keyFor(model), &domain.ChatResponse{}, time.Minute)
+	}
+
+	n, err := c.Delete(ctx, "flush-test-?")
+	if err != nil || n != 2 {
+		t.Errorf("Delete(flush-test-?) = %d, %v; want 2", n, err)
+	}
+	if n, err := c.Delete(ctx, "flush-test-[c]"); err != nil || n != 1 {
+		t.Errorf("Delete(flush-test-[c]) = %d, %v; want 1", n, err)
+	}
+	if _, ok := c.Get(ctx, keep); !ok {
+		t.Error("unmatched key was deleted")
+	}
+}
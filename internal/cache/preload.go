@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// PreloadEntry is one line of a cache preload file: a request and the
+// response to serve for it.
+type PreloadEntry struct {
+	Request  domain.ChatRequest   `json:"request"`
+	Response *domain.ChatResponse `json:"response"`
+
+	// TTLSeconds overrides the preload TTL for this entry. Zero uses it.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+
+	// TenantID names the tenant whose sampling defaults and transform rules
+	// apply to Request before it is keyed. Empty applies only gateway-wide
+	// defaults.
+	TenantID string `json:"tenant_id,omitempty"`
+}
+
+// PreloadOption configures Preload.
+type PreloadOption func(*preloadOptions)
+
+type preloadOptions struct {
+	prepare func(ctx context.Context, tenantID string, req *domain.ChatRequest) error
+}
+
+// WithRequestPreparer changes each entry's request the way the gateway
+// changes a live request before keying it, e.g. filling in default
+// max_tokens, so preloaded entries match the keys real requests look up.
+func WithRequestPreparer(prepare func(ctx context.Context, tenantID string, req *domain.ChatRequest) error) PreloadOption {
+	return func(o *preloadOptions) {
+		o.prepare = prepare
+	}
+}
+
+// sizeChecker is implemented by backends with a maximum value size, so
+// Preload can reject an oversized entry before storing any.
+type sizeChecker interface {
+	checkFits(resp *domain.ChatResponse) error
+}
+
+func (e PreloadEntry) validate() error {
+	switch {
+	case e.Request.Model == "":
+		return errors.New("request.model is required")
+	case len(e.Request.Messages) == 0:
+		return errors.New("request.messages is required")
+	case e.Request.Stream:
+		return errors.New("streaming requests are not cached")
+	case e.Response == nil || len(e.Response.Choices) == 0:
+		return errors.New("response must have at least one choice")
+	case e.TTLSeconds < 0:
+		return errors.New("ttl_seconds must not be negative")
+	}
+	return nil
+}
+
+// Preload seeds c with the JSON lines entries read from r, keyed the same
+// way the gateway keys live requests, so matching requests are cache hits
+// from the first call. Entries expire after ttl unless they set their own.
+// Every entry is validated, prepared and size-checked before any is stored,
+// so a bad file seeds nothing. It returns the number of entries stored.
+func Preload(ctx context.Context, c Cache, r io.Reader, ttl time.Duration, opts ...PreloadOption) (int, error) {
+	var o preloadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	checker, _ := c.(sizeChecker)
+
+	var entries []PreloadEntry
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var entry PreloadEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := entry.validate(); err != nil {
+			return 0, fmt.Errorf("line %d: %w", line, err)
+		}
+		if o.prepare != nil {
+			if err := o.prepare(ctx, entry.TenantID, &entry.Request); err != nil {
+				return 0, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if checker != nil {
+			if err := checker.checkFits(entry.Response); err != nil {
+				return 0, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		entries = append(entries, entry)
+		keys = append(keys, GenerateCacheKey(entry.Request))
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("read preload entries: %w", err)
+	}
+
+	for i, entry := range entries {
+		entryTTL := ttl
+		if entry.TTLSeconds > 0 {
+			entryTTL = time.Duration(entry.TTLSeconds) * time.Second
+		}
+		if err := c.Set(ctx, keys[i], entry.Response, entryTTL); err != nil {
+			return i, fmt.Errorf("store preload entry: %w", err)
+		}
+	}
+	return len(entries), nil
+}
+
+// PreloadFile runs Preload on the file at path.
+func PreloadFile(ctx context.Context, c Cache, path string, ttl time.Duration, opts ...PreloadOption) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open preload file: %w", err)
+	}
+	defer f.Close()
+	return Preload(ctx, c, f, ttl, opts...)
+}
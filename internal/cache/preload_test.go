@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// ttlRecorder records the TTL each key was stored with.
+type ttlRecorder struct {
+	*InMemoryCache
+	ttls map[string]time.Duration
+}
+
+func (c *ttlRecorder) Set(ctx context.Context, key string, resp *domain.ChatResponse, ttl time.Duration) error {
+	c.ttls[key] = ttl
+	return c.InMemoryCache.Set(ctx, key, resp, ttl)
+}
+
+func TestPreload(t *testing.T) {
+	input := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "What are your hours?"}]}, "response": {"id": "faq-1", "choices": [{"message": {"role": "assistant", "content": "9 to 5."}}]}}
+
+{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "Where are you?"}]}, "response": {"id": "faq-2", "choices": [{"message": {"role": "assistant", "content": "Lisbon."}}]}, "ttl_seconds": 3600}
+`
+	c := &ttlRecorder{InMemoryCache: NewInMemoryCache(), ttls: make(map[string]time.Duration)}
+
+	n, err := Preload(context.Background(), c, strings.NewReader(input), time.Minute)
+	if err != nil {
+		t.Fatalf("Preload() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Preload() = %d, want 2", n)
+	}
+
+	hours := GenerateCacheKey(domain.ChatRequest{Model: "gpt-4", Messages: []domain.Message{{Role: "user", Content: "What are your hours?"}}})
+	resp, ok := c.Get(context.Background(), hours)
+	if !ok || resp.ID != "faq-1" {
+		t.Fatalf("expected preloaded hit for faq-1, got %v, %v", resp, ok)
+	}
+	if c.ttls[hours] != time.Minute {
+		t.Errorf("default TTL = %v, want 1m", c.ttls[hours])
+	}
+
+	where := GenerateCacheKey(domain.ChatRequest{Model: "gpt-4", Messages: []domain.Message{{Role: "user", Content: "Where are you?"}}})
+	if c.ttls[where] != time.Hour {
+		t.Errorf("entry TTL = %v, want 1h", c.ttls[where])
+	}
+}
+
+func TestPreload_InvalidEntries(t *testing.T) {
+	valid := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{"message": {"role": "assistant", "content": "hello"}}]}}`
+
+	tests := []struct {
+		name    string
+		line    string
+		wantErr string
+	}{
+		{"malformed", `{"request": `, "line 2"},
+		{"no model", `{"request": {"messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{}]}}`, "request.model is required"},
+		{"no messages", `{"request": {"model": "gpt-4"}, "response": {"choices": [{}]}}`, "request.messages is required"},
+		{"streaming", `{"request": {"model": "gpt-4", "stream": true, "messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{}]}}`, "streaming"},
+		{"no response", `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}}`, "at least one choice"},
+		{"negative ttl", `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{}]}, "ttl_seconds": -1}`, "ttl_seconds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewInMemoryCache()
+			n, err := Preload(context.Background(), c, strings.NewReader(valid+"\n"+tt.line+"\n"), time.Minute)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Preload() error = %v, want %q", err, tt.wantErr)
+			}
+			if n != 0 || len(c.items) != 0 {
+				t.Errorf("a bad file stored %d entries, want none", len(c.items))
+			}
+		})
+	}
+}
+
+func TestPreload_OversizedEntryStoresNothing(t *testing.T) {
+	small := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{"message": {"role": "assistant", "content": "hello"}}]}}`
+	large := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "essay"}]}, "response": {"choices": [{"message": {"role": "assistant", "content": "` + strings.Repeat("x", 512) + `"}}]}}`
+
+	c := NewInMemoryCache(WithMaxValueSize(256))
+	n, err := Preload(context.Background(), c, strings.NewReader(small+"\n"+large+"\n"), time.Minute)
+	if !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Preload() error = %v, want ErrTooLarge on line 2", err)
+	}
+	if n != 0 || len(c.items) != 0 {
+		t.Errorf("a bad file stored %d entries, want none", len(c.items))
+	}
+}
+
+func TestPreload_PreparesRequestsBeforeKeying(t *testing.T) {
+	input := `{"request": {"model": "gpt-4", "messages": [{"role": "user", "content": "hi"}]}, "response": {"choices": [{"message": {"role": "assistant", "content": "hello"}}]}, "tenant_id": "acme"}`
+	var gotTenant string
+	prepare := func(ctx context.Context, tenantID string, req *domain.ChatRequest) error {
+		gotTenant = tenantID
+		maxTokens := 256
+		req.MaxTokens = &maxTokens
+		return nil
+	}
+
+	c := NewInMemoryCache()
+	if _, err := Preload(context.Background(), c, strings.NewReader(input), time.Minute, WithRequestPreparer(prepare)); err != nil {
+		t.Fatalf("Preload() error = %v", err)
+	}
+	if gotTenant != "acme" {
+		t.Errorf("preparer tenant = %q, want acme", gotTenant)
+	}
+
+	maxTokens := 256
+	key := GenerateCacheKey(domain.ChatRequest{Model: "gpt-4", Messages: []domain.Message{{Role: "user", Content: "hi"}}, MaxTokens: &maxTokens})
+	if _, ok := c.Get(context.Background(), key); !ok {
+		t.Error("expected the entry under the prepared request's key")
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// WithRetries retries failed Redis operations up to n more times, waiting
+// backoff before the first retry and doubling it for each one after. A miss
+// is not a failure and is never retried. The in-memory backend ignores it.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = n
+		o.retryBackoff = backoff
+	}
+}
+
+// retry runs op until it succeeds, reports a miss, runs out of retries or
+// ctx is done. Every failed attempt is counted as a backend error, so Redis
+// trouble shows up separately from ordinary misses.
+func (o options) retry(ctx context.Context, operation string, op func() error) error {
+	backoff := o.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			return err
+		}
+		metrics.RecordCacheBackendError(operation)
+		if attempt >= o.retries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestOptionsRetry(t *testing.T) {
+	ctx := context.Background()
+	errBlip := errors.New("connection reset")
+	o := applyOptions([]Option{WithRetries(2, time.Millisecond)})
+
+	tests := []struct {
+		name      string
+		failures  int
+		failWith  error
+		wantCalls int
+		wantErr   error
+	}{
+		{"succeeds first time", 0, errBlip, 1, nil},
+		{"transient error retried", 2, errBlip, 3, nil},
+		{"retries exhausted", 5, errBlip, 3, errBlip},
+		{"miss not retried", 5, redis.Nil, 1, redis.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errCount := metrics.CacheBackendErrors.WithLabelValues("test")
+			before := testutil.ToFloat64(errCount)
+
+			calls := 0
+			err := o.retry(ctx, "test", func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.failWith
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			wantErrors := float64(min(tt.failures, tt.wantCalls))
+			if tt.failWith == redis.Nil {
+				wantErrors = 0
+			}
+			if got := testutil.ToFloat64(errCount) - before; got != wantErrors {
+				t.Errorf("backend errors = %v, want %v", got, wantErrors)
+			}
+		})
+	}
+}
+
+func TestOptionsRetry_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	o := applyOptions([]Option{WithRetries(5, time.Hour)})
+
+	calls := 0
+	err := o.retry(ctx, "test", func() error {
+		calls++
+		cancel()
+		return errors.New("connection reset")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("retry() = %v after %d calls, want an error after 1", err, calls)
+	}
+}
+
+// flakyHook fails the next n commands as a dropped connection would.
+type flakyHook struct {
+	n int
+}
+
+func (h *flakyHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *flakyHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if h.n > 0 {
+			h.n--
+			cmd.SetErr(errors.New("connection reset by peer"))
+			return cmd.Err()
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *flakyHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisCache_RetriesTransientErrors(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set, skipping Redis cache tests")
+	}
+
+	c, err := NewRedisCache(url, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer c.Close()
+	if got := c.client.Options().MaxRetries; got > 0 {
+		t.Errorf("client MaxRetries = %d, want go-redis retries off under the cache's own", got)
+	}
+	hook := &flakyHook{}
+	c.client.AddHook(hook)
+
+	ctx := context.Background()
+	key := "cache:test-retry"
+	defer c.client.Del(ctx, key)
+
+	hook.n = 2
+	if err := c.Set(ctx, key, responseWithContent("retried"), time.Minute); err != nil {
+		t.Fatalf("Set() after two transient errors = %v, want success", err)
+	}
+
+	hook.n = 2
+	if got, ok := c.Get(ctx, key); !ok || got.Choices[0].Message.Content != "retried" {
+		t.Errorf("Get() after two transient errors = %v, %v; want the stored response", got, ok)
+	}
+
+	hook.n = 3
+	if err := c.Set(ctx, key, responseWithContent("lost"), time.Minute); err == nil {
+		t.Error("Set() after retries ran out should fail")
+	}
+}
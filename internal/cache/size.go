@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// ErrTooLarge is returned by Set when a response exceeds the maximum
+// cacheable size. The response is not stored.
+var ErrTooLarge = errors.New("response too large to cache")
+
+// DefaultMaxValueSize is the largest response, in encoded JSON bytes, that is
+// cached unless WithMaxValueSize says otherwise.
+const DefaultMaxValueSize = 1 << 20
+
+// Option configures a cache backend.
+type Option func(*options)
+
+type options struct {
+	maxValueSize      int
+	compressThreshold int
+	retries           int
+	retryBackoff      time.Duration
+}
+
+func applyOptions(opts []Option) options {
+	o := options{maxValueSize: DefaultMaxValueSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithMaxValueSize sets the largest response, in encoded JSON bytes, that is
+// cached. Larger responses are skipped. Zero removes the limit.
+func WithMaxValueSize(n int) Option {
+	return func(o *options) {
+		o.maxValueSize = n
+	}
+}
+
+// WithCompressThreshold gzips Redis values larger than n bytes. Zero stores
+// every value uncompressed. The in-memory backend ignores it.
+func WithCompressThreshold(n int) Option {
+	return func(o *options) {
+		o.compressThreshold = n
+	}
+}
+
+// checkSize returns ErrTooLarge, and counts the skip, when size exceeds the
+// limit.
+func (o options) checkSize(size int) error {
+	if o.maxValueSize > 0 && size > o.maxValueSize {
+		metrics.RecordCacheSkipped("too_large")
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// fits returns ErrTooLarge, without counting a skip, when value encoded as
+// JSON exceeds the limit.
+func (o options) fits(value any) error {
+	if o.maxValueSize <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if len(data) > o.maxValueSize {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// checkFits measures resp as Set does.
+func (c *InMemoryCache) checkFits(resp *domain.ChatResponse) error {
+	return c.opts.fits(resp)
+}
+
+// checkFits measures resp as encodeValue does, before compression.
+func (c *RedisCache) checkFits(resp *domain.ChatResponse) error {
+	return c.opts.fits(storedValue{ChatResponse: resp, StoredAt: time.Now().UnixMilli()})
+}
+
+// gzipMagic starts every gzip stream; JSON values never start with it, so
+// compressed and plain values can share a keyspace.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// storedValue is the Redis representation of an entry. The store time sits
+// beside the response fields, so values written before it existed still
+// decode, just without an age.
+type storedValue struct {
+	*domain.ChatResponse
+	StoredAt int64 `json:"x_stored_at,omitempty"`
+}
+
+// encodeValue marshals resp for Redis, compressing it above the threshold.
+func (o options) encodeValue(resp *domain.ChatResponse, storedAt time.Time) ([]byte, error) {
+	data, err := json.Marshal(storedValue{ChatResponse: resp, StoredAt: storedAt.UnixMilli()})
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkSize(len(data)); err != nil {
+		return nil, err
+	}
+	if o.compressThreshold <= 0 || len(data) <= o.compressThreshold {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("compress cache value: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compress cache value: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeValue reverses encodeValue, accepting compressed and plain values.
+func decodeValue(data []byte) (Entry, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return Entry{}, fmt.Errorf("decompress cache value: %w", err)
+		}
+		defer zr.Close()
+		if data, err = io.ReadAll(zr); err != nil {
+			return Entry{}, fmt.Errorf("decompress cache value: %w", err)
+		}
+	}
+
+	value := storedValue{ChatResponse: &domain.ChatResponse{}}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Response: value.ChatResponse}
+	if value.StoredAt > 0 {
+		entry.StoredAt = time.UnixMilli(value.StoredAt)
+	}
+	return entry, nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func responseWithContent(content string) *domain.ChatResponse {
+	return &domain.ChatResponse{
+		ID:      "resp",
+		Object:  "chat.completion",
+		Model:   "gpt-4",
+		Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: content}}},
+	}
+}
+
+func TestInMemoryCache_MaxValueSize(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache(WithMaxValueSize(512))
+	skipped := testutil.ToFloat64(metrics.CacheSkipped.WithLabelValues("too_large"))
+
+	if err := c.Set(ctx, "small", responseWithContent("short"), time.Minute); err != nil {
+		t.Fatalf("Set(small) error = %v", err)
+	}
+	if _, ok := c.Get(ctx, "small"); !ok {
+		t.Error("expected small response to be cached")
+	}
+
+	if err := c.Set(ctx, "large", responseWithContent(strings.Repeat("x", 1024)), time.Minute); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Set(large) error = %v, want ErrTooLarge", err)
+	}
+	if _, ok := c.Get(ctx, "large"); ok {
+		t.Error("large response should not be cached")
+	}
+	if got := testutil.ToFloat64(metrics.CacheSkipped.WithLabelValues("too_large")) - skipped; got != 1 {
+		t.Errorf("too_large skips increased by %v, want 1", got)
+	}
+
+	unlimited := NewInMemoryCache(WithMaxValueSize(0))
+	if err := unlimited.Set(ctx, "large", responseWithContent(strings.Repeat("x", 2<<20)), time.Minute); err != nil {
+		t.Errorf("Set() without a limit error = %v", err)
+	}
+}
+
+func TestEncodeValue_Compression(t *testing.T) {
+	large := responseWithContent(strings.Repeat("the quick brown fox ", 200))
+	plain, _ := json.Marshal(large)
+
+	tests := []struct {
+		name     string
+		opts     options
+		wantGzip bool
+	}{
+		{"disabled", options{}, false},
+		{"below threshold", options{compressThreshold: 1 << 20}, false},
+		{"above threshold", options{compressThreshold: 256}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.opts.encodeValue(large, time.Now())
+			if err != nil {
+				t.Fatalf("encodeValue() error = %v", err)
+			}
+			if got := bytes.HasPrefix(data, gzipMagic); got != tt.wantGzip {
+				t.Errorf("compressed = %v, want %v", got, tt.wantGzip)
+			}
+			if tt.wantGzip && len(data) >= len(plain)/2 {
+				t.Errorf("compressed value is %d bytes, plain is %d", len(data), len(plain))
+			}
+
+			decoded, err := decodeValue(data)
+			if err != nil {
+				t.Fatalf("decodeValue() error = %v", err)
+			}
+			if decoded.Response.Choices[0].Message.Content != large.Choices[0].Message.Content {
+				t.Error("round trip changed the response content")
+			}
+		})
+	}
+
+	if _, err := (options{maxValueSize: 100, compressThreshold: 10}).encodeValue(large, time.Now()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("size limit applies before compression, got %v", err)
+	}
+}
+
+func TestRedisCache_CompressedRoundTrip(t *testing.T) {
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		t.Skip("REDIS_URL not set, skipping Redis cache tests")
+	}
+
+	c, err := NewRedisCache(url, WithCompressThreshold(64))
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	resp := responseWithContent(strings.Repeat("cached ", 100))
+	if err := c.Set(ctx, "cache:test-compressed", resp, time.Minute); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	defer c.client.Del(ctx, "cache:test-compressed")
+
+	got, ok := c.Get(ctx, "cache:test-compressed")
+	if !ok || got.Choices[0].Message.Content != resp.Choices[0].Message.Content {
+		t.Errorf("Get() = %v, %v; want the stored response", got, ok)
+	}
+}
@@ -1,5 +1,7 @@
 // Package circuitbreaker implements the circuit breaker pattern for failure protection.
 // It prevents cascading failures by failing fast when a service is unhealthy.
+// A breaker can also trip on latency: when the rolling p95 of recorded
+// latencies exceeds a threshold, a slow but otherwise successful service is shed.
 //
 // States:
 //   - Closed: Normal operation, requests pass through
@@ -13,6 +15,7 @@ package circuitbreaker
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -34,6 +37,10 @@ type CircuitBreaker interface {
 	// Enough failures will open the circuit.
 	RecordFailure(ctx context.Context)
 
+	// RecordLatency records the latency of a completed request.
+	// A rolling p95 above Config.LatencyThreshold will open the circuit.
+	RecordLatency(ctx context.Context, latency time.Duration)
+
 	// State returns the current state of the circuit breaker.
 	State(ctx context.Context) State
 }
@@ -65,15 +72,54 @@ type Config struct {
 	FailureThreshold int           // Failures before opening
 	SuccessThreshold int           // Successes to close from half-open
 	Timeout          time.Duration // Time before transitioning to half-open
+
+	// Latency-based tripping. Disabled when LatencyThreshold is zero.
+	LatencyThreshold  time.Duration // Rolling p95 above which the circuit opens
+	LatencyWindowSize int           // Number of recent samples in the rolling window
+	LatencyMinSamples int           // Samples required before the p95 is evaluated
 }
 
 // DefaultConfig returns sensible defaults for most use cases.
 func DefaultConfig() Config {
 	return Config{
-		FailureThreshold: 5,
-		SuccessThreshold: 2,
-		Timeout:          30 * time.Second,
+		FailureThreshold:  5,
+		SuccessThreshold:  2,
+		Timeout:           30 * time.Second,
+		LatencyWindowSize: 100,
+		LatencyMinSamples: 20,
+	}
+}
+
+func (c Config) latencyEnabled() bool {
+	return c.LatencyThreshold > 0
+}
+
+func (c Config) latencyWindowSize() int {
+	if c.LatencyWindowSize > 0 {
+		return c.LatencyWindowSize
+	}
+	return 100
+}
+
+func (c Config) latencyMinSamples() int {
+	if c.LatencyMinSamples > 0 {
+		return c.LatencyMinSamples
 	}
+	return 1
+}
+
+// percentile95 returns the 95th percentile of the given samples using the
+// nearest-rank method. The input slice is not modified.
+func percentile95(samples []time.Duration) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := (len(sorted)*95 + 99) / 100
+	return sorted[rank-1]
 }
 
 // InMemoryCircuitBreaker tracks failures and controls request flow to a service.
@@ -85,6 +131,9 @@ type InMemoryCircuitBreaker struct {
 	successes   int
 	lastFailure time.Time
 	config      Config
+
+	latencies   []time.Duration // ring buffer of recent latencies
+	latencyNext int
 }
 
 // NewInMemory creates a new in-memory circuit breaker.
@@ -140,6 +189,7 @@ func (cb *InMemoryCircuitBreaker) RecordSuccess(ctx context.Context) {
 			cb.state = StateClosed
 			cb.failures = 0
 			cb.successes = 0
+			cb.resetLatencies()
 		}
 	}
 }
@@ -162,12 +212,69 @@ func (cb *InMemoryCircuitBreaker) RecordFailure(ctx context.Context) {
 	}
 }
 
+func (cb *InMemoryCircuitBreaker) RecordLatency(ctx context.Context, latency time.Duration) {
+	if !cb.config.latencyEnabled() {
+		return
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case StateClosed:
+		window := cb.config.latencyWindowSize()
+		if len(cb.latencies) < window {
+			cb.latencies = append(cb.latencies, latency)
+		} else {
+			cb.latencies[cb.latencyNext] = latency
+		}
+		cb.latencyNext = (cb.latencyNext + 1) % window
+
+		if len(cb.latencies) >= cb.config.latencyMinSamples() &&
+			percentile95(cb.latencies) > cb.config.LatencyThreshold {
+			cb.state = StateOpen
+			cb.lastFailure = time.Now()
+			cb.resetLatencies()
+		}
+	case StateHalfOpen:
+		// A slow probe means the service hasn't recovered yet.
+		if latency > cb.config.LatencyThreshold {
+			cb.state = StateOpen
+			cb.lastFailure = time.Now()
+			cb.successes = 0
+		}
+	}
+}
+
+func (cb *InMemoryCircuitBreaker) resetLatencies() {
+	cb.latencies = cb.latencies[:0]
+	cb.latencyNext = 0
+}
+
+// LatencyP95 returns the p95 of the latencies in the current window.
+func (cb *InMemoryCircuitBreaker) LatencyP95() time.Duration {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	return percentile95(cb.latencies)
+}
+
 func (cb *InMemoryCircuitBreaker) State(ctx context.Context) State {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 	return cb.state
 }
 
+// OpenRemaining returns how long until an open circuit lets a trial request
+// through, or zero when it is not open.
+func (cb *InMemoryCircuitBreaker) OpenRemaining(ctx context.Context) time.Duration {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	if cb.state != StateOpen {
+		return 0
+	}
+	return max(0, cb.config.Timeout-time.Since(cb.lastFailure))
+}
+
 func (cb *InMemoryCircuitBreaker) Failures() int {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
@@ -181,8 +288,24 @@ type Manager struct {
 	breakers map[string]CircuitBreaker
 	config   Config
 	factory  func(providerID string) CircuitBreaker
+
+	// stateConcurrency and stateTimeout bound States() for breakers whose
+	// state read is a network round-trip.
+	stateConcurrency int
+	stateTimeout     time.Duration
+
+	// stateFile persists in-memory breaker state across restarts; restored
+	// holds what was loaded from it at startup.
+	stateFile string
+	restored  map[string]savedBreaker
 }
 
+// Defaults for reading remote breaker states in States().
+const (
+	defaultStateConcurrency = 8
+	defaultStateTimeout     = 2 * time.Second
+)
+
 // ManagerOption configures a Manager.
 type ManagerOption func(*Manager)
 
@@ -200,6 +323,20 @@ func WithRedis(redisURL string) ManagerOption {
 	}
 }
 
+// WithStateConcurrency caps how many remote breaker states States() reads
+// at once and how long it waits for them. States not read in time are
+// reported as "unknown". In-memory breakers are always read directly.
+func WithStateConcurrency(n int, timeout time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if n > 0 {
+			m.stateConcurrency = n
+		}
+		if timeout > 0 {
+			m.stateTimeout = timeout
+		}
+	}
+}
+
 // NewManager creates a new circuit breaker manager.
 // By default, it uses in-memory circuit breakers.
 // Use WithRedis option for distributed circuit breakers.
@@ -210,11 +347,16 @@ func NewManager(cfg Config, opts ...ManagerOption) *Manager {
 		factory: func(providerID string) CircuitBreaker {
 			return NewInMemory(cfg)
 		},
+		stateConcurrency: defaultStateConcurrency,
+		stateTimeout:     defaultStateTimeout,
 	}
 
 	for _, opt := range opts {
 		opt(m)
 	}
+	if m.stateFile != "" {
+		m.loadState()
+	}
 
 	return m
 }
@@ -237,19 +379,81 @@ func (m *Manager) Get(providerID string) CircuitBreaker {
 	}
 
 	cb = m.factory(providerID)
+	if saved, ok := m.restored[providerID]; ok {
+		if local, ok := cb.(*InMemoryCircuitBreaker); ok {
+			local.restore(saved.LastFailure)
+		}
+	}
 	m.breakers[providerID] = cb
 	return cb
 }
 
-// States returns the current state of all circuit breakers.
+// OpenRemaining returns how long until providerID's open circuit lets a
+// trial request through. Breakers that cannot tell report the configured
+// Timeout.
+func (m *Manager) OpenRemaining(ctx context.Context, providerID string) time.Duration {
+	if cb, ok := m.Get(providerID).(interface {
+		OpenRemaining(ctx context.Context) time.Duration
+	}); ok {
+		return cb.OpenRemaining(ctx)
+	}
+	return m.config.Timeout
+}
+
+// States returns the current state of all circuit breakers. In-memory
+// states are read inline; remote ones are read concurrently, bounded by the
+// manager's state concurrency and timeout.
 func (m *Manager) States() map[string]string {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	ctx := context.Background()
-	states := make(map[string]string)
+	breakers := make(map[string]CircuitBreaker, len(m.breakers))
 	for id, cb := range m.breakers {
-		states[id] = cb.State(ctx).String()
+		breakers[id] = cb
+	}
+	m.mu.RUnlock()
+
+	states := make(map[string]string, len(breakers))
+	remote := make(map[string]CircuitBreaker)
+	for id, cb := range breakers {
+		if local, ok := cb.(*InMemoryCircuitBreaker); ok {
+			states[id] = local.State(context.Background()).String()
+			continue
+		}
+		remote[id] = cb
 	}
+	if len(remote) == 0 {
+		return states
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.stateTimeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, m.stateConcurrency)
+	)
+	for id, cb := range remote {
+		wg.Add(1)
+		go func(id string, cb CircuitBreaker) {
+			defer wg.Done()
+
+			state := "unknown"
+			select {
+			case sem <- struct{}{}:
+				s := cb.State(ctx)
+				<-sem
+				if ctx.Err() == nil {
+					state = s.String()
+				}
+			case <-ctx.Done():
+			}
+
+			mu.Lock()
+			states[id] = state
+			mu.Unlock()
+		}(id, cb)
+	}
+	wg.Wait()
+
 	return states
 }
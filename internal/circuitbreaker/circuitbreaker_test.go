@@ -2,6 +2,8 @@ package circuitbreaker
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -137,3 +139,202 @@ func TestManager_GetCreatesBreaker(t *testing.T) {
 		t.Error("expected different circuit breaker for different provider")
 	}
 }
+
+func TestCircuitBreaker_OpensOnSustainedHighLatency(t *testing.T) {
+	cfg := Config{
+		FailureThreshold:  5,
+		SuccessThreshold:  1,
+		Timeout:           time.Second,
+		LatencyThreshold:  100 * time.Millisecond,
+		LatencyWindowSize: 20,
+		LatencyMinSamples: 10,
+	}
+	cb := New(cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 9; i++ {
+		cb.RecordSuccess(ctx)
+		cb.RecordLatency(ctx, 2*time.Second)
+	}
+	if cb.State(ctx) != StateClosed {
+		t.Fatalf("expected StateClosed before min samples, got %v", cb.State(ctx))
+	}
+
+	cb.RecordLatency(ctx, 2*time.Second)
+	if cb.State(ctx) != StateOpen {
+		t.Errorf("expected StateOpen after sustained high latency, got %v", cb.State(ctx))
+	}
+	if err := cb.Allow(ctx); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("expected ErrCircuitBreakerOpen, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_IgnoresOccasionalSlowRequest(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LatencyThreshold = 100 * time.Millisecond
+	cfg.LatencyWindowSize = 20
+	cfg.LatencyMinSamples = 20
+	cb := New(cfg)
+	ctx := context.Background()
+
+	for i := 0; i < 40; i++ {
+		latency := 10 * time.Millisecond
+		if i%20 == 0 {
+			latency = 5 * time.Second
+		}
+		cb.RecordLatency(ctx, latency)
+	}
+
+	if cb.State(ctx) != StateClosed {
+		t.Errorf("expected StateClosed with p95 under threshold, got %v", cb.State(ctx))
+	}
+	if p95 := cb.LatencyP95(); p95 != 10*time.Millisecond {
+		t.Errorf("expected p95 of 10ms, got %v", p95)
+	}
+}
+
+func TestCircuitBreaker_LatencyDisabledByDefault(t *testing.T) {
+	cb := New(DefaultConfig())
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		cb.RecordLatency(ctx, time.Minute)
+	}
+
+	if cb.State(ctx) != StateClosed {
+		t.Errorf("expected StateClosed with latency tripping disabled, got %v", cb.State(ctx))
+	}
+}
+
+func TestCircuitBreaker_SlowProbeReopensFromHalfOpen(t *testing.T) {
+	cfg := Config{
+		FailureThreshold:  1,
+		SuccessThreshold:  1,
+		Timeout:           50 * time.Millisecond,
+		LatencyThreshold:  100 * time.Millisecond,
+		LatencyMinSamples: 5,
+	}
+	cb := New(cfg)
+	ctx := context.Background()
+
+	cb.RecordFailure(ctx)
+	time.Sleep(60 * time.Millisecond)
+	cb.Allow(ctx)
+
+	cb.RecordLatency(ctx, time.Second)
+
+	if cb.State(ctx) != StateOpen {
+		t.Errorf("expected StateOpen after slow probe in half-open, got %v", cb.State(ctx))
+	}
+}
+
+func TestPercentile95(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		want    time.Duration
+	}{
+		{"empty", nil, 0},
+		{"single", []time.Duration{5}, 5},
+		{"twenty", []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, 19},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile95(tt.samples); got != tt.want {
+				t.Errorf("percentile95() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// slowBreaker simulates a remote breaker whose state read takes a round-trip.
+type slowBreaker struct {
+	delay time.Duration
+}
+
+func (b *slowBreaker) Allow(ctx context.Context) error                          { return nil }
+func (b *slowBreaker) RecordSuccess(ctx context.Context)                        {}
+func (b *slowBreaker) RecordFailure(ctx context.Context)                        {}
+func (b *slowBreaker) RecordLatency(ctx context.Context, latency time.Duration) {}
+func (b *slowBreaker) State(ctx context.Context) State {
+	select {
+	case <-time.After(b.delay):
+		return StateOpen
+	case <-ctx.Done():
+		return StateClosed
+	}
+}
+
+func TestManager_StatesReadsRemoteBreakersConcurrently(t *testing.T) {
+	m := NewManager(DefaultConfig(), WithStateConcurrency(10, time.Second))
+	m.factory = func(string) CircuitBreaker { return &slowBreaker{delay: 100 * time.Millisecond} }
+	for i := 0; i < 10; i++ {
+		m.Get(fmt.Sprintf("provider-%d", i))
+	}
+
+	start := time.Now()
+	states := m.States()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("States() took %v, want concurrent reads (~100ms)", elapsed)
+	}
+	if len(states) != 10 {
+		t.Fatalf("got %d states, want 10", len(states))
+	}
+	for id, s := range states {
+		if s != "open" {
+			t.Errorf("%s = %q, want open", id, s)
+		}
+	}
+}
+
+func TestManager_StatesTimeoutReportsUnknown(t *testing.T) {
+	m := NewManager(DefaultConfig(), WithStateConcurrency(1, 50*time.Millisecond))
+	m.factory = func(string) CircuitBreaker { return &slowBreaker{delay: time.Second} }
+	m.Get("slow")
+
+	start := time.Now()
+	states := m.States()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("States() took %v, want it bounded by the timeout", elapsed)
+	}
+	if states["slow"] != "unknown" {
+		t.Errorf("slow = %q, want unknown", states["slow"])
+	}
+}
+
+func TestManager_StateFileRestoresOpenCircuits(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	cfg := Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Minute}
+
+	before := NewManager(cfg, WithStateFile(path))
+	before.Get("openai").RecordFailure(ctx)
+	before.Get("anthropic").RecordSuccess(ctx)
+	if err := before.SaveState(); err != nil {
+		t.Fatalf("SaveState() error = %v", err)
+	}
+
+	after := NewManager(cfg, WithStateFile(path))
+	if err := after.Get("openai").Allow(ctx); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("restored openai Allow() = %v, want ErrCircuitBreakerOpen", err)
+	}
+	if remaining := after.OpenRemaining(ctx, "openai"); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("restored openai OpenRemaining() = %v, want within the timeout", remaining)
+	}
+	if err := after.Get("anthropic").Allow(ctx); err != nil {
+		t.Errorf("anthropic Allow() = %v, want closed", err)
+	}
+
+	expired := NewManager(Config{FailureThreshold: 1, SuccessThreshold: 1, Timeout: time.Nanosecond}, WithStateFile(path))
+	if err := expired.Get("openai").Allow(ctx); err != nil {
+		t.Errorf("Allow() after the timeout = %v, want a trial request", err)
+	}
+}
+
+func TestManager_StateFileMissing(t *testing.T) {
+	m := NewManager(DefaultConfig(), WithStateFile(filepath.Join(t.TempDir(), "missing.json")))
+	if s := m.Get("openai").State(context.Background()); s != StateClosed {
+		t.Errorf("State() = %v, want closed", s)
+	}
+}
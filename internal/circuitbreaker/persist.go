@@ -0,0 +1,104 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedBreaker is the persisted state of a breaker that was not closed.
+type savedBreaker struct {
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// WithStateFile restores in-memory breakers from path, as written by
+// SaveState, so a provider whose circuit was open before a restart stays
+// open until its timeout runs out instead of taking a burst of requests.
+// A missing or unreadable file starts every breaker closed. Redis breakers
+// already share their state and are not affected.
+func WithStateFile(path string) ManagerOption {
+	return func(m *Manager) {
+		m.stateFile = path
+	}
+}
+
+func (m *Manager) loadState() {
+	data, err := os.ReadFile(m.stateFile)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("failed to read circuit breaker state", "path", m.stateFile, "error", err)
+		}
+		return
+	}
+	var saved map[string]savedBreaker
+	if err := json.Unmarshal(data, &saved); err != nil {
+		slog.Warn("failed to decode circuit breaker state", "path", m.stateFile, "error", err)
+		return
+	}
+	m.restored = saved
+	for id, s := range saved {
+		slog.Info("restoring open circuit breaker", "provider", id, "last_failure", s.LastFailure)
+	}
+}
+
+// restore opens the circuit as if its last failure happened at lastFailure.
+// Half-open breakers are restored as open too: their timeout has already
+// passed, so the next Allow lets a trial request through.
+func (cb *InMemoryCircuitBreaker) restore(lastFailure time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = StateOpen
+	cb.lastFailure = lastFailure
+}
+
+// SaveState writes every in-memory breaker that is not closed to the
+// manager's state file, replacing it atomically. It does nothing without
+// WithStateFile.
+func (m *Manager) SaveState() error {
+	if m.stateFile == "" {
+		return nil
+	}
+
+	m.mu.RLock()
+	saved := make(map[string]savedBreaker)
+	for id, cb := range m.breakers {
+		local, ok := cb.(*InMemoryCircuitBreaker)
+		if !ok {
+			continue
+		}
+		local.mu.RLock()
+		if local.state != StateClosed {
+			saved[id] = savedBreaker{LastFailure: local.lastFailure}
+		}
+		local.mu.RUnlock()
+	}
+	m.mu.RUnlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return fmt.Errorf("encode circuit breaker state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.stateFile), filepath.Base(m.stateFile)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create circuit breaker state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write circuit breaker state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close circuit breaker state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.stateFile); err != nil {
+		return fmt.Errorf("replace circuit breaker state file: %w", err)
+	}
+	return nil
+}
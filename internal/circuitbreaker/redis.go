@@ -94,6 +94,57 @@ end
 return state
 `)
 
+// recordLatencyScript records a latency sample and opens the circuit when the
+// rolling p95 exceeds the threshold.
+// Keys: [state_key, latencies_key, last_failure_key, successes_key]
+// Args: [latency_ms, threshold_ms, window_size, min_samples]
+// Returns: new state as string
+var recordLatencyScript = redis.NewScript(`
+local state = redis.call('GET', KEYS[1]) or 'closed'
+local latency = tonumber(ARGV[1])
+local threshold = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local minSamples = tonumber(ARGV[4])
+
+if state == 'half-open' then
+    if latency > threshold then
+        redis.call('SET', KEYS[1], 'open')
+        redis.call('SET', KEYS[3], redis.call('TIME')[1])
+        redis.call('SET', KEYS[4], '0')
+        return 'open'
+    end
+    return 'half-open'
+end
+
+if state ~= 'closed' then
+    return state
+end
+
+redis.call('LPUSH', KEYS[2], latency)
+redis.call('LTRIM', KEYS[2], 0, window - 1)
+
+local samples = redis.call('LRANGE', KEYS[2], 0, -1)
+if #samples < minSamples then
+    return 'closed'
+end
+
+local values = {}
+for i, v in ipairs(samples) do
+    values[i] = tonumber(v)
+end
+table.sort(values)
+
+local rank = math.ceil(#values * 0.95)
+if values[rank] > threshold then
+    redis.call('SET', KEYS[1], 'open')
+    redis.call('SET', KEYS[3], redis.call('TIME')[1])
+    redis.call('DEL', KEYS[2])
+    return 'open'
+end
+
+return 'closed'
+`)
+
 // RedisCircuitBreaker implements a distributed circuit breaker using Redis.
 // It uses Lua scripts for atomic state transitions, ensuring consistency
 // across multiple gateway instances.
@@ -155,6 +206,10 @@ func (cb *RedisCircuitBreaker) lastFailureKey() string {
 	return cb.keyPrefix + "last_failure"
 }
 
+func (cb *RedisCircuitBreaker) latenciesKey() string {
+	return cb.keyPrefix + "latencies"
+}
+
 // Allow checks if a request should be allowed through.
 // Uses a Lua script for atomic state check and transition from open to half-open.
 func (cb *RedisCircuitBreaker) Allow(ctx context.Context) error {
@@ -211,6 +266,29 @@ func (cb *RedisCircuitBreaker) RecordFailure(ctx context.Context) {
 	recordFailureScript.Run(ctx, cb.client, keys, args...)
 }
 
+// RecordLatency records the latency of a completed request.
+// Uses a Lua script so the rolling window and p95 check are evaluated atomically.
+func (cb *RedisCircuitBreaker) RecordLatency(ctx context.Context, latency time.Duration) {
+	if !cb.config.latencyEnabled() {
+		return
+	}
+
+	keys := []string{
+		cb.stateKey(),
+		cb.latenciesKey(),
+		cb.lastFailureKey(),
+		cb.successesKey(),
+	}
+	args := []interface{}{
+		latency.Milliseconds(),
+		cb.config.LatencyThreshold.Milliseconds(),
+		cb.config.latencyWindowSize(),
+		cb.config.latencyMinSamples(),
+	}
+
+	recordLatencyScript.Run(ctx, cb.client, keys, args...)
+}
+
 // State returns the current state of the circuit breaker.
 func (cb *RedisCircuitBreaker) State(ctx context.Context) State {
 	result, err := cb.client.Get(ctx, cb.stateKey()).Result()
@@ -222,6 +300,24 @@ func (cb *RedisCircuitBreaker) State(ctx context.Context) State {
 	return parseState(result)
 }
 
+// OpenRemaining returns how long until an open circuit lets a trial request
+// through, or zero when it is not open. It compares the failure time Redis
+// recorded with the local clock, so it is only as exact as the clocks agree.
+func (cb *RedisCircuitBreaker) OpenRemaining(ctx context.Context) time.Duration {
+	if cb.State(ctx) != StateOpen {
+		return 0
+	}
+	result, err := cb.client.Get(ctx, cb.lastFailureKey()).Result()
+	if err != nil {
+		return cb.config.Timeout
+	}
+	lastFailure, err := strconv.ParseInt(result, 10, 64)
+	if err != nil {
+		return cb.config.Timeout
+	}
+	return max(0, cb.config.Timeout-time.Since(time.Unix(lastFailure, 0)))
+}
+
 // Failures returns the current failure count.
 func (cb *RedisCircuitBreaker) Failures(ctx context.Context) int {
 	result, err := cb.client.Get(ctx, cb.failuresKey()).Result()
@@ -241,6 +337,7 @@ func (cb *RedisCircuitBreaker) Reset(ctx context.Context) error {
 	pipe.Set(ctx, cb.failuresKey(), "0", 0)
 	pipe.Set(ctx, cb.successesKey(), "0", 0)
 	pipe.Del(ctx, cb.lastFailureKey())
+	pipe.Del(ctx, cb.latenciesKey())
 	_, err := pipe.Exec(ctx)
 	return err
 }
@@ -2,6 +2,7 @@ package circuitbreaker
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -225,3 +226,52 @@ func TestManager_WithRedisOption(t *testing.T) {
 		t.Error("expected RedisCircuitBreaker type")
 	}
 }
+
+func TestRedisCircuitBreaker_OpensOnSustainedHighLatency(t *testing.T) {
+	redisURL := getRedisURL(t)
+	ctx := context.Background()
+
+	cfg := DefaultConfig()
+	cfg.LatencyThreshold = 100 * time.Millisecond
+	cfg.LatencyWindowSize = 20
+	cfg.LatencyMinSamples = 10
+	cb, err := NewRedis(redisURL, "test-provider-latency", cfg)
+	if err != nil {
+		t.Fatalf("failed to create redis circuit breaker: %v", err)
+	}
+	defer cb.Close()
+	cb.Reset(ctx)
+	defer cb.Reset(ctx)
+
+	for i := 0; i < 9; i++ {
+		cb.RecordLatency(ctx, 2*time.Second)
+	}
+	if cb.State(ctx) != StateClosed {
+		t.Fatalf("expected StateClosed before min samples, got %v", cb.State(ctx))
+	}
+
+	cb.RecordLatency(ctx, 2*time.Second)
+	if cb.State(ctx) != StateOpen {
+		t.Errorf("expected StateOpen after sustained high latency, got %v", cb.State(ctx))
+	}
+}
+
+func TestManager_StatesRedisWithinDeadline(t *testing.T) {
+	redisURL := getRedisURL(t)
+
+	m := NewManager(DefaultConfig(), WithRedis(redisURL), WithStateConcurrency(4, time.Second))
+	for i := 0; i < 12; i++ {
+		m.Get(fmt.Sprintf("redis-states-%d", i))
+	}
+
+	start := time.Now()
+	states := m.States()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("States() took %v, want under the 1s deadline", elapsed)
+	}
+	for id, s := range states {
+		if s == "unknown" {
+			t.Errorf("%s was not read before the deadline", id)
+		}
+	}
+}
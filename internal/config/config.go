@@ -1,9 +1,16 @@
 package config
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/router"
 )
 
 type Config struct {
@@ -14,23 +21,317 @@ type Config struct {
 	OpenAIAPIKey     string
 	OpenAIBaseURL    string
 	AnthropicAPIKey  string
+	MistralAPIKey    string
+	MistralBaseURL   string
 	OllamaBaseURL    string
 	DefaultProvider  string
+	DefaultModel     string
+	FallbackOrder    []string
 	OTLPEndpoint     string
+	TraceSampleRatio float64
 	AWSRegion        string
 	EncryptionKey    string
 	AdminAuthEnabled bool
 
+	// Async queue and notification topic; when set they are added to the
+	// readiness probe.
+	SQSRequestQueueURL  string
+	SQSResponseQueueURL string
+	SNSTopicArn         string
+
+	// RequireEncryption refuses to start without an ENCRYPTION_KEY, so
+	// provider keys can never be persisted in plaintext.
+	RequireEncryption bool
+
+	// DefaultSystemPrompts maps model name to the system prompt injected when
+	// a request omits one. Loaded from DEFAULT_SYSTEM_PROMPTS as a JSON object.
+	DefaultSystemPrompts map[string]string
+
+	// DefaultMaxTokens maps model name, or "*" for any other model, to the
+	// max_tokens sent when a request sets none. Loaded from
+	// DEFAULT_MAX_TOKENS as a JSON object.
+	DefaultMaxTokens map[string]int
+
+	// TenantCacheTTL caches tenant lookups for this long in front of the
+	// database (0 disables). With REDIS_URL set, invalidations are shared
+	// across instances.
+	TenantCacheTTL time.Duration
+
+	// MaxFallbackAttempts caps how many providers one request may try
+	// (0 = no limit).
+	MaxFallbackAttempts int
+
+	// RequestTimeBudget bounds the total time of a chat completion's provider
+	// attempts, from REQUEST_TIME_BUDGET_MS (0 = no budget). No fallback is
+	// started with less than FallbackMinRemaining of it left, from
+	// FALLBACK_MIN_REMAINING_MS.
+	RequestTimeBudget    time.Duration
+	FallbackMinRemaining time.Duration
+
+	// PrefixModelIDs lists models as "provider/model" in GET /v1/models.
+	PrefixModelIDs bool
+
+	// EstimateMissingUsage bills responses whose provider reported no token
+	// usage from an estimate instead of as zero.
+	EstimateMissingUsage bool
+
+	// FailOnUsageRecordError fails a request whose usage cannot be recorded
+	// instead of returning the response unbilled.
+	FailOnUsageRecordError bool
+
+	// TokenEstimateErrorThreshold is the relative divergence between a
+	// streamed response's token estimate and its provider-reported usage
+	// beyond which the error is recorded, from TOKEN_ESTIMATE_ERROR_THRESHOLD.
+	TokenEstimateErrorThreshold float64
+
+	// DebugProviderTenants and DebugProviderModels log the outbound provider
+	// request and response bodies, redacted and truncated, at debug level for
+	// requests from these tenants or for these models.
+	DebugProviderTenants []string
+	DebugProviderModels  []string
+
+	// MemoryMaxTenants caps the in-memory tenant repository used without
+	// DATABASE_URL (0 = no limit). When full, creates fail unless
+	// MemoryTenantLRU evicts the least recently used tenant instead.
+	MemoryMaxTenants int
+	MemoryTenantLRU  bool
+
+	// MemoryDefaultTenant seeds the in-memory repository with the "default"
+	// tenant and its public gw-default-key, from TENANT_MEMORY_DEFAULT.
+	MemoryDefaultTenant bool
+
+	// OptionalProviders are reported by /health but never mark it degraded,
+	// from OPTIONAL_PROVIDERS (comma-separated provider IDs).
+	OptionalProviders []string
+
+	// ForwardHeaders lists client request headers passed through to providers,
+	// from FORWARD_HEADERS (comma-separated, e.g. "X-Session-ID,X-Trace-Tag").
+	ForwardHeaders []string
+
+	// RequestIDHeaders lists the inbound headers a request ID is read from,
+	// in order, from REQUEST_ID_HEADERS (e.g. "X-Correlation-ID,traceparent").
+	// RequestIDEchoHeader, from REQUEST_ID_RESPONSE_HEADER, echoes it.
+	RequestIDHeaders    []string
+	RequestIDEchoHeader string
+
+	// CORSAllowedOrigins lists origins allowed to call the API from a
+	// browser, from CORS_ALLOWED_ORIGINS ("*" for any; empty disables CORS).
+	CORSAllowedOrigins []string
+
+	// CORSExposeHeaders lists response headers readable by cross-origin
+	// callers, from CORS_EXPOSE_HEADERS (nil uses the gateway's defaults).
+	CORSExposeHeaders []string
+
+	// OllamaModelAliases maps client model names to exact Ollama tags, from
+	// OLLAMA_MODEL_ALIASES as a JSON object.
+	OllamaModelAliases map[string]string
+
+	// RoutingStrategy selects how the primary provider is chosen when the
+	// request does not pin one: "" keeps DefaultProvider first, "weighted"
+	// picks at random weighted by cost, latency and health.
+	RoutingStrategy string
+
+	// UnknownModelStrategy routes requests for models no provider is known
+	// to serve, from UNKNOWN_MODEL_STRATEGY: "default", "reject" or
+	// "broadcast-probe". ModelProbeTTL is how long broadcast-probe caches
+	// each provider's model list, from MODEL_PROBE_TTL.
+	UnknownModelStrategy string
+	ModelProbeTTL        time.Duration
+
+	// RoutingWeights sets the weighted strategy's factors, from
+	// ROUTING_WEIGHTS as a JSON object with "cost", "latency" and "health".
+	RoutingWeights map[string]float64
+
+	// ProviderCosts is each provider's relative cost for weighted routing,
+	// from PROVIDER_COSTS as a JSON object (e.g. {"openai": 2, "ollama": 0}).
+	ProviderCosts map[string]float64
+
+	// ProviderDailyCostCaps caps each provider's spend per UTC day in USD,
+	// from PROVIDER_DAILY_COST_CAPS as a JSON object (e.g. {"openai": 500}).
+	ProviderDailyCostCaps map[string]float64
+
+	// HeaderRoutingRules route requests by header ahead of model routing,
+	// from HEADER_ROUTING_RULES as a JSON array, e.g.
+	// [{"header": "X-Region", "value": "eu", "provider": "bedrock"}]. The
+	// first matching rule wins. Rules are checked against the registered
+	// providers at startup by router.ValidateHeaderRules.
+	HeaderRoutingRules []router.HeaderRule
+
 	// Horizontal scaling features
 	UseDistributedCircuitBreaker bool
 
+	// ProviderRateLimits caps outbound requests per minute to each provider,
+	// from PROVIDER_RATE_LIMITS as a JSON object (e.g. {"openai": 3000}).
+	ProviderRateLimits map[string]int
+
+	// ProviderRetryableStatuses overrides which upstream HTTP statuses fall
+	// through to the next provider, from PROVIDER_RETRYABLE_STATUSES as a JSON
+	// object (e.g. {"openai": [429, 503]}).
+	ProviderRetryableStatuses map[string][]int
+
+	// ProviderTimeout bounds a non-streaming provider call, from
+	// PROVIDER_TIMEOUT.
+	ProviderTimeout time.Duration
+
+	// ModelsProviderTimeout bounds each provider's model listing, for GET
+	// /v1/models and unknown-model probes, from MODELS_PROVIDER_TIMEOUT, and
+	// ModelsTimeout all of GET /v1/models, from MODELS_TIMEOUT.
+	ModelsProviderTimeout time.Duration
+	ModelsTimeout         time.Duration
+
+	// ProviderStreamIdleTimeout aborts a provider stream that sends nothing
+	// for this long. Streams have no overall provider deadline (0 disables
+	// idle detection).
+	ProviderStreamIdleTimeout time.Duration
+
+	// ProviderMaxConnsPerHost caps concurrent connections to each provider
+	// host; further requests queue for a free connection (0 = unlimited).
+	ProviderMaxConnsPerHost int
+
+	// ProviderRateLimitWait is how long a request may queue for provider
+	// capacity before falling through to the next provider (0 = never queue).
+	ProviderRateLimitWait time.Duration
+
+	// RateLimitSweepInterval is how often the in-memory tenant rate limiter
+	// drops expired windows (0 disables the sweep).
+	RateLimitSweepInterval time.Duration
+
+	// TenantCostGaugeMaxTenants enables the per-tenant period cost gauge for
+	// up to this many tenants (0 disables it).
+	TenantCostGaugeMaxTenants int
+
+	// BudgetExceededFields lists the details sent with a budget-exceeded
+	// response, from BUDGET_EXCEEDED_FIELDS (nil uses the gateway's defaults;
+	// "none" sends the message alone). BudgetUpgradeURL, from
+	// BUDGET_UPGRADE_URL, is sent as upgrade_url.
+	BudgetExceededFields []string
+	BudgetUpgradeURL     string
+
+	// TenantWebhookRetries is how many times a failed budget alert POSTed to
+	// a tenant's webhook URL is retried, from TENANT_WEBHOOK_RETRIES. Each
+	// delivery is signed with the tenant's own webhook secret.
+	TenantWebhookRetries int
+
+	// TenantCostGaugeInterval is how often the period cost gauge re-reads
+	// tracked tenants' spend (0 disables the refresh).
+	TenantCostGaugeInterval time.Duration
+
+	// MetricsSnapshotInterval is how often per-tenant totals are written to
+	// tenant_metrics_snapshots, from METRICS_SNAPSHOT_INTERVAL (0 disables
+	// snapshots; they need DATABASE_URL).
+	MetricsSnapshotInterval time.Duration
+
+	// MetricsSnapshotRetention is how long snapshot history is kept, from
+	// METRICS_SNAPSHOT_RETENTION (0 keeps everything). Each tenant and
+	// instance's latest snapshot is always kept.
+	MetricsSnapshotRetention time.Duration
+
+	// CacheMaxValueBytes is the largest response cached, in encoded JSON
+	// bytes, from CACHE_MAX_VALUE_BYTES (0 = no limit).
+	CacheMaxValueBytes int
+
+	// CacheCompressThresholdBytes gzips Redis cache values above this size,
+	// from CACHE_COMPRESS_THRESHOLD_BYTES (0 disables compression).
+	CacheCompressThresholdBytes int
+
+	// CacheRedisRetries retries failed Redis cache operations, from
+	// CACHE_REDIS_RETRIES, waiting CacheRedisRetryBackoff (from
+	// CACHE_REDIS_RETRY_BACKOFF_MS) before the first retry and doubling it.
+	CacheRedisRetries      int
+	CacheRedisRetryBackoff time.Duration
+
+	// CachePreloadFile is a JSON lines file of request/response pairs seeded
+	// into the response cache at startup, from CACHE_PRELOAD_FILE.
+	CachePreloadFile string
+
+	// UsageDeadLetterFile is where usage records that fail to persist are
+	// kept for replay, from USAGE_DEAD_LETTER_FILE. Empty keeps them in memory.
+	UsageDeadLetterFile string
+
+	// ArchiveFile is a JSON lines file that sampled chat requests and their
+	// responses are appended to, from ARCHIVE_FILE. Empty disables archiving.
+	ArchiveFile string
+	// ArchiveSampleRate is the fraction of requests archived, from
+	// ARCHIVE_SAMPLE_RATE; ArchiveTenantSampleRates overrides it per tenant,
+	// from ARCHIVE_TENANT_SAMPLE_RATES.
+	ArchiveSampleRate        float64
+	ArchiveTenantSampleRates map[string]float64
+
+	// CBStateConcurrency caps concurrent Redis circuit breaker state reads
+	// when reporting health (0 = default of 8).
+	CBStateConcurrency int
+
+	// CBPerModel keys circuit breakers by provider and model instead of by
+	// provider alone.
+	CBPerModel bool
+
+	// CBStateFile is where open in-memory circuit breakers are saved on
+	// shutdown and restored from on startup. Empty disables persistence.
+	CBStateFile string
+
+	// Latency-based circuit breaking (0 disables)
+	CBLatencyThreshold time.Duration
+
+	// MaxStreamDuration cuts off streaming responses that run longer than this
+	MaxStreamDuration time.Duration
+
+	// MaxRequestBodyBytes caps request bodies after gzip or deflate
+	// decompression, from MAX_REQUEST_BODY_BYTES.
+	MaxRequestBodyBytes int64
+
+	// RequestDedupWindow is how long a successful non-streaming response is
+	// reused for identical requests from the same tenant, from
+	// REQUEST_DEDUP_WINDOW_MS. Zero disables deduplication.
+	RequestDedupWindow time.Duration
+
+	// SlowRequestThreshold is the latency past which a chat completion is
+	// logged as slow, from SLOW_REQUEST_THRESHOLD_MS. Zero disables it.
+	SlowRequestThreshold time.Duration
+
+	// ModerationProvider serves POST /v1/moderations, from
+	// MODERATION_PROVIDER, and ModerationModel is used for requests naming
+	// no model, from MODERATION_MODEL.
+	ModerationProvider string
+	ModerationModel    string
+
+	// ListenSocket is a Unix domain socket path the server also listens on,
+	// from LISTEN_SOCKET. Setting ADDR to "none" serves only the socket.
+	ListenSocket string
+
+	// ListenSocketMode is the socket file's permissions, from
+	// LISTEN_SOCKET_MODE in octal (default 0660).
+	ListenSocketMode os.FileMode
+
+	// ErrorFormat is the default shape of API error responses, from
+	// ERROR_FORMAT: "openai" (default) or "simple".
+	ErrorFormat string
+
+	// SSERetry is the reconnect delay advertised to streaming clients, from
+	// SSE_RETRY_MS in milliseconds (0 omits the retry field).
+	SSERetry time.Duration
+
+	// HTTP server timeouts. WriteTimeout does not apply to streaming
+	// responses, which are bounded by MaxStreamDuration instead.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
 	// Graceful shutdown
 	ShutdownTimeout time.Duration
 	DrainTimeout    time.Duration
 
+	// StreamDrainTimeout is how long shutdown waits for streaming responses
+	// before cancelling them; regular requests use DrainTimeout.
+	StreamDrainTimeout time.Duration
+
 	// Instance identification (for observability)
 	PodName   string
 	Namespace string
+
+	// MetricsLabels are added to every exported metric, from the JSON map
+	// METRICS_CONST_LABELS; CLUSTER and REGION, when set, fill in the
+	// cluster and region labels unless the map names them.
+	MetricsLabels map[string]string
 }
 
 func Load() (*Config, error) {
@@ -42,22 +343,315 @@ func Load() (*Config, error) {
 		OpenAIAPIKey:                 getEnv("OPENAI_API_KEY", ""),
 		OpenAIBaseURL:                getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
 		AnthropicAPIKey:              getEnv("ANTHROPIC_API_KEY", ""),
+		MistralAPIKey:                getEnv("MISTRAL_API_KEY", ""),
+		MistralBaseURL:               getEnv("MISTRAL_BASE_URL", "https://api.mistral.ai/v1"),
 		OllamaBaseURL:                getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
 		DefaultProvider:              getEnv("DEFAULT_PROVIDER", "ollama"),
+		DefaultModel:                 getEnv("DEFAULT_MODEL", ""),
+		ForwardHeaders:               getListEnv("FORWARD_HEADERS"),
+		RequestIDHeaders:             getListEnv("REQUEST_ID_HEADERS"),
+		RequestIDEchoHeader:          getEnv("REQUEST_ID_RESPONSE_HEADER", ""),
+		CORSAllowedOrigins:           getListEnv("CORS_ALLOWED_ORIGINS"),
+		CORSExposeHeaders:            getListEnv("CORS_EXPOSE_HEADERS"),
+		MaxFallbackAttempts:          getIntEnv("MAX_FALLBACK_ATTEMPTS", 0),
+		RequestTimeBudget:            time.Duration(getIntEnv("REQUEST_TIME_BUDGET_MS", 0)) * time.Millisecond,
+		FallbackMinRemaining:         time.Duration(getIntEnv("FALLBACK_MIN_REMAINING_MS", 1000)) * time.Millisecond,
+		OptionalProviders:            getListEnv("OPTIONAL_PROVIDERS"),
+		RoutingStrategy:              getEnv("ROUTING_STRATEGY", ""),
+		UnknownModelStrategy:         getEnv("UNKNOWN_MODEL_STRATEGY", "default"),
+		ModelProbeTTL:                getDurationEnv("MODEL_PROBE_TTL", 5*time.Minute),
+		PrefixModelIDs:               getEnv("PREFIX_MODEL_IDS", "false") == "true",
+		DebugProviderTenants:         getListEnv("DEBUG_PROVIDER_TENANTS"),
+		DebugProviderModels:          getListEnv("DEBUG_PROVIDER_MODELS"),
+		EstimateMissingUsage:         getEnv("ESTIMATE_MISSING_USAGE", "true") == "true",
+		FailOnUsageRecordError:       getEnv("FAIL_ON_USAGE_RECORD_ERROR", "false") == "true",
+		TokenEstimateErrorThreshold:  getFloatEnv("TOKEN_ESTIMATE_ERROR_THRESHOLD", 0.2),
+		MemoryMaxTenants:             getIntEnv("TENANT_MEMORY_MAX", 0),
+		MemoryTenantLRU:              getEnv("TENANT_MEMORY_LRU", "false") == "true",
+		MemoryDefaultTenant:          getEnv("TENANT_MEMORY_DEFAULT", "true") == "true",
+		TenantCacheTTL:               getDurationEnv("TENANT_CACHE_TTL", 0),
+		FallbackOrder:                getListEnv("FALLBACK_ORDER"),
 		OTLPEndpoint:                 getEnv("OTLP_ENDPOINT", ""),
+		TraceSampleRatio:             getFloatEnv("OTEL_TRACE_SAMPLE_RATIO", 1.0),
 		AWSRegion:                    getEnv("AWS_REGION", ""),
 		EncryptionKey:                getEnv("ENCRYPTION_KEY", ""),
 		AdminAuthEnabled:             getEnv("ADMIN_AUTH_ENABLED", "false") == "true",
+		SQSRequestQueueURL:           getEnv("SQS_REQUEST_QUEUE_URL", ""),
+		SQSResponseQueueURL:          getEnv("SQS_RESPONSE_QUEUE_URL", ""),
+		SNSTopicArn:                  getEnv("SNS_TOPIC_ARN", ""),
+		RequireEncryption:            getEnv("REQUIRE_ENCRYPTION", "false") == "true",
 		UseDistributedCircuitBreaker: getEnv("USE_DISTRIBUTED_CB", "false") == "true",
+		CBStateConcurrency:           getIntEnv("CB_STATE_CONCURRENCY", 0),
+		CBStateFile:                  getEnv("CB_STATE_FILE", ""),
+		CBPerModel:                   getEnv("CB_PER_MODEL", "false") == "true",
+		CBLatencyThreshold:           getDurationEnv("CB_LATENCY_THRESHOLD", 0),
+		ProviderTimeout:              getDurationEnv("PROVIDER_TIMEOUT", 120*time.Second),
+		ModelsProviderTimeout:        getDurationEnv("MODELS_PROVIDER_TIMEOUT", 5*time.Second),
+		ModelsTimeout:                getDurationEnv("MODELS_TIMEOUT", 10*time.Second),
+		ProviderStreamIdleTimeout:    getDurationEnv("PROVIDER_STREAM_IDLE_TIMEOUT", 60*time.Second),
+		ProviderMaxConnsPerHost:      getIntEnv("PROVIDER_MAX_CONNS_PER_HOST", 0),
+		ProviderRateLimitWait:        getDurationEnv("PROVIDER_RATE_LIMIT_WAIT", 0),
+		RateLimitSweepInterval:       getDurationEnv("RATE_LIMIT_SWEEP_INTERVAL", time.Minute),
+		TenantCostGaugeMaxTenants:    getIntEnv("TENANT_COST_GAUGE_MAX_TENANTS", 0),
+		TenantCostGaugeInterval:      getDurationEnv("TENANT_COST_GAUGE_INTERVAL", time.Minute),
+		MetricsSnapshotInterval:      getDurationEnv("METRICS_SNAPSHOT_INTERVAL", 0),
+		MetricsSnapshotRetention:     getDurationEnv("METRICS_SNAPSHOT_RETENTION", 30*24*time.Hour),
+		BudgetExceededFields:         getListEnv("BUDGET_EXCEEDED_FIELDS"),
+		BudgetUpgradeURL:             getEnv("BUDGET_UPGRADE_URL", ""),
+		TenantWebhookRetries:         getIntEnv("TENANT_WEBHOOK_RETRIES", 3),
+		CachePreloadFile:             getEnv("CACHE_PRELOAD_FILE", ""),
+		CacheMaxValueBytes:           getIntEnv("CACHE_MAX_VALUE_BYTES", 1<<20),
+		CacheCompressThresholdBytes:  getIntEnv("CACHE_COMPRESS_THRESHOLD_BYTES", 0),
+		CacheRedisRetries:            getIntEnv("CACHE_REDIS_RETRIES", 2),
+		CacheRedisRetryBackoff:       time.Duration(getIntEnv("CACHE_REDIS_RETRY_BACKOFF_MS", 10)) * time.Millisecond,
+		UsageDeadLetterFile:          getEnv("USAGE_DEAD_LETTER_FILE", ""),
+		ArchiveFile:                  getEnv("ARCHIVE_FILE", ""),
+		ArchiveSampleRate:            getFloatEnv("ARCHIVE_SAMPLE_RATE", 1.0),
+		MaxStreamDuration:            getDurationEnv("MAX_STREAM_DURATION", 10*time.Minute),
+		MaxRequestBodyBytes:          int64(getIntEnv("MAX_REQUEST_BODY_BYTES", 10<<20)),
+		RequestDedupWindow:           time.Duration(getIntEnv("REQUEST_DEDUP_WINDOW_MS", 0)) * time.Millisecond,
+		SlowRequestThreshold:         time.Duration(getIntEnv("SLOW_REQUEST_THRESHOLD_MS", 0)) * time.Millisecond,
+		ModerationProvider:           getEnv("MODERATION_PROVIDER", "openai"),
+		ModerationModel:              getEnv("MODERATION_MODEL", ""),
+		ListenSocket:                 getEnv("LISTEN_SOCKET", ""),
+		ErrorFormat:                  getEnv("ERROR_FORMAT", "openai"),
+		SSERetry:                     time.Duration(getIntEnv("SSE_RETRY_MS", 3000)) * time.Millisecond,
+		ReadTimeout:                  getDurationEnv("SERVER_READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:                 getDurationEnv("SERVER_WRITE_TIMEOUT", 120*time.Second),
+		IdleTimeout:                  getDurationEnv("SERVER_IDLE_TIMEOUT", 120*time.Second),
 		ShutdownTimeout:              getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
 		DrainTimeout:                 getDurationEnv("DRAIN_TIMEOUT", 15*time.Second),
+		StreamDrainTimeout:           getDurationEnv("STREAM_DRAIN_TIMEOUT", 30*time.Second),
 		PodName:                      getEnv("POD_NAME", getHostname()),
 		Namespace:                    getEnv("POD_NAMESPACE", "default"),
 	}
 
+	prompts, err := getJSONMapEnv[string]("DEFAULT_SYSTEM_PROMPTS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.DefaultSystemPrompts = prompts
+
+	maxTokens, err := getJSONMapEnv[int]("DEFAULT_MAX_TOKENS")
+	if err != nil {
+		return nil, err
+	}
+	for model, n := range maxTokens {
+		if n <= 0 {
+			return nil, fmt.Errorf("DEFAULT_MAX_TOKENS for %q must be positive, got %d", model, n)
+		}
+	}
+	cfg.DefaultMaxTokens = maxTokens
+
+	aliases, err := getJSONMapEnv[string]("OLLAMA_MODEL_ALIASES")
+	if err != nil {
+		return nil, err
+	}
+	cfg.OllamaModelAliases = aliases
+
+	metricsLabels, err := getJSONMapEnv[string]("METRICS_CONST_LABELS")
+	if err != nil {
+		return nil, err
+	}
+	for name, env := range map[string]string{"cluster": "CLUSTER", "region": "REGION"} {
+		if _, ok := metricsLabels[name]; !ok && os.Getenv(env) != "" {
+			if metricsLabels == nil {
+				metricsLabels = make(map[string]string)
+			}
+			metricsLabels[name] = os.Getenv(env)
+		}
+	}
+	for name := range metricsLabels {
+		if !validLabelName(name) {
+			return nil, fmt.Errorf("METRICS_CONST_LABELS: invalid label name %q", name)
+		}
+	}
+	cfg.MetricsLabels = metricsLabels
+
+	providerLimits, err := getJSONMapEnv[int]("PROVIDER_RATE_LIMITS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ProviderRateLimits = providerLimits
+
+	switch cfg.RoutingStrategy {
+	case "", "weighted":
+	default:
+		return nil, fmt.Errorf("ROUTING_STRATEGY must be empty or \"weighted\", got %q", cfg.RoutingStrategy)
+	}
+
+	switch cfg.UnknownModelStrategy {
+	case "default", "reject", "broadcast-probe":
+	default:
+		return nil, fmt.Errorf("UNKNOWN_MODEL_STRATEGY must be \"default\", \"reject\" or \"broadcast-probe\", got %q", cfg.UnknownModelStrategy)
+	}
+
+	socketMode, err := strconv.ParseUint(getEnv("LISTEN_SOCKET_MODE", "0660"), 8, 32)
+	if err != nil || socketMode > 0o777 {
+		return nil, fmt.Errorf("LISTEN_SOCKET_MODE must be an octal file mode, got %q", os.Getenv("LISTEN_SOCKET_MODE"))
+	}
+	cfg.ListenSocketMode = os.FileMode(socketMode)
+	if cfg.Addr == "none" && cfg.ListenSocket == "" {
+		return nil, errors.New("LISTEN_SOCKET must be set when ADDR is \"none\"")
+	}
+
+	switch cfg.ErrorFormat {
+	case "openai", "simple":
+	default:
+		return nil, fmt.Errorf("ERROR_FORMAT must be \"openai\" or \"simple\", got %q", cfg.ErrorFormat)
+	}
+
+	weights, err := getJSONMapEnv[float64]("ROUTING_WEIGHTS")
+	if err != nil {
+		return nil, err
+	}
+	for k := range weights {
+		if k != "cost" && k != "latency" && k != "health" {
+			return nil, fmt.Errorf("ROUTING_WEIGHTS: unknown factor %q", k)
+		}
+	}
+	cfg.RoutingWeights = weights
+
+	headerRules, err := getJSONListEnv[router.HeaderRule]("HEADER_ROUTING_RULES")
+	if err != nil {
+		return nil, err
+	}
+	cfg.HeaderRoutingRules = headerRules
+
+	providerCosts, err := getJSONMapEnv[float64]("PROVIDER_COSTS")
+	if err != nil {
+		return nil, err
+	}
+	cfg.ProviderCosts = providerCosts
+
+	retryable, err := getJSONMapEnv[[]int]("PROVIDER_RETRYABLE_STATUSES")
+	if err != nil {
+		return nil, err
+	}
+	for id, statuses := range retryable {
+		for _, s := range statuses {
+			if s < 100 || s > 599 {
+				return nil, fmt.Errorf("PROVIDER_RETRYABLE_STATUSES: invalid status %d for %q", s, id)
+			}
+		}
+	}
+	cfg.ProviderRetryableStatuses = retryable
+
+	costCaps, err := getJSONMapEnv[float64]("PROVIDER_DAILY_COST_CAPS")
+	if err != nil {
+		return nil, err
+	}
+	for id, limit := range costCaps {
+		if limit < 0 {
+			return nil, fmt.Errorf("PROVIDER_DAILY_COST_CAPS: cap for %q must not be negative", id)
+		}
+	}
+	cfg.ProviderDailyCostCaps = costCaps
+
+	if cfg.ArchiveSampleRate < 0 || cfg.ArchiveSampleRate > 1 {
+		return nil, errors.New("ARCHIVE_SAMPLE_RATE must be between 0 and 1")
+	}
+	archiveRates, err := getJSONMapEnv[float64]("ARCHIVE_TENANT_SAMPLE_RATES")
+	if err != nil {
+		return nil, err
+	}
+	for tenantID, rate := range archiveRates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("ARCHIVE_TENANT_SAMPLE_RATES: rate for %q must be between 0 and 1", tenantID)
+		}
+	}
+	cfg.ArchiveTenantSampleRates = archiveRates
+
+	if cfg.MemoryMaxTenants < 0 {
+		return nil, errors.New("TENANT_MEMORY_MAX must not be negative")
+	}
+
+	if len(cfg.BudgetExceededFields) == 1 && cfg.BudgetExceededFields[0] == "none" {
+		cfg.BudgetExceededFields = []string{}
+	}
+
+	if cfg.TokenEstimateErrorThreshold < 0 {
+		return nil, errors.New("TOKEN_ESTIMATE_ERROR_THRESHOLD must not be negative")
+	}
+
+	if cfg.TenantWebhookRetries < 0 {
+		return nil, errors.New("TENANT_WEBHOOK_RETRIES must not be negative")
+	}
+
+	if cfg.MaxRequestBodyBytes <= 0 {
+		return nil, errors.New("MAX_REQUEST_BODY_BYTES must be positive")
+	}
+
+	if cfg.RequestDedupWindow < 0 {
+		return nil, errors.New("REQUEST_DEDUP_WINDOW_MS must not be negative")
+	}
+
+	if cfg.RequestTimeBudget < 0 {
+		return nil, errors.New("REQUEST_TIME_BUDGET_MS must not be negative")
+	}
+
+	if cfg.FallbackMinRemaining < 0 {
+		return nil, errors.New("FALLBACK_MIN_REMAINING_MS must not be negative")
+	}
+
+	if cfg.SlowRequestThreshold < 0 {
+		return nil, errors.New("SLOW_REQUEST_THRESHOLD_MS must not be negative")
+	}
+
+	if cfg.CacheRedisRetries < 0 {
+		return nil, errors.New("CACHE_REDIS_RETRIES must not be negative")
+	}
+
+	if cfg.TenantCostGaugeMaxTenants < 0 {
+		return nil, errors.New("TENANT_COST_GAUGE_MAX_TENANTS must not be negative")
+	}
+
+	if cfg.MetricsSnapshotInterval < 0 {
+		return nil, errors.New("METRICS_SNAPSHOT_INTERVAL must not be negative")
+	}
+
+	if cfg.MetricsSnapshotRetention < 0 {
+		return nil, errors.New("METRICS_SNAPSHOT_RETENTION must not be negative")
+	}
+
+	if cfg.RequireEncryption && cfg.EncryptionKey == "" {
+		return nil, errors.New("ENCRYPTION_KEY must be set when REQUIRE_ENCRYPTION is enabled")
+	}
+
 	return cfg, nil
 }
 
+// redactedValue replaces secrets in Redacted output.
+const redactedValue = "[REDACTED]"
+
+// Redacted returns a copy of the config that is safe to show operators: API
+// and encryption keys are replaced and passwords are stripped from
+// connection URLs. Unset secrets stay empty so it is visible they are unset.
+func (c *Config) Redacted() Config {
+	out := *c
+	for _, secret := range []*string{&out.OpenAIAPIKey, &out.AnthropicAPIKey, &out.MistralAPIKey, &out.EncryptionKey} {
+		if *secret != "" {
+			*secret = redactedValue
+		}
+	}
+	out.RedisURL = redactURL(out.RedisURL)
+	out.DatabaseURL = redactURL(out.DatabaseURL)
+	return out
+}
+
+// redactURL masks the password in a connection URL. A value that does not
+// parse is hidden entirely, since it may still carry credentials.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	return u.Redacted()
+}
+
 func getHostname() string {
 	if h, err := os.Hostname(); err == nil {
 		return h
@@ -80,3 +674,73 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// validLabelName reports whether name is a Prometheus label name not reserved
+// for Prometheus itself.
+func validLabelName(name string) bool {
+	if name == "" || strings.HasPrefix(name, "__") {
+		return false
+	}
+	for i, c := range name {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// getListEnv splits a comma-separated value, dropping blank entries.
+func getListEnv(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getJSONListEnv[V any](key string) ([]V, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	var list []V
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return list, nil
+}
+
+func getJSONMapEnv[V any](key string) (map[string]V, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	var m map[string]V
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", key, err)
+	}
+	return m, nil
+}
@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/router"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -52,6 +56,9 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.AdminAuthEnabled {
 		t.Error("AdminAuthEnabled should default to false")
 	}
+	if cfg.ReadTimeout != 30*time.Second || cfg.WriteTimeout != 120*time.Second || cfg.IdleTimeout != 120*time.Second {
+		t.Errorf("server timeouts = %v/%v/%v, want 30s/120s/120s", cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout)
+	}
 }
 
 func TestLoad_FromEnv(t *testing.T) {
@@ -168,3 +175,333 @@ func TestAdminAuthEnabled_FalseValues(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_RequireEncryption(t *testing.T) {
+	os.Setenv("REQUIRE_ENCRYPTION", "true")
+	defer os.Unsetenv("REQUIRE_ENCRYPTION")
+	os.Unsetenv("ENCRYPTION_KEY")
+
+	if _, err := Load(); err == nil {
+		t.Error("Load() should fail when REQUIRE_ENCRYPTION is set without ENCRYPTION_KEY")
+	}
+
+	os.Setenv("ENCRYPTION_KEY", "my-secret-key")
+	defer os.Unsetenv("ENCRYPTION_KEY")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.RequireEncryption {
+		t.Error("RequireEncryption should be true when REQUIRE_ENCRYPTION=true")
+	}
+}
+
+func TestLoad_DefaultSystemPrompts(t *testing.T) {
+	os.Setenv("DEFAULT_SYSTEM_PROMPTS", `{"llama3":"Answer in Markdown."}`)
+	defer os.Unsetenv("DEFAULT_SYSTEM_PROMPTS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if got := cfg.DefaultSystemPrompts["llama3"]; got != "Answer in Markdown." {
+		t.Errorf("DefaultSystemPrompts[llama3] = %q, want %q", got, "Answer in Markdown.")
+	}
+
+	os.Setenv("DEFAULT_SYSTEM_PROMPTS", "not-json")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for invalid DEFAULT_SYSTEM_PROMPTS")
+	}
+}
+
+func TestLoad_TraceSampleRatio(t *testing.T) {
+	os.Unsetenv("OTEL_TRACE_SAMPLE_RATIO")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TraceSampleRatio != 1.0 {
+		t.Errorf("TraceSampleRatio default = %v, want 1.0", cfg.TraceSampleRatio)
+	}
+
+	os.Setenv("OTEL_TRACE_SAMPLE_RATIO", "0.05")
+	defer os.Unsetenv("OTEL_TRACE_SAMPLE_RATIO")
+
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.TraceSampleRatio != 0.05 {
+		t.Errorf("TraceSampleRatio = %v, want 0.05", cfg.TraceSampleRatio)
+	}
+}
+
+func TestLoad_FallbackOrder(t *testing.T) {
+	os.Setenv("FALLBACK_ORDER", " anthropic, openai,,ollama ")
+	defer os.Unsetenv("FALLBACK_ORDER")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	want := []string{"anthropic", "openai", "ollama"}
+	if len(cfg.FallbackOrder) != len(want) {
+		t.Fatalf("FallbackOrder = %v, want %v", cfg.FallbackOrder, want)
+	}
+	for i := range want {
+		if cfg.FallbackOrder[i] != want[i] {
+			t.Errorf("FallbackOrder[%d] = %q, want %q", i, cfg.FallbackOrder[i], want[i])
+		}
+	}
+}
+
+func TestLoad_MaxFallbackAttempts(t *testing.T) {
+	os.Setenv("MAX_FALLBACK_ATTEMPTS", "2")
+	defer os.Unsetenv("MAX_FALLBACK_ATTEMPTS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.MaxFallbackAttempts != 2 {
+		t.Errorf("MaxFallbackAttempts = %d, want 2", cfg.MaxFallbackAttempts)
+	}
+}
+
+func TestLoad_ProviderRateLimits(t *testing.T) {
+	os.Setenv("PROVIDER_RATE_LIMITS", `{"openai": 3000, "anthropic": 1000}`)
+	os.Setenv("PROVIDER_RATE_LIMIT_WAIT", "2")
+	defer os.Unsetenv("PROVIDER_RATE_LIMITS")
+	defer os.Unsetenv("PROVIDER_RATE_LIMIT_WAIT")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ProviderRateLimits["openai"] != 3000 || cfg.ProviderRateLimits["anthropic"] != 1000 {
+		t.Errorf("ProviderRateLimits = %v", cfg.ProviderRateLimits)
+	}
+	if cfg.ProviderRateLimitWait != 2*time.Second {
+		t.Errorf("ProviderRateLimitWait = %v, want 2s", cfg.ProviderRateLimitWait)
+	}
+
+	os.Setenv("PROVIDER_RATE_LIMITS", `{"openai": "fast"}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for non-numeric PROVIDER_RATE_LIMITS")
+	}
+}
+
+func TestLoad_HeaderRoutingRules(t *testing.T) {
+	os.Setenv("HEADER_ROUTING_RULES", `[{"header": "X-Region", "value": "eu", "provider": "bedrock"}]`)
+	defer os.Unsetenv("HEADER_ROUTING_RULES")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := router.HeaderRule{Header: "X-Region", Value: "eu", Provider: "bedrock"}
+	if len(cfg.HeaderRoutingRules) != 1 || cfg.HeaderRoutingRules[0] != want {
+		t.Errorf("HeaderRoutingRules = %v, want %v", cfg.HeaderRoutingRules, want)
+	}
+
+	os.Setenv("HEADER_ROUTING_RULES", `{"header": "X-Region"}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for rules that are not a JSON array")
+	}
+}
+
+func TestLoad_RoutingStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		weights  string
+		wantErr  bool
+	}{
+		{"weighted", "weighted", `{"cost": 2, "latency": 1}`, false},
+		{"unknown strategy", "fastest", "", true},
+		{"unknown factor", "weighted", `{"price": 1}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("ROUTING_STRATEGY", tt.strategy)
+			os.Setenv("ROUTING_WEIGHTS", tt.weights)
+			defer os.Unsetenv("ROUTING_STRATEGY")
+			defer os.Unsetenv("ROUTING_WEIGHTS")
+
+			cfg, err := Load()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.RoutingWeights["cost"] != 2 {
+				t.Errorf("RoutingWeights = %v", cfg.RoutingWeights)
+			}
+		})
+	}
+}
+
+func TestLoad_ErrorFormat(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ErrorFormat != "openai" {
+		t.Errorf("default ErrorFormat = %q, want openai", cfg.ErrorFormat)
+	}
+
+	os.Setenv("ERROR_FORMAT", "problem")
+	defer os.Unsetenv("ERROR_FORMAT")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for unknown ERROR_FORMAT")
+	}
+}
+
+func TestLoad_ListenSocket(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ListenSocket != "" || cfg.ListenSocketMode != 0o660 {
+		t.Errorf("defaults = %q %v, want no socket and 0660", cfg.ListenSocket, cfg.ListenSocketMode)
+	}
+
+	os.Setenv("ADDR", "none")
+	defer os.Unsetenv("ADDR")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for ADDR=none without LISTEN_SOCKET")
+	}
+
+	os.Setenv("LISTEN_SOCKET", "/run/aigateway/gateway.sock")
+	os.Setenv("LISTEN_SOCKET_MODE", "0600")
+	defer os.Unsetenv("LISTEN_SOCKET")
+	defer os.Unsetenv("LISTEN_SOCKET_MODE")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ListenSocket != "/run/aigateway/gateway.sock" || cfg.ListenSocketMode != 0o600 {
+		t.Errorf("socket = %q %v", cfg.ListenSocket, cfg.ListenSocketMode)
+	}
+
+	os.Setenv("LISTEN_SOCKET_MODE", "rw-rw----")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for a non-octal LISTEN_SOCKET_MODE")
+	}
+}
+
+func TestLoad_ProviderMaxConnsPerHost(t *testing.T) {
+	os.Setenv("PROVIDER_MAX_CONNS_PER_HOST", "32")
+	defer os.Unsetenv("PROVIDER_MAX_CONNS_PER_HOST")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ProviderMaxConnsPerHost != 32 {
+		t.Errorf("ProviderMaxConnsPerHost = %d, want 32", cfg.ProviderMaxConnsPerHost)
+	}
+}
+
+func TestLoad_MetricsLabels(t *testing.T) {
+	os.Setenv("METRICS_CONST_LABELS", `{"region":"eu-west-1","env":"prod"}`)
+	os.Setenv("CLUSTER", "eu1")
+	os.Setenv("REGION", "ignored")
+	defer os.Unsetenv("METRICS_CONST_LABELS")
+	defer os.Unsetenv("CLUSTER")
+	defer os.Unsetenv("REGION")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := map[string]string{"cluster": "eu1", "region": "eu-west-1", "env": "prod"}
+	if len(cfg.MetricsLabels) != len(want) {
+		t.Errorf("MetricsLabels = %v, want %v", cfg.MetricsLabels, want)
+	}
+	for name, value := range want {
+		if cfg.MetricsLabels[name] != value {
+			t.Errorf("MetricsLabels[%s] = %q, want %q", name, cfg.MetricsLabels[name], value)
+		}
+	}
+
+	os.Setenv("METRICS_CONST_LABELS", `{"cluster-name":"eu1"}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for an invalid label name")
+	}
+}
+
+func TestLoad_DefaultMaxTokens(t *testing.T) {
+	os.Setenv("DEFAULT_MAX_TOKENS", `{"*": 4096, "gpt-4o": 16384}`)
+	defer os.Unsetenv("DEFAULT_MAX_TOKENS")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DefaultMaxTokens["*"] != 4096 || cfg.DefaultMaxTokens["gpt-4o"] != 16384 {
+		t.Errorf("DefaultMaxTokens = %v", cfg.DefaultMaxTokens)
+	}
+
+	os.Setenv("DEFAULT_MAX_TOKENS", `{"gpt-4o": 0}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for a non-positive max_tokens")
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := &Config{
+		OpenAIAPIKey:  "sk-secret",
+		MistralAPIKey: "mistral-secret",
+		RedisURL:      "redis://:hunter2@localhost:6379",
+		DatabaseURL:   "postgres://user:pw@db/gateway",
+		Addr:          ":8080",
+	}
+
+	got := cfg.Redacted()
+	if got.OpenAIAPIKey != "[REDACTED]" {
+		t.Errorf("OpenAIAPIKey = %q", got.OpenAIAPIKey)
+	}
+	if got.MistralAPIKey != "[REDACTED]" {
+		t.Errorf("MistralAPIKey = %q", got.MistralAPIKey)
+	}
+	if got.AnthropicAPIKey != "" {
+		t.Errorf("unset key should stay empty, got %q", got.AnthropicAPIKey)
+	}
+	if strings.Contains(got.RedisURL, "hunter2") || strings.Contains(got.DatabaseURL, ":pw@") {
+		t.Errorf("URL passwords not redacted: %q, %q", got.RedisURL, got.DatabaseURL)
+	}
+	if got.Addr != ":8080" {
+		t.Errorf("Addr = %q, want unchanged", got.Addr)
+	}
+	if cfg.OpenAIAPIKey != "sk-secret" {
+		t.Error("Redacted must not modify the original")
+	}
+}
+
+func TestLoad_ArchiveSampleRates(t *testing.T) {
+	os.Setenv("ARCHIVE_SAMPLE_RATE", "0.05")
+	os.Setenv("ARCHIVE_TENANT_SAMPLE_RATES", `{"tenant-debug": 1}`)
+	defer os.Unsetenv("ARCHIVE_SAMPLE_RATE")
+	defer os.Unsetenv("ARCHIVE_TENANT_SAMPLE_RATES")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ArchiveSampleRate != 0.05 || cfg.ArchiveTenantSampleRates["tenant-debug"] != 1 {
+		t.Errorf("archive rates = %v, %v", cfg.ArchiveSampleRate, cfg.ArchiveTenantSampleRates)
+	}
+
+	os.Setenv("ARCHIVE_TENANT_SAMPLE_RATES", `{"tenant-debug": 2}`)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for a tenant rate above 1")
+	}
+	os.Setenv("ARCHIVE_TENANT_SAMPLE_RATES", "")
+	os.Setenv("ARCHIVE_SAMPLE_RATE", "-1")
+	if _, err := Load(); err == nil {
+		t.Error("expected error for a negative rate")
+	}
+}
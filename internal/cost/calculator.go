@@ -4,6 +4,7 @@ package cost
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,6 +28,13 @@ var defaultPricing = map[string]ModelPricing{
 	"claude-3-opus-20240229":     {InputPer1K: 0.015, OutputPer1K: 0.075},
 	"claude-3-sonnet-20240229":   {InputPer1K: 0.003, OutputPer1K: 0.015},
 	"claude-3-haiku-20240307":    {InputPer1K: 0.00025, OutputPer1K: 0.00125},
+	"mistral-large-latest":       {InputPer1K: 0.002, OutputPer1K: 0.006},
+	"mistral-small-latest":       {InputPer1K: 0.0002, OutputPer1K: 0.0006},
+	"codestral-latest":           {InputPer1K: 0.0003, OutputPer1K: 0.0009},
+	"open-mistral-nemo":          {InputPer1K: 0.00015, OutputPer1K: 0.00015},
+	"open-mistral-7b":            {InputPer1K: 0.00025, OutputPer1K: 0.00025},
+	"open-mixtral-8x7b":          {InputPer1K: 0.0007, OutputPer1K: 0.0007},
+	"open-mixtral-8x22b":         {InputPer1K: 0.002, OutputPer1K: 0.006},
 }
 
 // Calculator computes costs for LLM requests based on model pricing.
@@ -48,12 +56,33 @@ func (c *Calculator) Calculate(model string, usage domain.Usage) float64 {
 		return 0
 	}
 
-	inputCost := float64(usage.PromptTokens) / 1000 * pricing.InputPer1K
-	outputCost := float64(usage.CompletionTokens) / 1000 * pricing.OutputPer1K
+	return pricing.cost(usage)
+}
+
+func (p ModelPricing) cost(usage domain.Usage) float64 {
+	inputCost := float64(usage.PromptTokens) / 1000 * p.InputPer1K
+	outputCost := float64(usage.CompletionTokens) / 1000 * p.OutputPer1K
 
 	return inputCost + outputCost
 }
 
+// CalculateForTenant is like Calculate but bills models listed in the
+// tenant's PricingOverrides at the tenant's negotiated rate.
+func (c *Calculator) CalculateForTenant(tenant *domain.Tenant, model string, usage domain.Usage) float64 {
+	if tenant != nil {
+		if price, ok := tenant.PricingOverrides[model]; ok {
+			return ModelPricing{InputPer1K: price.InputPer1K, OutputPer1K: price.OutputPer1K}.cost(usage)
+		}
+	}
+	return c.Calculate(model, usage)
+}
+
+// Priced reports whether the calculator has a price for model.
+func (c *Calculator) Priced(model string) bool {
+	_, ok := c.pricing[model]
+	return ok
+}
+
 func (c *Calculator) SetPricing(model string, pricing ModelPricing) {
 	c.pricing[model] = pricing
 }
@@ -70,6 +99,22 @@ type UsageRecord struct {
 	Cached       bool
 	LatencyMs    int64
 	Timestamp    time.Time
+
+	// Metadata is the client-supplied request metadata, kept for attribution.
+	Metadata map[string]string
+
+	// Tags are parsed from the X-Tags header for per-project cost breakdowns.
+	Tags map[string]string
+}
+
+// ProviderTotals aggregates usage for a single provider over a time range.
+type ProviderTotals struct {
+	Provider     string  `json:"provider"`
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
 }
 
 // Tracker defines the interface for usage tracking backends.
@@ -77,6 +122,13 @@ type Tracker interface {
 	Record(ctx context.Context, record UsageRecord) error
 	GetTenantUsage(ctx context.Context, tenantID string, since time.Time) ([]UsageRecord, error)
 	GetTenantTotalCost(ctx context.Context, tenantID string, since time.Time) (float64, error)
+	// GetProviderTotals returns per-provider totals for records in
+	// [since, until), ordered by provider.
+	GetProviderTotals(ctx context.Context, since, until time.Time) ([]ProviderTotals, error)
+	// GetTenantTagTotals groups a tenant's records since the given time by
+	// the value of tag key, ordered by value. Records without the tag are
+	// left out.
+	GetTenantTagTotals(ctx context.Context, tenantID, key string, since time.Time) ([]TagTotals, error)
 }
 
 type InMemoryTracker struct {
@@ -124,6 +176,69 @@ func (t *InMemoryTracker) GetTenantTotalCost(ctx context.Context, tenantID strin
 	return total, nil
 }
 
+func (t *InMemoryTracker) GetProviderTotals(ctx context.Context, since, until time.Time) ([]ProviderTotals, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	byProvider := make(map[string]*ProviderTotals)
+	latency := make(map[string]int64)
+	for i := range t.records {
+		r := &t.records[i]
+		if r.Timestamp.Before(since) || !r.Timestamp.Before(until) {
+			continue
+		}
+		totals, ok := byProvider[r.Provider]
+		if !ok {
+			totals = &ProviderTotals{Provider: r.Provider}
+			byProvider[r.Provider] = totals
+		}
+		totals.Requests++
+		totals.InputTokens += int64(r.InputTokens)
+		totals.OutputTokens += int64(r.OutputTokens)
+		totals.CostUSD += r.CostUSD
+		latency[r.Provider] += r.LatencyMs
+	}
+
+	result := make([]ProviderTotals, 0, len(byProvider))
+	for provider, totals := range byProvider {
+		totals.AvgLatencyMs = float64(latency[provider]) / float64(totals.Requests)
+		result = append(result, *totals)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Provider < result[j].Provider })
+	return result, nil
+}
+
+func (t *InMemoryTracker) GetTenantTagTotals(ctx context.Context, tenantID, key string, since time.Time) ([]TagTotals, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	byValue := make(map[string]*TagTotals)
+	for i := range t.records {
+		r := &t.records[i]
+		if r.TenantID != tenantID || r.Timestamp.Before(since) {
+			continue
+		}
+		value, ok := r.Tags[key]
+		if !ok {
+			continue
+		}
+		totals, ok := byValue[value]
+		if !ok {
+			totals = &TagTotals{Value: value}
+			byValue[value] = totals
+		}
+		totals.Requests++
+		totals.CostUSD += r.CostUSD
+	}
+
+	result := make([]TagTotals, 0, len(byValue))
+	for _, totals := range byValue {
+		result = append(result, *totals)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Value < result[j].Value })
+	return result, nil
+}
+
 func (t *InMemoryTracker) GetAllRecords() []UsageRecord {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -2,6 +2,7 @@ package cost
 
 import (
 	"context"
+	"math"
 	"testing"
 	"time"
 
@@ -44,6 +45,15 @@ func TestCalculator_Calculate(t *testing.T) {
 			},
 			expected: 0.001 + 0.0015, // 2K * 0.0005 + 1K * 0.0015
 		},
+		{
+			name:  "mistral-large-latest",
+			model: "mistral-large-latest",
+			usage: domain.Usage{
+				PromptTokens:     1000,
+				CompletionTokens: 1000,
+			},
+			expected: 0.002 + 0.006, // 1K * 0.002 + 1K * 0.006
+		},
 	}
 
 	for _, tt := range tests {
@@ -56,6 +66,39 @@ func TestCalculator_Calculate(t *testing.T) {
 	}
 }
 
+func TestCalculator_CalculateForTenant(t *testing.T) {
+	calc := NewCalculator()
+	usage := domain.Usage{PromptTokens: 1000, CompletionTokens: 500}
+
+	discounted := &domain.Tenant{
+		ID: "tenant-discount",
+		PricingOverrides: map[string]domain.ModelPrice{
+			"gpt-4": {InputPer1K: 0.015, OutputPer1K: 0.03},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		tenant   *domain.Tenant
+		model    string
+		expected float64
+	}{
+		{"override applies", discounted, "gpt-4", 0.015 + 0.015},
+		{"other models use list price", discounted, "gpt-3.5-turbo", 0.0005 + 0.00075},
+		{"no overrides", &domain.Tenant{ID: "tenant-list"}, "gpt-4", 0.03 + 0.03},
+		{"nil tenant", nil, "gpt-4", 0.03 + 0.03},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := calc.CalculateForTenant(tt.tenant, tt.model, usage)
+			if math.Abs(result-tt.expected) > 1e-9 {
+				t.Errorf("expected %f, got %f", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestInMemoryTracker_Record(t *testing.T) {
 	tracker := NewInMemoryTracker()
 	ctx := context.Background()
@@ -113,3 +156,94 @@ func TestInMemoryTracker_GetTenantTotalCost(t *testing.T) {
 		t.Errorf("expected ~0.30, got %f", total)
 	}
 }
+
+func TestInMemoryTracker_GetProviderTotals(t *testing.T) {
+	tracker := NewInMemoryTracker()
+	ctx := context.Background()
+
+	now := time.Now()
+	records := []UsageRecord{
+		{Provider: "openai", InputTokens: 100, OutputTokens: 50, CostUSD: 0.10, LatencyMs: 200, Timestamp: now},
+		{Provider: "openai", InputTokens: 300, OutputTokens: 150, CostUSD: 0.30, LatencyMs: 400, Timestamp: now},
+		{Provider: "anthropic", InputTokens: 10, OutputTokens: 5, CostUSD: 0.02, LatencyMs: 100, Timestamp: now},
+		{Provider: "openai", CostUSD: 9.99, Timestamp: now.Add(-48 * time.Hour)},
+	}
+	for _, r := range records {
+		tracker.Record(ctx, r)
+	}
+
+	totals, err := tracker.GetProviderTotals(ctx, now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []ProviderTotals{
+		{Provider: "anthropic", Requests: 1, InputTokens: 10, OutputTokens: 5, CostUSD: 0.02, AvgLatencyMs: 100},
+		{Provider: "openai", Requests: 2, InputTokens: 400, OutputTokens: 200, CostUSD: 0.40, AvgLatencyMs: 300},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("got %d providers, want %d: %+v", len(totals), len(want), totals)
+	}
+	for i := range want {
+		got := totals[i]
+		if got.Provider != want[i].Provider || got.Requests != want[i].Requests ||
+			got.InputTokens != want[i].InputTokens || got.OutputTokens != want[i].OutputTokens ||
+			got.AvgLatencyMs != want[i].AvgLatencyMs || math.Abs(got.CostUSD-want[i].CostUSD) > 1e-9 {
+			t.Errorf("totals[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestInMemoryTracker_GetTenantTagTotals(t *testing.T) {
+	tracker := NewInMemoryTracker()
+	ctx := context.Background()
+
+	now := time.Now()
+	records := []UsageRecord{
+		{TenantID: "tenant1", CostUSD: 0.10, Tags: map[string]string{"project": "search"}, Timestamp: now},
+		{TenantID: "tenant1", CostUSD: 0.20, Tags: map[string]string{"project": "search", "team": "ml"}, Timestamp: now},
+		{TenantID: "tenant1", CostUSD: 0.05, Tags: map[string]string{"project": "chat"}, Timestamp: now},
+		{TenantID: "tenant1", CostUSD: 1.00, Timestamp: now},
+		{TenantID: "tenant2", CostUSD: 5.00, Tags: map[string]string{"project": "search"}, Timestamp: now},
+		{TenantID: "tenant1", CostUSD: 9.99, Tags: map[string]string{"project": "search"}, Timestamp: now.Add(-48 * time.Hour)},
+	}
+	for _, r := range records {
+		tracker.Record(ctx, r)
+	}
+
+	totals, err := tracker.GetTenantTagTotals(ctx, "tenant1", "project", now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []TagTotals{
+		{Value: "chat", Requests: 1, CostUSD: 0.05},
+		{Value: "search", Requests: 2, CostUSD: 0.30},
+	}
+	if len(totals) != len(want) {
+		t.Fatalf("got %d values, want %d: %+v", len(totals), len(want), totals)
+	}
+	for i := range want {
+		got := totals[i]
+		if got.Value != want[i].Value || got.Requests != want[i].Requests || math.Abs(got.CostUSD-want[i].CostUSD) > 1e-9 {
+			t.Errorf("totals[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestInMemoryTracker_GetTenantTagTotals_IncludesSince(t *testing.T) {
+	tracker := NewInMemoryTracker()
+	ctx := context.Background()
+
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tracker.Record(ctx, UsageRecord{TenantID: "tenant1", CostUSD: 0.10, Tags: map[string]string{"project": "search"}, Timestamp: since})
+
+	// Matches the Postgres tracker, which selects created_at >= since.
+	totals, err := tracker.GetTenantTagTotals(ctx, "tenant1", "project", since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(totals) != 1 || totals[0].Requests != 1 {
+		t.Errorf("totals = %+v, want the record at since", totals)
+	}
+}
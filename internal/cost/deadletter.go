@@ -0,0 +1,316 @@
+package cost
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/google/uuid"
+)
+
+// DeadLetter is a usage record that could not be persisted.
+type DeadLetter struct {
+	ID       string      `json:"id"`
+	Record   UsageRecord `json:"record"`
+	Error    string      `json:"error"`
+	FailedAt time.Time   `json:"failed_at"`
+}
+
+// DeadLetterStore keeps usage records that failed to persist so they can be
+// replayed instead of being lost.
+type DeadLetterStore interface {
+	Add(ctx context.Context, letter DeadLetter) error
+	// List returns dead letters oldest first.
+	List(ctx context.Context) ([]DeadLetter, error)
+	Remove(ctx context.Context, ids ...string) error
+}
+
+// InMemoryDeadLetterStore holds dead letters for the life of the process.
+type InMemoryDeadLetterStore struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func NewInMemoryDeadLetterStore() *InMemoryDeadLetterStore {
+	return &InMemoryDeadLetterStore{}
+}
+
+func (s *InMemoryDeadLetterStore) Add(ctx context.Context, letter DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(s.letters, letter)
+	return nil
+}
+
+func (s *InMemoryDeadLetterStore) List(ctx context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]DeadLetter(nil), s.letters...), nil
+}
+
+func (s *InMemoryDeadLetterStore) Remove(ctx context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = withoutIDs(s.letters, ids)
+	return nil
+}
+
+// FileDeadLetterStore appends dead letters to a JSON lines file, so they
+// survive restarts and do not depend on the database that just failed.
+type FileDeadLetterStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileDeadLetterStore uses the file at path, creating it if needed.
+func NewFileDeadLetterStore(path string) (*FileDeadLetterStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open dead letter file: %w", err)
+	}
+	f.Close()
+	return &FileDeadLetterStore{path: path}, nil
+}
+
+func (s *FileDeadLetterStore) Add(ctx context.Context, letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("encode dead letter: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open dead letter file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write dead letter: %w", err)
+	}
+	return f.Sync()
+}
+
+func (s *FileDeadLetterStore) List(ctx context.Context) ([]DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+func (s *FileDeadLetterStore) Remove(ctx context.Context, ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	letters, err := s.read()
+	if err != nil {
+		return err
+	}
+	return s.rewrite(withoutIDs(letters, ids))
+}
+
+func (s *FileDeadLetterStore) read() ([]DeadLetter, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("open dead letter file: %w", err)
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("decode dead letter: %w", err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read dead letter file: %w", err)
+	}
+	return letters, nil
+}
+
+// rewrite replaces the file atomically so a crash never loses letters.
+func (s *FileDeadLetterStore) rewrite(letters []DeadLetter) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create dead letter file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	enc := json.NewEncoder(tmp)
+	for _, letter := range letters {
+		if err := enc.Encode(letter); err != nil {
+			tmp.Close()
+			return fmt.Errorf("encode dead letter: %w", err)
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync dead letter file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close dead letter file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replace dead letter file: %w", err)
+	}
+	return nil
+}
+
+func withoutIDs(letters []DeadLetter, ids []string) []DeadLetter {
+	drop := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	kept := letters[:0:0]
+	for _, letter := range letters {
+		if !drop[letter.ID] {
+			kept = append(kept, letter)
+		}
+	}
+	return kept
+}
+
+// RetryingTracker retries failed usage writes and dead-letters records that
+// still fail, so a database outage does not silently drop billing events.
+// Reads go straight to the wrapped tracker.
+type RetryingTracker struct {
+	Tracker
+	deadLetters DeadLetterStore
+	attempts    int
+	backoff     time.Duration
+
+	// replayMu serializes Replay so overlapping calls cannot both write a
+	// dead letter before either removes it, billing it twice.
+	replayMu sync.Mutex
+}
+
+// RetryingTrackerOption configures a RetryingTracker.
+type RetryingTrackerOption func(*RetryingTracker)
+
+// WithRecordRetries sets how many times a write is attempted and the delay
+// before the first retry, which doubles on each further retry.
+func WithRecordRetries(attempts int, backoff time.Duration) RetryingTrackerOption {
+	return func(t *RetryingTracker) {
+		t.attempts = max(attempts, 1)
+		t.backoff = backoff
+	}
+}
+
+// NewRetryingTracker wraps tracker, defaulting to three attempts with a
+// 100ms initial backoff.
+func NewRetryingTracker(tracker Tracker, deadLetters DeadLetterStore, opts ...RetryingTrackerOption) *RetryingTracker {
+	t := &RetryingTracker{
+		Tracker:     tracker,
+		deadLetters: deadLetters,
+		attempts:    3,
+		backoff:     100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Record writes the record, retrying on failure. If every attempt fails the
+// record is dead-lettered and Record returns nil; it only returns an error
+// when the record could be neither written nor dead-lettered.
+func (t *RetryingTracker) Record(ctx context.Context, record UsageRecord) error {
+	err := t.record(ctx, record)
+	if err == nil {
+		return nil
+	}
+
+	letter := DeadLetter{
+		ID:       uuid.NewString(),
+		Record:   record,
+		Error:    err.Error(),
+		FailedAt: time.Now(),
+	}
+	// The request context may be what ran out; the dead letter must still
+	// be written.
+	if dlErr := t.deadLetters.Add(context.WithoutCancel(ctx), letter); dlErr != nil {
+		return fmt.Errorf("record usage: %w (dead letter failed: %v)", err, dlErr)
+	}
+
+	metrics.RecordUsageDeadLettered()
+	slog.Warn("usage record dead-lettered",
+		"dead_letter_id", letter.ID,
+		"request_id", record.RequestID,
+		"tenant_id", record.TenantID,
+		"error", err,
+	)
+	return nil
+}
+
+func (t *RetryingTracker) record(ctx context.Context, record UsageRecord) error {
+	delay := t.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = t.Tracker.Record(ctx, record); err == nil || attempt >= t.attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// DeadLetters lists records waiting to be replayed.
+func (t *RetryingTracker) DeadLetters(ctx context.Context) ([]DeadLetter, error) {
+	return t.deadLetters.List(ctx)
+}
+
+// ReplayResult reports the outcome of Replay.
+type ReplayResult struct {
+	Replayed int `json:"replayed"`
+	Failed   int `json:"failed"`
+}
+
+// Replay writes every dead letter to the wrapped tracker once, removing the
+// ones that succeed. Failures stay dead-lettered for a later replay. A call
+// made while another is running waits for it, then replays what is left.
+func (t *RetryingTracker) Replay(ctx context.Context) (ReplayResult, error) {
+	t.replayMu.Lock()
+	defer t.replayMu.Unlock()
+
+	letters, err := t.deadLetters.List(ctx)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	var result ReplayResult
+	var replayed []string
+	for _, letter := range letters {
+		if err := t.Tracker.Record(ctx, letter.Record); err != nil {
+			result.Failed++
+			continue
+		}
+		replayed = append(replayed, letter.ID)
+	}
+	result.Replayed = len(replayed)
+
+	if len(replayed) > 0 {
+		if err := t.deadLetters.Remove(ctx, replayed...); err != nil {
+			return result, fmt.Errorf("remove replayed dead letters: %w", err)
+		}
+	}
+	return result, nil
+}
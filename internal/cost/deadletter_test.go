@@ -0,0 +1,165 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// flakyTracker fails every Record call until failures reaches zero.
+type flakyTracker struct {
+	*InMemoryTracker
+	failures int
+	calls    int
+}
+
+func (f *flakyTracker) Record(ctx context.Context, record UsageRecord) error {
+	f.calls++
+	if f.failures != 0 {
+		if f.failures > 0 {
+			f.failures--
+		}
+		return errors.New("database unavailable")
+	}
+	return f.InMemoryTracker.Record(ctx, record)
+}
+
+func TestRetryingTracker_RetriesBeforeDeadLettering(t *testing.T) {
+	inner := &flakyTracker{InMemoryTracker: NewInMemoryTracker(), failures: 2}
+	store := NewInMemoryDeadLetterStore()
+	tracker := NewRetryingTracker(inner, store, WithRecordRetries(3, time.Millisecond))
+
+	if err := tracker.Record(context.Background(), UsageRecord{TenantID: "t1", RequestID: "req-1", Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if inner.calls != 3 {
+		t.Errorf("calls = %d, want 3", inner.calls)
+	}
+	if letters, _ := store.List(context.Background()); len(letters) != 0 {
+		t.Errorf("dead letters = %d, want 0", len(letters))
+	}
+	if usage, _ := tracker.GetTenantUsage(context.Background(), "t1", time.Time{}); len(usage) != 1 {
+		t.Errorf("recorded usage = %d, want 1", len(usage))
+	}
+}
+
+func TestRetryingTracker_DeadLettersAndReplays(t *testing.T) {
+	stores := map[string]func(t *testing.T) DeadLetterStore{
+		"memory": func(t *testing.T) DeadLetterStore { return NewInMemoryDeadLetterStore() },
+		"file": func(t *testing.T) DeadLetterStore {
+			store, err := NewFileDeadLetterStore(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+			if err != nil {
+				t.Fatalf("NewFileDeadLetterStore() error = %v", err)
+			}
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			inner := &flakyTracker{InMemoryTracker: NewInMemoryTracker(), failures: -1}
+			tracker := NewRetryingTracker(inner, newStore(t), WithRecordRetries(2, time.Millisecond))
+			before := testutil.ToFloat64(metrics.UsageDeadLettered)
+
+			for _, id := range []string{"req-1", "req-2"} {
+				record := UsageRecord{TenantID: "t1", RequestID: id, CostUSD: 0.5, Timestamp: time.Now()}
+				if err := tracker.Record(ctx, record); err != nil {
+					t.Fatalf("Record() error = %v", err)
+				}
+			}
+
+			letters, err := tracker.DeadLetters(ctx)
+			if err != nil {
+				t.Fatalf("DeadLetters() error = %v", err)
+			}
+			if len(letters) != 2 {
+				t.Fatalf("dead letters = %d, want 2", len(letters))
+			}
+			if letters[0].Record.RequestID != "req-1" || letters[0].Error != "database unavailable" {
+				t.Errorf("first dead letter = %+v", letters[0])
+			}
+			if got := testutil.ToFloat64(metrics.UsageDeadLettered) - before; got != 2 {
+				t.Errorf("dead-lettered metric increased by %v, want 2", got)
+			}
+
+			// Still failing: nothing is replayed or lost.
+			result, err := tracker.Replay(ctx)
+			if err != nil {
+				t.Fatalf("Replay() error = %v", err)
+			}
+			if result != (ReplayResult{Failed: 2}) {
+				t.Errorf("Replay() = %+v, want 2 failed", result)
+			}
+
+			inner.failures = 0
+			result, err = tracker.Replay(ctx)
+			if err != nil {
+				t.Fatalf("Replay() error = %v", err)
+			}
+			if result != (ReplayResult{Replayed: 2}) {
+				t.Errorf("Replay() = %+v, want 2 replayed", result)
+			}
+			if letters, _ := tracker.DeadLetters(ctx); len(letters) != 0 {
+				t.Errorf("dead letters after replay = %d, want 0", len(letters))
+			}
+			total, _ := tracker.GetTenantTotalCost(ctx, "t1", time.Time{})
+			if total != 1 {
+				t.Errorf("total cost after replay = %v, want 1", total)
+			}
+		})
+	}
+}
+
+// countingTracker counts writes per request ID, pausing on each so
+// overlapping replays would interleave.
+type countingTracker struct {
+	*InMemoryTracker
+	mu     sync.Mutex
+	writes map[string]int
+}
+
+func (c *countingTracker) Record(ctx context.Context, record UsageRecord) error {
+	time.Sleep(5 * time.Millisecond)
+	c.mu.Lock()
+	c.writes[record.RequestID]++
+	c.mu.Unlock()
+	return c.InMemoryTracker.Record(ctx, record)
+}
+
+func TestRetryingTracker_ConcurrentReplaysWriteOnce(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryDeadLetterStore()
+	for _, id := range []string{"req-1", "req-2", "req-3"} {
+		store.Add(ctx, DeadLetter{ID: "dl-" + id, Record: UsageRecord{TenantID: "t1", RequestID: id, Timestamp: time.Now()}})
+	}
+	inner := &countingTracker{InMemoryTracker: NewInMemoryTracker(), writes: make(map[string]int)}
+	tracker := NewRetryingTracker(inner, store)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.Replay(ctx); err != nil {
+				t.Errorf("Replay() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id, n := range inner.writes {
+		if n != 1 {
+			t.Errorf("%s written %d times, want 1", id, n)
+		}
+	}
+	if len(inner.writes) != 3 {
+		t.Errorf("replayed %d records, want 3", len(inner.writes))
+	}
+}
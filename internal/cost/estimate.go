@@ -0,0 +1,118 @@
+package cost
+
+import (
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// TokenEstimator approximates the number of tokens in a piece of text. It is
+// used when a provider reports no usage for a response that clearly consumed
+// tokens.
+type TokenEstimator interface {
+	EstimateTokens(model, text string) int
+}
+
+// CharEstimator estimates tokens from character count. The default ratio of
+// four characters per token is a reasonable average for English text on
+// OpenAI and Anthropic tokenizers.
+type CharEstimator struct {
+	CharsPerToken int
+}
+
+// EstimateTokens returns ceil(len(text) / CharsPerToken), or zero for empty text.
+func (e CharEstimator) EstimateTokens(_ string, text string) int {
+	if text == "" {
+		return 0
+	}
+	per := e.CharsPerToken
+	if per <= 0 {
+		per = 4
+	}
+	return (len(text) + per - 1) / per
+}
+
+// DefaultEstimator is the estimator used when none is configured.
+var DefaultEstimator TokenEstimator = CharEstimator{CharsPerToken: 4}
+
+// MissingUsage reports whether resp carries zero prompt tokens for a
+// non-empty prompt or zero completion tokens for non-empty output, i.e.
+// whether billing it as reported would under-charge.
+func MissingUsage(req *domain.ChatRequest, resp *domain.ChatResponse) bool {
+	if resp.Usage.PromptTokens == 0 {
+		for _, m := range req.Messages {
+			if m.Content != "" {
+				return true
+			}
+		}
+	}
+	if resp.Usage.CompletionTokens == 0 {
+		for _, c := range resp.Choices {
+			if c.Message != nil && c.Message.Content != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EstimateUsage returns the usage est would assign to resp, ignoring any
+// usage resp reports.
+func EstimateUsage(est TokenEstimator, req *domain.ChatRequest, resp *domain.ChatResponse) domain.Usage {
+	estimated := *resp
+	estimated.Usage = domain.Usage{}
+	ReconcileUsage(est, req, &estimated)
+	return estimated.Usage
+}
+
+// EstimateError returns the relative error, (estimated - reported) /
+// reported, of estimated prompt and completion tokens against what a provider
+// reported. A count the provider reported as zero has no error.
+func EstimateError(estimated, reported domain.Usage) (prompt, completion float64) {
+	if reported.PromptTokens > 0 {
+		prompt = float64(estimated.PromptTokens-reported.PromptTokens) / float64(reported.PromptTokens)
+	}
+	if reported.CompletionTokens > 0 {
+		completion = float64(estimated.CompletionTokens-reported.CompletionTokens) / float64(reported.CompletionTokens)
+	}
+	return prompt, completion
+}
+
+// ReconcileUsage fills in zero prompt or completion token counts on resp with
+// estimates derived from the request and response content. Provider-reported
+// counts are never lowered. It reports whether any estimate was applied.
+func ReconcileUsage(est TokenEstimator, req *domain.ChatRequest, resp *domain.ChatResponse) bool {
+	if est == nil {
+		est = DefaultEstimator
+	}
+
+	estimated := false
+
+	if resp.Usage.PromptTokens == 0 {
+		prompt := 0
+		for _, m := range req.Messages {
+			prompt += est.EstimateTokens(req.Model, m.Content)
+		}
+		if prompt > 0 {
+			resp.Usage.PromptTokens = prompt
+			estimated = true
+		}
+	}
+
+	if resp.Usage.CompletionTokens == 0 {
+		completion := 0
+		for _, c := range resp.Choices {
+			if c.Message != nil {
+				completion += est.EstimateTokens(req.Model, c.Message.Content)
+			}
+		}
+		if completion > 0 {
+			resp.Usage.CompletionTokens = completion
+			estimated = true
+		}
+	}
+
+	if estimated {
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	}
+
+	return estimated
+}
@@ -0,0 +1,150 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestCharEstimator_EstimateTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		est      CharEstimator
+		text     string
+		expected int
+	}{
+		{"empty", CharEstimator{CharsPerToken: 4}, "", 0},
+		{"exact multiple", CharEstimator{CharsPerToken: 4}, "abcdefgh", 2},
+		{"rounds up", CharEstimator{CharsPerToken: 4}, "abcde", 2},
+		{"zero ratio uses default", CharEstimator{}, "abcdefgh", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.est.EstimateTokens("gpt-4", tt.text); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestReconcileUsage(t *testing.T) {
+	req := &domain.ChatRequest{
+		Model:    "gpt-4",
+		Messages: []domain.Message{{Role: "user", Content: strings.Repeat("a", 40)}},
+	}
+
+	tests := []struct {
+		name          string
+		usage         domain.Usage
+		content       string
+		wantEstimated bool
+		wantUsage     domain.Usage
+	}{
+		{
+			name:          "zero usage with content is estimated",
+			content:       strings.Repeat("b", 20),
+			wantEstimated: true,
+			wantUsage:     domain.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+		{
+			name:          "reported usage is kept",
+			usage:         domain.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19},
+			content:       strings.Repeat("b", 20),
+			wantEstimated: false,
+			wantUsage:     domain.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19},
+		},
+		{
+			name:          "only missing completion is estimated",
+			usage:         domain.Usage{PromptTokens: 12, TotalTokens: 12},
+			content:       strings.Repeat("b", 20),
+			wantEstimated: true,
+			wantUsage:     domain.Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17},
+		},
+		{
+			name:          "empty completion stays zero",
+			usage:         domain.Usage{PromptTokens: 12, TotalTokens: 12},
+			wantEstimated: false,
+			wantUsage:     domain.Usage{PromptTokens: 12, TotalTokens: 12},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &domain.ChatResponse{
+				Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: tt.content}}},
+				Usage:   tt.usage,
+			}
+
+			got := ReconcileUsage(nil, req, resp)
+			if got != tt.wantEstimated {
+				t.Errorf("expected estimated=%v, got %v", tt.wantEstimated, got)
+			}
+			if resp.Usage != tt.wantUsage {
+				t.Errorf("expected usage %+v, got %+v", tt.wantUsage, resp.Usage)
+			}
+		})
+	}
+}
+
+func TestMissingUsage(t *testing.T) {
+	req := &domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "Hello"}}}
+	reply := []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "Hi"}}}
+
+	tests := []struct {
+		name string
+		req  *domain.ChatRequest
+		resp *domain.ChatResponse
+		want bool
+	}{
+		{"usage reported", req, &domain.ChatResponse{Choices: reply, Usage: domain.Usage{PromptTokens: 2, CompletionTokens: 1}}, false},
+		{"no usage", req, &domain.ChatResponse{Choices: reply}, true},
+		{"no completion tokens", req, &domain.ChatResponse{Choices: reply, Usage: domain.Usage{PromptTokens: 2}}, true},
+		{"no prompt tokens", req, &domain.ChatResponse{Choices: reply, Usage: domain.Usage{CompletionTokens: 1}}, true},
+		{"empty reply", &domain.ChatRequest{}, &domain.ChatResponse{Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant"}}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MissingUsage(tt.req, tt.resp); got != tt.want {
+				t.Errorf("MissingUsage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateError(t *testing.T) {
+	req := &domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "abcdefghijklmnop"}}}
+	resp := &domain.ChatResponse{
+		Choices: []domain.Choice{{Message: &domain.Message{Role: "assistant", Content: "abcdefgh"}}},
+		Usage:   domain.Usage{PromptTokens: 8, CompletionTokens: 1},
+	}
+
+	estimated := EstimateUsage(CharEstimator{CharsPerToken: 4}, req, resp)
+	if estimated.PromptTokens != 4 || estimated.CompletionTokens != 2 {
+		t.Fatalf("EstimateUsage() = %+v, want 4 prompt and 2 completion tokens", estimated)
+	}
+	if resp.Usage.PromptTokens != 8 {
+		t.Errorf("EstimateUsage() changed the reported usage to %+v", resp.Usage)
+	}
+
+	tests := []struct {
+		name           string
+		reported       domain.Usage
+		wantPrompt     float64
+		wantCompletion float64
+	}{
+		{"under and over", domain.Usage{PromptTokens: 8, CompletionTokens: 1}, -0.5, 1},
+		{"exact", domain.Usage{PromptTokens: 4, CompletionTokens: 2}, 0, 0},
+		{"nothing reported", domain.Usage{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, completion := EstimateError(estimated, tt.reported)
+			if prompt != tt.wantPrompt || completion != tt.wantCompletion {
+				t.Errorf("EstimateError() = %v, %v; want %v, %v", prompt, completion, tt.wantPrompt, tt.wantCompletion)
+			}
+		})
+	}
+}
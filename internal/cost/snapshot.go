@@ -0,0 +1,332 @@
+package cost
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// DefaultSnapshotInterval is how often a Snapshotter persists tenant totals
+// when no interval is configured.
+const DefaultSnapshotInterval = 5 * time.Minute
+
+// DefaultSnapshotRetention is how long snapshot history is kept when no
+// retention is configured.
+const DefaultSnapshotRetention = 30 * 24 * time.Hour
+
+// TenantSnapshot holds a tenant's cumulative usage as seen by one gateway
+// instance at a point in time.
+type TenantSnapshot struct {
+	TenantID     string    `json:"tenant_id"`
+	Instance     string    `json:"instance"`
+	Requests     int64     `json:"requests"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+	TakenAt      time.Time `json:"taken_at"`
+}
+
+// SnapshotStore persists tenant snapshots.
+type SnapshotStore interface {
+	// SaveSnapshots writes a batch of snapshots taken together.
+	SaveSnapshots(ctx context.Context, snapshots []TenantSnapshot) error
+	// LatestSnapshots returns the most recent snapshot of each tenant taken
+	// by each instance.
+	LatestSnapshots(ctx context.Context) ([]TenantSnapshot, error)
+	// TenantSnapshots returns a tenant's snapshots from every instance taken
+	// since the given time, oldest first.
+	TenantSnapshots(ctx context.Context, tenantID string, since time.Time) ([]TenantSnapshot, error)
+	// PruneSnapshots deletes snapshots taken before the given time, except
+	// the latest of each tenant and instance, which carry the totals, and
+	// returns how many were deleted.
+	PruneSnapshots(ctx context.Context, before time.Time) (int64, error)
+}
+
+// InMemorySnapshotStore is a SnapshotStore local to the process, for tests
+// and development.
+type InMemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots []TenantSnapshot
+}
+
+func NewInMemorySnapshotStore() *InMemorySnapshotStore {
+	return &InMemorySnapshotStore{}
+}
+
+func (s *InMemorySnapshotStore) SaveSnapshots(ctx context.Context, snapshots []TenantSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, snapshots...)
+	return nil
+}
+
+func (s *InMemorySnapshotStore) LatestSnapshots(ctx context.Context) ([]TenantSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := s.latest()
+	result := make([]TenantSnapshot, 0, len(latest))
+	for _, snap := range latest {
+		result = append(result, snap)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TenantID != result[j].TenantID {
+			return result[i].TenantID < result[j].TenantID
+		}
+		return result[i].Instance < result[j].Instance
+	})
+	return result, nil
+}
+
+func (s *InMemorySnapshotStore) PruneSnapshots(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	latest := s.latest()
+	kept := s.snapshots[:0]
+	for _, snap := range s.snapshots {
+		if snap.TakenAt.Before(before) && latest[[2]string{snap.TenantID, snap.Instance}] != snap {
+			continue
+		}
+		kept = append(kept, snap)
+	}
+	pruned := int64(len(s.snapshots) - len(kept))
+	s.snapshots = kept
+	return pruned, nil
+}
+
+// latest returns the most recent snapshot of each tenant and instance.
+// Callers hold mu.
+func (s *InMemorySnapshotStore) latest() map[[2]string]TenantSnapshot {
+	latest := make(map[[2]string]TenantSnapshot)
+	for _, snap := range s.snapshots {
+		key := [2]string{snap.TenantID, snap.Instance}
+		if prev, ok := latest[key]; !ok || !snap.TakenAt.Before(prev.TakenAt) {
+			latest[key] = snap
+		}
+	}
+	return latest
+}
+
+func (s *InMemorySnapshotStore) TenantSnapshots(ctx context.Context, tenantID string, since time.Time) ([]TenantSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []TenantSnapshot
+	for _, snap := range s.snapshots {
+		if snap.TenantID == tenantID && !snap.TakenAt.Before(since) {
+			result = append(result, snap)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].TakenAt.Before(result[j].TakenAt) })
+	return result, nil
+}
+
+// Snapshotter keeps running per-tenant totals of the usage recorded through
+// it and periodically writes the tenants that changed to a SnapshotStore.
+// Totals are kept per instance; a tenant's cluster-wide totals are the sum of
+// every instance's latest snapshot, which is what Seed loads into the
+// aigateway_tenant_lifetime_* counters at startup. Reads go straight to the
+// wrapped tracker.
+type Snapshotter struct {
+	Tracker
+	store     SnapshotStore
+	instance  string
+	interval  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	totals map[string]TenantSnapshot
+	dirty  map[string]bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// SnapshotterOption configures a Snapshotter.
+type SnapshotterOption func(*Snapshotter)
+
+// WithSnapshotInterval sets how often changed totals are written. Zero or a
+// negative interval disables the background writes; Snapshot and Stop still
+// write.
+func WithSnapshotInterval(d time.Duration) SnapshotterOption {
+	return func(s *Snapshotter) {
+		s.interval = d
+	}
+}
+
+// WithSnapshotRetention sets how long snapshot history is kept; older
+// snapshots are pruned after each background write, except the latest of
+// each tenant and instance. Zero or a negative retention keeps everything.
+func WithSnapshotRetention(d time.Duration) SnapshotterOption {
+	return func(s *Snapshotter) {
+		s.retention = d
+	}
+}
+
+// WithSnapshotInstance names the instance whose totals are kept.
+func WithSnapshotInstance(name string) SnapshotterOption {
+	return func(s *Snapshotter) {
+		s.instance = name
+	}
+}
+
+// NewSnapshotter wraps tracker and starts writing snapshots every
+// DefaultSnapshotInterval.
+func NewSnapshotter(tracker Tracker, store SnapshotStore, opts ...SnapshotterOption) *Snapshotter {
+	s := &Snapshotter{
+		Tracker:   tracker,
+		store:     store,
+		interval:  DefaultSnapshotInterval,
+		retention: DefaultSnapshotRetention,
+		now:       time.Now,
+		totals:    make(map[string]TenantSnapshot),
+		dirty:     make(map[string]bool),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.interval > 0 {
+		go s.run()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// Seed adds every instance's latest snapshots to the lifetime counters, so
+// they start from the cluster-wide totals, and the ones taken under this
+// instance's name to its own totals, so a restarted instance that kept its
+// name continues from where it stopped. It is meant to be called once,
+// before traffic is served.
+func (s *Snapshotter) Seed(ctx context.Context) error {
+	snapshots, err := s.store.LatestSnapshots(ctx)
+	if err != nil {
+		return fmt.Errorf("load tenant snapshots: %w", err)
+	}
+
+	cluster := make(map[string]TenantSnapshot)
+	s.mu.Lock()
+	for _, snap := range snapshots {
+		cluster[snap.TenantID] = addTotals(cluster[snap.TenantID], snap)
+		if snap.Instance == s.instance {
+			s.totals[snap.TenantID] = addTotals(s.totals[snap.TenantID], snap)
+		}
+	}
+	s.mu.Unlock()
+
+	for id, totals := range cluster {
+		metrics.AddTenantLifetimeTotals(id, totals.Requests, totals.InputTokens, totals.OutputTokens, totals.CostUSD)
+	}
+	return nil
+}
+
+// Record writes the record to the wrapped tracker and, if that succeeds,
+// adds it to the tenant's totals.
+func (s *Snapshotter) Record(ctx context.Context, record UsageRecord) error {
+	if err := s.Tracker.Record(ctx, record); err != nil {
+		return err
+	}
+
+	usage := TenantSnapshot{
+		Requests:     1,
+		InputTokens:  int64(record.InputTokens),
+		OutputTokens: int64(record.OutputTokens),
+		CostUSD:      record.CostUSD,
+	}
+	s.mu.Lock()
+	s.totals[record.TenantID] = addTotals(s.totals[record.TenantID], usage)
+	s.dirty[record.TenantID] = true
+	s.mu.Unlock()
+
+	metrics.AddTenantLifetimeTotals(record.TenantID, usage.Requests, usage.InputTokens, usage.OutputTokens, usage.CostUSD)
+	return nil
+}
+
+// Totals returns the tenant's current totals.
+func (s *Snapshotter) Totals(tenantID string) TenantSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := s.totals[tenantID]
+	snap.TenantID, snap.Instance = tenantID, s.instance
+	return snap
+}
+
+// Snapshot writes the totals of every tenant that changed since the last
+// successful write. On failure those tenants are written again next time.
+func (s *Snapshotter) Snapshot(ctx context.Context) error {
+	takenAt := s.now()
+
+	s.mu.Lock()
+	snapshots := make([]TenantSnapshot, 0, len(s.dirty))
+	for id := range s.dirty {
+		snap := s.totals[id]
+		snap.TenantID, snap.Instance, snap.TakenAt = id, s.instance, takenAt
+		snapshots = append(snapshots, snap)
+	}
+	clear(s.dirty)
+	s.mu.Unlock()
+
+	if len(snapshots) == 0 {
+		return nil
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].TenantID < snapshots[j].TenantID })
+
+	if err := s.store.SaveSnapshots(ctx, snapshots); err != nil {
+		s.mu.Lock()
+		for _, snap := range snapshots {
+			s.dirty[snap.TenantID] = true
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("save tenant snapshots: %w", err)
+	}
+	return nil
+}
+
+// Stop ends the background writes and writes a final snapshot so totals
+// recorded since the last one survive a graceful shutdown.
+func (s *Snapshotter) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return s.Snapshot(ctx)
+}
+
+func (s *Snapshotter) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(context.Background()); err != nil {
+				slog.Warn("failed to write tenant metrics snapshot", "error", err)
+			}
+			if s.retention > 0 {
+				if _, err := s.store.PruneSnapshots(context.Background(), s.now().Add(-s.retention)); err != nil {
+					slog.Warn("failed to prune tenant metrics snapshots", "error", err)
+				}
+			}
+		}
+	}
+}
+
+func addTotals(a, b TenantSnapshot) TenantSnapshot {
+	a.Requests += b.Requests
+	a.InputTokens += b.InputTokens
+	a.OutputTokens += b.OutputTokens
+	a.CostUSD += b.CostUSD
+	return a
+}
@@ -0,0 +1,151 @@
+package cost
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// failingSnapshotStore rejects writes while failing is set.
+type failingSnapshotStore struct {
+	*InMemorySnapshotStore
+	failing bool
+}
+
+func (s *failingSnapshotStore) SaveSnapshots(ctx context.Context, snapshots []TenantSnapshot) error {
+	if s.failing {
+		return errors.New("database unavailable")
+	}
+	return s.InMemorySnapshotStore.SaveSnapshots(ctx, snapshots)
+}
+
+func TestSnapshotter_WritesChangedTenants(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySnapshotStore()
+	s := NewSnapshotter(NewInMemoryTracker(), store, WithSnapshotInterval(0), WithSnapshotInstance("pod-a"))
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	s.Record(ctx, UsageRecord{TenantID: "snap-a", InputTokens: 10, OutputTokens: 5, CostUSD: 0.5})
+	s.Record(ctx, UsageRecord{TenantID: "snap-a", InputTokens: 20, OutputTokens: 1, CostUSD: 0.25})
+	s.Record(ctx, UsageRecord{TenantID: "snap-b", InputTokens: 1, CostUSD: 1})
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	s.Record(ctx, UsageRecord{TenantID: "snap-a", InputTokens: 5, CostUSD: 0.25})
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	history, _ := store.TenantSnapshots(ctx, "snap-a", time.Time{})
+	if len(history) != 2 {
+		t.Fatalf("snap-a snapshots = %d, want 2", len(history))
+	}
+	want := TenantSnapshot{TenantID: "snap-a", Instance: "pod-a", Requests: 3, InputTokens: 35, OutputTokens: 6, CostUSD: 1, TakenAt: now}
+	if history[1] != want {
+		t.Errorf("latest snap-a snapshot = %+v, want %+v", history[1], want)
+	}
+
+	// snap-b did not change after the first snapshot.
+	if history, _ := store.TenantSnapshots(ctx, "snap-b", time.Time{}); len(history) != 1 {
+		t.Errorf("snap-b snapshots = %d, want 1", len(history))
+	}
+}
+
+func TestSnapshotter_SeedCarriesTotalsAcrossRestart(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySnapshotStore()
+
+	first := NewSnapshotter(NewInMemoryTracker(), store, WithSnapshotInterval(0), WithSnapshotInstance("pod-a"))
+	first.Record(ctx, UsageRecord{TenantID: "snap-seed", InputTokens: 100, OutputTokens: 40, CostUSD: 2})
+	if err := first.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	// Another instance's totals count towards the cluster-wide counters but
+	// not towards this instance's own totals.
+	store.SaveSnapshots(ctx, []TenantSnapshot{{TenantID: "snap-seed", Instance: "pod-b", Requests: 50, TakenAt: time.Now()}})
+
+	requestsBefore := testutil.ToFloat64(metrics.TenantLifetimeRequests.WithLabelValues("snap-seed"))
+
+	second := NewSnapshotter(NewInMemoryTracker(), store, WithSnapshotInterval(0), WithSnapshotInstance("pod-a"))
+	if err := second.Seed(ctx); err != nil {
+		t.Fatalf("Seed() error = %v", err)
+	}
+	second.Record(ctx, UsageRecord{TenantID: "snap-seed", InputTokens: 1, CostUSD: 0.5})
+
+	got := second.Totals("snap-seed")
+	if got.Requests != 2 || got.InputTokens != 101 || got.OutputTokens != 40 || got.CostUSD != 2.5 {
+		t.Errorf("Totals() = %+v, want 2 requests, 101/40 tokens, $2.5", got)
+	}
+	if delta := testutil.ToFloat64(metrics.TenantLifetimeRequests.WithLabelValues("snap-seed")) - requestsBefore; delta != 52 {
+		t.Errorf("lifetime requests counter grew by %v, want 52 (1 + 50 seeded + 1 recorded)", delta)
+	}
+}
+
+func TestSnapshotter_RetriesFailedWrite(t *testing.T) {
+	ctx := context.Background()
+	store := &failingSnapshotStore{InMemorySnapshotStore: NewInMemorySnapshotStore(), failing: true}
+	s := NewSnapshotter(NewInMemoryTracker(), store, WithSnapshotInterval(0))
+
+	s.Record(ctx, UsageRecord{TenantID: "snap-retry", CostUSD: 1})
+	if err := s.Snapshot(ctx); err == nil {
+		t.Fatal("Snapshot() error = nil, want store error")
+	}
+
+	store.failing = false
+	if err := s.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	if history, _ := store.TenantSnapshots(ctx, "snap-retry", time.Time{}); len(history) != 1 || history[0].Requests != 1 {
+		t.Errorf("snapshots after retry = %+v, want one with 1 request", history)
+	}
+}
+
+func TestSnapshotter_FailedRecordNotCounted(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyTracker{InMemoryTracker: NewInMemoryTracker(), failures: 1}
+	s := NewSnapshotter(inner, NewInMemorySnapshotStore(), WithSnapshotInterval(0))
+
+	if err := s.Record(ctx, UsageRecord{TenantID: "snap-fail", CostUSD: 1}); err == nil {
+		t.Fatal("Record() error = nil, want tracker error")
+	}
+	if got := s.Totals("snap-fail"); got.Requests != 0 {
+		t.Errorf("Totals().Requests = %d, want 0", got.Requests)
+	}
+}
+
+func TestInMemorySnapshotStore_PruneKeepsLatest(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemorySnapshotStore()
+	old := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.SaveSnapshots(ctx, []TenantSnapshot{
+		{TenantID: "snap-prune", Instance: "pod-a", Requests: 1, TakenAt: old},
+		{TenantID: "snap-prune", Instance: "pod-a", Requests: 2, TakenAt: old.Add(time.Hour)},
+		{TenantID: "snap-prune", Instance: "pod-a", Requests: 3, TakenAt: old.Add(48 * time.Hour)},
+		// pod-b stopped long ago; its last snapshot still carries its totals.
+		{TenantID: "snap-prune", Instance: "pod-b", Requests: 4, TakenAt: old},
+	})
+
+	pruned, err := store.PruneSnapshots(ctx, old.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneSnapshots() error = %v", err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned = %d, want 2", pruned)
+	}
+
+	latest, _ := store.LatestSnapshots(ctx)
+	var requests int64
+	for _, snap := range latest {
+		requests += snap.Requests
+	}
+	if len(latest) != 2 || requests != 7 {
+		t.Errorf("latest after prune = %+v, want pod-a's 3 and pod-b's 4 requests", latest)
+	}
+}
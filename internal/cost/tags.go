@@ -0,0 +1,54 @@
+package cost
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// TagsHeader carries request tags as comma-separated key=value pairs.
+	TagsHeader = "X-Tags"
+
+	maxTags      = 10
+	maxTagLength = 64
+)
+
+// ParseTags parses an X-Tags header value such as "project=search,team=ml".
+// Keys must be unique and non-empty; an empty header yields nil.
+func ParseTags(header string) (map[string]string, error) {
+	var tags map[string]string
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag %q must be key=value", pair)
+		}
+		if len(key) > maxTagLength || len(value) > maxTagLength {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", key, maxTagLength)
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		if _, dup := tags[key]; dup {
+			return nil, fmt.Errorf("duplicate tag %q", key)
+		}
+		tags[key] = value
+	}
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	return tags, nil
+}
+
+// TagTotals aggregates usage for one value of a tag key.
+type TagTotals struct {
+	Value    string  `json:"value"`
+	Requests int64   `json:"requests"`
+	CostUSD  float64 `json:"cost_usd"`
+}
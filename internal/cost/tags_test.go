@@ -0,0 +1,42 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"single", "project=search", map[string]string{"project": "search"}, false},
+		{"multiple with spaces", " project=search , team = ml ", map[string]string{"project": "search", "team": "ml"}, false},
+		{"empty value", "project=", map[string]string{"project": ""}, false},
+		{"missing equals", "project", nil, true},
+		{"missing key", "=search", nil, true},
+		{"duplicate key", "project=a,project=b", nil, true},
+		{"too long", "project=" + strings.Repeat("x", 65), nil, true},
+		{"too many", "a=1,b=2,c=3,d=4,e=5,f=6,g=7,h=8,i=9,j=10,k=11", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTags(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTags(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseTags(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("tag %q = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package cost
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WordEstimator is a length heuristic tuned to byte-pair tokenizers such as
+// OpenAI's cl100k_base; it does no byte-pair merging and ships no
+// vocabulary. Text is split the way those tokenizers pre-split it (words with
+// their leading space, digit groups of up to three, punctuation runs,
+// whitespace), and each piece is charged by length and script: common-length
+// English words are one token, longer words are split, and CJK text costs
+// about a token per character. It is far closer than a character ratio for
+// code, numbers and non-English text, but it is still an estimate.
+type WordEstimator struct{}
+
+// maxWholeWord is the longest ASCII word counted as a single token.
+const maxWholeWord = 8
+
+func (WordEstimator) EstimateTokens(_ string, text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\'' && i > 0 && isContraction(text[i+size:]):
+			// 's, 't, 're, 've, 'm, 'll, 'd are tokens of their own.
+			end := i + size
+			for end < len(text) && isASCIILetter(text[end]) {
+				end++
+			}
+			tokens++
+			i = end
+		case unicode.IsLetter(r):
+			n, end := wordTokens(text, i)
+			tokens += n
+			i = end
+		case unicode.IsDigit(r):
+			end := i
+			digits := 0
+			for end < len(text) {
+				d, ds := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsDigit(d) {
+					break
+				}
+				digits++
+				end += ds
+			}
+			tokens += (digits + 2) / 3
+			i = end
+		case r == '\n' || r == '\r':
+			for i < len(text) && (text[i] == '\n' || text[i] == '\r') {
+				i++
+			}
+			tokens++
+		case unicode.IsSpace(r):
+			end := i
+			for end < len(text) && (text[end] == ' ' || text[end] == '\t') {
+				end++
+			}
+			// A single space joins the piece after it and spaces before a
+			// newline join the newline; other runs, such as indentation or
+			// trailing space, cost one token.
+			switch {
+			case end == len(text):
+				tokens++
+			case text[end] == '\n' || text[end] == '\r':
+			case end-i > 1:
+				tokens++
+			}
+			i = end
+		default:
+			// Punctuation and symbols: runs like "()" or "==" merge into
+			// pairs.
+			end := i
+			runes := 0
+			for end < len(text) {
+				p, ps := utf8.DecodeRuneInString(text[end:])
+				if unicode.IsLetter(p) || unicode.IsDigit(p) || unicode.IsSpace(p) {
+					break
+				}
+				runes++
+				end += ps
+			}
+			tokens += (runes + 1) / 2
+			i = end
+		}
+	}
+	return tokens
+}
+
+// wordTokens charges the letter run starting at i and returns its end.
+func wordTokens(text string, i int) (int, int) {
+	ascii := 0
+	wide := 0
+	other := 0
+	end := i
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isWideScript(r):
+			wide++
+		default:
+			other++
+		}
+		end += size
+	}
+
+	tokens := wide + (other+1)/2
+	if ascii > 0 {
+		if ascii <= maxWholeWord {
+			tokens++
+		} else {
+			tokens += (ascii + 3) / 4
+		}
+	}
+	return tokens, end
+}
+
+// isWideScript reports scripts written without spaces, where BPE vocabularies
+// trained mostly on English spend roughly a token per character.
+func isWideScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isContraction(rest string) bool {
+	lower := strings.ToLower(rest)
+	for _, suffix := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+		if strings.HasPrefix(lower, suffix) && (len(lower) == len(suffix) || !isASCIILetter(lower[len(suffix)])) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelEstimator picks an estimator by model name prefix, so models with a
+// known tokenizer family get a closer estimate and everything else falls back
+// to a cheap character ratio.
+type ModelEstimator struct {
+	// Families maps a model name prefix to its estimator. The longest
+	// matching prefix wins.
+	Families map[string]TokenEstimator
+
+	// Fallback estimates models no family matches. Defaults to
+	// DefaultEstimator.
+	Fallback TokenEstimator
+}
+
+// NewModelEstimator returns an estimator that uses WordEstimator for OpenAI
+// and Anthropic models and the character ratio for the rest.
+func NewModelEstimator() *ModelEstimator {
+	words := WordEstimator{}
+	return &ModelEstimator{
+		Families: map[string]TokenEstimator{
+			"gpt-":             words,
+			"chatgpt-":         words,
+			"o1":               words,
+			"o3":               words,
+			"claude-":          words,
+			"anthropic.claude": words,
+		},
+		Fallback: DefaultEstimator,
+	}
+}
+
+func (e *ModelEstimator) EstimateTokens(model, text string) int {
+	best := ""
+	var est TokenEstimator
+	for prefix, candidate := range e.Families {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best, est = prefix, candidate
+		}
+	}
+	if est == nil {
+		est = e.Fallback
+	}
+	if est == nil {
+		est = DefaultEstimator
+	}
+	return est.EstimateTokens(model, text)
+}
@@ -0,0 +1,76 @@
+package cost
+
+import (
+	"strings"
+	"testing"
+)
+
+// Expected counts are from OpenAI's cl100k_base tokenizer.
+func TestWordEstimator_KnownCounts(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"I'm sure they'll agree", 6},
+		{"1234567890", 4},
+		{"a\n\nb", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := (WordEstimator{}).EstimateTokens("gpt-4", tt.text); got != tt.want {
+				t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordEstimator_CloserThanCharRatio(t *testing.T) {
+	// cl100k_base counts for text where a character ratio is badly off.
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"digits", strings.Repeat("9", 30), 10},
+		{"chinese", strings.Repeat("你好世界", 10), 40},
+		{"short words", strings.Repeat("to be or not ", 20), 80},
+	}
+
+	chars := CharEstimator{CharsPerToken: 4}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words := (WordEstimator{}).EstimateTokens("gpt-4", tt.text)
+			char := chars.EstimateTokens("gpt-4", tt.text)
+			if absDiff(words, tt.want) >= absDiff(char, tt.want) {
+				t.Errorf("word estimate %d should be closer to %d than char estimate %d", words, tt.want, char)
+			}
+		})
+	}
+}
+
+func TestModelEstimator_PicksFamily(t *testing.T) {
+	est := NewModelEstimator()
+	text := "The quick brown fox jumps over the lazy dog."
+
+	if got := est.EstimateTokens("gpt-4o-mini", text); got != 10 {
+		t.Errorf("gpt-4o-mini estimate = %d, want word estimate 10", got)
+	}
+	if got := est.EstimateTokens("claude-3-5-haiku-20241022", text); got != 10 {
+		t.Errorf("claude estimate = %d, want word estimate 10", got)
+	}
+	if got := est.EstimateTokens("llama3.2", text); got != DefaultEstimator.EstimateTokens("llama3.2", text) {
+		t.Errorf("unknown model estimate = %d, want fallback", got)
+	}
+}
+
+func absDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -1,15 +1,45 @@
 package domain
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 var (
 	ErrTenantNotFound     = errors.New("tenant not found")
+	ErrTenantExists       = errors.New("tenant already exists")
+	ErrTenantLimitReached = errors.New("tenant limit reached")
 	ErrInvalidAPIKey      = errors.New("invalid API key")
 	ErrRateLimitExceeded  = errors.New("rate limit exceeded")
 	ErrProviderNotFound   = errors.New("provider not found")
 	ErrProviderError      = errors.New("provider error")
 	ErrInvalidRequest     = errors.New("invalid request")
 	ErrModelNotAllowed    = errors.New("model not allowed for tenant")
+	ErrUnknownModel       = errors.New("unknown model")
+	ErrProviderNotAllowed = errors.New("provider not allowed for tenant")
 	ErrBudgetExceeded     = errors.New("budget exceeded")
 	ErrCircuitBreakerOpen = errors.New("circuit breaker open")
+	// ErrRouteUnavailable is returned when a header routing rule matches
+	// but its provider cannot take the request.
+	ErrRouteUnavailable = errors.New("header-routed provider unavailable")
 )
+
+// ProviderStatusError is returned when a provider's API answers with a
+// non-success HTTP status. It matches ErrProviderError with errors.Is.
+type ProviderStatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the provider asked callers to wait, from its
+	// Retry-After header; zero if it sent none.
+	RetryAfter time.Duration
+}
+
+func (e *ProviderStatusError) Error() string {
+	return fmt.Sprintf("%s error: status=%d body=%s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *ProviderStatusError) Unwrap() error {
+	return ErrProviderError
+}
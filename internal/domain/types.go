@@ -1,20 +1,129 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type Tenant struct {
-	ID                string    `json:"id"`
-	Name              string    `json:"name"`
-	APIKey            string    `json:"api_key,omitempty"`
-	APIKeyHash        string    `json:"-"`
-	BudgetUSD         float64   `json:"budget_usd"`
-	RateLimitRPM      int       `json:"rate_limit_rpm"`
-	AllowedModels     []string  `json:"allowed_models,omitempty"`
-	DefaultProvider   string    `json:"default_provider,omitempty"`
-	FallbackProviders []string  `json:"fallback_providers,omitempty"`
-	Enabled           bool      `json:"enabled"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                string                `json:"id"`
+	Name              string                `json:"name"`
+	APIKey            string                `json:"api_key,omitempty"`
+	APIKeyHash        string                `json:"-"`
+	BudgetUSD         float64               `json:"budget_usd"`
+	BudgetPeriod      BudgetPeriod          `json:"budget_period,omitempty"`
+	RateLimitRPM      int                   `json:"rate_limit_rpm"`
+	AllowedModels     []string              `json:"allowed_models,omitempty"`
+	AllowedProviders  []string              `json:"allowed_providers,omitempty"`
+	Scopes            []string              `json:"scopes,omitempty"`
+	DefaultProvider   string                `json:"default_provider,omitempty"`
+	FallbackProviders []string              `json:"fallback_providers,omitempty"`
+	ProviderKeys      map[string]string     `json:"-"` // stored only, not yet used for requests
+	TransformRules    []TransformRule       `json:"transform_rules,omitempty"`
+	PricingOverrides  map[string]ModelPrice `json:"pricing_overrides,omitempty"`
+	SamplingDefaults  *SamplingDefaults     `json:"sampling_defaults,omitempty"`
+	WebhookURL        string                `json:"webhook_url,omitempty"`
+	WebhookSecret     string                `json:"-"`
+	DefaultModel      string                `json:"default_model,omitempty"`
+	MaxStreamSeconds  int                   `json:"max_stream_seconds,omitempty"`
+	Enabled           bool                  `json:"enabled"`
+	CreatedAt         time.Time             `json:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+}
+
+// ProviderAllowed reports whether the tenant may be routed to the provider.
+// An empty AllowedProviders allows every provider.
+func (t *Tenant) ProviderAllowed(providerID string) bool {
+	if len(t.AllowedProviders) == 0 {
+		return true
+	}
+	for _, id := range t.AllowedProviders {
+		if id == providerID {
+			return true
+		}
+	}
+	return false
+}
+
+// API key scopes limit which endpoints a tenant's key may call.
+const (
+	ScopeChatWrite  = "chat:write"
+	ScopeUsageRead  = "usage:read"
+	ScopeModelsRead = "models:read"
+)
+
+// ValidScope reports whether s is a scope the gateway enforces.
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeChatWrite, ScopeUsageRead, ScopeModelsRead:
+		return true
+	}
+	return false
+}
+
+// HasScope reports whether the tenant's key may use the scope. Empty Scopes
+// grants every scope, so keys created before scopes existed keep full access.
+func (t *Tenant) HasScope(scope string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelPrice is a negotiated price per 1K tokens for one model. Overrides
+// replace the gateway's list price for that tenant only.
+type ModelPrice struct {
+	InputPer1K  float64 `json:"input_per_1k"`
+	OutputPer1K float64 `json:"output_per_1k"`
+}
+
+// SamplingDefaults are sampling parameters filled in on a tenant's requests
+// that leave them unset. Values the client sends always win.
+type SamplingDefaults struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+// BudgetPeriod is the window over which a tenant's budget is measured.
+// The zero value is treated as BudgetPeriodMonthly.
+type BudgetPeriod string
+
+const (
+	BudgetPeriodDaily   BudgetPeriod = "daily"
+	BudgetPeriodWeekly  BudgetPeriod = "weekly"
+	BudgetPeriodMonthly BudgetPeriod = "monthly"
+)
+
+// Valid reports whether p is empty or one of the known periods.
+func (p BudgetPeriod) Valid() bool {
+	switch p {
+	case "", BudgetPeriodDaily, BudgetPeriodWeekly, BudgetPeriodMonthly:
+		return true
+	default:
+		return false
+	}
+}
+
+// TransformRule is a declarative rewrite applied to a tenant's traffic.
+// See the transform package for supported types and fields.
+type TransformRule struct {
+	Type  string   `json:"type"`
+	Stage string   `json:"stage,omitempty"`
+	From  string   `json:"from,omitempty"`
+	To    string   `json:"to,omitempty"`
+	Field string   `json:"field,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+
+	Pattern     string `json:"pattern,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
 }
 
 type ChatRequest struct {
@@ -25,11 +134,97 @@ type ChatRequest struct {
 	Stream      bool      `json:"stream,omitempty"`
 	TopP        *float64  `json:"top_p,omitempty"`
 	Stop        []string  `json:"stop,omitempty"`
+	Logprobs    bool      `json:"logprobs,omitempty"`
+	TopLogprobs *int      `json:"top_logprobs,omitempty"`
+
+	// Store and Metadata are OpenAI dashboard fields. They are passed through
+	// to OpenAI untouched and do not affect the generated output.
+	Store    bool              `json:"store,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// ParallelToolCalls is OpenAI's switch for letting the model call several
+	// tools in one turn; nil leaves the provider's default, which allows it.
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty"`
+
+	// Tools lists the functions the model may call, in OpenAI's format.
+	Tools []Tool `json:"tools,omitempty"`
+
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions mirrors OpenAI's stream_options. With IncludeUsage set, the
+// stream ends with a chunk carrying usage and no choices; the gateway
+// synthesizes it for providers that do not send one.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// Clone returns a deep copy of r. Providers receive requests by value, but
+// slices, maps and pointers are still shared, so each fallback attempt gets
+// its own copy and cannot see changes an earlier provider made.
+func (r ChatRequest) Clone() ChatRequest {
+	c := r
+	c.Messages = append([]Message(nil), r.Messages...)
+	c.Stop = append([]string(nil), r.Stop...)
+	c.Tools = append([]Tool(nil), r.Tools...)
+	c.Temperature = clonePtr(r.Temperature)
+	c.MaxTokens = clonePtr(r.MaxTokens)
+	c.TopP = clonePtr(r.TopP)
+	c.TopLogprobs = clonePtr(r.TopLogprobs)
+	c.StreamOptions = clonePtr(r.StreamOptions)
+	c.ParallelToolCalls = clonePtr(r.ParallelToolCalls)
+	if r.Metadata != nil {
+		c.Metadata = make(map[string]string, len(r.Metadata))
+		for k, v := range r.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// SplitSystem separates r's system messages from the conversation, for
+// providers that take a single top-level system prompt. Every system message,
+// including one sent mid-conversation, is joined into the prompt in order;
+// empty ones are dropped.
+func (r ChatRequest) SplitSystem() (string, []Message) {
+	var system []string
+	messages := make([]Message, 0, len(r.Messages))
+	for _, m := range r.Messages {
+		if m.Role != "system" {
+			messages = append(messages, m)
+			continue
+		}
+		if m.Content != "" {
+			system = append(system, m.Content)
+		}
+	}
+	return strings.Join(system, "\n\n"), messages
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Tool is a function the model may call.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
 
 type ChatResponse struct {
@@ -43,15 +238,51 @@ type ChatResponse struct {
 }
 
 type Choice struct {
-	Index        int      `json:"index"`
-	Message      *Message `json:"message,omitempty"`
-	Delta        *Delta   `json:"delta,omitempty"`
-	FinishReason string   `json:"finish_reason,omitempty"`
+	Index        int       `json:"index"`
+	Message      *Message  `json:"message,omitempty"`
+	Delta        *Delta    `json:"delta,omitempty"`
+	Logprobs     *Logprobs `json:"logprobs,omitempty"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+}
+
+// Logprobs holds per-token log probabilities for a choice. Only returned by
+// providers that support them, and only when the request sets Logprobs.
+type Logprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
 }
 
 type Delta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a fragment of a streamed tool call in OpenAI's format. The
+// first fragment for an Index carries ID, Type and the function name; later
+// fragments carry only pieces of Arguments, which clients concatenate.
+type ToolCall struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 }
 
 type Usage struct {
@@ -61,12 +292,19 @@ type Usage struct {
 }
 
 type Gateway struct {
-	Provider  string  `json:"provider"`
+	Provider  string  `json:"provider,omitempty"`
 	LatencyMs int64   `json:"latency_ms"`
-	CostUSD   float64 `json:"cost_usd"`
-	CacheHit  bool    `json:"cache_hit"`
-	RequestID string  `json:"request_id"`
+	CostUSD   float64 `json:"cost_usd,omitempty"`
+	CacheHit  bool    `json:"cache_hit,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
 	TraceID   string  `json:"trace_id,omitempty"`
+
+	// Attempts is how many providers were called, Fallback reports whether
+	// the answer came from a provider other than the primary, and
+	// RetriedProviders lists the providers that failed before it, in order.
+	Attempts         int      `json:"attempts,omitempty"`
+	Fallback         bool     `json:"fallback,omitempty"`
+	RetriedProviders []string `json:"retried_providers,omitempty"`
 }
 
 type StreamChunk struct {
@@ -75,16 +313,76 @@ type StreamChunk struct {
 	Created int64    `json:"created"`
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
 }
 
 type Model struct {
-	ID       string `json:"id"`
-	Object   string `json:"object"`
-	OwnedBy  string `json:"owned_by"`
-	Provider string `json:"provider,omitempty"`
+	ID           string             `json:"id"`
+	Object       string             `json:"object"`
+	OwnedBy      string             `json:"owned_by"`
+	Provider     string             `json:"provider,omitempty"`
+	Capabilities *ModelCapabilities `json:"capabilities,omitempty"`
+}
+
+// ModelCapabilities describes what a model supports so clients can adapt.
+// A nil Capabilities means the provider doesn't know.
+type ModelCapabilities struct {
+	Streaming  bool `json:"streaming,omitempty"`
+	Tools      bool `json:"tools,omitempty"`
+	Vision     bool `json:"vision,omitempty"`
+	MaxContext int  `json:"max_context,omitempty"`
 }
 
 type ModelsResponse struct {
 	Object string  `json:"object"`
 	Data   []Model `json:"data"`
+	// Unavailable maps providers left out of Data to why: "error" or
+	// "timeout".
+	Unavailable map[string]string `json:"unavailable_providers,omitempty"`
+}
+
+// ModerationRequest mirrors OpenAI's moderation request. Input is a string,
+// an array of strings, or an array of {"type": "text"|"image_url", ...}
+// parts, and is passed to the provider as sent.
+type ModerationRequest struct {
+	Input any    `json:"input"`
+	Model string `json:"model,omitempty"`
+}
+
+// Texts returns the text in Input, for token estimation. Image parts are
+// skipped.
+func (r ModerationRequest) Texts() []string {
+	switch input := r.Input.(type) {
+	case string:
+		return []string{input}
+	case []any:
+		var texts []string
+		for _, item := range input {
+			switch v := item.(type) {
+			case string:
+				texts = append(texts, v)
+			case map[string]any:
+				if text, ok := v["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return texts
+	}
+	return nil
+}
+
+type ModerationResponse struct {
+	ID      string             `json:"id"`
+	Model   string             `json:"model"`
+	Results []ModerationResult `json:"results"`
+}
+
+// ModerationResult is the verdict for one input. Categories are kept as
+// maps so categories a provider adds later pass through unchanged.
+type ModerationResult struct {
+	Flagged                   bool                `json:"flagged"`
+	Categories                map[string]bool     `json:"categories"`
+	CategoryScores            map[string]float64  `json:"category_scores"`
+	CategoryAppliedInputTypes map[string][]string `json:"category_applied_input_types,omitempty"`
 }
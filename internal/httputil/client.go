@@ -17,6 +17,7 @@ type ClientConfig struct {
 	IdleConnTimeout       time.Duration // Keep-alive connection timeout
 	MaxIdleConns          int           // Max idle connections across all hosts
 	MaxIdleConnsPerHost   int           // Max idle connections per host
+	MaxConnsPerHost       int           // Max concurrent connections per host; 0 = unlimited, excess requests queue
 }
 
 // DefaultConfig returns production-ready timeout settings.
@@ -44,6 +45,7 @@ func NewClient(cfg ClientConfig) *http.Client {
 		IdleConnTimeout:       cfg.IdleConnTimeout,
 		MaxIdleConns:          cfg.MaxIdleConns,
 		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
 		ForceAttemptHTTP2:     true,
 	}
 
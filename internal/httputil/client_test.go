@@ -1,6 +1,7 @@
 package httputil
 
 import (
+	"net/http"
 	"testing"
 	"time"
 )
@@ -113,3 +114,20 @@ func TestClientConfig_ZeroValues(t *testing.T) {
 		t.Errorf("Timeout = %v, want 0", client.Timeout)
 	}
 }
+
+func TestNewClient_MaxConnsPerHost(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxConnsPerHost = 8
+
+	transport, ok := NewClient(cfg).Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected *http.Transport")
+	}
+	if transport.MaxConnsPerHost != 8 {
+		t.Errorf("MaxConnsPerHost = %d, want 8", transport.MaxConnsPerHost)
+	}
+
+	if unlimited := NewClient(DefaultConfig()).Transport.(*http.Transport); unlimited.MaxConnsPerHost != 0 {
+		t.Errorf("default MaxConnsPerHost = %d, want 0 (unlimited)", unlimited.MaxConnsPerHost)
+	}
+}
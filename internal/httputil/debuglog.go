@@ -0,0 +1,120 @@
+package httputil
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// DebugBodyLimit caps how much of each provider request and response body
+// debug logging records.
+const DebugBodyLimit = 4096
+
+type debugLoggingKey struct{}
+
+// WithDebugLogging returns a context whose provider requests are logged,
+// bodies included, at debug level by clients from DebugClient.
+func WithDebugLogging(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugLoggingKey{}, true)
+}
+
+func debugLogging(ctx context.Context) bool {
+	on, _ := ctx.Value(debugLoggingKey{}).(bool)
+	return on
+}
+
+// secretPatterns match credentials and personal data that must not reach
+// the logs: API keys and bearer tokens in the common provider formats, and
+// email addresses.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(sk|gw|pk|rk)-[A-Za-z0-9_\-]{8,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`),
+	regexp.MustCompile(`(?i)"(api_?key|password|secret|token)"\s*:\s*"[^"]*"`),
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+}
+
+// RedactSecrets replaces credentials and email addresses in s.
+func RedactSecrets(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// DebugClient returns a copy of c that logs requests made with a
+// WithDebugLogging context and behaves exactly like c otherwise.
+func DebugClient(c *http.Client) *http.Client {
+	debug := *c
+	base := c.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	debug.Transport = &debugTransport{base: base}
+	return &debug
+}
+
+// debugTransport logs requests made with a WithDebugLogging context. Headers
+// are never logged, since they carry the provider keys.
+type debugTransport struct {
+	base http.RoundTripper
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !debugLogging(req.Context()) {
+		return t.base.RoundTrip(req)
+	}
+
+	logger := slog.Default().With("method", req.Method, "url", req.URL.Redacted())
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(io.LimitReader(rc, DebugBodyLimit+1))
+			rc.Close()
+		}
+	}
+	logger.DebugContext(req.Context(), "provider request", "body", debugBody(body))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		logger.DebugContext(req.Context(), "provider request failed", "error", err)
+		return nil, err
+	}
+	resp.Body = &debugBodyLogger{ReadCloser: resp.Body, ctx: req.Context(), logger: logger.With("status", resp.StatusCode)}
+	return resp, nil
+}
+
+// debugBodyLogger records the start of a response body as it is read and
+// logs it on Close, so streamed responses are logged without buffering them.
+type debugBodyLogger struct {
+	io.ReadCloser
+	ctx    context.Context
+	logger *slog.Logger
+	buf    []byte
+	once   sync.Once
+}
+
+func (b *debugBodyLogger) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := DebugBodyLimit + 1 - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+func (b *debugBodyLogger) Close() error {
+	b.once.Do(func() {
+		b.logger.DebugContext(b.ctx, "provider response", "body", debugBody(b.buf))
+	})
+	return b.ReadCloser.Close()
+}
+
+func debugBody(body []byte) string {
+	s := string(body)
+	if len(body) > DebugBodyLimit {
+		s = string(body[:DebugBodyLimit]) + "...(truncated)"
+	}
+	return RedactSecrets(s)
+}
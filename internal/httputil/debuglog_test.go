@@ -0,0 +1,58 @@
+package httputil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"reply":"contact jane@example.com","padding":"` + strings.Repeat("x", DebugBodyLimit) + `"}`))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(prev)
+
+	client := DebugClient(NewClient(ClientConfig{}))
+	send := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, strings.NewReader(`{"api_key":"hunter2","prompt":"use sk-abcdef123456"}`))
+		req.Header.Set("Authorization", "Bearer sk-live-secret-key")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+
+	send(context.Background())
+	if logs.Len() != 0 {
+		t.Fatalf("logged without WithDebugLogging: %s", logs.String())
+	}
+
+	send(WithDebugLogging(context.Background()))
+	out := logs.String()
+	for _, want := range []string{"provider request", "provider response", "status=200", "...(truncated)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs missing %q: %s", want, out)
+		}
+	}
+	for _, secret := range []string{"hunter2", "sk-abcdef123456", "sk-live-secret-key", "jane@example.com"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logs leak %q: %s", secret, out)
+		}
+	}
+	if strings.Count(out, "x") > DebugBodyLimit+100 {
+		t.Errorf("response body not truncated: %d bytes logged", len(out))
+	}
+}
@@ -0,0 +1,61 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+)
+
+type forwardedHeadersKey struct{}
+
+// neverForward lists headers that carry gateway or provider credentials and
+// must not be copied to an upstream request even if allow-listed.
+var neverForward = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"X-Api-Key":           true,
+	"Cookie":              true,
+}
+
+// FilterHeaders returns the subset of src named in allow. Credential headers
+// are always dropped. It returns nil when nothing matches.
+func FilterHeaders(src http.Header, allow []string) http.Header {
+	var out http.Header
+	for _, name := range allow {
+		key := http.CanonicalHeaderKey(name)
+		if neverForward[key] {
+			continue
+		}
+		values := src.Values(key)
+		if len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(http.Header)
+		}
+		out[key] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// WithForwardedHeaders returns a context carrying headers that providers
+// should copy onto their outbound requests.
+func WithForwardedHeaders(ctx context.Context, h http.Header) context.Context {
+	if len(h) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, forwardedHeadersKey{}, h)
+}
+
+// ApplyForwardedHeaders copies headers stored in the request's context onto
+// req. Call it before setting provider headers so those always take precedence.
+func ApplyForwardedHeaders(req *http.Request) {
+	h, _ := req.Context().Value(forwardedHeadersKey{}).(http.Header)
+	for key, values := range h {
+		if neverForward[key] {
+			continue
+		}
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+}
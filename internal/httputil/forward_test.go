@@ -0,0 +1,56 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestFilterHeaders(t *testing.T) {
+	src := http.Header{}
+	src.Set("X-Session-ID", "sess-1")
+	src.Set("X-Other", "nope")
+	src.Set("Authorization", "Bearer sk-tenant")
+
+	got := FilterHeaders(src, []string{"x-session-id", "Authorization", "X-Missing"})
+
+	if got.Get("X-Session-ID") != "sess-1" {
+		t.Errorf("expected X-Session-ID to be kept, got %q", got.Get("X-Session-ID"))
+	}
+	if got.Get("X-Other") != "" {
+		t.Error("expected headers outside the allow-list to be dropped")
+	}
+	if got.Get("Authorization") != "" {
+		t.Error("expected Authorization to never be forwarded")
+	}
+	if len(got) != 1 {
+		t.Errorf("expected 1 header, got %v", got)
+	}
+}
+
+func TestApplyForwardedHeaders(t *testing.T) {
+	fwd := http.Header{}
+	fwd.Set("X-Session-ID", "sess-1")
+	fwd.Set("Authorization", "Bearer sk-tenant")
+
+	ctx := WithForwardedHeaders(context.Background(), fwd)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://example.com", nil)
+	ApplyForwardedHeaders(req)
+	req.Header.Set("Authorization", "Bearer sk-provider")
+
+	if req.Header.Get("X-Session-ID") != "sess-1" {
+		t.Errorf("expected X-Session-ID to be applied, got %q", req.Header.Get("X-Session-ID"))
+	}
+	if got := req.Header.Values("Authorization"); len(got) != 1 || got[0] != "Bearer sk-provider" {
+		t.Errorf("expected only the provider Authorization, got %v", got)
+	}
+}
+
+func TestApplyForwardedHeaders_NoneInContext(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	ApplyForwardedHeaders(req)
+
+	if len(req.Header) != 0 {
+		t.Errorf("expected no headers, got %v", req.Header)
+	}
+}
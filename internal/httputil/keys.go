@@ -0,0 +1,59 @@
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrNoAPIKeys is returned by DoWithKeys when called without any keys.
+var ErrNoAPIKeys = errors.New("no API keys configured")
+
+// SplitKeys parses a comma-separated list of API keys, dropping blanks.
+// A single key without commas yields a one-element slice.
+func SplitKeys(s string) []string {
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// DoWithKeys sends a request built for each key in turn, moving on to the next
+// key when the upstream answers 401 Unauthorized. This lets a key be rotated
+// without downtime by configuring the old and new keys together.
+//
+// newRequest is called once per attempt so the body can be re-read. The
+// returned index identifies the key that produced the response. If every key
+// is rejected, the last 401 response is returned for the caller to report.
+func DoWithKeys(client *http.Client, keys []string, newRequest func(key string) (*http.Request, error)) (*http.Response, int, error) {
+	if len(keys) == 0 {
+		return nil, -1, ErrNoAPIKeys
+	}
+
+	for i, key := range keys {
+		req, err := newRequest(key)
+		if err != nil {
+			return nil, i, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, i, err
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || i == len(keys)-1 {
+			return resp, i, nil
+		}
+
+		// Drain so the connection can be reused for the next attempt.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Unreachable: the loop always returns on the last key.
+	return nil, -1, ErrNoAPIKeys
+}
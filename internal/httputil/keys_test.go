@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"sk-one", []string{"sk-one"}},
+		{"sk-one, sk-two", []string{"sk-one", "sk-two"}},
+		{"sk-one,,", []string{"sk-one"}},
+	}
+
+	for _, tt := range tests {
+		got := SplitKeys(tt.in)
+		if len(got) != len(tt.want) {
+			t.Errorf("SplitKeys(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("SplitKeys(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestDoWithKeys_FailsOverOn401(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Authorization")
+		seen = append(seen, key)
+		if key != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, idx, err := DoWithKeys(server.Client(), []string{"old", "new"}, func(key string) (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+		return req, nil
+	})
+	if err != nil {
+		t.Fatalf("DoWithKeys() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if idx != 1 {
+		t.Errorf("key index = %d, want 1", idx)
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected 2 attempts, got %d", len(seen))
+	}
+}
+
+func TestDoWithKeys_ReturnsLast401(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	resp, idx, err := DoWithKeys(server.Client(), []string{"a", "b"}, func(key string) (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, server.URL, http.NoBody)
+	})
+	if err != nil {
+		t.Fatalf("DoWithKeys() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized || idx != 1 {
+		t.Errorf("got status %d idx %d, want 401 idx 1", resp.StatusCode, idx)
+	}
+}
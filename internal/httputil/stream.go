@@ -0,0 +1,81 @@
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStreamIdleTimeout is how long a streaming response may go without
+// sending data before it is aborted.
+const DefaultStreamIdleTimeout = 60 * time.Second
+
+// ErrStreamIdle is returned when reading a streaming response that sent
+// nothing within the idle timeout.
+var ErrStreamIdle = errors.New("stream idle timeout")
+
+// StreamClient returns a copy of c for streaming requests. A stream can
+// legitimately run for minutes, so the copy drops c's overall Timeout and
+// instead aborts a response body that goes idle for longer than idle. The
+// copy shares c's transport, and with it the connection pool and per-host
+// limits. Zero or a negative idle disables idle detection.
+func StreamClient(c *http.Client, idle time.Duration) *http.Client {
+	stream := *c
+	stream.Timeout = 0
+	if idle > 0 {
+		base := c.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		stream.Transport = &idleTimeoutTransport{base: base, idle: idle}
+	}
+	return &stream
+}
+
+type idleTimeoutTransport struct {
+	base http.RoundTripper
+	idle time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newIdleTimeoutBody(resp.Body, t.idle)
+	return resp, nil
+}
+
+// idleTimeoutBody closes the underlying body when no Read returns within the
+// idle timeout, which unblocks a reader stuck waiting on a silent upstream.
+type idleTimeoutBody struct {
+	body     io.ReadCloser
+	idle     time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, idle time.Duration) *idleTimeoutBody {
+	b := &idleTimeoutBody{body: body, idle: idle}
+	b.timer = time.AfterFunc(idle, func() {
+		b.timedOut.Store(true)
+		b.body.Close()
+	})
+	return b
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if b.timedOut.Load() {
+		return n, ErrStreamIdle
+	}
+	b.timer.Reset(b.idle)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
@@ -0,0 +1,85 @@
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		switch r.URL.Path {
+		case "/steady":
+			// Runs well past the client timeout but never goes idle.
+			for i := 0; i < 6; i++ {
+				w.Write([]byte("data: tick\n\n"))
+				flusher.Flush()
+				time.Sleep(30 * time.Millisecond)
+			}
+		case "/stalled":
+			w.Write([]byte("data: tick\n\n"))
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(ClientConfig{Timeout: 100 * time.Millisecond})
+	stream := StreamClient(client, 100*time.Millisecond)
+
+	if stream.Timeout != 0 {
+		t.Errorf("stream client Timeout = %v, want 0", stream.Timeout)
+	}
+	if client.Timeout != 100*time.Millisecond {
+		t.Errorf("original client Timeout changed to %v", client.Timeout)
+	}
+
+	t.Run("long stream is not cut", func(t *testing.T) {
+		resp, err := stream.Get(server.URL + "/steady")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read error = %v", err)
+		}
+		if len(body) != 6*len("data: tick\n\n") {
+			t.Errorf("body length = %d, want all six events", len(body))
+		}
+	})
+
+	t.Run("idle stream is aborted", func(t *testing.T) {
+		resp, err := stream.Get(server.URL + "/stalled")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+		start := time.Now()
+		_, err = io.ReadAll(resp.Body)
+		if !errors.Is(err, ErrStreamIdle) {
+			t.Errorf("read error = %v, want ErrStreamIdle", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("idle stream took %v to abort", elapsed)
+		}
+	})
+
+	t.Run("same stream times out on the regular client", func(t *testing.T) {
+		resp, err := client.Get(server.URL + "/steady")
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err == nil {
+			t.Error("expected the client timeout to cut the stream")
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// constLabelGatherer adds the same labels to every metric it gathers, so
+// series from several clusters or regions can be told apart once scraped
+// into one place. Metrics keep their own value for a label they already
+// carry.
+type constLabelGatherer struct {
+	prometheus.Gatherer
+	labels []*dto.LabelPair
+}
+
+// WithConstLabels wraps g so every gathered metric also carries labels.
+func WithConstLabels(g prometheus.Gatherer, labels map[string]string) prometheus.Gatherer {
+	if len(labels) == 0 {
+		return g
+	}
+	pairs := make([]*dto.LabelPair, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return &constLabelGatherer{Gatherer: g, labels: pairs}
+}
+
+func (g *constLabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, m := range family.Metric {
+			m.Label = g.addLabels(m.Label)
+		}
+	}
+	return families, err
+}
+
+func (g *constLabelGatherer) addLabels(own []*dto.LabelPair) []*dto.LabelPair {
+	labels := own
+	for _, pair := range g.labels {
+		if !hasLabel(own, pair.GetName()) {
+			labels = append(labels, pair)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	return labels
+}
+
+func hasLabel(labels []*dto.LabelPair, name string) bool {
+	for _, l := range labels {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler serves the default registry's metrics with labels added to every
+// series, like promhttp.Handler.
+func Handler(labels map[string]string) http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(WithConstLabels(prometheus.DefaultGatherer, labels), promhttp.HandlerOpts{}))
+}
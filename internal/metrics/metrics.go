@@ -1,6 +1,10 @@
 package metrics
 
 import (
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -47,6 +51,14 @@ var (
 		[]string{"tenant_id"},
 	)
 
+	CacheSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_cache_skipped_total",
+			Help: "Responses not cached, by reason",
+		},
+		[]string{"reason"},
+	)
+
 	CacheMisses = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "aigateway_cache_misses_total",
@@ -55,6 +67,14 @@ var (
 		[]string{"tenant_id"},
 	)
 
+	CacheBackendErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_cache_backend_errors_total",
+			Help: "Failed cache backend operations, including ones later retried",
+		},
+		[]string{"operation"},
+	)
+
 	CircuitBreakerState = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "aigateway_circuit_breaker_state",
@@ -71,6 +91,30 @@ var (
 		[]string{"provider", "error_type"},
 	)
 
+	ProviderKeyUsed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_provider_key_used_total",
+			Help: "Upstream requests by provider and the index of the API key that was accepted",
+		},
+		[]string{"provider", "key_index"},
+	)
+
+	RequestsArchived = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_requests_archived_total",
+			Help: "Requests written to the request archive",
+		},
+		[]string{"tenant_id", "reason"},
+	)
+
+	ProviderThrottled = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_provider_throttled_total",
+			Help: "Requests rejected by the outbound per-provider rate limit",
+		},
+		[]string{"provider"},
+	)
+
 	RateLimitHits = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "aigateway_rate_limit_hits_total",
@@ -79,6 +123,24 @@ var (
 		[]string{"tenant_id"},
 	)
 
+	RequestBytes = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aigateway_request_bytes",
+			Help:    "Chat completion request body size in bytes",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 9),
+		},
+		[]string{"model"},
+	)
+
+	ResponseBytes = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aigateway_response_bytes",
+			Help:    "Chat completion response size in bytes, summed over the whole stream for streaming responses",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 9),
+		},
+		[]string{"model"},
+	)
+
 	ActiveStreams = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "aigateway_active_streams",
@@ -110,6 +172,94 @@ var (
 		},
 		[]string{"tenant_id"},
 	)
+
+	BudgetAlertLevel = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aigateway_budget_alert_level",
+			Help: "Current budget alert level (0=none, 1=warning, 2=critical, 3=exceeded)",
+		},
+		[]string{"tenant_id"},
+	)
+
+	TenantPeriodCost = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aigateway_tenant_period_cost_usd",
+			Help: "Tenant spend in USD in its current budget period",
+		},
+		[]string{"tenant_id"},
+	)
+
+	BudgetAlertsSuppressed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_budget_alerts_suppressed_total",
+			Help: "Total number of budget alerts suppressed by deduplication",
+		},
+		[]string{"level"},
+	)
+
+	ProviderCostCapRemaining = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aigateway_provider_cost_cap_remaining_usd",
+			Help: "USD left today under each provider's daily cost cap",
+		},
+		[]string{"provider"},
+	)
+
+	UsageDeadLettered = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "aigateway_usage_dead_lettered_total",
+			Help: "Usage records that failed to persist and were dead-lettered",
+		},
+	)
+
+	MissingUsage = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_missing_usage_total",
+			Help: "Responses with content for which the provider reported no token usage",
+		},
+		[]string{"provider", "model"},
+	)
+
+	TokenEstimateError = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aigateway_token_estimate_error",
+			Help:    "Relative error of divergent token estimates against provider-reported usage, (estimate - reported) / reported",
+			Buckets: []float64{-0.75, -0.5, -0.25, -0.1, 0, 0.1, 0.25, 0.5, 1, 2},
+		},
+		[]string{"provider", "model", "kind"},
+	)
+
+	SlowRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_slow_requests_total",
+			Help: "Chat completions that took longer than the slow-request threshold",
+		},
+		[]string{"provider", "model"},
+	)
+
+	TenantLifetimeRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_tenant_lifetime_requests_total",
+			Help: "Requests recorded per tenant, carried across restarts by metrics snapshots",
+		},
+		[]string{"tenant_id"},
+	)
+
+	TenantLifetimeTokens = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_tenant_lifetime_tokens_total",
+			Help: "Tokens (input/output) recorded per tenant, carried across restarts by metrics snapshots",
+		},
+		[]string{"tenant_id", "type"},
+	)
+
+	TenantLifetimeCost = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aigateway_tenant_lifetime_cost_usd_total",
+			Help: "Cost in USD recorded per tenant, carried across restarts by metrics snapshots",
+		},
+		[]string{"tenant_id"},
+	)
 )
 
 func RecordRequest(tenantID, provider, model, status string, durationSec float64) {
@@ -126,16 +276,72 @@ func RecordCost(tenantID, provider, model string, costUSD float64) {
 	CostTotal.WithLabelValues(tenantID, provider, model).Add(costUSD)
 }
 
+// RecordPayloadSizes observes the request and response sizes of one call.
+func RecordPayloadSizes(model string, requestBytes, responseBytes int64) {
+	RequestBytes.WithLabelValues(model).Observe(float64(requestBytes))
+	ResponseBytes.WithLabelValues(model).Observe(float64(responseBytes))
+}
+
 func RecordCacheHit(tenantID string) {
 	CacheHits.WithLabelValues(tenantID).Inc()
 }
 
+func RecordCacheSkipped(reason string) {
+	CacheSkipped.WithLabelValues(reason).Inc()
+}
+
 func RecordCacheMiss(tenantID string) {
 	CacheMisses.WithLabelValues(tenantID).Inc()
 }
 
+func RecordCacheBackendError(operation string) {
+	CacheBackendErrors.WithLabelValues(operation).Inc()
+}
+
+// providerErrorCounts mirrors ProviderErrors per provider so the admin API
+// can report error totals without scraping Prometheus.
+var (
+	providerErrorMu     sync.Mutex
+	providerErrorCounts = make(map[string]int64)
+	providerErrorsSince = time.Now()
+)
+
 func RecordProviderError(provider, errorType string) {
 	ProviderErrors.WithLabelValues(provider, errorType).Inc()
+
+	providerErrorMu.Lock()
+	providerErrorCounts[provider]++
+	providerErrorMu.Unlock()
+}
+
+// ProviderErrorCountsSince returns when the provider error totals started
+// counting, which is process start.
+func ProviderErrorCountsSince() time.Time {
+	return providerErrorsSince
+}
+
+// ProviderErrorCounts returns provider error totals since process start.
+func ProviderErrorCounts() map[string]int64 {
+	providerErrorMu.Lock()
+	defer providerErrorMu.Unlock()
+
+	counts := make(map[string]int64, len(providerErrorCounts))
+	for provider, n := range providerErrorCounts {
+		counts[provider] = n
+	}
+	return counts
+}
+
+func RecordProviderKeyUsed(provider string, keyIndex int) {
+	ProviderKeyUsed.WithLabelValues(provider, strconv.Itoa(keyIndex)).Inc()
+}
+
+func RecordArchived(tenantID, reason string) {
+	RequestsArchived.WithLabelValues(tenantID, reason).Inc()
+}
+
+func RecordProviderThrottled(provider string) {
+	ProviderThrottled.WithLabelValues(provider).Inc()
 }
 
 func RecordRateLimitHit(tenantID string) {
@@ -150,6 +356,49 @@ func SetBudgetUsage(tenantID string, ratio float64) {
 	BudgetUsageRatio.WithLabelValues(tenantID).Set(ratio)
 }
 
+func SetBudgetAlertLevel(tenantID string, level int) {
+	BudgetAlertLevel.WithLabelValues(tenantID).Set(float64(level))
+}
+
+func SetTenantPeriodCost(tenantID string, usd float64) {
+	TenantPeriodCost.WithLabelValues(tenantID).Set(usd)
+}
+
+func RecordBudgetAlertSuppressed(level string) {
+	BudgetAlertsSuppressed.WithLabelValues(level).Inc()
+}
+
+func SetProviderCostCapRemaining(provider string, usd float64) {
+	ProviderCostCapRemaining.WithLabelValues(provider).Set(usd)
+}
+
+func RecordUsageDeadLettered() {
+	UsageDeadLettered.Inc()
+}
+
+func RecordMissingUsage(provider, model string) {
+	MissingUsage.WithLabelValues(provider, model).Inc()
+}
+
+func RecordSlowRequest(provider, model string) {
+	SlowRequests.WithLabelValues(provider, model).Inc()
+}
+
+// AddTenantLifetimeTotals adds to a tenant's lifetime counters, either for
+// one recorded request or, at startup, to seed them from a snapshot.
+func AddTenantLifetimeTotals(tenantID string, requests, inputTokens, outputTokens int64, costUSD float64) {
+	TenantLifetimeRequests.WithLabelValues(tenantID).Add(float64(requests))
+	TenantLifetimeTokens.WithLabelValues(tenantID, "input").Add(float64(inputTokens))
+	TenantLifetimeTokens.WithLabelValues(tenantID, "output").Add(float64(outputTokens))
+	TenantLifetimeCost.WithLabelValues(tenantID).Add(costUSD)
+}
+
+// RecordTokenEstimateError records the relative error of a prompt or
+// completion ("kind") token estimate.
+func RecordTokenEstimateError(provider, model, kind string, relErr float64) {
+	TokenEstimateError.WithLabelValues(provider, model, kind).Observe(relErr)
+}
+
 // Instance-aware metrics for horizontal scaling
 var currentPodName string
 
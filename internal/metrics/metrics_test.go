@@ -1,8 +1,12 @@
 package metrics
 
 import (
+	"io"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
@@ -89,6 +93,18 @@ func TestRecordProviderError(t *testing.T) {
 	}
 }
 
+func TestRecordProviderKeyUsed(t *testing.T) {
+	ProviderKeyUsed.Reset()
+
+	RecordProviderKeyUsed("openai", 0)
+	RecordProviderKeyUsed("openai", 1)
+	RecordProviderKeyUsed("openai", 1)
+
+	if got := testutil.ToFloat64(ProviderKeyUsed.WithLabelValues("openai", "1")); got != 2 {
+		t.Errorf("key_index=1 count = %v, want 2", got)
+	}
+}
+
 func TestRecordRateLimitHit(t *testing.T) {
 	RateLimitHits.Reset()
 
@@ -170,3 +186,105 @@ func TestMultipleTenants(t *testing.T) {
 		t.Errorf("tenant2 success = %v, want 1", tenant2Success)
 	}
 }
+
+func TestSetBudgetAlertLevel(t *testing.T) {
+	BudgetAlertLevel.Reset()
+
+	SetBudgetAlertLevel("tenant1", 2)
+
+	level := testutil.ToFloat64(BudgetAlertLevel.WithLabelValues("tenant1"))
+	if level != 2 {
+		t.Errorf("BudgetAlertLevel = %v, want 2", level)
+	}
+}
+
+func TestRecordBudgetAlertSuppressed(t *testing.T) {
+	BudgetAlertsSuppressed.Reset()
+
+	RecordBudgetAlertSuppressed("warning")
+	RecordBudgetAlertSuppressed("warning")
+
+	count := testutil.ToFloat64(BudgetAlertsSuppressed.WithLabelValues("warning"))
+	if count != 2 {
+		t.Errorf("BudgetAlertsSuppressed = %v, want 2", count)
+	}
+}
+
+func TestProviderErrorCounts(t *testing.T) {
+	before := ProviderErrorCounts()["counts-test"]
+
+	RecordProviderError("counts-test", "timeout")
+	RecordProviderError("counts-test", "server_error")
+
+	counts := ProviderErrorCounts()
+	if got := counts["counts-test"] - before; got != 2 {
+		t.Errorf("counts-test errors = %d, want 2", got)
+	}
+
+	counts["counts-test"] = 100
+	if ProviderErrorCounts()["counts-test"] == 100 {
+		t.Error("ProviderErrorCounts should return a copy")
+	}
+}
+
+func TestRecordPayloadSizes(t *testing.T) {
+	RequestBytes.Reset()
+	ResponseBytes.Reset()
+
+	RecordPayloadSizes("gpt-4", 512, 2048)
+	RecordPayloadSizes("gpt-4o", 100, 300)
+
+	if n := testutil.CollectAndCount(RequestBytes); n != 2 {
+		t.Errorf("request_bytes series = %d, want 2", n)
+	}
+	if n := testutil.CollectAndCount(ResponseBytes); n != 2 {
+		t.Errorf("response_bytes series = %d, want 2", n)
+	}
+}
+
+func TestWithConstLabels(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "const_label_test_total", Help: "test"}, []string{"tenant_id", "region"})
+	reg.MustRegister(counter)
+	counter.WithLabelValues("t1", "own-region").Inc()
+
+	families, err := WithConstLabels(reg, map[string]string{"cluster": "eu1", "region": "eu-west-1"}).Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) != 1 || len(families[0].Metric) != 1 {
+		t.Fatalf("families = %v, want one metric", families)
+	}
+
+	var got []string
+	for _, l := range families[0].Metric[0].Label {
+		got = append(got, l.GetName()+"="+l.GetValue())
+	}
+	// The metric's own region wins over the constant one.
+	want := "cluster=eu1,region=own-region,tenant_id=t1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("labels = %v, want %s", got, want)
+	}
+
+	// Reading a collector directly is unaffected.
+	if v := testutil.ToFloat64(counter.WithLabelValues("t1", "own-region")); v != 1 {
+		t.Errorf("counter = %v, want 1", v)
+	}
+}
+
+func TestHandler_ConstLabels(t *testing.T) {
+	RequestsTotal.Reset()
+	RecordRequest("labels-tenant", "openai", "gpt-4", "success", 0.5)
+
+	rec := httptest.NewRecorder()
+	Handler(map[string]string{"cluster": "eu1"}).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	want := `aigateway_requests_total{cluster="eu1",model="gpt-4",provider="openai",status="success",tenant_id="labels-tenant"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("metrics output missing %s", want)
+	}
+	if v := testutil.ToFloat64(RequestsTotal.WithLabelValues("labels-tenant", "openai", "gpt-4", "success")); v != 1 {
+		t.Errorf("RequestsTotal = %v, want 1", v)
+	}
+}
@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+const defaultHealthCheckTimeout = 3 * time.Second
+
+// topicAttributesAPI is the subset of the SNS client used by the health check.
+type topicAttributesAPI interface {
+	GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error)
+}
+
+// SNSHealthChecker verifies that the notification topic exists and is
+// reachable with the gateway's credentials.
+type SNSHealthChecker struct {
+	client   topicAttributesAPI
+	topicArn string
+	timeout  time.Duration
+}
+
+// NewSNSHealthChecker creates a health checker for the given topic.
+func NewSNSHealthChecker(cfg aws.Config, topicArn string) *SNSHealthChecker {
+	return newSNSHealthChecker(sns.NewFromConfig(cfg), topicArn)
+}
+
+func newSNSHealthChecker(client topicAttributesAPI, topicArn string) *SNSHealthChecker {
+	return &SNSHealthChecker{
+		client:   client,
+		topicArn: topicArn,
+		timeout:  defaultHealthCheckTimeout,
+	}
+}
+
+func (c *SNSHealthChecker) Name() string {
+	return "sns"
+}
+
+func (c *SNSHealthChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	_, err := c.client.GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(c.topicArn),
+	})
+	if err != nil {
+		return fmt.Errorf("get topic attributes: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type mockTopicAttributes struct {
+	err      error
+	delay    time.Duration
+	topicArn string
+}
+
+func (m *mockTopicAttributes) GetTopicAttributes(ctx context.Context, params *sns.GetTopicAttributesInput, optFns ...func(*sns.Options)) (*sns.GetTopicAttributesOutput, error) {
+	m.topicArn = aws.ToString(params.TopicArn)
+
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &sns.GetTopicAttributesOutput{}, nil
+}
+
+func TestSNSHealthChecker_Check(t *testing.T) {
+	const arn = "arn:aws:sns:us-east-1:123456789012:budget-alerts"
+
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{"topic reachable", nil, false},
+		{"topic not found", errors.New("NotFound: Topic does not exist"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockTopicAttributes{err: tt.err}
+			checker := newSNSHealthChecker(client, arn)
+
+			err := checker.Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if client.topicArn != arn {
+				t.Errorf("TopicArn = %q, want %q", client.topicArn, arn)
+			}
+		})
+	}
+}
+
+func TestSNSHealthChecker_Timeout(t *testing.T) {
+	checker := newSNSHealthChecker(&mockTopicAttributes{delay: time.Second}, "arn")
+	checker.timeout = 10 * time.Millisecond
+
+	err := checker.Check(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() error = %v, want deadline exceeded", err)
+	}
+}
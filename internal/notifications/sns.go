@@ -25,6 +25,7 @@ const (
 )
 
 type Notification struct {
+	ID       string                 `json:"id,omitempty"`
 	Type     NotificationType       `json:"type"`
 	TenantID string                 `json:"tenant_id,omitempty"`
 	Message  string                 `json:"message"`
@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookSignatureHeader = "X-Gateway-Signature"
+	WebhookEventHeader     = "X-Gateway-Event"
+	WebhookDeliveryHeader  = "X-Gateway-Delivery"
+)
+
+// WebhookNotifier POSTs notifications as JSON to URLs chosen per call, such
+// as the one a tenant configured for its budget alerts. Bodies are signed
+// with HMAC-SHA256 under a secret chosen per call, so each receiver holds a
+// secret of its own and cannot sign deliveries meant for another.
+type WebhookNotifier struct {
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// WebhookOption configures a WebhookNotifier.
+type WebhookOption func(*WebhookNotifier)
+
+// WithWebhookClient sets the HTTP client used for deliveries.
+func WithWebhookClient(c *http.Client) WebhookOption {
+	return func(n *WebhookNotifier) {
+		n.client = c
+	}
+}
+
+// WithWebhookRetries retries a failed delivery up to retries more times,
+// waiting backoff before the first retry and doubling it for each one after.
+func WithWebhookRetries(retries int, backoff time.Duration) WebhookOption {
+	return func(n *WebhookNotifier) {
+		n.retries = retries
+		n.backoff = backoff
+	}
+}
+
+// NewWebhookNotifier creates a notifier. By default it retries a failed
+// delivery three times, starting at one second.
+func NewWebhookNotifier(opts ...WebhookOption) *WebhookNotifier {
+	n := &WebhookNotifier{
+		client:  &http.Client{Timeout: 10 * time.Second},
+		retries: 3,
+		backoff: time.Second,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// SignWebhook returns the signature header value for body, "sha256=" followed
+// by the hex HMAC-SHA256 of body under secret.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post delivers notification to url, signed with secret. Network errors, 429s and 5xx responses
+// are retried; other responses are final. Every attempt carries the same
+// notification ID in X-Gateway-Delivery, so receivers can drop repeats.
+func (n *WebhookNotifier) Post(ctx context.Context, url, secret string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("marshal notification: %w", err)
+	}
+	signature := SignWebhook([]byte(secret), body)
+
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.deliver(ctx, url, body, signature, notification)
+		if err == nil {
+			slog.Info("webhook delivered",
+				"type", notification.Type,
+				"tenant_id", notification.TenantID,
+				"attempts", attempt+1,
+			)
+			return nil
+		}
+		if !retry || attempt >= n.retries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// deliver makes one attempt and reports whether a failure is worth retrying.
+func (n *WebhookNotifier) deliver(ctx context.Context, url string, body []byte, signature string, notification Notification) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, signature)
+	req.Header.Set(WebhookEventHeader, string(notification.Type))
+	if notification.ID != "" {
+		req.Header.Set(WebhookDeliveryHeader, notification.ID)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("post webhook: unexpected status %d", resp.StatusCode)
+}
@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier_Post(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"delivered", []int{http.StatusOK}, 1, false},
+		{"server error retried", []int{http.StatusBadGateway, http.StatusOK}, 2, false},
+		{"rate limit retried", []int{http.StatusTooManyRequests, http.StatusAccepted}, 2, false},
+		{"client error not retried", []int{http.StatusBadRequest}, 1, true},
+		{"retries exhausted", []int{500, 500, 500, 500}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+			defer srv.Close()
+
+			n := NewWebhookNotifier(WithWebhookRetries(2, time.Millisecond))
+			err := n.Post(context.Background(), srv.URL, "secret", Notification{ID: "n-1", Type: NotificationBudgetExceeded})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 	"github.com/felipepmaragno/ai-gateway/internal/httputil"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
 )
 
 const (
@@ -21,23 +23,76 @@ const (
 )
 
 type Provider struct {
-	apiKey  string
+	apiKeys []string
 	baseURL string
 	client  *http.Client
+
+	// streamClient shares client's transport but has no overall timeout,
+	// relying on idle detection so long streams are not cut off.
+	streamClient *http.Client
+	streamIdle   time.Duration
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithHTTPClient replaces the default HTTP client, e.g. to bound connections
+// per host.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) {
+		p.client = c
+	}
+}
+
+// WithStreamIdleTimeout sets how long a streaming response may send nothing
+// before it is aborted. Streams have no overall timeout.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.streamIdle = d
+	}
+}
+
+// New creates an Anthropic provider. apiKey may be a comma-separated list;
+// later keys are only tried when the upstream rejects the earlier ones with 401.
+func New(apiKey string, opts ...Option) *Provider {
+	p := &Provider{
+		apiKeys:    httputil.SplitKeys(apiKey),
+		baseURL:    defaultBaseURL,
+		client:     httputil.DefaultClient(),
+		streamIdle: httputil.DefaultStreamIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.streamClient = httputil.StreamClient(p.client, p.streamIdle)
+	return p
 }
 
-func New(apiKey string) *Provider {
-	return &Provider{
-		apiKey:  apiKey,
-		baseURL: defaultBaseURL,
-		client:  httputil.DefaultClient(),
+// do sends a request with key failover and records which key was accepted.
+func (p *Provider) do(client *http.Client, newRequest func(key string) (*http.Request, error)) (*http.Response, error) {
+	resp, keyIndex, err := httputil.DoWithKeys(client, p.apiKeys, newRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		metrics.RecordProviderKeyUsed(p.ID(), keyIndex)
+		if keyIndex > 0 {
+			slog.Warn("anthropic rejected primary API key, using fallback key", "key_index", keyIndex)
+		}
 	}
+	return resp, nil
 }
 
 func (p *Provider) ID() string {
 	return "anthropic"
 }
 
+// BaseURL returns the API endpoint requests are sent to.
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
 func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
 	anthropicReq := toAnthropicRequest(req)
 
@@ -46,16 +101,17 @@ func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", p.apiKey)
-	httpReq.Header.Set("anthropic-version", anthropicVersion)
-
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.do(p.client, func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httputil.ApplyForwardedHeaders(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("x-api-key", key)
+		httpReq.Header.Set("anthropic-version", anthropicVersion)
+		return httpReq, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("do request: %w", err)
 	}
@@ -63,7 +119,7 @@ func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("anthropic error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+		return nil, &domain.ProviderStatusError{Provider: "anthropic", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 	}
 
 	var anthropicResp anthropicResponse
@@ -91,18 +147,18 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 			return
 		}
 
-		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
-		if err != nil {
-			errs <- fmt.Errorf("create request: %w", err)
-			return
-		}
-
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("x-api-key", p.apiKey)
-		httpReq.Header.Set("anthropic-version", anthropicVersion)
-		httpReq.Header.Set("Accept", "text/event-stream")
-
-		resp, err := p.client.Do(httpReq)
+		resp, err := p.do(p.streamClient, func(key string) (*http.Request, error) {
+			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("create request: %w", err)
+			}
+			httputil.ApplyForwardedHeaders(httpReq)
+			httpReq.Header.Set("Content-Type", "application/json")
+			httpReq.Header.Set("x-api-key", key)
+			httpReq.Header.Set("anthropic-version", anthropicVersion)
+			httpReq.Header.Set("Accept", "text/event-stream")
+			return httpReq, nil
+		})
 		if err != nil {
 			errs <- fmt.Errorf("do request: %w", err)
 			return
@@ -111,10 +167,11 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
-			errs <- fmt.Errorf("anthropic error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+			errs <- &domain.ProviderStatusError{Provider: "anthropic", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 			return
 		}
 
+		translator := newStreamTranslator(req.Model)
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -132,22 +189,7 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 				continue
 			}
 
-			if event.Type == "content_block_delta" && event.Delta != nil {
-				chunk := domain.StreamChunk{
-					ID:      event.Index,
-					Object:  "chat.completion.chunk",
-					Created: time.Now().Unix(),
-					Model:   req.Model,
-					Choices: []domain.Choice{
-						{
-							Index: 0,
-							Delta: &domain.Delta{
-								Content: event.Delta.Text,
-							},
-						},
-					},
-				}
-
+			if chunk, ok := translator.translate(event); ok {
 				select {
 				case chunks <- chunk:
 				case <-ctx.Done():
@@ -169,12 +211,17 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 }
 
 func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
+	// All Claude 3 models share a 200k context window and support tools and images.
+	claude3 := &domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true, MaxContext: 200000}
+	// Claude 3.5 Haiku is text-only.
+	claude35Haiku := &domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 200000}
+
 	models := []domain.Model{
-		{ID: "claude-3-5-sonnet-20241022", Object: "model", OwnedBy: "anthropic", Provider: "anthropic"},
-		{ID: "claude-3-5-haiku-20241022", Object: "model", OwnedBy: "anthropic", Provider: "anthropic"},
-		{ID: "claude-3-opus-20240229", Object: "model", OwnedBy: "anthropic", Provider: "anthropic"},
-		{ID: "claude-3-sonnet-20240229", Object: "model", OwnedBy: "anthropic", Provider: "anthropic"},
-		{ID: "claude-3-haiku-20240307", Object: "model", OwnedBy: "anthropic", Provider: "anthropic"},
+		{ID: "claude-3-5-sonnet-20241022", Object: "model", OwnedBy: "anthropic", Provider: "anthropic", Capabilities: claude3},
+		{ID: "claude-3-5-haiku-20241022", Object: "model", OwnedBy: "anthropic", Provider: "anthropic", Capabilities: claude35Haiku},
+		{ID: "claude-3-opus-20240229", Object: "model", OwnedBy: "anthropic", Provider: "anthropic", Capabilities: claude3},
+		{ID: "claude-3-sonnet-20240229", Object: "model", OwnedBy: "anthropic", Provider: "anthropic", Capabilities: claude3},
+		{ID: "claude-3-haiku-20240307", Object: "model", OwnedBy: "anthropic", Provider: "anthropic", Capabilities: claude3},
 	}
 	return models, nil
 }
@@ -189,6 +236,23 @@ type anthropicRequest struct {
 	MaxTokens int                `json:"max_tokens"`
 	Stream    bool               `json:"stream,omitempty"`
 	System    string             `json:"system,omitempty"`
+
+	Tools      []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// anthropicToolChoice is only sent to turn off parallel tool use, Anthropic's
+// equivalent of OpenAI's parallel_tool_calls=false. Anthropic rejects a
+// tool_choice without tools, so it is left out when the request has none.
+type anthropicToolChoice struct {
+	Type                   string `json:"type"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
 }
 
 type anthropicMessage struct {
@@ -208,8 +272,11 @@ type anthropicResponse struct {
 }
 
 type contentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 type anthropicUsage struct {
@@ -218,25 +285,113 @@ type anthropicUsage struct {
 }
 
 type streamEvent struct {
-	Type  string       `json:"type"`
-	Index string       `json:"index,omitempty"`
-	Delta *streamDelta `json:"delta,omitempty"`
+	Type         string              `json:"type"`
+	Index        int                 `json:"index"`
+	Message      *streamMessage      `json:"message,omitempty"`
+	ContentBlock *streamContentBlock `json:"content_block,omitempty"`
+	Delta        *streamDelta        `json:"delta,omitempty"`
 }
 
-type streamDelta struct {
+type streamMessage struct {
+	ID string `json:"id"`
+}
+
+type streamContentBlock struct {
 	Type string `json:"type"`
-	Text string `json:"text"`
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
 }
 
-func toAnthropicRequest(req domain.ChatRequest) anthropicRequest {
-	var systemPrompt string
-	messages := make([]anthropicMessage, 0, len(req.Messages))
+type streamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"`
+	StopReason  string `json:"stop_reason"`
+}
+
+// streamTranslator turns Anthropic stream events into OpenAI-style chunks.
+// Anthropic numbers content blocks across text and tool use, while OpenAI
+// numbers tool calls on their own, so block indexes are remapped.
+type streamTranslator struct {
+	id    string
+	model string
+	// created is shared by every chunk, since Anthropic sends no timestamp.
+	created   int64
+	toolIndex map[int]int
+}
+
+func newStreamTranslator(model string) *streamTranslator {
+	return &streamTranslator{model: model, created: time.Now().Unix(), toolIndex: make(map[int]int)}
+}
+
+// translate returns the chunk for event, or false if the event carries
+// nothing for the client.
+func (t *streamTranslator) translate(event streamEvent) (domain.StreamChunk, bool) {
+	var choice domain.Choice
+
+	switch event.Type {
+	case "message_start":
+		if event.Message != nil {
+			t.id = event.Message.ID
+		}
+		return domain.StreamChunk{}, false
 
-	for _, m := range req.Messages {
-		if m.Role == "system" {
-			systemPrompt = m.Content
-			continue
+	case "content_block_start":
+		if event.ContentBlock == nil || event.ContentBlock.Type != "tool_use" {
+			return domain.StreamChunk{}, false
 		}
+		idx := len(t.toolIndex)
+		t.toolIndex[event.Index] = idx
+		choice.Delta = &domain.Delta{ToolCalls: []domain.ToolCall{{
+			Index:    idx,
+			ID:       event.ContentBlock.ID,
+			Type:     "function",
+			Function: domain.FunctionCall{Name: event.ContentBlock.Name},
+		}}}
+
+	case "content_block_delta":
+		if event.Delta == nil {
+			return domain.StreamChunk{}, false
+		}
+		switch event.Delta.Type {
+		case "input_json_delta":
+			idx, ok := t.toolIndex[event.Index]
+			if !ok {
+				return domain.StreamChunk{}, false
+			}
+			choice.Delta = &domain.Delta{ToolCalls: []domain.ToolCall{{
+				Index:    idx,
+				Function: domain.FunctionCall{Arguments: event.Delta.PartialJSON},
+			}}}
+		default:
+			choice.Delta = &domain.Delta{Content: event.Delta.Text}
+		}
+
+	case "message_delta":
+		if event.Delta == nil || event.Delta.StopReason == "" {
+			return domain.StreamChunk{}, false
+		}
+		choice.Delta = &domain.Delta{}
+		choice.FinishReason = mapStopReason(event.Delta.StopReason)
+
+	default:
+		return domain.StreamChunk{}, false
+	}
+
+	return domain.StreamChunk{
+		ID:      t.id,
+		Object:  "chat.completion.chunk",
+		Created: t.created,
+		Model:   t.model,
+		Choices: []domain.Choice{choice},
+	}, true
+}
+
+func toAnthropicRequest(req domain.ChatRequest) anthropicRequest {
+	system, conversation := req.SplitSystem()
+	messages := make([]anthropicMessage, 0, len(conversation))
+
+	for _, m := range conversation {
 		messages = append(messages, anthropicMessage{
 			Role:    m.Role,
 			Content: m.Content,
@@ -248,19 +403,48 @@ func toAnthropicRequest(req domain.ChatRequest) anthropicRequest {
 		maxTokens = *req.MaxTokens
 	}
 
+	tools := make([]anthropicTool, 0, len(req.Tools))
+	for _, t := range req.Tools {
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		tools = append(tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	var toolChoice *anthropicToolChoice
+	if len(tools) > 0 && req.ParallelToolCalls != nil && !*req.ParallelToolCalls {
+		toolChoice = &anthropicToolChoice{Type: "auto", DisableParallelToolUse: true}
+	}
+
 	return anthropicRequest{
-		Model:     req.Model,
-		Messages:  messages,
-		MaxTokens: maxTokens,
-		System:    systemPrompt,
+		Model:      req.Model,
+		Messages:   messages,
+		MaxTokens:  maxTokens,
+		System:     system,
+		Tools:      tools,
+		ToolChoice: toolChoice,
 	}
 }
 
 func toOpenAIResponse(resp anthropicResponse, model string) *domain.ChatResponse {
 	var content string
+	var toolCalls []domain.ToolCall
 	for _, block := range resp.Content {
-		if block.Type == "text" {
+		switch block.Type {
+		case "text":
 			content += block.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, domain.ToolCall{
+				Index:    len(toolCalls),
+				ID:       block.ID,
+				Type:     "function",
+				Function: domain.FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
 		}
 	}
 
@@ -273,8 +457,9 @@ func toOpenAIResponse(resp anthropicResponse, model string) *domain.ChatResponse
 			{
 				Index: 0,
 				Message: &domain.Message{
-					Role:    "assistant",
-					Content: content,
+					Role:      "assistant",
+					Content:   content,
+					ToolCalls: toolCalls,
 				},
 				FinishReason: mapStopReason(resp.StopReason),
 			},
@@ -295,6 +480,8 @@ func mapStopReason(reason string) string {
 		return "length"
 	case "stop_sequence":
 		return "stop"
+	case "tool_use":
+		return "tool_calls"
 	default:
 		return reason
 	}
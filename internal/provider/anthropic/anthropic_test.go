@@ -0,0 +1,291 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestModels_Capabilities(t *testing.T) {
+	models, err := New("test-key").Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+
+	for _, m := range models {
+		if m.Capabilities == nil {
+			t.Errorf("%s: expected capabilities", m.ID)
+			continue
+		}
+		if !m.Capabilities.Streaming || !m.Capabilities.Tools {
+			t.Errorf("%s: expected streaming and tools support", m.ID)
+		}
+		if m.Capabilities.MaxContext != 200000 {
+			t.Errorf("%s: MaxContext = %d, want 200000", m.ID, m.Capabilities.MaxContext)
+		}
+	}
+}
+
+func TestChatCompletion_RotatesKeyOn401(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-api-key")
+		seen = append(seen, key)
+		if key != "new-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","model":"claude-3-5-sonnet","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	p := New("old-key,new-key")
+	p.baseURL = server.URL
+
+	resp, err := p.ChatCompletion(context.Background(), domain.ChatRequest{Model: "claude-3-5-sonnet"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if resp.Choices[0].Message.Content != "hi" {
+		t.Errorf("content = %q, want hi", resp.Choices[0].Message.Content)
+	}
+	if len(seen) != 2 || seen[0] != "old-key" {
+		t.Errorf("unexpected key sequence: %v", seen)
+	}
+}
+
+func TestToAnthropicRequest_IgnoresLogprobs(t *testing.T) {
+	topN := 3
+	req := domain.ChatRequest{
+		Model:       "claude-3-5-sonnet",
+		Messages:    []domain.Message{{Role: "user", Content: "Hello"}},
+		Logprobs:    true,
+		TopLogprobs: &topN,
+	}
+
+	body, err := json.Marshal(toAnthropicRequest(req))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(body), "logprobs") {
+		t.Errorf("anthropic request should not carry logprobs: %s", body)
+	}
+}
+
+func TestToAnthropicRequest_SystemMessages(t *testing.T) {
+	req := domain.ChatRequest{
+		Model: "claude-3-5-sonnet",
+		Messages: []domain.Message{
+			{Role: "system", Content: "You are terse."},
+			{Role: "system", Content: "Answer in French."},
+			{Role: "user", Content: "Hello"},
+			{Role: "assistant", Content: "Bonjour"},
+			{Role: "system", Content: "Now answer in English."},
+			{Role: "user", Content: "How are you?"},
+		},
+	}
+
+	got := toAnthropicRequest(req)
+
+	wantSystem := "You are terse.\n\nAnswer in French.\n\nNow answer in English."
+	if got.System != wantSystem {
+		t.Errorf("system = %q, want %q", got.System, wantSystem)
+	}
+	var roles []string
+	for _, m := range got.Messages {
+		roles = append(roles, m.Role)
+	}
+	if strings.Join(roles, ",") != "user,assistant,user" {
+		t.Errorf("message roles = %v, want user,assistant,user", roles)
+	}
+}
+
+func TestToAnthropicRequest_ParallelToolCalls(t *testing.T) {
+	on, off := true, false
+	weather := []domain.Tool{{
+		Type: "function",
+		Function: domain.ToolFunction{
+			Name:       "get_weather",
+			Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		},
+	}}
+	tests := []struct {
+		name     string
+		parallel *bool
+		tools    []domain.Tool
+		want     string
+	}{
+		{"unset", nil, weather, ""},
+		{"allowed", &on, weather, ""},
+		{"disabled", &off, weather, `"tool_choice":{"type":"auto","disable_parallel_tool_use":true}`},
+		{"disabled without tools", &off, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := domain.ChatRequest{
+				Model:             "claude-3-5-sonnet",
+				Messages:          []domain.Message{{Role: "user", Content: "Hello"}},
+				ParallelToolCalls: tt.parallel,
+				Tools:             tt.tools,
+			}
+
+			body, err := json.Marshal(toAnthropicRequest(req))
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if strings.Contains(string(body), "parallel_tool_calls") {
+				t.Errorf("anthropic request should not carry parallel_tool_calls: %s", body)
+			}
+			if tt.want == "" {
+				if strings.Contains(string(body), "tool_choice") {
+					t.Errorf("unexpected tool_choice: %s", body)
+				}
+			} else if !strings.Contains(string(body), tt.want) {
+				t.Errorf("body = %s, want it to contain %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatCompletionStream_ToolCallDeltas(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		`{"type":"message_stop"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			w.Write([]byte("data: " + e + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	p := New("test-key")
+	p.baseURL = server.URL
+
+	chunks, errs := p.ChatCompletionStream(context.Background(), domain.ChatRequest{Model: "claude-3-5-sonnet"})
+	var got []domain.StreamChunk
+	for c := range chunks {
+		got = append(got, c)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("expected 5 chunks, got %d", len(got))
+	}
+	if got[0].ID != "msg_1" || got[0].Choices[0].Delta.Content != "Checking." {
+		t.Errorf("unexpected text chunk: %+v", got[0].Choices[0].Delta)
+	}
+
+	start := got[1].Choices[0].Delta.ToolCalls
+	if len(start) != 1 || start[0].Index != 0 || start[0].ID != "toolu_1" || start[0].Type != "function" || start[0].Function.Name != "get_weather" {
+		t.Errorf("unexpected tool call start: %+v", start)
+	}
+
+	var args string
+	for _, c := range got[2:4] {
+		calls := c.Choices[0].Delta.ToolCalls
+		if len(calls) != 1 || calls[0].Index != 0 || calls[0].ID != "" {
+			t.Fatalf("unexpected argument fragment: %+v", calls)
+		}
+		args += calls[0].Function.Arguments
+	}
+	if args != `{"city":"Paris"}` {
+		t.Errorf("arguments = %s", args)
+	}
+
+	if got[4].Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", got[4].Choices[0].FinishReason)
+	}
+}
+
+func TestStreamTranslator_StableCreated(t *testing.T) {
+	tr := newStreamTranslator("claude-3-5-sonnet")
+	if tr.created == 0 {
+		t.Fatal("translator created is not set")
+	}
+	tr.created = 1714564800
+
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+	}
+	for _, e := range events {
+		var event streamEvent
+		if err := json.Unmarshal([]byte(e), &event); err != nil {
+			t.Fatalf("unmarshal %s: %v", e, err)
+		}
+		if chunk, ok := tr.translate(event); ok && chunk.Created != 1714564800 {
+			t.Errorf("chunk created = %d, want the translator's", chunk.Created)
+		}
+	}
+}
+
+func TestToAnthropicRequest_Tools(t *testing.T) {
+	req := domain.ChatRequest{
+		Model:    "claude-3-5-sonnet",
+		Messages: []domain.Message{{Role: "user", Content: "Weather in Paris?"}},
+		Tools: []domain.Tool{
+			{Type: "function", Function: domain.ToolFunction{
+				Name:        "get_weather",
+				Description: "Current weather",
+				Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+			}},
+			{Type: "function", Function: domain.ToolFunction{Name: "now"}},
+		},
+	}
+
+	body, err := json.Marshal(toAnthropicRequest(req))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `"tools":[{"name":"get_weather","description":"Current weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}},{"name":"now","input_schema":{"type":"object"}}]`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("body = %s, want it to contain %s", body, want)
+	}
+}
+
+func TestToOpenAIResponse_ToolUse(t *testing.T) {
+	resp := anthropicResponse{
+		ID: "msg_1",
+		Content: []contentBlock{
+			{Type: "text", Text: "Checking."},
+			{Type: "tool_use", ID: "toolu_1", Name: "get_weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+		},
+		StopReason: "tool_use",
+	}
+
+	got := toOpenAIResponse(resp, "claude-3-5-sonnet")
+	msg := got.Choices[0].Message
+	if msg.Content != "Checking." {
+		t.Errorf("content = %q, want %q", msg.Content, "Checking.")
+	}
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("tool calls = %d, want 1", len(msg.ToolCalls))
+	}
+	call := msg.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if got.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish_reason = %q, want tool_calls", got.Choices[0].FinishReason)
+	}
+}
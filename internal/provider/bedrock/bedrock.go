@@ -39,6 +39,14 @@ func NewWithConfig(cfg aws.Config) *Provider {
 	}
 }
 
+// BaseURL returns the Bedrock runtime endpoint for the provider's region.
+func (p *Provider) BaseURL() string {
+	if p.region == "" {
+		return ""
+	}
+	return "https://bedrock-runtime." + p.region + ".amazonaws.com"
+}
+
 func (p *Provider) ID() string {
 	return "bedrock"
 }
@@ -101,6 +109,8 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 		stream := output.GetStream()
 		defer stream.Close()
 
+		// Bedrock sends no timestamp, so one is shared by the whole stream.
+		created := time.Now().Unix()
 		for event := range stream.Events() {
 			switch v := event.(type) {
 			case *types.ResponseStreamMemberChunk:
@@ -113,7 +123,7 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 					chunk := domain.StreamChunk{
 						ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
 						Object:  "chat.completion.chunk",
-						Created: time.Now().Unix(),
+						Created: created,
 						Model:   req.Model,
 						Choices: []domain.Choice{
 							{
@@ -147,16 +157,23 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 }
 
 func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
+	// Tool use is not wired through InvokeModel here, so it is not advertised.
+	claude3 := &domain.ModelCapabilities{Streaming: true, Vision: true, MaxContext: 200000}
+	claude35Haiku := &domain.ModelCapabilities{Streaming: true, MaxContext: 200000}
+	titanExpress := &domain.ModelCapabilities{Streaming: true, MaxContext: 8192}
+	titanLite := &domain.ModelCapabilities{Streaming: true, MaxContext: 4096}
+	llama3 := &domain.ModelCapabilities{Streaming: true, MaxContext: 8192}
+
 	models := []domain.Model{
-		{ID: "anthropic.claude-3-5-sonnet-20241022-v2:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock"},
-		{ID: "anthropic.claude-3-5-haiku-20241022-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock"},
-		{ID: "anthropic.claude-3-opus-20240229-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock"},
-		{ID: "anthropic.claude-3-sonnet-20240229-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock"},
-		{ID: "anthropic.claude-3-haiku-20240307-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock"},
-		{ID: "amazon.titan-text-express-v1", Object: "model", OwnedBy: "amazon", Provider: "bedrock"},
-		{ID: "amazon.titan-text-lite-v1", Object: "model", OwnedBy: "amazon", Provider: "bedrock"},
-		{ID: "meta.llama3-70b-instruct-v1:0", Object: "model", OwnedBy: "meta", Provider: "bedrock"},
-		{ID: "meta.llama3-8b-instruct-v1:0", Object: "model", OwnedBy: "meta", Provider: "bedrock"},
+		{ID: "anthropic.claude-3-5-sonnet-20241022-v2:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock", Capabilities: claude3},
+		{ID: "anthropic.claude-3-5-haiku-20241022-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock", Capabilities: claude35Haiku},
+		{ID: "anthropic.claude-3-opus-20240229-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock", Capabilities: claude3},
+		{ID: "anthropic.claude-3-sonnet-20240229-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock", Capabilities: claude3},
+		{ID: "anthropic.claude-3-haiku-20240307-v1:0", Object: "model", OwnedBy: "anthropic", Provider: "bedrock", Capabilities: claude3},
+		{ID: "amazon.titan-text-express-v1", Object: "model", OwnedBy: "amazon", Provider: "bedrock", Capabilities: titanExpress},
+		{ID: "amazon.titan-text-lite-v1", Object: "model", OwnedBy: "amazon", Provider: "bedrock", Capabilities: titanLite},
+		{ID: "meta.llama3-70b-instruct-v1:0", Object: "model", OwnedBy: "meta", Provider: "bedrock", Capabilities: llama3},
+		{ID: "meta.llama3-8b-instruct-v1:0", Object: "model", OwnedBy: "meta", Provider: "bedrock", Capabilities: llama3},
 	}
 	return models, nil
 }
@@ -227,14 +244,10 @@ func mapModelID(model string) string {
 }
 
 func toBedrockRequest(req domain.ChatRequest) bedrockRequest {
-	var systemPrompt string
-	messages := make([]bedrockMessage, 0, len(req.Messages))
+	system, conversation := req.SplitSystem()
+	messages := make([]bedrockMessage, 0, len(conversation))
 
-	for _, m := range req.Messages {
-		if m.Role == "system" {
-			systemPrompt = m.Content
-			continue
-		}
+	for _, m := range conversation {
 		messages = append(messages, bedrockMessage{
 			Role:    m.Role,
 			Content: m.Content,
@@ -250,7 +263,7 @@ func toBedrockRequest(req domain.ChatRequest) bedrockRequest {
 		AnthropicVersion: "bedrock-2023-05-31",
 		MaxTokens:        maxTokens,
 		Messages:         messages,
-		System:           systemPrompt,
+		System:           system,
 	}
 }
 
@@ -0,0 +1,35 @@
+package bedrock
+
+import (
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestToBedrockRequest_SystemMessages(t *testing.T) {
+	req := domain.ChatRequest{
+		Model: "claude-3-haiku",
+		Messages: []domain.Message{
+			{Role: "system", Content: "You are terse."},
+			{Role: "user", Content: "Hello"},
+			{Role: "system", Content: ""},
+			{Role: "assistant", Content: "Hi"},
+			{Role: "system", Content: "Answer in French."},
+			{Role: "user", Content: "How are you?"},
+		},
+	}
+
+	got := toBedrockRequest(req)
+
+	if want := "You are terse.\n\nAnswer in French."; got.System != want {
+		t.Errorf("system = %q, want %q", got.System, want)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("messages = %d, want 3", len(got.Messages))
+	}
+	for i, role := range []string{"user", "assistant", "user"} {
+		if got.Messages[i].Role != role {
+			t.Errorf("message %d role = %q, want %q", i, got.Messages[i].Role, role)
+		}
+	}
+}
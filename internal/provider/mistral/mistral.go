@@ -0,0 +1,275 @@
+package mistral
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/httputil"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// DefaultBaseURL is Mistral's hosted API.
+const DefaultBaseURL = "https://api.mistral.ai/v1"
+
+type Provider struct {
+	apiKeys []string
+	baseURL string
+	client  *http.Client
+
+	// streamClient shares client's transport but has no overall timeout,
+	// relying on idle detection so long streams are not cut off.
+	streamClient *http.Client
+	streamIdle   time.Duration
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithBaseURL overrides DefaultBaseURL, e.g. for a proxy or a self-hosted
+// deployment.
+func WithBaseURL(url string) Option {
+	return func(p *Provider) {
+		p.baseURL = url
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, e.g. to bound connections
+// per host.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) {
+		p.client = c
+	}
+}
+
+// WithStreamIdleTimeout sets how long a streaming response may send nothing
+// before it is aborted. Streams have no overall timeout.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.streamIdle = d
+	}
+}
+
+// New creates a Mistral provider. apiKey may be a comma-separated list; the
+// next key is used when the upstream returns 401.
+func New(apiKey string, opts ...Option) *Provider {
+	p := &Provider{
+		apiKeys:    httputil.SplitKeys(apiKey),
+		baseURL:    DefaultBaseURL,
+		client:     httputil.DefaultClient(),
+		streamIdle: httputil.DefaultStreamIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.streamClient = httputil.StreamClient(p.client, p.streamIdle)
+	return p
+}
+
+// do sends a request with key failover and records which key was accepted.
+func (p *Provider) do(client *http.Client, newRequest func(key string) (*http.Request, error)) (*http.Response, error) {
+	resp, keyIndex, err := httputil.DoWithKeys(client, p.apiKeys, newRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		metrics.RecordProviderKeyUsed(p.ID(), keyIndex)
+		if keyIndex > 0 {
+			slog.Warn("mistral rejected primary API key, using fallback key", "key_index", keyIndex)
+		}
+	}
+	return resp, nil
+}
+
+func (p *Provider) ID() string {
+	return "mistral"
+}
+
+// BaseURL returns the API endpoint requests are sent to.
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
+func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	body, err := json.Marshal(toMistralRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := p.do(p.client, p.chatRequest(ctx, body, false))
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &domain.ProviderStatusError{Provider: "mistral", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
+	}
+
+	var chatResp domain.ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &chatResp, nil
+}
+
+func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequest) (<-chan domain.StreamChunk, <-chan error) {
+	chunks := make(chan domain.StreamChunk)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+
+		req.Stream = true
+		body, err := json.Marshal(toMistralRequest(req))
+		if err != nil {
+			errs <- fmt.Errorf("marshal request: %w", err)
+			return
+		}
+
+		resp, err := p.do(p.streamClient, p.chatRequest(ctx, body, true))
+		if err != nil {
+			errs <- fmt.Errorf("do request: %w", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			bodyBytes, _ := io.ReadAll(resp.Body)
+			errs <- &domain.ProviderStatusError{Provider: "mistral", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
+			return
+		}
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				return
+			}
+
+			var chunk domain.StreamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				continue
+			}
+
+			select {
+			case chunks <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			errs <- fmt.Errorf("scan error: %w", err)
+		}
+	}()
+
+	return chunks, errs
+}
+
+func (p *Provider) chatRequest(ctx context.Context, body []byte, stream bool) func(key string) (*http.Request, error) {
+	return func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httputil.ApplyForwardedHeaders(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+		if stream {
+			httpReq.Header.Set("Accept", "text/event-stream")
+		}
+		return httpReq, nil
+	}
+}
+
+// Models lists the hosted models the gateway prices and routes to Mistral.
+func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
+	models := make([]domain.Model, len(hostedModels))
+	for i, m := range hostedModels {
+		caps := m.caps
+		models[i] = domain.Model{ID: m.id, Object: "model", OwnedBy: "mistralai", Provider: "mistral", Capabilities: &caps}
+	}
+	return models, nil
+}
+
+func (p *Provider) HealthCheck(ctx context.Context) error {
+	resp, err := p.do(p.client, func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", http.NoBody)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+		return httpReq, nil
+	})
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mistral unhealthy: status=%d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+var hostedModels = []struct {
+	id   string
+	caps domain.ModelCapabilities
+}{
+	{"mistral-large-latest", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 128000}},
+	{"mistral-small-latest", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 32000}},
+	{"codestral-latest", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 32000}},
+	{"open-mistral-nemo", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 128000}},
+	{"open-mistral-7b", domain.ModelCapabilities{Streaming: true, MaxContext: 32000}},
+	{"open-mixtral-8x7b", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 32000}},
+	{"open-mixtral-8x22b", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 64000}},
+}
+
+// mistralRequest carries only the parameters Mistral accepts; it answers
+// unknown ones, such as logprobs or store, with 422. Tools use OpenAI's
+// format, which Mistral accepts as is.
+type mistralRequest struct {
+	Model             string           `json:"model"`
+	Messages          []domain.Message `json:"messages"`
+	Temperature       *float64         `json:"temperature,omitempty"`
+	TopP              *float64         `json:"top_p,omitempty"`
+	MaxTokens         *int             `json:"max_tokens,omitempty"`
+	Stream            bool             `json:"stream,omitempty"`
+	Stop              []string         `json:"stop,omitempty"`
+	Tools             []domain.Tool    `json:"tools,omitempty"`
+	ParallelToolCalls *bool            `json:"parallel_tool_calls,omitempty"`
+}
+
+func toMistralRequest(req domain.ChatRequest) mistralRequest {
+	return mistralRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stream:      req.Stream,
+		Stop:        req.Stop,
+
+		Tools:             req.Tools,
+		ParallelToolCalls: req.ParallelToolCalls,
+	}
+}
@@ -0,0 +1,168 @@
+package mistral
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestToMistralRequest_DropsUnsupportedFields(t *testing.T) {
+	temp := 0.2
+	topN := 3
+	maxTokens := 64
+	req := domain.ChatRequest{
+		Model:         "mistral-small-latest",
+		Messages:      []domain.Message{{Role: "user", Content: "Hello"}},
+		Temperature:   &temp,
+		MaxTokens:     &maxTokens,
+		Stop:          []string{"\n"},
+		Logprobs:      true,
+		TopLogprobs:   &topN,
+		Store:         true,
+		Metadata:      map[string]string{"feature": "search"},
+		StreamOptions: &domain.StreamOptions{IncludeUsage: true},
+	}
+
+	body, err := json.Marshal(toMistralRequest(req))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var sent map[string]interface{}
+	json.Unmarshal(body, &sent)
+
+	for _, field := range []string{"logprobs", "top_logprobs", "store", "metadata", "stream_options"} {
+		if _, ok := sent[field]; ok {
+			t.Errorf("request should not include %q: %s", field, body)
+		}
+	}
+	if sent["model"] != "mistral-small-latest" || sent["temperature"] != 0.2 || sent["max_tokens"] != float64(64) {
+		t.Errorf("unexpected request: %s", body)
+	}
+}
+
+func TestToMistralRequest_KeepsTools(t *testing.T) {
+	parallel := false
+	req := domain.ChatRequest{
+		Model:    "mistral-large-latest",
+		Messages: []domain.Message{{Role: "user", Content: "Weather in Paris?"}},
+		Tools: []domain.Tool{{Type: "function", Function: domain.ToolFunction{
+			Name:       "get_weather",
+			Parameters: json.RawMessage(`{"type":"object"}`),
+		}}},
+		ParallelToolCalls: &parallel,
+	}
+
+	body, err := json.Marshal(toMistralRequest(req))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var sent struct {
+		Tools []struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+		ParallelToolCalls *bool `json:"parallel_tool_calls"`
+	}
+	json.Unmarshal(body, &sent)
+
+	if len(sent.Tools) != 1 || sent.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("tools not forwarded: %s", body)
+	}
+	if sent.ParallelToolCalls == nil || *sent.ParallelToolCalls {
+		t.Errorf("parallel_tool_calls not forwarded: %s", body)
+	}
+}
+
+func TestChatCompletion(t *testing.T) {
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"cmpl-1","object":"chat.completion","model":"mistral-large-latest","choices":[{
+			"index":0,"message":{"role":"assistant","content":"Bonjour"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	resp, err := New("test-key", WithBaseURL(server.URL)).ChatCompletion(context.Background(), domain.ChatRequest{
+		Model:    "mistral-large-latest",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if path != "/chat/completions" || auth != "Bearer test-key" {
+		t.Errorf("path = %q, auth = %q", path, auth)
+	}
+	if resp.Choices[0].Message.Content != "Bonjour" || resp.Usage.TotalTokens != 7 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestChatCompletion_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message":"rate limited"}`))
+	}))
+	defer server.Close()
+
+	_, err := New("test-key", WithBaseURL(server.URL)).ChatCompletion(context.Background(), domain.ChatRequest{Model: "open-mistral-7b"})
+	statusErr, ok := err.(*domain.ProviderStatusError)
+	if !ok {
+		t.Fatalf("error = %v, want *domain.ProviderStatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusTooManyRequests || statusErr.RetryAfter.Seconds() != 3 {
+		t.Errorf("unexpected error: %+v", statusErr)
+	}
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","content":"Bon"}}]}` + "\n\n"))
+		w.Write([]byte(`data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"jour"},"finish_reason":"stop"}]}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	chunks, errs := New("test-key", WithBaseURL(server.URL)).ChatCompletionStream(context.Background(), domain.ChatRequest{Model: "mistral-small-latest"})
+	var content string
+	for c := range chunks {
+		content += c.Choices[0].Delta.Content
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if sent["stream"] != true {
+		t.Errorf("request stream = %v, want true", sent["stream"])
+	}
+	if content != "Bonjour" {
+		t.Errorf("content = %q, want Bonjour", content)
+	}
+}
+
+func TestModels(t *testing.T) {
+	models, err := New("test-key").Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+	if len(models) != len(hostedModels) {
+		t.Fatalf("models = %d, want %d", len(models), len(hostedModels))
+	}
+	for _, m := range models {
+		if m.Provider != "mistral" || m.Capabilities == nil || !m.Capabilities.Streaming {
+			t.Errorf("unexpected model: %+v", m)
+		}
+	}
+}
@@ -17,21 +17,91 @@ import (
 type Provider struct {
 	baseURL string
 	client  *http.Client
+
+	// streamClient shares client's transport but has no overall timeout,
+	// relying on idle detection so long generations are not cut off.
+	streamClient *http.Client
+	streamIdle   time.Duration
+
+	// aliases maps client-facing model names to Ollama tags; tags reverses it
+	// for the model listing.
+	aliases map[string]string
+	tags    map[string]string
 }
 
-func New(baseURL string) *Provider {
-	return &Provider{
-		baseURL: baseURL,
-		client:  httputil.DefaultClient(),
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithModelAliases translates friendly model names (e.g. "llama3") to the
+// exact Ollama tag (e.g. "llama3:8b-instruct-q4_0"). Unlisted names pass
+// through unchanged.
+func WithModelAliases(aliases map[string]string) Option {
+	return func(p *Provider) {
+		p.aliases = aliases
+		p.tags = make(map[string]string, len(aliases))
+		for alias, tag := range aliases {
+			p.tags[tag] = alias
+		}
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client, e.g. to bound connections
+// per host.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) {
+		p.client = c
+	}
+}
+
+// WithStreamIdleTimeout sets how long a streaming response may send nothing
+// before it is aborted. Streams have no overall timeout.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.streamIdle = d
+	}
+}
+
+func New(baseURL string, opts ...Option) *Provider {
+	p := &Provider{
+		baseURL:    baseURL,
+		client:     httputil.DefaultClient(),
+		streamIdle: httputil.DefaultStreamIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	p.streamClient = httputil.StreamClient(p.client, p.streamIdle)
+	return p
+}
+
+// ollamaModelName returns the Ollama tag for a requested model name.
+func (p *Provider) ollamaModelName(model string) string {
+	if tag, ok := p.aliases[model]; ok {
+		return tag
+	}
+	return model
+}
+
+// clientModelName returns the name clients should use for an Ollama tag.
+func (p *Provider) clientModelName(tag string) string {
+	if alias, ok := p.tags[tag]; ok {
+		return alias
+	}
+	return tag
 }
 
 func (p *Provider) ID() string {
 	return "ollama"
 }
 
+// BaseURL returns the API endpoint requests are sent to.
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
 func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
 	ollamaReq := toOllamaRequest(req)
+	ollamaReq.Model = p.ollamaModelName(req.Model)
 
 	body, err := json.Marshal(ollamaReq)
 	if err != nil {
@@ -43,6 +113,7 @@ func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 
+	httputil.ApplyForwardedHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.client.Do(httpReq)
@@ -53,7 +124,7 @@ func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+		return nil, &domain.ProviderStatusError{Provider: "ollama", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 	}
 
 	var ollamaResp ollamaChatResponse
@@ -73,6 +144,7 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 		defer close(errs)
 
 		ollamaReq := toOllamaRequest(req)
+		ollamaReq.Model = p.ollamaModelName(req.Model)
 		ollamaReq.Stream = true
 
 		body, err := json.Marshal(ollamaReq)
@@ -87,9 +159,10 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 			return
 		}
 
+		httputil.ApplyForwardedHeaders(httpReq)
 		httpReq.Header.Set("Content-Type", "application/json")
 
-		resp, err := p.client.Do(httpReq)
+		resp, err := p.streamClient.Do(httpReq)
 		if err != nil {
 			errs <- fmt.Errorf("do request: %w", err)
 			return
@@ -98,10 +171,13 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
-			errs <- fmt.Errorf("ollama error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+			errs <- &domain.ProviderStatusError{Provider: "ollama", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 			return
 		}
 
+		// Every chunk of a stream carries the same created time: that of
+		// the first chunk, as Ollama stamps each one separately.
+		var created int64
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -114,7 +190,10 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 				continue
 			}
 
-			chunk := toOpenAIStreamChunk(ollamaChunk, req.Model)
+			if created == 0 {
+				created = createdUnix(ollamaChunk.CreatedAt, time.Now())
+			}
+			chunk := toOpenAIStreamChunk(ollamaChunk, req.Model, created)
 
 			select {
 			case chunks <- chunk:
@@ -159,10 +238,12 @@ func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
 	models := make([]domain.Model, len(tagsResp.Models))
 	for i, m := range tagsResp.Models {
 		models[i] = domain.Model{
-			ID:       m.Name,
+			ID:       p.clientModelName(m.Name),
 			Object:   "model",
 			OwnedBy:  "ollama",
 			Provider: "ollama",
+			// Every Ollama model streams; tool and vision support vary per model.
+			Capabilities: &domain.ModelCapabilities{Streaming: true},
 		}
 	}
 
@@ -271,11 +352,20 @@ func toOllamaRequest(req domain.ChatRequest) ollamaChatRequest {
 	return ollamaReq
 }
 
+// createdUnix converts Ollama's created_at to Unix seconds, or returns
+// fallback's when it is missing or malformed.
+func createdUnix(createdAt string, fallback time.Time) int64 {
+	if t, err := time.Parse(time.RFC3339Nano, createdAt); err == nil {
+		return t.Unix()
+	}
+	return fallback.Unix()
+}
+
 func toOpenAIResponse(resp ollamaChatResponse, model string) *domain.ChatResponse {
 	return &domain.ChatResponse{
 		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
 		Object:  "chat.completion",
-		Created: time.Now().Unix(),
+		Created: createdUnix(resp.CreatedAt, time.Now()),
 		Model:   model,
 		Choices: []domain.Choice{
 			{
@@ -295,7 +385,7 @@ func toOpenAIResponse(resp ollamaChatResponse, model string) *domain.ChatRespons
 	}
 }
 
-func toOpenAIStreamChunk(chunk ollamaStreamChunk, model string) domain.StreamChunk {
+func toOpenAIStreamChunk(chunk ollamaStreamChunk, model string, created int64) domain.StreamChunk {
 	finishReason := ""
 	if chunk.Done {
 		finishReason = "stop"
@@ -304,7 +394,7 @@ func toOpenAIStreamChunk(chunk ollamaStreamChunk, model string) domain.StreamChu
 	return domain.StreamChunk{
 		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
 		Object:  "chat.completion.chunk",
-		Created: time.Now().Unix(),
+		Created: created,
 		Model:   model,
 		Choices: []domain.Choice{
 			{
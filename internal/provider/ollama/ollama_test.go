@@ -0,0 +1,121 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func TestChatCompletion_ResolvesModelAlias(t *testing.T) {
+	tests := []struct {
+		name      string
+		model     string
+		wantTag   string
+		wantModel string
+	}{
+		{"alias", "llama3", "llama3:8b-instruct-q4_0", "llama3"},
+		{"passthrough", "mistral:7b", "mistral:7b", "mistral:7b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent ollamaChatRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&sent)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"model":"` + sent.Model + `","message":{"role":"assistant","content":"Hi"},"done":true}`))
+			}))
+			defer server.Close()
+
+			p := New(server.URL, WithModelAliases(map[string]string{"llama3": "llama3:8b-instruct-q4_0"}))
+			resp, err := p.ChatCompletion(context.Background(), domain.ChatRequest{
+				Model:    tt.model,
+				Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+			})
+			if err != nil {
+				t.Fatalf("ChatCompletion() error = %v", err)
+			}
+
+			if sent.Model != tt.wantTag {
+				t.Errorf("sent model = %q, want %q", sent.Model, tt.wantTag)
+			}
+			if resp.Model != tt.wantModel {
+				t.Errorf("response model = %q, want %q", resp.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestModels_ReverseMapsAliases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[{"name":"llama3:8b-instruct-q4_0"},{"name":"mistral:7b"}]}`))
+	}))
+	defer server.Close()
+
+	p := New(server.URL, WithModelAliases(map[string]string{"llama3": "llama3:8b-instruct-q4_0"}))
+	models, err := p.Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+
+	want := []string{"llama3", "mistral:7b"}
+	if len(models) != len(want) {
+		t.Fatalf("got %d models, want %d", len(models), len(want))
+	}
+	for i, id := range want {
+		if models[i].ID != id {
+			t.Errorf("models[%d].ID = %q, want %q", i, models[i].ID, id)
+		}
+	}
+}
+
+func TestCreatedTimestamps(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			w.Write([]byte(`{"model":"llama3","created_at":"2024-05-01T12:00:00.123456Z","message":{"role":"assistant","content":"Hi"},"done":true}`))
+			return
+		}
+		w.Write([]byte(`{"model":"llama3","created_at":"2024-05-01T12:00:00.5Z","message":{"role":"assistant","content":"Hel"},"done":false}` + "\n"))
+		w.Write([]byte(`{"model":"llama3","created_at":"2024-05-01T12:00:01.7Z","message":{"role":"assistant","content":"lo"},"done":false}` + "\n"))
+		w.Write([]byte(`{"model":"llama3","created_at":"2024-05-01T12:00:03Z","message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	want := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC).Unix()
+	p := New(server.URL)
+
+	resp, err := p.ChatCompletion(context.Background(), domain.ChatRequest{Model: "llama3"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if resp.Created != want {
+		t.Errorf("response created = %d, want %d", resp.Created, want)
+	}
+
+	chunks, errs := p.ChatCompletionStream(context.Background(), domain.ChatRequest{Model: "llama3"})
+	n := 0
+	for c := range chunks {
+		n++
+		if c.Created != want {
+			t.Errorf("chunk %d created = %d, want %d", n, c.Created, want)
+		}
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("got %d chunks, want 3", n)
+	}
+
+	if got := createdUnix("not a time", time.Unix(42, 0)); got != 42 {
+		t.Errorf("createdUnix(malformed) = %d, want fallback 42", got)
+	}
+}
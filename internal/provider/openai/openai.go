@@ -7,46 +7,104 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 	"github.com/felipepmaragno/ai-gateway/internal/httputil"
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
 )
 
 type Provider struct {
-	apiKey  string
+	apiKeys []string
 	baseURL string
 	client  *http.Client
+
+	// streamClient shares client's transport but has no overall timeout,
+	// relying on idle detection so long streams are not cut off.
+	streamClient *http.Client
+	streamIdle   time.Duration
+}
+
+// Option configures a Provider.
+type Option func(*Provider)
+
+// WithHTTPClient replaces the default HTTP client, e.g. to bound connections
+// per host.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Provider) {
+		p.client = c
+	}
+}
+
+// WithStreamIdleTimeout sets how long a streaming response may send nothing
+// before it is aborted. Streams have no overall timeout.
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(p *Provider) {
+		p.streamIdle = d
+	}
+}
+
+// New creates an OpenAI provider. apiKey may be a comma-separated list; keys
+// are tried in order and the next one is used when the upstream returns 401,
+// so a key can be rotated by listing the old and new keys together.
+func New(apiKey, baseURL string, opts ...Option) *Provider {
+	p := &Provider{
+		apiKeys:    httputil.SplitKeys(apiKey),
+		baseURL:    baseURL,
+		client:     httputil.DefaultClient(),
+		streamIdle: httputil.DefaultStreamIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.streamClient = httputil.StreamClient(p.client, p.streamIdle)
+	return p
 }
 
-func New(apiKey, baseURL string) *Provider {
-	return &Provider{
-		apiKey:  apiKey,
-		baseURL: baseURL,
-		client:  httputil.DefaultClient(),
+// do sends a request with key failover and records which key was accepted.
+func (p *Provider) do(client *http.Client, newRequest func(key string) (*http.Request, error)) (*http.Response, error) {
+	resp, keyIndex, err := httputil.DoWithKeys(client, p.apiKeys, newRequest)
+	if err != nil {
+		return nil, err
 	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		metrics.RecordProviderKeyUsed(p.ID(), keyIndex)
+		if keyIndex > 0 {
+			slog.Warn("openai rejected primary API key, using fallback key", "key_index", keyIndex)
+		}
+	}
+	return resp, nil
 }
 
 func (p *Provider) ID() string {
 	return "openai"
 }
 
+// BaseURL returns the API endpoint requests are sent to.
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
 func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.do(p.client, func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httputil.ApplyForwardedHeaders(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+		return httpReq, nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("do request: %w", err)
 	}
@@ -54,7 +112,7 @@ func (p *Provider) ChatCompletion(ctx context.Context, req domain.ChatRequest) (
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("openai error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+		return nil, &domain.ProviderStatusError{Provider: "openai", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 	}
 
 	var chatResp domain.ChatResponse
@@ -80,17 +138,17 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 			return
 		}
 
-		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
-		if err != nil {
-			errs <- fmt.Errorf("create request: %w", err)
-			return
-		}
-
-		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-		httpReq.Header.Set("Accept", "text/event-stream")
-
-		resp, err := p.client.Do(httpReq)
+		resp, err := p.do(p.streamClient, func(key string) (*http.Request, error) {
+			httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+			if err != nil {
+				return nil, fmt.Errorf("create request: %w", err)
+			}
+			httputil.ApplyForwardedHeaders(httpReq)
+			httpReq.Header.Set("Content-Type", "application/json")
+			httpReq.Header.Set("Authorization", "Bearer "+key)
+			httpReq.Header.Set("Accept", "text/event-stream")
+			return httpReq, nil
+		})
 		if err != nil {
 			errs <- fmt.Errorf("do request: %w", err)
 			return
@@ -99,7 +157,7 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body)
-			errs <- fmt.Errorf("openai error: status=%d body=%s", resp.StatusCode, string(bodyBytes))
+			errs <- &domain.ProviderStatusError{Provider: "openai", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 			return
 		}
 
@@ -135,15 +193,44 @@ func (p *Provider) ChatCompletionStream(ctx context.Context, req domain.ChatRequ
 	return chunks, errs
 }
 
-func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", http.NoBody)
+// Moderate classifies req's input with OpenAI's moderation endpoint, which
+// picks its default model when req names none.
+func (p *Provider) Moderate(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error) {
+	body, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	resp, err := p.do(p.client, func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/moderations", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httputil.ApplyForwardedHeaders(httpReq)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+		return httpReq, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &domain.ProviderStatusError{Provider: "openai", StatusCode: resp.StatusCode, Body: string(bodyBytes), RetryAfter: httputil.RetryAfter(resp.Header, time.Now())}
 	}
 
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	var modResp domain.ModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&modResp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &modResp, nil
+}
 
-	resp, err := p.client.Do(httpReq)
+func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
+	resp, err := p.do(p.client, p.modelsRequest(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("do request: %w", err)
 	}
@@ -160,20 +247,14 @@ func (p *Provider) Models(ctx context.Context) ([]domain.Model, error) {
 
 	for i := range modelsResp.Data {
 		modelsResp.Data[i].Provider = "openai"
+		modelsResp.Data[i].Capabilities = capabilitiesFor(modelsResp.Data[i].ID)
 	}
 
 	return modelsResp.Data, nil
 }
 
 func (p *Provider) HealthCheck(ctx context.Context) error {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", http.NoBody)
-	if err != nil {
-		return fmt.Errorf("create request: %w", err)
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
-
-	resp, err := p.client.Do(httpReq)
+	resp, err := p.do(p.client, p.modelsRequest(ctx))
 	if err != nil {
 		return fmt.Errorf("do request: %w", err)
 	}
@@ -185,3 +266,40 @@ func (p *Provider) HealthCheck(ctx context.Context) error {
 
 	return nil
 }
+
+func (p *Provider) modelsRequest(ctx context.Context) func(key string) (*http.Request, error) {
+	return func(key string) (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", http.NoBody)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+key)
+		return httpReq, nil
+	}
+}
+
+// modelCapabilities is matched by longest prefix so dated snapshots
+// (e.g. gpt-4o-2024-08-06) inherit their family's capabilities.
+var modelCapabilities = []struct {
+	prefix string
+	caps   domain.ModelCapabilities
+}{
+	{"gpt-4o-mini", domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true, MaxContext: 128000}},
+	{"gpt-4o", domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true, MaxContext: 128000}},
+	{"gpt-4-turbo", domain.ModelCapabilities{Streaming: true, Tools: true, Vision: true, MaxContext: 128000}},
+	{"gpt-4-32k", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 32768}},
+	{"gpt-4", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 8192}},
+	{"gpt-3.5-turbo", domain.ModelCapabilities{Streaming: true, Tools: true, MaxContext: 16385}},
+}
+
+// capabilitiesFor returns nil for models that aren't chat models we know
+// about (embeddings, audio, image models).
+func capabilitiesFor(modelID string) *domain.ModelCapabilities {
+	for _, entry := range modelCapabilities {
+		if strings.HasPrefix(modelID, entry.prefix) {
+			caps := entry.caps
+			return &caps
+		}
+	}
+	return nil
+}
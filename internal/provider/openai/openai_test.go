@@ -0,0 +1,319 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/felipepmaragno/ai-gateway/internal/httputil"
+)
+
+func TestModels_Capabilities(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[
+			{"id":"gpt-4o-2024-08-06","object":"model","owned_by":"openai"},
+			{"id":"gpt-3.5-turbo","object":"model","owned_by":"openai"},
+			{"id":"text-embedding-3-small","object":"model","owned_by":"openai"}
+		]}`))
+	}))
+	defer server.Close()
+
+	models, err := New("test-key", server.URL).Models(context.Background())
+	if err != nil {
+		t.Fatalf("Models() error = %v", err)
+	}
+	if len(models) != 3 {
+		t.Fatalf("expected 3 models, got %d", len(models))
+	}
+
+	gpt4o := models[0].Capabilities
+	if gpt4o == nil || !gpt4o.Vision || !gpt4o.Tools || gpt4o.MaxContext != 128000 {
+		t.Errorf("gpt-4o capabilities = %+v", gpt4o)
+	}
+
+	gpt35 := models[1].Capabilities
+	if gpt35 == nil || gpt35.Vision || gpt35.MaxContext != 16385 {
+		t.Errorf("gpt-3.5-turbo capabilities = %+v", gpt35)
+	}
+
+	if models[2].Capabilities != nil {
+		t.Errorf("embedding model should have no capabilities, got %+v", models[2].Capabilities)
+	}
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	tests := []struct {
+		model      string
+		wantNil    bool
+		maxContext int
+	}{
+		{"gpt-4o-mini", false, 128000},
+		{"gpt-4-turbo-2024-04-09", false, 128000},
+		{"gpt-4-32k", false, 32768},
+		{"gpt-4", false, 8192},
+		{"dall-e-3", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			caps := capabilitiesFor(tt.model)
+			if (caps == nil) != tt.wantNil {
+				t.Fatalf("capabilitiesFor(%q) = %+v, wantNil %v", tt.model, caps, tt.wantNil)
+			}
+			if caps != nil && caps.MaxContext != tt.maxContext {
+				t.Errorf("MaxContext = %d, want %d", caps.MaxContext, tt.maxContext)
+			}
+		})
+	}
+}
+
+func TestChatCompletion_RotatesKeyOn401(t *testing.T) {
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		seen = append(seen, auth)
+		if auth != "Bearer new-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[]}`))
+	}))
+	defer server.Close()
+
+	p := New("old-key, new-key", server.URL)
+	resp, err := p.ChatCompletion(context.Background(), domain.ChatRequest{Model: "gpt-4o"})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if resp.ID != "chatcmpl-1" {
+		t.Errorf("ID = %q, want chatcmpl-1", resp.ID)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer old-key" {
+		t.Errorf("unexpected auth sequence: %v", seen)
+	}
+}
+
+func TestChatCompletion_LogprobsRoundTrip(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{
+			"index":0,
+			"message":{"role":"assistant","content":"Hi"},
+			"logprobs":{"content":[{"token":"Hi","logprob":-0.01,"bytes":[72,105],
+				"top_logprobs":[{"token":"Hi","logprob":-0.01,"bytes":[72,105]},{"token":"Hello","logprob":-4.6,"bytes":null}]}]},
+			"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	topN := 2
+	resp, err := New("test-key", server.URL).ChatCompletion(context.Background(), domain.ChatRequest{
+		Model:       "gpt-4o",
+		Messages:    []domain.Message{{Role: "user", Content: "Hello"}},
+		Logprobs:    true,
+		TopLogprobs: &topN,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if sent["logprobs"] != true || sent["top_logprobs"] != float64(2) {
+		t.Errorf("request logprobs = %v, top_logprobs = %v", sent["logprobs"], sent["top_logprobs"])
+	}
+
+	lp := resp.Choices[0].Logprobs
+	if lp == nil || len(lp.Content) != 1 {
+		t.Fatalf("expected one logprob entry, got %+v", lp)
+	}
+	if lp.Content[0].Token != "Hi" || len(lp.Content[0].TopLogprobs) != 2 {
+		t.Errorf("unexpected logprobs: %+v", lp.Content[0])
+	}
+	if lp.Content[0].TopLogprobs[1].Logprob != -4.6 {
+		t.Errorf("top_logprobs[1].logprob = %v, want -4.6", lp.Content[0].TopLogprobs[1].Logprob)
+	}
+}
+
+func TestChatCompletion_PassesThroughStoreAndMetadata(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[]}`))
+	}))
+	defer server.Close()
+
+	_, err := New("test-key", server.URL).ChatCompletion(context.Background(), domain.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []domain.Message{{Role: "user", Content: "Hello"}},
+		Store:    true,
+		Metadata: map[string]string{"feature": "search"},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if sent["store"] != true {
+		t.Errorf("request store = %v, want true", sent["store"])
+	}
+	metadata, _ := sent["metadata"].(map[string]interface{})
+	if metadata["feature"] != "search" {
+		t.Errorf("request metadata = %v", sent["metadata"])
+	}
+}
+
+func TestChatCompletion_PassesThroughParallelToolCalls(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[]}`))
+	}))
+	defer server.Close()
+
+	p := New("test-key", server.URL)
+	req := domain.ChatRequest{Model: "gpt-4o", Messages: []domain.Message{{Role: "user", Content: "Hello"}}}
+	if _, err := p.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if _, ok := sent["parallel_tool_calls"]; ok {
+		t.Errorf("unset parallel_tool_calls was sent: %v", sent["parallel_tool_calls"])
+	}
+
+	off := false
+	req.ParallelToolCalls = &off
+	if _, err := p.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	if sent["parallel_tool_calls"] != false {
+		t.Errorf("request parallel_tool_calls = %v, want false", sent["parallel_tool_calls"])
+	}
+}
+
+func TestChatCompletionStream_ToolCallDeltas(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}` + "\n\n"))
+		w.Write([]byte(`data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":\"Paris\"}"}}]}}]}` + "\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	chunks, errs := New("test-key", server.URL).ChatCompletionStream(context.Background(), domain.ChatRequest{Model: "gpt-4o"})
+	var got []domain.StreamChunk
+	for c := range chunks {
+		got = append(got, c)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(got))
+	}
+
+	first := got[0].Choices[0].Delta.ToolCalls
+	if len(first) != 1 || first[0].ID != "call_1" || first[0].Function.Name != "get_weather" {
+		t.Errorf("unexpected first tool call fragment: %+v", first)
+	}
+	if args := got[1].Choices[0].Delta.ToolCalls[0].Function.Arguments; args != `{"city":"Paris"}` {
+		t.Errorf("arguments = %s", args)
+	}
+
+	// Re-encoding must keep the OpenAI wire shape clients reassemble from.
+	out, _ := json.Marshal(got[0].Choices[0].Delta)
+	want := `{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather"}}]}`
+	if string(out) != want {
+		t.Errorf("encoded delta = %s, want %s", out, want)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	client := &http.Client{}
+	if p := New("test-key", "http://example.invalid", WithHTTPClient(client)); p.client != client {
+		t.Error("WithHTTPClient should replace the default client")
+	}
+}
+
+func TestTimeouts_StreamingVsNonStreaming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req domain.ChatRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			time.Sleep(300 * time.Millisecond)
+			json.NewEncoder(w).Encode(domain.ChatResponse{ID: "late"})
+			return
+		}
+		// The stream outlasts the client timeout but never goes idle.
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 6; i++ {
+			fmt.Fprintf(w, "data: {\"id\":\"chunk-%d\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"x\"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	p := New("test-key", server.URL,
+		WithHTTPClient(httputil.NewClient(httputil.ClientConfig{Timeout: 100 * time.Millisecond})),
+		WithStreamIdleTimeout(100*time.Millisecond),
+	)
+
+	if _, err := p.ChatCompletion(context.Background(), domain.ChatRequest{Model: "gpt-4"}); err == nil {
+		t.Error("expected slow non-streaming call to time out")
+	}
+
+	chunks, errs := p.ChatCompletionStream(context.Background(), domain.ChatRequest{Model: "gpt-4"})
+	count := 0
+	for range chunks {
+		count++
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if count != 6 {
+		t.Errorf("chunks = %d, want 6", count)
+	}
+}
+
+func TestModerate(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moderations" || r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("request = %s %s, auth %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"modr-1","model":"omni-moderation-latest","results":[
+			{"flagged":true,"categories":{"violence":true,"hate":false},"category_scores":{"violence":0.91,"hate":0.01},
+			 "category_applied_input_types":{"violence":["text"]}}
+		]}`))
+	}))
+	defer server.Close()
+
+	resp, err := New("test-key", server.URL).Moderate(context.Background(), domain.ModerationRequest{Input: []any{"some text"}})
+	if err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+	if _, ok := got["model"]; ok {
+		t.Errorf("model sent though none was requested: %v", got)
+	}
+	if input, _ := got["input"].([]any); len(input) != 1 || input[0] != "some text" {
+		t.Errorf("input sent = %v", got["input"])
+	}
+	if resp.ID != "modr-1" || len(resp.Results) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	result := resp.Results[0]
+	if !result.Flagged || !result.Categories["violence"] || result.CategoryScores["violence"] != 0.91 || result.CategoryAppliedInputTypes["violence"][0] != "text" {
+		t.Errorf("result = %+v", result)
+	}
+}
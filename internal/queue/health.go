@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const defaultHealthCheckTimeout = 3 * time.Second
+
+// queueAttributesAPI is the subset of the SQS client used by the health check.
+type queueAttributesAPI interface {
+	GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+}
+
+// SQSHealthChecker verifies that the configured queues exist and are
+// reachable with the gateway's credentials.
+type SQSHealthChecker struct {
+	client    queueAttributesAPI
+	queueURLs []string
+	timeout   time.Duration
+}
+
+// NewSQSHealthChecker creates a health checker for the given queue URLs.
+// Empty URLs are ignored.
+func NewSQSHealthChecker(cfg aws.Config, queueURLs ...string) *SQSHealthChecker {
+	return newSQSHealthChecker(sqs.NewFromConfig(cfg), queueURLs...)
+}
+
+func newSQSHealthChecker(client queueAttributesAPI, queueURLs ...string) *SQSHealthChecker {
+	urls := make([]string, 0, len(queueURLs))
+	for _, u := range queueURLs {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return &SQSHealthChecker{
+		client:    client,
+		queueURLs: urls,
+		timeout:   defaultHealthCheckTimeout,
+	}
+}
+
+func (c *SQSHealthChecker) Name() string {
+	return "sqs"
+}
+
+func (c *SQSHealthChecker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	for _, url := range c.queueURLs {
+		_, err := c.client.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(url),
+			AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+		})
+		if err != nil {
+			return fmt.Errorf("get queue attributes %s: %w", url, err)
+		}
+	}
+
+	return nil
+}
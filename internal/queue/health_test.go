@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+type mockQueueAttributes struct {
+	failURL string
+	calls   []string
+	delay   time.Duration
+}
+
+func (m *mockQueueAttributes) GetQueueAttributes(ctx context.Context, params *sqs.GetQueueAttributesInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error) {
+	url := aws.ToString(params.QueueUrl)
+	m.calls = append(m.calls, url)
+
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if url == m.failURL {
+		return nil, errors.New("AWS.SimpleQueueService.NonExistentQueue")
+	}
+	return &sqs.GetQueueAttributesOutput{}, nil
+}
+
+func TestSQSHealthChecker_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		failURL string
+		wantErr bool
+	}{
+		{"all queues reachable", "", false},
+		{"missing response queue", "https://sqs/responses", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockQueueAttributes{failURL: tt.failURL}
+			checker := newSQSHealthChecker(client, "https://sqs/requests", "", "https://sqs/responses")
+
+			err := checker.Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(client.calls) != 2 {
+				t.Errorf("expected 2 queues checked (empty URL skipped), got %v", client.calls)
+			}
+		})
+	}
+}
+
+func TestSQSHealthChecker_Timeout(t *testing.T) {
+	client := &mockQueueAttributes{delay: time.Second}
+	checker := newSQSHealthChecker(client, "https://sqs/requests")
+	checker.timeout = 10 * time.Millisecond
+
+	err := checker.Check(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check() error = %v, want deadline exceeded", err)
+	}
+}
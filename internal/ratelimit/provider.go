@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+)
+
+// ErrProviderThrottled is returned when a provider's outbound limit is
+// exhausted and the request cannot wait for capacity.
+var ErrProviderThrottled = errors.New("provider rate limit exceeded")
+
+// ProviderLimiter caps the outbound request rate to each upstream provider,
+// independent of tenant, so a traffic spike cannot exhaust an org-level quota.
+// Each provider gets a token bucket that refills at its RPM and holds at most
+// one minute's worth of tokens. Providers without a limit are not throttled.
+type ProviderLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	maxWait time.Duration
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	capacity float64
+	perSec   float64
+	tokens   float64
+	last     time.Time
+}
+
+// ProviderLimiterOption configures a ProviderLimiter.
+type ProviderLimiterOption func(*ProviderLimiter)
+
+// WithMaxWait lets requests queue for up to d waiting for capacity instead of
+// being rejected immediately.
+func WithMaxWait(d time.Duration) ProviderLimiterOption {
+	return func(l *ProviderLimiter) {
+		l.maxWait = d
+	}
+}
+
+// NewProviderLimiter creates a limiter from a provider ID to requests-per-minute
+// map. Non-positive limits are ignored.
+func NewProviderLimiter(rpm map[string]int, opts ...ProviderLimiterOption) *ProviderLimiter {
+	l := &ProviderLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	now := l.now()
+	for provider, limit := range rpm {
+		if limit <= 0 {
+			continue
+		}
+		l.buckets[provider] = &tokenBucket{
+			capacity: float64(limit),
+			perSec:   float64(limit) / 60,
+			tokens:   float64(limit),
+			last:     now,
+		}
+	}
+
+	return l
+}
+
+// Wait takes a token for provider, blocking up to the configured max wait.
+// It returns ErrProviderThrottled if capacity will not be available in time,
+// or the context error if ctx ends while waiting.
+func (l *ProviderLimiter) Wait(ctx context.Context, provider string) error {
+	delay, ok := l.reserve(provider)
+	if !ok {
+		metrics.RecordProviderThrottled(provider)
+		return ErrProviderThrottled
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release(provider)
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, possibly borrowing against future refill, and
+// returns how long the caller must wait before using it.
+func (l *ProviderLimiter) reserve(provider string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[provider]
+	if !ok {
+		return 0, true
+	}
+
+	now := l.now()
+	b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.perSec)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	delay := time.Duration((1 - b.tokens) / b.perSec * float64(time.Second))
+	if delay > l.maxWait {
+		return 0, false
+	}
+	b.tokens--
+	return delay, true
+}
+
+// release returns a token reserved by a caller that gave up waiting.
+func (l *ProviderLimiter) release(provider string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[provider]; ok {
+		b.tokens = min(b.capacity, b.tokens+1)
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProviderLimiter_CapsRequests(t *testing.T) {
+	metrics.ProviderThrottled.Reset()
+	l := NewProviderLimiter(map[string]int{"openai": 3})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "openai"); err != nil {
+			t.Fatalf("request %d: unexpected error %v", i+1, err)
+		}
+	}
+
+	if err := l.Wait(ctx, "openai"); !errors.Is(err, ErrProviderThrottled) {
+		t.Errorf("4th request: error = %v, want ErrProviderThrottled", err)
+	}
+
+	if got := testutil.ToFloat64(metrics.ProviderThrottled.WithLabelValues("openai")); got != 1 {
+		t.Errorf("throttled count = %v, want 1", got)
+	}
+}
+
+func TestProviderLimiter_UnlimitedProvider(t *testing.T) {
+	l := NewProviderLimiter(map[string]int{"openai": 1, "ollama": 0})
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(ctx, "ollama"); err != nil {
+			t.Fatalf("ollama should not be limited: %v", err)
+		}
+		if err := l.Wait(ctx, "anthropic"); err != nil {
+			t.Fatalf("unconfigured provider should not be limited: %v", err)
+		}
+	}
+}
+
+func TestProviderLimiter_Refills(t *testing.T) {
+	now := time.Now()
+	l := NewProviderLimiter(map[string]int{"openai": 60})
+	l.now = func() time.Time { return now }
+	l.buckets["openai"].last = now
+	ctx := context.Background()
+
+	for i := 0; i < 60; i++ {
+		if err := l.Wait(ctx, "openai"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	if err := l.Wait(ctx, "openai"); !errors.Is(err, ErrProviderThrottled) {
+		t.Fatalf("expected bucket to be empty, got %v", err)
+	}
+
+	// 60 RPM refills one token per second.
+	now = now.Add(time.Second)
+	if err := l.Wait(ctx, "openai"); err != nil {
+		t.Errorf("expected a token after 1s, got %v", err)
+	}
+}
+
+func TestProviderLimiter_QueuesWithinMaxWait(t *testing.T) {
+	// 600 RPM = one token every 100ms.
+	l := NewProviderLimiter(map[string]int{"openai": 600}, WithMaxWait(time.Second))
+	l.buckets["openai"].tokens = 0
+	ctx := context.Background()
+
+	start := time.Now()
+	if err := l.Wait(ctx, "openai"); err != nil {
+		t.Fatalf("expected request to queue, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("waited %v, expected to queue for a refill", waited)
+	}
+}
+
+func TestProviderLimiter_WaitRespectsContext(t *testing.T) {
+	l := NewProviderLimiter(map[string]int{"openai": 1}, WithMaxWait(time.Minute))
+	l.buckets["openai"].tokens = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx, "openai"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want deadline exceeded", err)
+	}
+	if l.buckets["openai"].tokens < -0.01 {
+		t.Errorf("abandoned reservation should be released, tokens = %v", l.buckets["openai"].tokens)
+	}
+}
@@ -15,11 +15,20 @@ type RateLimiter interface {
 	Allow(ctx context.Context, tenantID string, limit int) (allowed bool, remaining int, resetAt time.Time, err error)
 }
 
+// DefaultSweepInterval is how often the in-memory limiter drops windows that
+// have expired.
+const DefaultSweepInterval = time.Minute
+
 // InMemoryRateLimiter implements rate limiting using in-memory sliding windows.
-// Suitable for single-instance deployments.
+// Suitable for single-instance deployments. A background sweep removes the
+// windows of tenants that have gone idle so they do not accumulate.
 type InMemoryRateLimiter struct {
 	mu      sync.Mutex
 	windows map[string]*window
+
+	sweepInterval time.Duration
+	stop          chan struct{}
+	stopOnce      sync.Once
 }
 
 type window struct {
@@ -27,12 +36,71 @@ type window struct {
 	resetAt time.Time
 }
 
-func NewInMemoryRateLimiter() *InMemoryRateLimiter {
-	return &InMemoryRateLimiter{
-		windows: make(map[string]*window),
+// InMemoryOption configures an InMemoryRateLimiter.
+type InMemoryOption func(*InMemoryRateLimiter)
+
+// WithSweepInterval sets how often expired windows are removed. Zero or a
+// negative interval disables the sweep.
+func WithSweepInterval(d time.Duration) InMemoryOption {
+	return func(r *InMemoryRateLimiter) {
+		r.sweepInterval = d
+	}
+}
+
+func NewInMemoryRateLimiter(opts ...InMemoryOption) *InMemoryRateLimiter {
+	r := &InMemoryRateLimiter{
+		windows:       make(map[string]*window),
+		sweepInterval: DefaultSweepInterval,
+		stop:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.sweepInterval > 0 {
+		go r.sweep()
+	}
+	return r
+}
+
+// Stop ends the background sweep.
+func (r *InMemoryRateLimiter) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+func (r *InMemoryRateLimiter) sweep() {
+	ticker := time.NewTicker(r.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			r.removeExpired(now)
+		}
 	}
 }
 
+// removeExpired deletes windows whose reset time has passed. Allow would
+// replace them with a fresh window anyway, so nothing is lost.
+func (r *InMemoryRateLimiter) removeExpired(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tenantID, w := range r.windows {
+		if now.After(w.resetAt) {
+			delete(r.windows, tenantID)
+		}
+	}
+}
+
+// size returns the number of tracked tenant windows.
+func (r *InMemoryRateLimiter) size() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.windows)
+}
+
 func (r *InMemoryRateLimiter) Allow(ctx context.Context, tenantID string, limit int) (bool, int, time.Time, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -6,6 +6,41 @@ import (
 	"time"
 )
 
+func TestInMemoryRateLimiter_SweepRemovesIdleTenants(t *testing.T) {
+	rl := NewInMemoryRateLimiter(WithSweepInterval(10 * time.Millisecond))
+	defer rl.Stop()
+
+	rl.Allow(context.Background(), "idle-tenant", 10)
+	if rl.size() != 1 {
+		t.Fatalf("expected 1 window, got %d", rl.size())
+	}
+
+	// Age the window past its reset instead of waiting out the minute.
+	rl.mu.Lock()
+	rl.windows["idle-tenant"].resetAt = time.Now().Add(-time.Second)
+	rl.mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for rl.size() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle tenant window was not swept")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInMemoryRateLimiter_SweepKeepsActiveWindows(t *testing.T) {
+	rl := NewInMemoryRateLimiter(WithSweepInterval(0))
+
+	rl.Allow(context.Background(), "active-tenant", 2)
+	rl.removeExpired(time.Now())
+
+	allowed, remaining, _, _ := rl.Allow(context.Background(), "active-tenant", 2)
+	if !allowed || remaining != 0 {
+		t.Errorf("active window should survive the sweep, got allowed=%v remaining=%d", allowed, remaining)
+	}
+}
+
 func TestInMemoryRateLimiter_Allow(t *testing.T) {
 	rl := NewInMemoryRateLimiter()
 	ctx := context.Background()
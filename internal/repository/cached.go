@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// InvalidationBus broadcasts tenant cache invalidations between gateway
+// instances. An empty tenant ID invalidates every cached tenant.
+type InvalidationBus interface {
+	Publish(ctx context.Context, tenantID string) error
+	// Subscribe calls handler for every invalidation until ctx is done.
+	Subscribe(ctx context.Context, handler func(tenantID string)) error
+}
+
+// Reloader is implemented by repositories that hold tenants in memory and
+// can be told to drop them, e.g. after an out-of-band database change.
+type Reloader interface {
+	Reload(ctx context.Context) error
+}
+
+// CachedTenantRepository caches API key lookups in front of another
+// repository. Entries expire after the TTL, so changes made directly in the
+// database are picked up within that window. Writes through this repository
+// and calls to Reload invalidate immediately and, with an InvalidationBus,
+// on every other instance too.
+type CachedTenantRepository struct {
+	TenantRepository
+
+	ttl time.Duration
+	bus InvalidationBus
+
+	mu      sync.RWMutex
+	entries map[string]cachedTenant // keyed by API key hash
+	// generation counts invalidations. A lookup only caches its result if
+	// no invalidation happened while it read the underlying repository, so
+	// a tenant revoked mid-lookup is not cached again.
+	generation uint64
+}
+
+type cachedTenant struct {
+	tenant    *domain.Tenant
+	expiresAt time.Time
+}
+
+// CacheOption configures a CachedTenantRepository.
+type CacheOption func(*CachedTenantRepository)
+
+// WithInvalidationBus propagates invalidations to other instances.
+func WithInvalidationBus(bus InvalidationBus) CacheOption {
+	return func(c *CachedTenantRepository) {
+		c.bus = bus
+	}
+}
+
+func NewCachedTenantRepository(inner TenantRepository, ttl time.Duration, opts ...CacheOption) *CachedTenantRepository {
+	c := &CachedTenantRepository{
+		TenantRepository: inner,
+		ttl:              ttl,
+		entries:          make(map[string]cachedTenant),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Start listens for invalidations from other instances until ctx is done.
+// It is a no-op without an InvalidationBus.
+func (c *CachedTenantRepository) Start(ctx context.Context) {
+	if c.bus == nil {
+		return
+	}
+	go func() {
+		if err := c.bus.Subscribe(ctx, c.invalidate); err != nil && ctx.Err() == nil {
+			slog.Error("tenant invalidation subscription ended", "error", err)
+		}
+	}()
+}
+
+func (c *CachedTenantRepository) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+	hash := hashAPIKey(apiKey)
+
+	c.mu.RLock()
+	entry, ok := c.entries[hash]
+	generation := c.generation
+	c.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.tenant, nil
+	}
+
+	// Misses are not cached so a newly issued key works immediately.
+	tenant, err := c.TenantRepository.GetByAPIKey(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.entries[hash] = cachedTenant{tenant: tenant, expiresAt: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return tenant, nil
+}
+
+func (c *CachedTenantRepository) Create(ctx context.Context, tenant *domain.Tenant) error {
+	if err := c.TenantRepository.Create(ctx, tenant); err != nil {
+		return err
+	}
+	c.broadcast(ctx, tenant.ID)
+	return nil
+}
+
+func (c *CachedTenantRepository) Update(ctx context.Context, tenant *domain.Tenant) error {
+	if err := c.TenantRepository.Update(ctx, tenant); err != nil {
+		return err
+	}
+	c.broadcast(ctx, tenant.ID)
+	return nil
+}
+
+func (c *CachedTenantRepository) Delete(ctx context.Context, id string) error {
+	if err := c.TenantRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.broadcast(ctx, id)
+	return nil
+}
+
+// Reload drops every cached tenant on this and all other instances.
+func (c *CachedTenantRepository) Reload(ctx context.Context) error {
+	c.broadcast(ctx, "")
+	return nil
+}
+
+// broadcast invalidates locally first so this instance is consistent even
+// if publishing fails.
+func (c *CachedTenantRepository) broadcast(ctx context.Context, tenantID string) {
+	c.invalidate(tenantID)
+	if c.bus == nil {
+		return
+	}
+	if err := c.bus.Publish(ctx, tenantID); err != nil {
+		slog.Warn("failed to publish tenant invalidation", "error", err, "tenant_id", tenantID)
+	}
+}
+
+func (c *CachedTenantRepository) invalidate(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	if tenantID == "" {
+		c.entries = make(map[string]cachedTenant)
+		return
+	}
+	for hash, entry := range c.entries {
+		if entry.tenant.ID == tenantID {
+			delete(c.entries, hash)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// countingRepo counts lookups that reach the underlying repository.
+type countingRepo struct {
+	TenantRepository
+	lookups int
+}
+
+func (r *countingRepo) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+	r.lookups++
+	return r.TenantRepository.GetByAPIKey(ctx, apiKey)
+}
+
+// invalidatingRepo invalidates the cache while a lookup is in flight, as an
+// update on another instance would.
+type invalidatingRepo struct {
+	TenantRepository
+	cache *CachedTenantRepository
+}
+
+func (r *invalidatingRepo) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+	tenant, err := r.TenantRepository.GetByAPIKey(ctx, apiKey)
+	r.cache.invalidate(tenant.ID)
+	return tenant, err
+}
+
+// memoryBus delivers invalidations synchronously to every subscriber.
+type memoryBus struct {
+	mu         sync.Mutex
+	handlers   []func(string)
+	subscribed chan struct{}
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{subscribed: make(chan struct{}, 8)}
+}
+
+func (b *memoryBus) Publish(ctx context.Context, tenantID string) error {
+	b.mu.Lock()
+	handlers := append([]func(string){}, b.handlers...)
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(tenantID)
+	}
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, handler func(string)) error {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.mu.Unlock()
+	b.subscribed <- struct{}{}
+	<-ctx.Done()
+	return nil
+}
+
+func TestCachedTenantRepository_CachesLookups(t *testing.T) {
+	inner := &countingRepo{TenantRepository: NewInMemoryTenantRepository()}
+	repo := NewCachedTenantRepository(inner, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := repo.GetByAPIKey(ctx, "gw-default-key"); err != nil {
+			t.Fatalf("GetByAPIKey() error = %v", err)
+		}
+	}
+	if inner.lookups != 1 {
+		t.Errorf("expected 1 backend lookup, got %d", inner.lookups)
+	}
+
+	if _, err := repo.GetByAPIKey(ctx, "unknown"); err != domain.ErrTenantNotFound {
+		t.Errorf("expected ErrTenantNotFound, got %v", err)
+	}
+}
+
+func TestCachedTenantRepository_ExpiresAfterTTL(t *testing.T) {
+	inner := &countingRepo{TenantRepository: NewInMemoryTenantRepository()}
+	repo := NewCachedTenantRepository(inner, 10*time.Millisecond)
+	ctx := context.Background()
+
+	repo.GetByAPIKey(ctx, "gw-default-key")
+	time.Sleep(20 * time.Millisecond)
+	repo.GetByAPIKey(ctx, "gw-default-key")
+
+	if inner.lookups != 2 {
+		t.Errorf("expected expired entry to be refetched, got %d lookups", inner.lookups)
+	}
+}
+
+func TestCachedTenantRepository_InvalidatesOnUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two instances sharing one database and one bus.
+	db := NewInMemoryTenantRepository()
+	bus := newMemoryBus()
+	a := NewCachedTenantRepository(db, time.Hour, WithInvalidationBus(bus))
+	b := NewCachedTenantRepository(db, time.Hour, WithInvalidationBus(bus))
+	a.Start(ctx)
+	b.Start(ctx)
+	<-bus.subscribed
+	<-bus.subscribed
+
+	for _, repo := range []*CachedTenantRepository{a, b} {
+		if _, err := repo.GetByAPIKey(ctx, "gw-default-key"); err != nil {
+			t.Fatalf("GetByAPIKey() error = %v", err)
+		}
+	}
+
+	// Rotate the key on instance a; the old key must stop working on b too.
+	tenant, _ := db.GetByID(ctx, "default")
+	rotated := *tenant
+	rotated.APIKey = "gw-rotated-key"
+	if err := a.Update(ctx, &rotated); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	for name, repo := range map[string]*CachedTenantRepository{"a": a, "b": b} {
+		if _, err := repo.GetByAPIKey(ctx, "gw-default-key"); err != domain.ErrTenantNotFound {
+			t.Errorf("instance %s: old key still accepted (err = %v)", name, err)
+		}
+		if _, err := repo.GetByAPIKey(ctx, "gw-rotated-key"); err != nil {
+			t.Errorf("instance %s: new key rejected: %v", name, err)
+		}
+	}
+}
+
+func TestCachedTenantRepository_Reload(t *testing.T) {
+	db := NewInMemoryTenantRepository()
+	repo := NewCachedTenantRepository(db, time.Hour)
+	ctx := context.Background()
+
+	repo.GetByAPIKey(ctx, "gw-default-key")
+
+	// Change the tenant behind the cache's back, as a direct SQL update would.
+	tenant, _ := db.GetByID(ctx, "default")
+	updated := *tenant
+	updated.APIKey = "gw-default-key"
+	updated.Name = "changed"
+	db.Update(ctx, &updated)
+
+	if got, _ := repo.GetByAPIKey(ctx, "gw-default-key"); got.Name == "changed" {
+		t.Fatal("expected stale entry before reload")
+	}
+
+	if err := repo.Reload(ctx); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, _ := repo.GetByAPIKey(ctx, "gw-default-key"); got == nil || got.Name != "changed" {
+		t.Errorf("expected change to be visible after reload, got %+v", got)
+	}
+}
+
+func TestCachedTenantRepository_SkipsCachingAfterConcurrentInvalidation(t *testing.T) {
+	inner := &invalidatingRepo{TenantRepository: NewInMemoryTenantRepository()}
+	repo := NewCachedTenantRepository(inner, time.Minute)
+	inner.cache = repo
+
+	if _, err := repo.GetByAPIKey(context.Background(), "gw-default-key"); err != nil {
+		t.Fatalf("GetByAPIKey() error = %v", err)
+	}
+
+	repo.mu.RLock()
+	cached := len(repo.entries)
+	repo.mu.RUnlock()
+	if cached != 0 {
+		t.Errorf("expected lookup invalidated mid-flight not to be cached, got %d entries", cached)
+	}
+}
@@ -3,91 +3,207 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/crypto"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 	"github.com/lib/pq"
 )
 
+const tenantColumns = `id, name, api_key_hash, budget_usd, budget_period, rate_limit_rpm,
+		       allowed_models, default_provider, fallback_providers, provider_keys, transform_rules, pricing_overrides, allowed_providers, scopes, sampling_defaults, webhook_url, webhook_secret, default_model, max_stream_seconds, enabled, created_at, updated_at`
+
 type PostgresTenantRepository struct {
-	db *sql.DB
+	db        *sql.DB
+	encryptor *crypto.Encryptor
 }
 
-func NewPostgresTenantRepository(db *sql.DB) *PostgresTenantRepository {
-	return &PostgresTenantRepository{db: db}
+func NewPostgresTenantRepository(db *sql.DB, opts ...Option) *PostgresTenantRepository {
+	o := applyOptions(opts)
+	return &PostgresTenantRepository{db: db, encryptor: o.encryptor}
 }
 
-func (r *PostgresTenantRepository) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
-	hash := hashAPIKey(apiKey)
+// CheckEncryption fails with ErrEncryptionRequired when no encryptor is
+// configured but some tenant already has encrypted provider keys or a webhook
+// secret, which could then not be read. Call it at startup so a missing
+// ENCRYPTION_KEY is reported before the first request for such a tenant.
+func (r *PostgresTenantRepository) CheckEncryption(ctx context.Context) error {
+	if r.encryptor != nil {
+		return nil
+	}
 
-	query := `
-		SELECT id, name, api_key_hash, budget_usd, rate_limit_rpm, 
-		       allowed_models, default_provider, fallback_providers, enabled, created_at, updated_at
-		FROM tenants
-		WHERE api_key_hash = $1 AND enabled = true
-	`
+	var stored bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM tenants
+			WHERE (provider_keys IS NOT NULL AND provider_keys <> '{}'::jsonb)
+			   OR COALESCE(webhook_secret, '') <> ''
+		)`).Scan(&stored)
+	if err != nil {
+		return fmt.Errorf("check stored secrets: %w", err)
+	}
+	if stored {
+		return ErrEncryptionRequired
+	}
+	return nil
+}
+
+// uniqueViolation is the Postgres error code for a unique constraint
+// violation.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err is a Postgres unique constraint
+// violation, e.g. from inserting a row whose key is already taken.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
 
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *PostgresTenantRepository) scanTenant(row rowScanner) (*domain.Tenant, error) {
 	var tenant domain.Tenant
-	var allowedModels, fallbackProviders pq.StringArray
-	var defaultProvider sql.NullString
+	var allowedModels, fallbackProviders, allowedProviders, scopes pq.StringArray
+	var defaultProvider, budgetPeriod, webhookURL, webhookSecret, defaultModel sql.NullString
+	var providerKeys, transformRules, pricingOverrides, samplingDefaults []byte
 
-	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+	err := row.Scan(
 		&tenant.ID,
 		&tenant.Name,
 		&tenant.APIKeyHash,
 		&tenant.BudgetUSD,
+		&budgetPeriod,
 		&tenant.RateLimitRPM,
 		&allowedModels,
 		&defaultProvider,
 		&fallbackProviders,
+		&providerKeys,
+		&transformRules,
+		&pricingOverrides,
+		&allowedProviders,
+		&scopes,
+		&samplingDefaults,
+		&webhookURL,
+		&webhookSecret,
+		&defaultModel,
+		&tenant.MaxStreamSeconds,
 		&tenant.Enabled,
 		&tenant.CreatedAt,
 		&tenant.UpdatedAt,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, domain.ErrTenantNotFound
-	}
 	if err != nil {
-		return nil, fmt.Errorf("query tenant: %w", err)
+		return nil, err
 	}
 
 	tenant.AllowedModels = []string(allowedModels)
 	tenant.FallbackProviders = []string(fallbackProviders)
+	tenant.AllowedProviders = []string(allowedProviders)
+	tenant.Scopes = []string(scopes)
 	if defaultProvider.Valid {
 		tenant.DefaultProvider = defaultProvider.String
 	}
+	if budgetPeriod.Valid {
+		tenant.BudgetPeriod = domain.BudgetPeriod(budgetPeriod.String)
+	}
+	if webhookURL.Valid {
+		tenant.WebhookURL = webhookURL.String
+	}
+	if defaultModel.Valid {
+		tenant.DefaultModel = defaultModel.String
+	}
+	if webhookSecret.Valid {
+		tenant.WebhookSecret, err = decryptSecret(r.encryptor, webhookSecret.String)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt webhook secret: %w", err)
+		}
+	}
+
+	if len(providerKeys) > 0 {
+		var encrypted map[string]string
+		if err := json.Unmarshal(providerKeys, &encrypted); err != nil {
+			return nil, fmt.Errorf("decode provider keys: %w", err)
+		}
+		tenant.ProviderKeys, err = decryptProviderKeys(r.encryptor, encrypted)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(transformRules) > 0 {
+		if err := json.Unmarshal(transformRules, &tenant.TransformRules); err != nil {
+			return nil, fmt.Errorf("decode transform rules: %w", err)
+		}
+	}
+
+	if len(pricingOverrides) > 0 {
+		if err := json.Unmarshal(pricingOverrides, &tenant.PricingOverrides); err != nil {
+			return nil, fmt.Errorf("decode pricing overrides: %w", err)
+		}
+	}
+
+	if len(samplingDefaults) > 0 {
+		if err := json.Unmarshal(samplingDefaults, &tenant.SamplingDefaults); err != nil {
+			return nil, fmt.Errorf("decode sampling defaults: %w", err)
+		}
+	}
 
 	return &tenant, nil
 }
 
-func (r *PostgresTenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
+// marshalProviderKeys encrypts the tenant's provider keys and encodes them
+// for the provider_keys JSONB column.
+func (r *PostgresTenantRepository) marshalProviderKeys(tenant *domain.Tenant) ([]byte, error) {
+	encrypted, err := encryptProviderKeys(r.encryptor, tenant.ProviderKeys)
+	if err != nil {
+		return nil, err
+	}
+	if encrypted == nil {
+		encrypted = map[string]string{}
+	}
+	return json.Marshal(encrypted)
+}
+
+func marshalTransformRules(tenant *domain.Tenant) ([]byte, error) {
+	rules := tenant.TransformRules
+	if rules == nil {
+		rules = []domain.TransformRule{}
+	}
+	return json.Marshal(rules)
+}
+
+func marshalPricingOverrides(tenant *domain.Tenant) ([]byte, error) {
+	overrides := tenant.PricingOverrides
+	if overrides == nil {
+		overrides = map[string]domain.ModelPrice{}
+	}
+	return json.Marshal(overrides)
+}
+
+// marshalSamplingDefaults encodes the tenant's sampling defaults, storing
+// NULL when there are none.
+func marshalSamplingDefaults(tenant *domain.Tenant) ([]byte, error) {
+	if tenant.SamplingDefaults == nil {
+		return nil, nil
+	}
+	return json.Marshal(tenant.SamplingDefaults)
+}
+
+func (r *PostgresTenantRepository) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
+	hash := hashAPIKey(apiKey)
+
 	query := `
-		SELECT id, name, api_key_hash, budget_usd, rate_limit_rpm, 
-		       allowed_models, default_provider, fallback_providers, enabled, created_at, updated_at
+		SELECT ` + tenantColumns + `
 		FROM tenants
-		WHERE id = $1
+		WHERE api_key_hash = $1 AND enabled = true
 	`
 
-	var tenant domain.Tenant
-	var allowedModels, fallbackProviders pq.StringArray
-	var defaultProvider sql.NullString
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&tenant.ID,
-		&tenant.Name,
-		&tenant.APIKeyHash,
-		&tenant.BudgetUSD,
-		&tenant.RateLimitRPM,
-		&allowedModels,
-		&defaultProvider,
-		&fallbackProviders,
-		&tenant.Enabled,
-		&tenant.CreatedAt,
-		&tenant.UpdatedAt,
-	)
-
+	tenant, err := r.scanTenant(r.db.QueryRowContext(ctx, query, hash))
 	if err == sql.ErrNoRows {
 		return nil, domain.ErrTenantNotFound
 	}
@@ -95,19 +211,30 @@ func (r *PostgresTenantRepository) GetByID(ctx context.Context, id string) (*dom
 		return nil, fmt.Errorf("query tenant: %w", err)
 	}
 
-	tenant.AllowedModels = []string(allowedModels)
-	tenant.FallbackProviders = []string(fallbackProviders)
-	if defaultProvider.Valid {
-		tenant.DefaultProvider = defaultProvider.String
+	return tenant, nil
+}
+
+func (r *PostgresTenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
+	query := `
+		SELECT ` + tenantColumns + `
+		FROM tenants
+		WHERE id = $1
+	`
+
+	tenant, err := r.scanTenant(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query tenant: %w", err)
 	}
 
-	return &tenant, nil
+	return tenant, nil
 }
 
 func (r *PostgresTenantRepository) List(ctx context.Context) ([]*domain.Tenant, error) {
 	query := `
-		SELECT id, name, api_key_hash, budget_usd, rate_limit_rpm, 
-		       allowed_models, default_provider, fallback_providers, enabled, created_at, updated_at
+		SELECT ` + tenantColumns + `
 		FROM tenants
 		ORDER BY created_at DESC
 	`
@@ -120,60 +247,72 @@ func (r *PostgresTenantRepository) List(ctx context.Context) ([]*domain.Tenant,
 
 	var tenants []*domain.Tenant
 	for rows.Next() {
-		var tenant domain.Tenant
-		var allowedModels, fallbackProviders pq.StringArray
-		var defaultProvider sql.NullString
-
-		err := rows.Scan(
-			&tenant.ID,
-			&tenant.Name,
-			&tenant.APIKeyHash,
-			&tenant.BudgetUSD,
-			&tenant.RateLimitRPM,
-			&allowedModels,
-			&defaultProvider,
-			&fallbackProviders,
-			&tenant.Enabled,
-			&tenant.CreatedAt,
-			&tenant.UpdatedAt,
-		)
+		tenant, err := r.scanTenant(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scan tenant: %w", err)
 		}
-
-		tenant.AllowedModels = []string(allowedModels)
-		tenant.FallbackProviders = []string(fallbackProviders)
-		if defaultProvider.Valid {
-			tenant.DefaultProvider = defaultProvider.String
-		}
-
-		tenants = append(tenants, &tenant)
+		tenants = append(tenants, tenant)
 	}
 
 	return tenants, rows.Err()
 }
 
 func (r *PostgresTenantRepository) Create(ctx context.Context, tenant *domain.Tenant) error {
+	providerKeys, err := r.marshalProviderKeys(tenant)
+	if err != nil {
+		return err
+	}
+	transformRules, err := marshalTransformRules(tenant)
+	if err != nil {
+		return fmt.Errorf("encode transform rules: %w", err)
+	}
+	pricingOverrides, err := marshalPricingOverrides(tenant)
+	if err != nil {
+		return fmt.Errorf("encode pricing overrides: %w", err)
+	}
+	samplingDefaults, err := marshalSamplingDefaults(tenant)
+	if err != nil {
+		return fmt.Errorf("encode sampling defaults: %w", err)
+	}
+	webhookSecret, err := encryptSecret(r.encryptor, tenant.WebhookSecret)
+	if err != nil {
+		return fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+
 	query := `
-		INSERT INTO tenants (id, name, api_key_hash, budget_usd, rate_limit_rpm, 
-		                     allowed_models, default_provider, fallback_providers, enabled, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO tenants (id, name, api_key_hash, budget_usd, budget_period, rate_limit_rpm, 
+		                     allowed_models, default_provider, fallback_providers, provider_keys, transform_rules, pricing_overrides, allowed_providers, scopes, sampling_defaults, webhook_url, webhook_secret, default_model, max_stream_seconds, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		tenant.ID,
 		tenant.Name,
 		tenant.APIKeyHash,
 		tenant.BudgetUSD,
+		sql.NullString{String: string(tenant.BudgetPeriod), Valid: tenant.BudgetPeriod != ""},
 		tenant.RateLimitRPM,
 		pq.Array(tenant.AllowedModels),
 		sql.NullString{String: tenant.DefaultProvider, Valid: tenant.DefaultProvider != ""},
 		pq.Array(tenant.FallbackProviders),
+		providerKeys,
+		transformRules,
+		pricingOverrides,
+		pq.Array(tenant.AllowedProviders),
+		pq.Array(tenant.Scopes),
+		samplingDefaults,
+		sql.NullString{String: tenant.WebhookURL, Valid: tenant.WebhookURL != ""},
+		sql.NullString{String: webhookSecret, Valid: webhookSecret != ""},
+		sql.NullString{String: tenant.DefaultModel, Valid: tenant.DefaultModel != ""},
+		tenant.MaxStreamSeconds,
 		tenant.Enabled,
 		tenant.CreatedAt,
 		tenant.UpdatedAt,
 	)
 
+	if IsUniqueViolation(err) {
+		return fmt.Errorf("insert tenant: %w", domain.ErrTenantExists)
+	}
 	if err != nil {
 		return fmt.Errorf("insert tenant: %w", err)
 	}
@@ -182,11 +321,33 @@ func (r *PostgresTenantRepository) Create(ctx context.Context, tenant *domain.Te
 }
 
 func (r *PostgresTenantRepository) Update(ctx context.Context, tenant *domain.Tenant) error {
+	providerKeys, err := r.marshalProviderKeys(tenant)
+	if err != nil {
+		return err
+	}
+	transformRules, err := marshalTransformRules(tenant)
+	if err != nil {
+		return fmt.Errorf("encode transform rules: %w", err)
+	}
+	pricingOverrides, err := marshalPricingOverrides(tenant)
+	if err != nil {
+		return fmt.Errorf("encode pricing overrides: %w", err)
+	}
+	samplingDefaults, err := marshalSamplingDefaults(tenant)
+	if err != nil {
+		return fmt.Errorf("encode sampling defaults: %w", err)
+	}
+	webhookSecret, err := encryptSecret(r.encryptor, tenant.WebhookSecret)
+	if err != nil {
+		return fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+
 	query := `
 		UPDATE tenants
-		SET name = $2, api_key_hash = $3, budget_usd = $4, rate_limit_rpm = $5,
-		    allowed_models = $6, default_provider = $7, fallback_providers = $8, 
-		    enabled = $9, updated_at = $10
+		SET name = $2, api_key_hash = $3, budget_usd = $4, budget_period = $5, rate_limit_rpm = $6,
+		    allowed_models = $7, default_provider = $8, fallback_providers = $9, 
+		    provider_keys = $10, transform_rules = $11, pricing_overrides = $12, allowed_providers = $13,
+		    scopes = $14, sampling_defaults = $15, webhook_url = $16, webhook_secret = $17, default_model = $18, max_stream_seconds = $19, enabled = $20, updated_at = $21
 		WHERE id = $1
 	`
 
@@ -195,14 +356,28 @@ func (r *PostgresTenantRepository) Update(ctx context.Context, tenant *domain.Te
 		tenant.Name,
 		tenant.APIKeyHash,
 		tenant.BudgetUSD,
+		sql.NullString{String: string(tenant.BudgetPeriod), Valid: tenant.BudgetPeriod != ""},
 		tenant.RateLimitRPM,
 		pq.Array(tenant.AllowedModels),
 		sql.NullString{String: tenant.DefaultProvider, Valid: tenant.DefaultProvider != ""},
 		pq.Array(tenant.FallbackProviders),
+		providerKeys,
+		transformRules,
+		pricingOverrides,
+		pq.Array(tenant.AllowedProviders),
+		pq.Array(tenant.Scopes),
+		samplingDefaults,
+		sql.NullString{String: tenant.WebhookURL, Valid: tenant.WebhookURL != ""},
+		sql.NullString{String: webhookSecret, Valid: webhookSecret != ""},
+		sql.NullString{String: tenant.DefaultModel, Valid: tenant.DefaultModel != ""},
+		tenant.MaxStreamSeconds,
 		tenant.Enabled,
 		time.Now(),
 	)
 
+	if IsUniqueViolation(err) {
+		return fmt.Errorf("update tenant: %w", domain.ErrTenantExists)
+	}
 	if err != nil {
 		return fmt.Errorf("update tenant: %w", err)
 	}
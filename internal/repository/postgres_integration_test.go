@@ -5,11 +5,14 @@ package repository_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/felipepmaragno/ai-gateway/internal/cost"
+	"github.com/felipepmaragno/ai-gateway/internal/crypto"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 	"github.com/felipepmaragno/ai-gateway/internal/repository"
 	"github.com/google/uuid"
@@ -108,6 +111,73 @@ func TestPostgresTenantRepository_CRUD(t *testing.T) {
 	}
 }
 
+func TestPostgresTenantRepository_ProviderKeysEncrypted(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	enc, err := crypto.NewEncryptor("integration-test-key")
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+
+	repo := repository.NewPostgresTenantRepository(db, repository.WithEncryptor(enc))
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ID:            uuid.New().String(),
+		Name:          "Provider Keys Tenant",
+		APIKeyHash:    "pkhash" + uuid.New().String()[:8],
+		RateLimitRPM:  60,
+		ProviderKeys:  map[string]string{"openai": "sk-tenant-secret"},
+		WebhookURL:    "https://example.com/hooks/budget",
+		WebhookSecret: "whsec_tenant",
+		Enabled:       true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	if err := repo.Create(ctx, tenant); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer repo.Delete(ctx, tenant.ID)
+
+	var raw string
+	if err := db.QueryRowContext(ctx, `SELECT provider_keys::text FROM tenants WHERE id = $1`, tenant.ID).Scan(&raw); err != nil {
+		t.Fatalf("raw select failed: %v", err)
+	}
+	if strings.Contains(raw, "sk-tenant-secret") {
+		t.Errorf("provider key stored in plaintext: %s", raw)
+	}
+	if err := db.QueryRowContext(ctx, `SELECT webhook_secret FROM tenants WHERE id = $1`, tenant.ID).Scan(&raw); err != nil {
+		t.Fatalf("raw select failed: %v", err)
+	}
+	if raw == "whsec_tenant" {
+		t.Error("webhook secret stored in plaintext")
+	}
+
+	got, err := repo.GetByID(ctx, tenant.ID)
+	if err != nil {
+		t.Fatalf("GetByID failed: %v", err)
+	}
+	if got.ProviderKeys["openai"] != "sk-tenant-secret" {
+		t.Errorf("expected decrypted provider key, got %q", got.ProviderKeys["openai"])
+	}
+	if got.WebhookSecret != "whsec_tenant" {
+		t.Errorf("expected decrypted webhook secret, got %q", got.WebhookSecret)
+	}
+
+	plainRepo := repository.NewPostgresTenantRepository(db)
+	if _, err := plainRepo.GetByID(ctx, tenant.ID); err == nil {
+		t.Error("expected read without encryptor to fail")
+	}
+	if err := plainRepo.CheckEncryption(ctx); !errors.Is(err, repository.ErrEncryptionRequired) {
+		t.Errorf("CheckEncryption() without encryptor = %v, want ErrEncryptionRequired", err)
+	}
+	if err := repo.CheckEncryption(ctx); err != nil {
+		t.Errorf("CheckEncryption() with encryptor = %v", err)
+	}
+}
+
 func TestPostgresUsageRepository_Record(t *testing.T) {
 	db := getTestDB(t)
 	defer db.Close()
@@ -165,4 +235,89 @@ func TestPostgresUsageRepository_Record(t *testing.T) {
 	if totalCost < 0.01 {
 		t.Errorf("expected total cost >= 0.01, got %f", totalCost)
 	}
+	totals, err := usageRepo.GetProviderTotals(ctx, since, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetProviderTotals failed: %v", err)
+	}
+
+	var openai *cost.ProviderTotals
+	for i := range totals {
+		if totals[i].Provider == "openai" {
+			openai = &totals[i]
+		}
+	}
+	if openai == nil || openai.Requests < 1 || openai.InputTokens < 100 {
+		t.Errorf("expected openai totals to include the record, got %+v", totals)
+	}
+}
+
+func TestPostgresSnapshotRepository_SaveAndRead(t *testing.T) {
+	db := getTestDB(t)
+	defer db.Close()
+
+	tenantRepo := repository.NewPostgresTenantRepository(db)
+	snapshotRepo := repository.NewPostgresSnapshotRepository(db)
+	ctx := context.Background()
+
+	tenant := &domain.Tenant{
+		ID:           uuid.New().String(),
+		Name:         "Snapshot Test Tenant",
+		APIKeyHash:   "snaphash" + uuid.New().String()[:8],
+		BudgetUSD:    100.0,
+		RateLimitRPM: 60,
+		Enabled:      true,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+
+	if err := tenantRepo.Create(ctx, tenant); err != nil {
+		t.Fatalf("Create tenant failed: %v", err)
+	}
+	defer tenantRepo.Delete(ctx, tenant.ID)
+
+	taken := time.Now().Add(-time.Minute).Truncate(time.Second)
+	snapshots := []cost.TenantSnapshot{
+		{TenantID: tenant.ID, Instance: "pod-a", Requests: 1, InputTokens: 10, OutputTokens: 5, CostUSD: 0.5, TakenAt: taken},
+		{TenantID: tenant.ID, Instance: "pod-a", Requests: 3, InputTokens: 30, OutputTokens: 9, CostUSD: 1.25, TakenAt: taken.Add(30 * time.Second)},
+		{TenantID: tenant.ID, Instance: "pod-b", Requests: 7, TakenAt: taken.Add(10 * time.Second)},
+	}
+	for _, s := range snapshots {
+		if err := snapshotRepo.SaveSnapshots(ctx, []cost.TenantSnapshot{s}); err != nil {
+			t.Fatalf("SaveSnapshots failed: %v", err)
+		}
+	}
+
+	latest, err := snapshotRepo.LatestSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("LatestSnapshots failed: %v", err)
+	}
+	var got *cost.TenantSnapshot
+	for i := range latest {
+		if latest[i].TenantID == tenant.ID && latest[i].Instance == "pod-a" {
+			got = &latest[i]
+		}
+	}
+	if got == nil || got.Requests != 3 || got.InputTokens != 30 || got.OutputTokens != 9 || got.CostUSD != 1.25 {
+		t.Errorf("expected pod-a's latest snapshot, got %+v", got)
+	}
+
+	history, err := snapshotRepo.TenantSnapshots(ctx, tenant.ID, taken)
+	if err != nil {
+		t.Fatalf("TenantSnapshots failed: %v", err)
+	}
+	if len(history) != 3 || history[1].Instance != "pod-b" {
+		t.Errorf("expected 3 snapshots oldest first, got %+v", history)
+	}
+
+	// Only pod-a's first snapshot is superseded; pod-b's only one is kept.
+	if _, err := snapshotRepo.PruneSnapshots(ctx, time.Now()); err != nil {
+		t.Fatalf("PruneSnapshots failed: %v", err)
+	}
+	history, err = snapshotRepo.TenantSnapshots(ctx, tenant.ID, taken)
+	if err != nil {
+		t.Fatalf("TenantSnapshots failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Instance != "pod-b" || history[1].Requests != 3 {
+		t.Errorf("expected pod-b's and pod-a's latest snapshots after pruning, got %+v", history)
+	}
 }
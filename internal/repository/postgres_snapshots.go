@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/cost"
+)
+
+// PostgresSnapshotRepository stores tenant metrics snapshots in the
+// tenant_metrics_snapshots table.
+type PostgresSnapshotRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresSnapshotRepository(db *sql.DB) *PostgresSnapshotRepository {
+	return &PostgresSnapshotRepository{db: db}
+}
+
+func (r *PostgresSnapshotRepository) SaveSnapshots(ctx context.Context, snapshots []cost.TenantSnapshot) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO tenant_metrics_snapshots (tenant_id, instance, requests, input_tokens, output_tokens, cost_usd, taken_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare snapshot insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range snapshots {
+		if _, err := stmt.ExecContext(ctx, s.TenantID, s.Instance, s.Requests, s.InputTokens, s.OutputTokens, s.CostUSD, s.TakenAt); err != nil {
+			return fmt.Errorf("insert snapshot for tenant %s: %w", s.TenantID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit snapshots: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgresSnapshotRepository) LatestSnapshots(ctx context.Context) ([]cost.TenantSnapshot, error) {
+	query := `
+		SELECT DISTINCT ON (tenant_id, instance) tenant_id, instance, requests, input_tokens, output_tokens, cost_usd, taken_at
+		FROM tenant_metrics_snapshots
+		ORDER BY tenant_id, instance, taken_at DESC
+	`
+	return r.query(ctx, query)
+}
+
+func (r *PostgresSnapshotRepository) TenantSnapshots(ctx context.Context, tenantID string, since time.Time) ([]cost.TenantSnapshot, error) {
+	query := `
+		SELECT tenant_id, instance, requests, input_tokens, output_tokens, cost_usd, taken_at
+		FROM tenant_metrics_snapshots
+		WHERE tenant_id = $1 AND taken_at >= $2
+		ORDER BY taken_at, instance
+	`
+	return r.query(ctx, query, tenantID, since)
+}
+
+func (r *PostgresSnapshotRepository) PruneSnapshots(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM tenant_metrics_snapshots s
+		WHERE s.taken_at < $1
+		  AND EXISTS (
+			SELECT 1 FROM tenant_metrics_snapshots n
+			WHERE n.tenant_id = s.tenant_id AND n.instance = s.instance AND n.taken_at > s.taken_at
+		  )
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune snapshots: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *PostgresSnapshotRepository) query(ctx context.Context, query string, args ...any) ([]cost.TenantSnapshot, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []cost.TenantSnapshot
+	for rows.Next() {
+		var s cost.TenantSnapshot
+		if err := rows.Scan(&s.TenantID, &s.Instance, &s.Requests, &s.InputTokens, &s.OutputTokens, &s.CostUSD, &s.TakenAt); err != nil {
+			return nil, fmt.Errorf("scan snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, rows.Err()
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -18,12 +19,21 @@ func NewPostgresUsageRepository(db *sql.DB) *PostgresUsageRepository {
 }
 
 func (r *PostgresUsageRepository) Record(ctx context.Context, record cost.UsageRecord) error {
+	metadata, err := marshalOptionalMap(record.Metadata)
+	if err != nil {
+		return fmt.Errorf("encode usage metadata: %w", err)
+	}
+	tags, err := marshalOptionalMap(record.Tags)
+	if err != nil {
+		return fmt.Errorf("encode usage tags: %w", err)
+	}
+
 	query := `
-		INSERT INTO usage_records (tenant_id, request_id, model, provider, input_tokens, output_tokens, cost_usd, cached, latency_ms, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO usage_records (tenant_id, request_id, model, provider, input_tokens, output_tokens, cost_usd, cached, latency_ms, status, created_at, metadata, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		record.TenantID,
 		record.RequestID,
 		record.Model,
@@ -35,6 +45,8 @@ func (r *PostgresUsageRepository) Record(ctx context.Context, record cost.UsageR
 		record.LatencyMs,
 		"success",
 		record.Timestamp,
+		metadata,
+		tags,
 	)
 
 	if err != nil {
@@ -44,6 +56,15 @@ func (r *PostgresUsageRepository) Record(ctx context.Context, record cost.UsageR
 	return nil
 }
 
+// marshalOptionalMap encodes m for a nullable JSONB column; empty maps are
+// stored as NULL.
+func marshalOptionalMap(m map[string]string) ([]byte, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m)
+}
+
 func (r *PostgresUsageRepository) GetTenantUsage(ctx context.Context, tenantID string, since time.Time) ([]cost.UsageRecord, error) {
 	query := `
 		SELECT tenant_id, request_id, model, provider, input_tokens, output_tokens, cost_usd, created_at
@@ -95,3 +116,58 @@ func (r *PostgresUsageRepository) GetTenantTotalCost(ctx context.Context, tenant
 
 	return total, nil
 }
+
+func (r *PostgresUsageRepository) GetProviderTotals(ctx context.Context, since, until time.Time) ([]cost.ProviderTotals, error) {
+	query := `
+		SELECT provider, COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0),
+		       COALESCE(SUM(cost_usd), 0), COALESCE(AVG(latency_ms), 0)
+		FROM usage_records
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY provider
+		ORDER BY provider
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("query provider totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []cost.ProviderTotals
+	for rows.Next() {
+		var t cost.ProviderTotals
+		if err := rows.Scan(&t.Provider, &t.Requests, &t.InputTokens, &t.OutputTokens, &t.CostUSD, &t.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("scan provider totals: %w", err)
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
+
+func (r *PostgresUsageRepository) GetTenantTagTotals(ctx context.Context, tenantID, key string, since time.Time) ([]cost.TagTotals, error) {
+	query := `
+		SELECT tags->>$2, COUNT(*), COALESCE(SUM(cost_usd), 0)
+		FROM usage_records
+		WHERE tenant_id = $1 AND created_at >= $3 AND tags ? $2
+		GROUP BY tags->>$2
+		ORDER BY tags->>$2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, key, since)
+	if err != nil {
+		return nil, fmt.Errorf("query tag totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []cost.TagTotals
+	for rows.Next() {
+		var t cost.TagTotals
+		if err := rows.Scan(&t.Value, &t.Requests, &t.CostUSD); err != nil {
+			return nil, fmt.Errorf("scan tag totals: %w", err)
+		}
+		totals = append(totals, t)
+	}
+
+	return totals, rows.Err()
+}
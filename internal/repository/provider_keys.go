@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/felipepmaragno/ai-gateway/internal/crypto"
+)
+
+// ErrEncryptionRequired is returned when provider keys or other tenant
+// secrets would be stored or read without an encryptor configured.
+var ErrEncryptionRequired = errors.New("encryption key required for tenant secrets")
+
+// Option configures a tenant repository.
+type Option func(*options)
+
+type options struct {
+	encryptor *crypto.Encryptor
+}
+
+// WithEncryptor enables encryption of tenant provider keys at rest.
+func WithEncryptor(enc *crypto.Encryptor) Option {
+	return func(o *options) {
+		o.encryptor = enc
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// encryptProviderKeys encrypts every provider key before it is persisted.
+// Plaintext keys are never written: without an encryptor it fails.
+func encryptProviderKeys(enc *crypto.Encryptor, keys map[string]string) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	if enc == nil {
+		return nil, ErrEncryptionRequired
+	}
+
+	encrypted := make(map[string]string, len(keys))
+	for provider, key := range keys {
+		ciphertext, err := enc.Encrypt(key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s key: %w", provider, err)
+		}
+		encrypted[provider] = ciphertext
+	}
+	return encrypted, nil
+}
+
+// encryptSecret encrypts a single tenant secret, such as its webhook signing
+// secret, on the same terms as provider keys. Empty stays empty.
+func encryptSecret(enc *crypto.Encryptor, secret string) (string, error) {
+	if secret == "" {
+		return "", nil
+	}
+	if enc == nil {
+		return "", ErrEncryptionRequired
+	}
+	return enc.Encrypt(secret)
+}
+
+// decryptSecret reverses encryptSecret after a read.
+func decryptSecret(enc *crypto.Encryptor, ciphertext string) (string, error) {
+	if ciphertext == "" {
+		return "", nil
+	}
+	if enc == nil {
+		return "", ErrEncryptionRequired
+	}
+	return enc.Decrypt(ciphertext)
+}
+
+// decryptProviderKeys reverses encryptProviderKeys after a read.
+func decryptProviderKeys(enc *crypto.Encryptor, encrypted map[string]string) (map[string]string, error) {
+	if len(encrypted) == 0 {
+		return nil, nil
+	}
+	if enc == nil {
+		return nil, ErrEncryptionRequired
+	}
+
+	keys := make(map[string]string, len(encrypted))
+	for provider, ciphertext := range encrypted {
+		plaintext, err := enc.Decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s key: %w", provider, err)
+		}
+		keys[provider] = plaintext
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/crypto"
+)
+
+func TestProviderKeys_EncryptDecryptCycle(t *testing.T) {
+	enc, err := crypto.NewEncryptor("test-encryption-key")
+	if err != nil {
+		t.Fatalf("NewEncryptor() error = %v", err)
+	}
+
+	keys := map[string]string{
+		"openai":    "sk-openai-secret",
+		"anthropic": "sk-ant-secret",
+	}
+
+	encrypted, err := encryptProviderKeys(enc, keys)
+	if err != nil {
+		t.Fatalf("encryptProviderKeys() error = %v", err)
+	}
+
+	for provider, plaintext := range keys {
+		if encrypted[provider] == "" || encrypted[provider] == plaintext {
+			t.Errorf("%s key was not encrypted: %q", provider, encrypted[provider])
+		}
+	}
+
+	decrypted, err := decryptProviderKeys(enc, encrypted)
+	if err != nil {
+		t.Fatalf("decryptProviderKeys() error = %v", err)
+	}
+
+	for provider, plaintext := range keys {
+		if decrypted[provider] != plaintext {
+			t.Errorf("%s key = %q, want %q", provider, decrypted[provider], plaintext)
+		}
+	}
+}
+
+func TestProviderKeys_RequiresEncryptor(t *testing.T) {
+	_, err := encryptProviderKeys(nil, map[string]string{"openai": "sk-secret"})
+	if !errors.Is(err, ErrEncryptionRequired) {
+		t.Errorf("expected ErrEncryptionRequired, got %v", err)
+	}
+
+	_, err = decryptProviderKeys(nil, map[string]string{"openai": "ciphertext"})
+	if !errors.Is(err, ErrEncryptionRequired) {
+		t.Errorf("expected ErrEncryptionRequired, got %v", err)
+	}
+}
+
+func TestProviderKeys_EmptyNeedsNoEncryptor(t *testing.T) {
+	encrypted, err := encryptProviderKeys(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if encrypted != nil {
+		t.Errorf("expected nil map, got %v", encrypted)
+	}
+}
+
+func TestProviderKeys_WrongKeyFailsDecrypt(t *testing.T) {
+	enc1, _ := crypto.NewEncryptor("key-one")
+	enc2, _ := crypto.NewEncryptor("key-two")
+
+	encrypted, err := encryptProviderKeys(enc1, map[string]string{"openai": "sk-secret"})
+	if err != nil {
+		t.Fatalf("encryptProviderKeys() error = %v", err)
+	}
+
+	if _, err := decryptProviderKeys(enc2, encrypted); err == nil {
+		t.Error("expected decrypt with wrong key to fail")
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const tenantInvalidationChannel = "aigateway:tenants:invalidate"
+
+// RedisInvalidationBus is an InvalidationBus backed by Redis pub/sub.
+type RedisInvalidationBus struct {
+	client *redis.Client
+}
+
+func NewRedisInvalidationBus(redisURL string) (*RedisInvalidationBus, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+
+	return &RedisInvalidationBus{client: client}, nil
+}
+
+func (b *RedisInvalidationBus) Publish(ctx context.Context, tenantID string) error {
+	return b.client.Publish(ctx, tenantInvalidationChannel, tenantID).Err()
+}
+
+func (b *RedisInvalidationBus) Subscribe(ctx context.Context, handler func(tenantID string)) error {
+	sub := b.client.Subscribe(ctx, tenantInvalidationChannel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			handler(msg.Payload)
+		}
+	}
+}
+
+func (b *RedisInvalidationBus) Close() error {
+	return b.client.Close()
+}
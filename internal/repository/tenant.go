@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"container/list"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -23,13 +26,62 @@ type InMemoryTenantRepository struct {
 	mu      sync.RWMutex
 	tenants map[string]*domain.Tenant
 	byKey   map[string]string
+
+	maxTenants int
+	evictLRU   bool
+	noDefault  bool
+	// recency orders tenant IDs from most to least recently used. It is
+	// only maintained with LRU eviction.
+	recency *list.List
+	elems   map[string]*list.Element
+}
+
+// InMemoryOption configures an InMemoryTenantRepository.
+type InMemoryOption func(*InMemoryTenantRepository)
+
+// WithMaxTenants caps how many tenants the repository holds (0 = no limit).
+// Once full, Create fails with domain.ErrTenantLimitReached unless LRU
+// eviction is enabled.
+func WithMaxTenants(n int) InMemoryOption {
+	return func(r *InMemoryTenantRepository) {
+		r.maxTenants = n
+	}
+}
+
+// WithLRUEviction makes Create evict the least recently used tenant instead
+// of failing when the repository is full. Lookups by ID or API key count as
+// use.
+func WithLRUEviction() InMemoryOption {
+	return func(r *InMemoryTenantRepository) {
+		r.evictLRU = true
+	}
 }
 
-func NewInMemoryTenantRepository() *InMemoryTenantRepository {
+// WithoutDefaultTenant skips seeding the "default" tenant, whose API key
+// gw-default-key is public, so a deployment only accepts keys of tenants
+// created through the Admin API.
+func WithoutDefaultTenant() InMemoryOption {
+	return func(r *InMemoryTenantRepository) {
+		r.noDefault = true
+	}
+}
+
+func NewInMemoryTenantRepository(opts ...InMemoryOption) *InMemoryTenantRepository {
 	repo := &InMemoryTenantRepository{
 		tenants: make(map[string]*domain.Tenant),
 		byKey:   make(map[string]string),
 	}
+	for _, opt := range opts {
+		opt(repo)
+	}
+	if repo.evictLRU {
+		repo.recency = list.New()
+		repo.elems = make(map[string]*list.Element)
+	}
+
+	if repo.noDefault {
+		return repo
+	}
 
 	defaultTenant := &domain.Tenant{
 		ID:                "default",
@@ -43,23 +95,81 @@ func NewInMemoryTenantRepository() *InMemoryTenantRepository {
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
-	repo.tenants[defaultTenant.ID] = defaultTenant
-	repo.byKey[defaultTenant.APIKeyHash] = defaultTenant.ID
+	repo.add(defaultTenant)
+	slog.Warn("default tenant is active: anyone can authenticate with the public key gw-default-key; set TENANT_MEMORY_DEFAULT=false outside local development",
+		"tenant_id", defaultTenant.ID,
+		"budget_usd", defaultTenant.BudgetUSD,
+	)
 
 	return repo
 }
 
-func (r *InMemoryTenantRepository) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+// add stores tenant; the caller holds the write lock and has checked
+// capacity.
+func (r *InMemoryTenantRepository) add(tenant *domain.Tenant) {
+	r.tenants[tenant.ID] = tenant
+	r.byKey[tenant.APIKeyHash] = tenant.ID
+	if r.recency != nil {
+		r.elems[tenant.ID] = r.recency.PushFront(tenant.ID)
+	}
+}
+
+// nameTaken reports whether a tenant other than exceptID is called name,
+// mirroring the unique index on tenants.name; the caller holds the lock.
+// Unnamed tenants never conflict, since the Admin API requires a name.
+func (r *InMemoryTenantRepository) nameTaken(name, exceptID string) bool {
+	if name == "" {
+		return false
+	}
+	for _, t := range r.tenants {
+		if t.ID != exceptID && t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// remove drops tenant; the caller holds the write lock.
+func (r *InMemoryTenantRepository) remove(tenant *domain.Tenant) {
+	if tenant.APIKeyHash != "" {
+		delete(r.byKey, tenant.APIKeyHash)
+	}
+	delete(r.tenants, tenant.ID)
+	if e, ok := r.elems[tenant.ID]; ok {
+		r.recency.Remove(e)
+		delete(r.elems, tenant.ID)
+	}
+}
+
+// lookup returns the tenant with id, marking it as recently used when LRU
+// eviction is enabled. Without eviction it only needs the read lock.
+func (r *InMemoryTenantRepository) lookup(id string) (*domain.Tenant, bool) {
+	if r.recency == nil {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		tenant, ok := r.tenants[id]
+		return tenant, ok
+	}
 
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant, ok := r.tenants[id]
+	if ok {
+		r.recency.MoveToFront(r.elems[id])
+	}
+	return tenant, ok
+}
+
+func (r *InMemoryTenantRepository) GetByAPIKey(ctx context.Context, apiKey string) (*domain.Tenant, error) {
 	hash := hashAPIKey(apiKey)
+	r.mu.RLock()
 	tenantID, ok := r.byKey[hash]
+	r.mu.RUnlock()
 	if !ok {
 		return nil, domain.ErrTenantNotFound
 	}
 
-	tenant, ok := r.tenants[tenantID]
+	tenant, ok := r.lookup(tenantID)
 	if !ok {
 		return nil, domain.ErrTenantNotFound
 	}
@@ -68,10 +178,7 @@ func (r *InMemoryTenantRepository) GetByAPIKey(ctx context.Context, apiKey strin
 }
 
 func (r *InMemoryTenantRepository) GetByID(ctx context.Context, id string) (*domain.Tenant, error) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	tenant, ok := r.tenants[id]
+	tenant, ok := r.lookup(id)
 	if !ok {
 		return nil, domain.ErrTenantNotFound
 	}
@@ -83,8 +190,27 @@ func (r *InMemoryTenantRepository) Create(ctx context.Context, tenant *domain.Te
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.tenants[tenant.ID] = tenant
-	r.byKey[tenant.APIKeyHash] = tenant.ID
+	if _, ok := r.tenants[tenant.ID]; ok {
+		return domain.ErrTenantExists
+	}
+	if _, ok := r.byKey[tenant.APIKeyHash]; ok && tenant.APIKeyHash != "" {
+		return domain.ErrTenantExists
+	}
+	if r.nameTaken(tenant.Name, tenant.ID) {
+		return domain.ErrTenantExists
+	}
+
+	if r.maxTenants > 0 && len(r.tenants) >= r.maxTenants {
+		if r.recency == nil {
+			return fmt.Errorf("%w: %d tenants", domain.ErrTenantLimitReached, r.maxTenants)
+		}
+		oldest := r.tenants[r.recency.Back().Value.(string)]
+		r.remove(oldest)
+		slog.Warn("in-memory tenant limit reached, evicted least recently used tenant",
+			"evicted_tenant_id", oldest.ID, "max_tenants", r.maxTenants)
+	}
+
+	r.add(tenant)
 
 	return nil
 }
@@ -97,6 +223,9 @@ func (r *InMemoryTenantRepository) Update(ctx context.Context, tenant *domain.Te
 	if !ok {
 		return domain.ErrTenantNotFound
 	}
+	if r.nameTaken(tenant.Name, tenant.ID) {
+		return domain.ErrTenantExists
+	}
 
 	if oldTenant.APIKeyHash != "" {
 		delete(r.byKey, oldTenant.APIKeyHash)
@@ -109,6 +238,9 @@ func (r *InMemoryTenantRepository) Update(ctx context.Context, tenant *domain.Te
 
 	tenant.UpdatedAt = time.Now()
 	r.tenants[tenant.ID] = tenant
+	if e, ok := r.elems[tenant.ID]; ok {
+		r.recency.MoveToFront(e)
+	}
 
 	return nil
 }
@@ -133,10 +265,7 @@ func (r *InMemoryTenantRepository) Delete(ctx context.Context, id string) error
 		return domain.ErrTenantNotFound
 	}
 
-	if tenant.APIKeyHash != "" {
-		delete(r.byKey, tenant.APIKeyHash)
-	}
-	delete(r.tenants, id)
+	r.remove(tenant)
 
 	return nil
 }
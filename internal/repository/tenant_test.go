@@ -2,10 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
+	"github.com/lib/pq"
 )
 
 func TestInMemoryTenantRepository_GetByAPIKey(t *testing.T) {
@@ -32,6 +35,121 @@ func TestInMemoryTenantRepository_GetByAPIKey_NotFound(t *testing.T) {
 	}
 }
 
+func TestInMemoryTenantRepository_Create_Duplicate(t *testing.T) {
+	repo := NewInMemoryTenantRepository()
+	ctx := context.Background()
+
+	if err := repo.Create(ctx, &domain.Tenant{ID: "t1", APIKeyHash: hashAPIKey("key-1")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tenant *domain.Tenant
+	}{
+		{"same ID", &domain.Tenant{ID: "t1", APIKeyHash: hashAPIKey("key-2")}},
+		{"same API key", &domain.Tenant{ID: "t2", APIKeyHash: hashAPIKey("key-1")}},
+		{"same name", &domain.Tenant{ID: "t3", Name: "default", APIKeyHash: hashAPIKey("key-3")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.Create(ctx, tt.tenant); !errors.Is(err, domain.ErrTenantExists) {
+				t.Errorf("Create() error = %v, want ErrTenantExists", err)
+			}
+		})
+	}
+
+	if tenant, _ := repo.GetByAPIKey(ctx, "key-1"); tenant == nil || tenant.ID != "t1" {
+		t.Errorf("original tenant was overwritten: %+v", tenant)
+	}
+}
+
+func TestInMemoryTenantRepository_MaxTenants(t *testing.T) {
+	ctx := context.Background()
+	// The default tenant takes the first slot.
+	repo := NewInMemoryTenantRepository(WithMaxTenants(2))
+
+	if err := repo.Create(ctx, &domain.Tenant{ID: "t1", APIKeyHash: hashAPIKey("k1")}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	err := repo.Create(ctx, &domain.Tenant{ID: "t2", APIKeyHash: hashAPIKey("k2")})
+	if !errors.Is(err, domain.ErrTenantLimitReached) {
+		t.Fatalf("Create() over the cap error = %v, want ErrTenantLimitReached", err)
+	}
+	if err.Error() != "tenant limit reached: 2 tenants" {
+		t.Errorf("error message = %q", err)
+	}
+
+	// Deleting frees a slot.
+	if err := repo.Delete(ctx, "t1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := repo.Create(ctx, &domain.Tenant{ID: "t2", APIKeyHash: hashAPIKey("k2")}); err != nil {
+		t.Errorf("Create() after delete error = %v", err)
+	}
+}
+
+func TestInMemoryTenantRepository_LRUEviction(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemoryTenantRepository(WithMaxTenants(2), WithLRUEviction())
+
+	if err := repo.Create(ctx, &domain.Tenant{ID: "t1", APIKeyHash: hashAPIKey("k1")}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// Using the default tenant leaves t1 least recently used.
+	if _, err := repo.GetByAPIKey(ctx, "gw-default-key"); err != nil {
+		t.Fatalf("GetByAPIKey() error = %v", err)
+	}
+	if err := repo.Create(ctx, &domain.Tenant{ID: "t2", APIKeyHash: hashAPIKey("k2")}); err != nil {
+		t.Fatalf("Create() with eviction error = %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, "t1"); err != domain.ErrTenantNotFound {
+		t.Errorf("t1 should have been evicted, got %v", err)
+	}
+	if _, err := repo.GetByAPIKey(ctx, "k1"); err != domain.ErrTenantNotFound {
+		t.Errorf("evicted tenant's key should not resolve, got %v", err)
+	}
+	for _, id := range []string{"default", "t2"} {
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			t.Errorf("GetByID(%q) error = %v", id, err)
+		}
+	}
+}
+
+func TestInMemoryTenantRepository_WithoutDefaultTenant(t *testing.T) {
+	ctx := context.Background()
+
+	seeded := NewInMemoryTenantRepository()
+	if _, err := seeded.GetByAPIKey(ctx, "gw-default-key"); err != nil {
+		t.Fatalf("default tenant should be seeded, got %v", err)
+	}
+
+	repo := NewInMemoryTenantRepository(WithoutDefaultTenant())
+	if _, err := repo.GetByAPIKey(ctx, "gw-default-key"); err != domain.ErrTenantNotFound {
+		t.Errorf("GetByAPIKey(gw-default-key) error = %v, want ErrTenantNotFound", err)
+	}
+	if _, err := repo.GetByID(ctx, "default"); err != domain.ErrTenantNotFound {
+		t.Errorf("GetByID(default) error = %v, want ErrTenantNotFound", err)
+	}
+	if tenants, _ := repo.List(ctx); len(tenants) != 0 {
+		t.Errorf("List() = %d tenants, want none", len(tenants))
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	if !IsUniqueViolation(fmt.Errorf("insert: %w", &pq.Error{Code: "23505"})) {
+		t.Error("unique_violation not detected")
+	}
+	if IsUniqueViolation(&pq.Error{Code: "23503"}) {
+		t.Error("foreign key violation reported as unique")
+	}
+	if IsUniqueViolation(errors.New("connection refused")) {
+		t.Error("plain error reported as unique violation")
+	}
+}
+
 func TestInMemoryTenantRepository_Create(t *testing.T) {
 	repo := NewInMemoryTenantRepository()
 	ctx := context.Background()
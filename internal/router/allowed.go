@@ -0,0 +1,26 @@
+package router
+
+import "context"
+
+type allowedProvidersKey struct{}
+
+// WithAllowedProviders restricts provider selection made with the returned
+// context to ids: hints naming another provider fail with
+// domain.ErrProviderNotAllowed, and model routing, the strategy, the default
+// provider and fallbacks skip providers outside the set. An empty ids allows
+// every provider.
+func WithAllowedProviders(ctx context.Context, ids []string) context.Context {
+	if len(ids) == 0 {
+		return ctx
+	}
+	allowed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	return context.WithValue(ctx, allowedProvidersKey{}, allowed)
+}
+
+func providerAllowed(ctx context.Context, id string) bool {
+	allowed, ok := ctx.Value(allowedProvidersKey{}).(map[string]bool)
+	return !ok || allowed[id]
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"context"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+type providerChainKey struct{}
+
+// WithProviderChain makes selection with the returned context without a
+// provider hint use ids, in order, instead of model routing, the strategy,
+// the default provider and the fallback order. Providers with an open
+// circuit or outside WithAllowedProviders are skipped. An empty ids leaves
+// selection unchanged.
+func WithProviderChain(ctx context.Context, ids []string) context.Context {
+	if len(ids) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, providerChainKey{}, ids)
+}
+
+func providerChain(ctx context.Context) ([]string, bool) {
+	ids, ok := ctx.Value(providerChainKey{}).([]string)
+	return ids, ok
+}
+
+// selectChain returns the usable providers of a per-request chain in order.
+// When the only providers left were skipped for an open circuit, it returns
+// domain.ErrCircuitBreakerOpen rather than domain.ErrProviderNotFound.
+func (r *Router) selectChain(ctx context.Context, chain []string, model string) ([]Provider, error) {
+	var providers []Provider
+	circuitOpen := false
+	for _, id := range chain {
+		p, ok := r.providers[id]
+		if !ok || !providerAllowed(ctx, id) {
+			continue
+		}
+		if r.breaker(id, model).Allow(ctx) != nil {
+			circuitOpen = true
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		if circuitOpen {
+			return nil, domain.ErrCircuitBreakerOpen
+		}
+		return nil, domain.ErrProviderNotFound
+	}
+	return providers, nil
+}
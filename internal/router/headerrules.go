@@ -0,0 +1,79 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// HeaderRule routes requests carrying a header value to a provider, e.g.
+// X-Region: eu to a Bedrock provider in eu-west-1.
+type HeaderRule struct {
+	Header string `json:"header"`
+	// Value must equal the header's value. Empty matches any non-empty value.
+	Value    string `json:"value"`
+	Provider string `json:"provider"`
+}
+
+func (rule HeaderRule) matches(h http.Header) bool {
+	v := h.Get(rule.Header)
+	if rule.Value == "" {
+		return v != ""
+	}
+	return v == rule.Value
+}
+
+// ValidateHeaderRules checks that every rule names a header and a registered
+// provider.
+func ValidateHeaderRules(rules []HeaderRule, providers map[string]Provider) error {
+	for i, rule := range rules {
+		if rule.Header == "" {
+			return fmt.Errorf("rule %d: header is required", i)
+		}
+		if rule.Provider == "" {
+			return fmt.Errorf("rule %d: provider is required", i)
+		}
+		if _, ok := providers[rule.Provider]; !ok {
+			return fmt.Errorf("rule %d: provider %q is not registered", i, rule.Provider)
+		}
+	}
+	return nil
+}
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders makes selection with the returned context match the
+// router's header rules against h.
+func WithRequestHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, requestHeadersKey{}, h)
+}
+
+// providerByHeaders returns the provider of the first header rule matching
+// the request, and whether any rule matched. A rule is a requirement, such
+// as keeping EU traffic in the EU, so when its provider is disallowed for
+// the tenant or its circuit is open the request fails with
+// domain.ErrRouteUnavailable instead of going elsewhere.
+func (r *Router) providerByHeaders(ctx context.Context, model string) (Provider, bool, error) {
+	h, ok := ctx.Value(requestHeadersKey{}).(http.Header)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, rule := range r.headerRules {
+		if !rule.matches(h) {
+			continue
+		}
+		p, ok := r.providers[rule.Provider]
+		if !ok || !providerAllowed(ctx, rule.Provider) {
+			return nil, true, fmt.Errorf("%w: %s", domain.ErrRouteUnavailable, rule.Provider)
+		}
+		if r.breaker(rule.Provider, model).Allow(ctx) != nil {
+			slog.Warn("circuit breaker open for header-routed provider", "provider", rule.Provider, "header", rule.Header)
+			return nil, true, fmt.Errorf("%w: %s", domain.ErrRouteUnavailable, rule.Provider)
+		}
+		return p, true, nil
+	}
+	return nil, false, nil
+}
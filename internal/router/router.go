@@ -5,7 +5,12 @@ package router
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/felipepmaragno/ai-gateway/internal/circuitbreaker"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
@@ -21,32 +26,76 @@ type Provider interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// Moderator is implemented by providers that can classify content with a
+// moderation model, as served by POST /v1/moderations.
+type Moderator interface {
+	Moderate(ctx context.Context, req domain.ModerationRequest) (*domain.ModerationResponse, error)
+}
+
 // Router manages provider selection with health-aware routing and automatic fallback.
 type Router struct {
 	providers       map[string]Provider
 	defaultProvider string
 	fallbackOrder   []string
 	cbManager       *circuitbreaker.Manager
+	strategy        Strategy
+	headerRules     []HeaderRule
+	perModel        bool
+	unknownModels   UnknownModels
+	priced          func(model string) bool
+	probe           *modelProbe
 }
 
 type Config struct {
 	Providers       map[string]Provider
 	DefaultProvider string
-	FallbackOrder   []string
+	FallbackOrder   []string // Defaults to provider IDs in alphabetical order
 	CBConfig        circuitbreaker.Config
 	RedisURL        string // If set, uses distributed circuit breaker
+
+	// CBStateConcurrency caps concurrent breaker state reads for /health when
+	// breakers live in Redis. Zero keeps the manager default.
+	CBStateConcurrency int
+
+	// CBStateFile persists in-memory breaker state across restarts (see
+	// SaveCircuitStates). Empty starts every breaker closed.
+	CBStateFile string
+
+	// CBPerModel keys circuit breakers by provider and model, so one model's
+	// failures (e.g. capacity on an expensive model) do not shed the
+	// provider's other models. Only priced models and models the provider
+	// has listed get their own breaker; any other name shares the
+	// provider's, so arbitrary model names cannot grow the breaker set.
+	CBPerModel bool
+
+	// Strategy picks the primary provider when neither a hint nor the model
+	// decides it. Nil keeps the default provider first.
+	Strategy Strategy
+
+	// HeaderRules route by request header (see WithRequestHeaders) ahead of
+	// model routing. Rules are tried in order and the first match wins.
+	HeaderRules []HeaderRule
+
+	// UnknownModels routes requests whose model no hint, header rule or
+	// model name maps to a provider; empty means UnknownModelsDefault.
+	// ProbeTTL is how long UnknownModelsProbe trusts a provider's model
+	// list; zero uses DefaultProbeTTL. ProbeTimeout bounds each listing;
+	// zero uses DefaultProbeTimeout.
+	UnknownModels UnknownModels
+	ProbeTTL      time.Duration
+	ProbeTimeout  time.Duration
+
+	// Priced reports whether the gateway has a price for a model, such as
+	// cost.Calculator.Priced. Priced models are never unknown, so they are
+	// not probed under UnknownModelsReject nor rejected by either strategy.
+	Priced func(model string) bool
 }
 
 func New(providers map[string]Provider, defaultProvider string) *Router {
-	fallbackOrder := make([]string, 0, len(providers))
-	for id := range providers {
-		fallbackOrder = append(fallbackOrder, id)
-	}
-
 	return &Router{
 		providers:       providers,
 		defaultProvider: defaultProvider,
-		fallbackOrder:   fallbackOrder,
+		fallbackOrder:   defaultFallbackOrder(providers),
 		cbManager:       circuitbreaker.NewManager(circuitbreaker.DefaultConfig()),
 	}
 }
@@ -54,32 +103,84 @@ func New(providers map[string]Provider, defaultProvider string) *Router {
 func NewWithConfig(cfg Config) *Router {
 	fallbackOrder := cfg.FallbackOrder
 	if len(fallbackOrder) == 0 {
-		fallbackOrder = make([]string, 0, len(cfg.Providers))
-		for id := range cfg.Providers {
-			fallbackOrder = append(fallbackOrder, id)
-		}
+		fallbackOrder = defaultFallbackOrder(cfg.Providers)
 	}
 
-	var cbOpts []circuitbreaker.ManagerOption
+	cbOpts := []circuitbreaker.ManagerOption{circuitbreaker.WithStateConcurrency(cfg.CBStateConcurrency, 0)}
 	if cfg.RedisURL != "" {
 		cbOpts = append(cbOpts, circuitbreaker.WithRedis(cfg.RedisURL))
 		slog.Info("using distributed circuit breaker", "backend", "redis")
 	} else {
 		slog.Info("using in-memory circuit breaker")
 	}
+	if cfg.CBStateFile != "" {
+		cbOpts = append(cbOpts, circuitbreaker.WithStateFile(cfg.CBStateFile))
+	}
 
 	return &Router{
 		providers:       cfg.Providers,
 		defaultProvider: cfg.DefaultProvider,
 		fallbackOrder:   fallbackOrder,
 		cbManager:       circuitbreaker.NewManager(cfg.CBConfig, cbOpts...),
+		strategy:        cfg.Strategy,
+		headerRules:     cfg.HeaderRules,
+		perModel:        cfg.CBPerModel,
+		unknownModels:   cfg.UnknownModels,
+		priced:          cfg.Priced,
+		probe:           newModelProbe(cfg.ProbeTTL, cfg.ProbeTimeout),
+	}
+}
+
+// breaker returns the circuit breaker guarding model on providerID: the
+// provider's own, or with CBPerModel one per provider and known model.
+func (r *Router) breaker(providerID, model string) circuitbreaker.CircuitBreaker {
+	return r.cbManager.Get(r.breakerKey(providerID, model))
+}
+
+func (r *Router) breakerKey(providerID, model string) string {
+	if !r.perModel || model == "" {
+		return providerID
+	}
+	if (r.priced == nil || !r.priced(model)) && !r.probe.listed(providerID, model) {
+		return providerID
 	}
+	return providerID + "/" + model
+}
+
+// defaultFallbackOrder sorts provider IDs so fallback behaviour does not
+// depend on map iteration order.
+func defaultFallbackOrder(providers map[string]Provider) []string {
+	order := make([]string, 0, len(providers))
+	for id := range providers {
+		order = append(order, id)
+	}
+	sort.Strings(order)
+	return order
+}
+
+// ValidateFallbackOrder checks that every provider in order is registered
+// and listed only once.
+func ValidateFallbackOrder(order []string, providers map[string]Provider) error {
+	seen := make(map[string]bool, len(order))
+	for _, id := range order {
+		if _, ok := providers[id]; !ok {
+			return fmt.Errorf("provider %q is not registered", id)
+		}
+		if seen[id] {
+			return fmt.Errorf("provider %q listed more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
 }
 
 func (r *Router) SelectProvider(ctx context.Context, providerHint string, model string) (Provider, error) {
 	if providerHint != "" {
+		if !providerAllowed(ctx, providerHint) {
+			return nil, domain.ErrProviderNotAllowed
+		}
 		if p, ok := r.providers[providerHint]; ok {
-			cb := r.cbManager.Get(providerHint)
+			cb := r.breaker(providerHint, model)
 			if err := cb.Allow(ctx); err != nil {
 				slog.Warn("circuit breaker open for requested provider", "provider", providerHint)
 				return nil, err
@@ -89,16 +190,42 @@ func (r *Router) SelectProvider(ctx context.Context, providerHint string, model
 		return nil, domain.ErrProviderNotFound
 	}
 
-	if p := r.findProviderByModel(model); p != nil {
-		cb := r.cbManager.Get(p.ID())
+	if chain, ok := providerChain(ctx); ok {
+		providers, err := r.selectChain(ctx, chain, model)
+		if err != nil {
+			return nil, err
+		}
+		return providers[0], nil
+	}
+
+	if p, matched, err := r.providerByHeaders(ctx, model); matched {
+		return p, err
+	}
+
+	if p := r.findProviderByModel(model); p == nil {
+		probed, err := r.routeUnknownModel(ctx, model)
+		if err != nil {
+			return nil, err
+		}
+		if probed != nil {
+			return probed, nil
+		}
+	} else if providerAllowed(ctx, p.ID()) {
+		cb := r.breaker(p.ID(), model)
 		if cb.Allow(ctx) == nil {
 			return p, nil
 		}
 		slog.Warn("circuit breaker open for model provider, trying fallback", "provider", p.ID())
 	}
 
-	if p, ok := r.providers[r.defaultProvider]; ok {
-		cb := r.cbManager.Get(r.defaultProvider)
+	if r.strategy != nil {
+		if p := r.pickByStrategy(ctx, model); p != nil {
+			return p, nil
+		}
+	}
+
+	if p, ok := r.providers[r.defaultProvider]; ok && providerAllowed(ctx, r.defaultProvider) {
+		cb := r.breaker(r.defaultProvider, model)
 		if cb.Allow(ctx) == nil {
 			return p, nil
 		}
@@ -106,7 +233,10 @@ func (r *Router) SelectProvider(ctx context.Context, providerHint string, model
 	}
 
 	for _, id := range r.fallbackOrder {
-		cb := r.cbManager.Get(id)
+		if !providerAllowed(ctx, id) {
+			continue
+		}
+		cb := r.breaker(id, model)
 		if cb.Allow(ctx) == nil {
 			if p, ok := r.providers[id]; ok {
 				slog.Info("using fallback provider", "provider", id)
@@ -115,22 +245,102 @@ func (r *Router) SelectProvider(ctx context.Context, providerHint string, model
 		}
 	}
 
+	if r.allCircuitsOpen(ctx, model) {
+		return nil, domain.ErrCircuitBreakerOpen
+	}
 	return nil, domain.ErrProviderNotFound
 }
 
+// allCircuitsOpen reports whether there is at least one provider usable with
+// ctx and every one of them has an open circuit, so nothing was selected
+// because of tripped breakers rather than missing providers.
+func (r *Router) allCircuitsOpen(ctx context.Context, model string) bool {
+	candidates := 0
+	for id := range r.providers {
+		if !providerAllowed(ctx, id) {
+			continue
+		}
+		candidates++
+		if r.breaker(id, model).Allow(ctx) == nil {
+			return false
+		}
+	}
+	return candidates > 0
+}
+
+// CircuitRetryAfter returns the shortest time until one of ids, or of every
+// provider usable with ctx when ids is empty, admits traffic for model again
+// after an open circuit.
+func (r *Router) CircuitRetryAfter(ctx context.Context, model string, ids ...string) time.Duration {
+	if len(ids) == 0 {
+		for id := range r.providers {
+			if providerAllowed(ctx, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	shortest := time.Duration(-1)
+	for _, id := range ids {
+		if remaining := r.cbManager.OpenRemaining(ctx, r.breakerKey(id, model)); shortest < 0 || remaining < shortest {
+			shortest = remaining
+		}
+	}
+	return max(shortest, 0)
+}
+
+// pickByStrategy offers every provider whose breaker allows traffic to the
+// configured strategy.
+func (r *Router) pickByStrategy(ctx context.Context, model string) Provider {
+	var candidates []string
+	states := make(map[string]string)
+	for _, id := range defaultFallbackOrder(r.providers) {
+		if !providerAllowed(ctx, id) {
+			continue
+		}
+		cb := r.breaker(id, model)
+		if cb.Allow(ctx) != nil {
+			continue
+		}
+		candidates = append(candidates, id)
+		states[id] = cb.State(ctx).String()
+	}
+
+	id := r.strategy.Pick(candidates, states)
+	return r.providers[id]
+}
+
 func (r *Router) SelectProviderWithFallback(ctx context.Context, providerHint string, model string) ([]Provider, error) {
+	if chain, ok := providerChain(ctx); ok && providerHint == "" {
+		return r.selectChain(ctx, chain, model)
+	}
+
+	// A matched header rule allows no other provider, so it has no
+	// fallbacks.
+	if providerHint == "" {
+		if p, matched, err := r.providerByHeaders(ctx, model); matched {
+			if err != nil {
+				return nil, err
+			}
+			return []Provider{p}, nil
+		}
+	}
+
 	var providers []Provider
 
-	primary, _ := r.SelectProvider(ctx, providerHint, model)
+	primary, err := r.SelectProvider(ctx, providerHint, model)
+	if errors.Is(err, domain.ErrProviderNotAllowed) || errors.Is(err, domain.ErrUnknownModel) {
+		return nil, err
+	}
 	if primary != nil {
 		providers = append(providers, primary)
 	}
 
 	for _, id := range r.fallbackOrder {
-		if primary != nil && id == primary.ID() {
+		if (primary != nil && id == primary.ID()) || !providerAllowed(ctx, id) {
 			continue
 		}
-		cb := r.cbManager.Get(id)
+		cb := r.breaker(id, model)
 		if cb.Allow(ctx) == nil {
 			if p, ok := r.providers[id]; ok {
 				providers = append(providers, p)
@@ -139,24 +349,54 @@ func (r *Router) SelectProviderWithFallback(ctx context.Context, providerHint st
 	}
 
 	if len(providers) == 0 {
+		if r.allCircuitsOpen(ctx, model) {
+			return nil, domain.ErrCircuitBreakerOpen
+		}
 		return nil, domain.ErrProviderNotFound
 	}
 
 	return providers, nil
 }
 
-func (r *Router) RecordSuccess(providerID string) {
-	r.cbManager.Get(providerID).RecordSuccess(context.Background())
+func (r *Router) RecordSuccess(providerID, model string) {
+	r.breaker(providerID, model).RecordSuccess(context.Background())
+}
+
+func (r *Router) RecordFailure(providerID, model string) {
+	r.breaker(providerID, model).RecordFailure(context.Background())
 }
 
-func (r *Router) RecordFailure(providerID string) {
-	r.cbManager.Get(providerID).RecordFailure(context.Background())
+// RecordLatency reports a completed request's latency so the provider's
+// circuit breaker can trip on sustained slowness.
+func (r *Router) RecordLatency(providerID, model string, latency time.Duration) {
+	r.breaker(providerID, model).RecordLatency(context.Background(), latency)
+	if obs, ok := r.strategy.(latencyObserver); ok {
+		obs.ObserveLatency(providerID, latency)
+	}
 }
 
 func (r *Router) CircuitBreakerStates() map[string]string {
 	return r.cbManager.States()
 }
 
+// SaveCircuitStates writes open in-memory circuits to Config.CBStateFile so
+// they are restored on the next start.
+func (r *Router) SaveCircuitStates() error {
+	return r.cbManager.SaveState()
+}
+
+// modelPrefixProviders routes model families whose names share a prefix,
+// such as Mistral's dated and "-latest" variants.
+var modelPrefixProviders = []struct {
+	prefix   string
+	provider string
+}{
+	{"mistral-", "mistral"},
+	{"open-mistral-", "mistral"},
+	{"open-mixtral-", "mistral"},
+	{"codestral-", "mistral"},
+}
+
 func (r *Router) findProviderByModel(model string) Provider {
 	modelProviderMap := map[string]string{
 		"gpt-4":         "openai",
@@ -165,7 +405,17 @@ func (r *Router) findProviderByModel(model string) Provider {
 		"claude-3":      "anthropic",
 	}
 
-	if providerID, ok := modelProviderMap[model]; ok {
+	providerID, ok := modelProviderMap[model]
+	if !ok {
+		for _, entry := range modelPrefixProviders {
+			if strings.HasPrefix(model, entry.prefix) {
+				providerID, ok = entry.provider, true
+				break
+			}
+		}
+	}
+
+	if ok {
 		if p, ok := r.providers[providerID]; ok {
 			return p
 		}
@@ -174,6 +424,21 @@ func (r *Router) findProviderByModel(model string) Provider {
 	return nil
 }
 
+// SplitModel splits a provider-prefixed model name such as
+// "bedrock/claude-3-haiku" into the provider ID and the model name the
+// provider expects. ok is false when model has no prefix naming a registered
+// provider, so model names that merely contain a slash pass through unchanged.
+func (r *Router) SplitModel(model string) (providerID, name string, ok bool) {
+	providerID, name, found := strings.Cut(model, "/")
+	if !found || name == "" {
+		return "", model, false
+	}
+	if _, registered := r.providers[providerID]; !registered {
+		return "", model, false
+	}
+	return providerID, name, true
+}
+
 func (r *Router) GetProvider(id string) (Provider, bool) {
 	p, ok := r.providers[id]
 	return p, ok
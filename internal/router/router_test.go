@@ -2,8 +2,14 @@ package router
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/felipepmaragno/ai-gateway/internal/circuitbreaker"
 	"github.com/felipepmaragno/ai-gateway/internal/domain"
 )
 
@@ -75,6 +81,25 @@ func TestRouter_SelectProvider_ByModel(t *testing.T) {
 	}
 }
 
+func TestRouter_SelectProvider_ByModelPrefix(t *testing.T) {
+	providers := map[string]Provider{
+		"openai":  &mockProvider{id: "openai"},
+		"mistral": &mockProvider{id: "mistral"},
+	}
+
+	r := New(providers, "openai")
+
+	for _, model := range []string{"mistral-large-latest", "open-mistral-7b", "open-mixtral-8x22b", "codestral-2405"} {
+		p, err := r.SelectProvider(context.Background(), "", model)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", model, err)
+		}
+		if p.ID() != "mistral" {
+			t.Errorf("expected mistral for %s, got %s", model, p.ID())
+		}
+	}
+}
+
 func TestRouter_SelectProvider_NotFound(t *testing.T) {
 	providers := map[string]Provider{
 		"ollama": &mockProvider{id: "ollama"},
@@ -127,6 +152,113 @@ func TestRouter_NewWithConfig_NoFallbackOrder(t *testing.T) {
 	}
 }
 
+func TestRouter_DefaultFallbackOrderIsDeterministic(t *testing.T) {
+	providers := map[string]Provider{
+		"openai":    &mockProvider{id: "openai"},
+		"ollama":    &mockProvider{id: "ollama"},
+		"anthropic": &mockProvider{id: "anthropic"},
+		"bedrock":   &mockProvider{id: "bedrock"},
+	}
+	want := []string{"anthropic", "bedrock", "ollama", "openai"}
+
+	for i := 0; i < 20; i++ {
+		r := NewWithConfig(Config{Providers: providers, CBConfig: circuitbreaker.DefaultConfig()})
+		if !reflect.DeepEqual(r.fallbackOrder, want) {
+			t.Fatalf("fallbackOrder = %v, want %v", r.fallbackOrder, want)
+		}
+	}
+
+	if got := New(providers, "openai").fallbackOrder; !reflect.DeepEqual(got, want) {
+		t.Errorf("New() fallbackOrder = %v, want %v", got, want)
+	}
+}
+
+func TestRouter_ConfiguredFallbackOrderOverridesDefault(t *testing.T) {
+	providers := map[string]Provider{
+		"openai":    &mockProvider{id: "openai"},
+		"ollama":    &mockProvider{id: "ollama"},
+		"anthropic": &mockProvider{id: "anthropic"},
+	}
+
+	r := NewWithConfig(Config{
+		Providers:     providers,
+		FallbackOrder: []string{"ollama", "openai", "anthropic"},
+		CBConfig:      circuitbreaker.DefaultConfig(),
+	})
+
+	chain, err := r.SelectProviderWithFallback(context.Background(), "", "unknown-model")
+	if err != nil {
+		t.Fatalf("SelectProviderWithFallback() error = %v", err)
+	}
+
+	var got []string
+	for _, p := range chain {
+		got = append(got, p.ID())
+	}
+	want := []string{"ollama", "openai", "anthropic"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chain = %v, want %v", got, want)
+	}
+}
+
+func TestValidateFallbackOrder(t *testing.T) {
+	providers := map[string]Provider{
+		"openai": &mockProvider{id: "openai"},
+		"ollama": &mockProvider{id: "ollama"},
+	}
+
+	tests := []struct {
+		name    string
+		order   []string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"valid", []string{"ollama", "openai"}, false},
+		{"unknown provider", []string{"openai", "mistral"}, true},
+		{"duplicate", []string{"openai", "openai"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFallbackOrder(tt.order, providers)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFallbackOrder() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouter_SplitModel(t *testing.T) {
+	providers := map[string]Provider{
+		"bedrock":   &mockProvider{id: "bedrock"},
+		"anthropic": &mockProvider{id: "anthropic"},
+	}
+	r := New(providers, "anthropic")
+
+	tests := []struct {
+		model        string
+		wantProvider string
+		wantName     string
+		wantOK       bool
+	}{
+		{"bedrock/claude-3-haiku", "bedrock", "claude-3-haiku", true},
+		{"anthropic/claude-3-haiku", "anthropic", "claude-3-haiku", true},
+		{"claude-3-haiku", "", "claude-3-haiku", false},
+		{"library/llama3", "", "library/llama3", false},
+		{"bedrock/", "", "bedrock/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			provider, name, ok := r.SplitModel(tt.model)
+			if provider != tt.wantProvider || name != tt.wantName || ok != tt.wantOK {
+				t.Errorf("SplitModel(%q) = (%q, %q, %v), want (%q, %q, %v)",
+					tt.model, provider, name, ok, tt.wantProvider, tt.wantName, tt.wantOK)
+			}
+		})
+	}
+}
+
 func TestRouter_SelectProviderWithFallback(t *testing.T) {
 	providers := map[string]Provider{
 		"openai": &mockProvider{id: "openai"},
@@ -145,6 +277,132 @@ func TestRouter_SelectProviderWithFallback(t *testing.T) {
 	}
 }
 
+func TestRouter_AllowedProviders(t *testing.T) {
+	providers := map[string]Provider{
+		"anthropic": &mockProvider{id: "anthropic"},
+		"bedrock":   &mockProvider{id: "bedrock"},
+		"ollama":    &mockProvider{id: "ollama"},
+		"openai":    &mockProvider{id: "openai"},
+	}
+	r := New(providers, "ollama")
+	ctx := WithAllowedProviders(context.Background(), []string{"bedrock", "anthropic"})
+
+	t.Run("hint outside the set is rejected", func(t *testing.T) {
+		if _, err := r.SelectProvider(ctx, "openai", "gpt-4"); !errors.Is(err, domain.ErrProviderNotAllowed) {
+			t.Errorf("SelectProvider() error = %v, want ErrProviderNotAllowed", err)
+		}
+		if _, err := r.SelectProviderWithFallback(ctx, "openai", "gpt-4"); !errors.Is(err, domain.ErrProviderNotAllowed) {
+			t.Errorf("SelectProviderWithFallback() error = %v, want ErrProviderNotAllowed", err)
+		}
+	})
+
+	t.Run("model routing and default skip disallowed providers", func(t *testing.T) {
+		p, err := r.SelectProvider(ctx, "", "gpt-4")
+		if err != nil {
+			t.Fatalf("SelectProvider() error = %v", err)
+		}
+		if p.ID() != "anthropic" {
+			t.Errorf("expected anthropic, got %s", p.ID())
+		}
+	})
+
+	t.Run("fallbacks only include allowed providers", func(t *testing.T) {
+		list, err := r.SelectProviderWithFallback(ctx, "bedrock", "some-model")
+		if err != nil {
+			t.Fatalf("SelectProviderWithFallback() error = %v", err)
+		}
+		var ids []string
+		for _, p := range list {
+			ids = append(ids, p.ID())
+		}
+		if want := []string{"bedrock", "anthropic"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("providers = %v, want %v", ids, want)
+		}
+	})
+
+	t.Run("no restriction allows everything", func(t *testing.T) {
+		list, err := r.SelectProviderWithFallback(WithAllowedProviders(context.Background(), nil), "", "some-model")
+		if err != nil {
+			t.Fatalf("SelectProviderWithFallback() error = %v", err)
+		}
+		if len(list) != 4 {
+			t.Errorf("expected 4 providers, got %d", len(list))
+		}
+	})
+}
+
+func TestRouter_ProviderChain(t *testing.T) {
+	providers := map[string]Provider{
+		"anthropic": &mockProvider{id: "anthropic"},
+		"bedrock":   &mockProvider{id: "bedrock"},
+		"ollama":    &mockProvider{id: "ollama"},
+		"openai":    &mockProvider{id: "openai"},
+	}
+	r := New(providers, "ollama")
+	ctx := WithProviderChain(context.Background(), []string{"openai", "bedrock", "anthropic"})
+
+	ids := func(list []Provider) []string {
+		var out []string
+		for _, p := range list {
+			out = append(out, p.ID())
+		}
+		return out
+	}
+
+	list, err := r.SelectProviderWithFallback(ctx, "", "claude-3")
+	if err != nil {
+		t.Fatalf("SelectProviderWithFallback() error = %v", err)
+	}
+	if want := []string{"openai", "bedrock", "anthropic"}; !reflect.DeepEqual(ids(list), want) {
+		t.Errorf("providers = %v, want %v", ids(list), want)
+	}
+
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("openai", "")
+	}
+	p, err := r.SelectProvider(ctx, "", "gpt-4")
+	if err != nil {
+		t.Fatalf("SelectProvider() error = %v", err)
+	}
+	if p.ID() != "bedrock" {
+		t.Errorf("expected bedrock with openai's circuit open, got %s", p.ID())
+	}
+
+	restricted := WithAllowedProviders(ctx, []string{"anthropic"})
+	list, err = r.SelectProviderWithFallback(restricted, "", "gpt-4")
+	if err != nil {
+		t.Fatalf("SelectProviderWithFallback() error = %v", err)
+	}
+	if want := []string{"anthropic"}; !reflect.DeepEqual(ids(list), want) {
+		t.Errorf("restricted providers = %v, want %v", ids(list), want)
+	}
+}
+
+func TestRouter_ProviderChainAllCircuitsOpen(t *testing.T) {
+	r := New(map[string]Provider{
+		"bedrock": &mockProvider{id: "bedrock"},
+		"ollama":  &mockProvider{id: "ollama"},
+		"openai":  &mockProvider{id: "openai"},
+	}, "ollama")
+	ctx := WithProviderChain(context.Background(), []string{"openai", "bedrock", "missing"})
+
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("openai", "")
+		r.RecordFailure("bedrock", "")
+	}
+	if _, err := r.SelectProvider(ctx, "", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("SelectProvider() error = %v, want ErrCircuitBreakerOpen", err)
+	}
+	if _, err := r.SelectProviderWithFallback(ctx, "", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("SelectProviderWithFallback() error = %v, want ErrCircuitBreakerOpen", err)
+	}
+
+	unknown := WithProviderChain(context.Background(), []string{"missing"})
+	if _, err := r.SelectProviderWithFallback(unknown, "", "gpt-4"); err != domain.ErrProviderNotFound {
+		t.Errorf("unknown chain error = %v, want ErrProviderNotFound", err)
+	}
+}
+
 func TestRouter_RecordSuccessAndFailure(t *testing.T) {
 	providers := map[string]Provider{
 		"openai": &mockProvider{id: "openai"},
@@ -153,8 +411,232 @@ func TestRouter_RecordSuccessAndFailure(t *testing.T) {
 	r := New(providers, "openai")
 
 	// Should not panic
-	r.RecordSuccess("openai")
-	r.RecordFailure("openai")
+	r.RecordSuccess("openai", "")
+	r.RecordFailure("openai", "")
+}
+
+func TestRouter_AllCircuitsOpen(t *testing.T) {
+	r := New(map[string]Provider{
+		"openai": &mockProvider{id: "openai"},
+		"ollama": &mockProvider{id: "ollama"},
+	}, "openai")
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("openai", "")
+	}
+	if p, err := r.SelectProvider(ctx, "", "gpt-4"); err != nil || p.ID() != "ollama" {
+		t.Fatalf("expected ollama with one circuit open, got %v, %v", p, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("ollama", "")
+	}
+	if _, err := r.SelectProvider(ctx, "", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("SelectProvider() error = %v, want ErrCircuitBreakerOpen", err)
+	}
+	if _, err := r.SelectProviderWithFallback(ctx, "", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("SelectProviderWithFallback() error = %v, want ErrCircuitBreakerOpen", err)
+	}
+
+	// Default breaker timeout is 30s; the wait is measured from the last failure.
+	if got := r.CircuitRetryAfter(ctx, ""); got <= 29*time.Second || got > 30*time.Second {
+		t.Errorf("CircuitRetryAfter() = %v, want just under 30s", got)
+	}
+
+	// Only missing providers, not open circuits, stay ErrProviderNotFound.
+	restricted := WithAllowedProviders(ctx, []string{"anthropic"})
+	if _, err := r.SelectProvider(restricted, "", "gpt-4"); err != domain.ErrProviderNotFound {
+		t.Errorf("restricted SelectProvider() error = %v, want ErrProviderNotFound", err)
+	}
+}
+
+func TestRouter_PerModelCircuitBreakers(t *testing.T) {
+	providers := map[string]Provider{
+		"openai": &mockProvider{id: "openai"},
+		"ollama": &mockProvider{id: "ollama"},
+	}
+	ctx := context.Background()
+
+	r := NewWithConfig(Config{
+		Providers:       providers,
+		DefaultProvider: "openai",
+		CBConfig:        circuitbreaker.DefaultConfig(),
+		CBPerModel:      true,
+		Priced:          func(model string) bool { return strings.HasPrefix(model, "gpt-") },
+	})
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("openai", "gpt-4")
+	}
+	if p, err := r.SelectProvider(ctx, "", "gpt-4"); err != nil || p.ID() != "ollama" {
+		t.Errorf("gpt-4 should fall back to ollama, got %v, %v", p, err)
+	}
+	if p, err := r.SelectProvider(ctx, "", "gpt-4o-mini"); err != nil || p.ID() != "openai" {
+		t.Errorf("gpt-4o-mini should stay on openai, got %v, %v", p, err)
+	}
+	if _, err := r.SelectProvider(ctx, "openai", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("hinted gpt-4 error = %v, want ErrCircuitBreakerOpen", err)
+	}
+	if got := r.CircuitRetryAfter(ctx, "gpt-4o-mini", "openai"); got != 0 {
+		t.Errorf("CircuitRetryAfter(gpt-4o-mini) = %v, want 0", got)
+	}
+	if states := r.CircuitBreakerStates(); states["openai/gpt-4"] != "open" {
+		t.Errorf("states = %v, want openai/gpt-4 open", states)
+	}
+
+	// Unpriced, unlisted names share the provider's breaker instead of
+	// each getting one.
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("openai", "made-up-model")
+	}
+	if _, err := r.SelectProvider(ctx, "openai", "another-made-up-model"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("hinted unknown model error = %v, want ErrCircuitBreakerOpen", err)
+	}
+	states := r.CircuitBreakerStates()
+	if _, ok := states["openai/made-up-model"]; ok || states["openai"] != "open" {
+		t.Errorf("states = %v, want the shared openai breaker open and no per-model one", states)
+	}
+	if p, err := r.SelectProvider(ctx, "openai", "gpt-4o-mini"); err != nil || p.ID() != "openai" {
+		t.Errorf("priced gpt-4o-mini keeps its own breaker, got %v, %v", p, err)
+	}
+
+	shared := NewWithConfig(Config{
+		Providers:       providers,
+		DefaultProvider: "openai",
+		CBConfig:        circuitbreaker.DefaultConfig(),
+	})
+	for i := 0; i < 5; i++ {
+		shared.RecordFailure("openai", "gpt-4")
+	}
+	if p, err := shared.SelectProvider(ctx, "", "gpt-4o-mini"); err != nil || p.ID() != "ollama" {
+		t.Errorf("without CBPerModel the provider circuit is shared, got %v, %v", p, err)
+	}
+}
+
+func TestRouter_SelectProvider_HeaderRules(t *testing.T) {
+	r := NewWithConfig(Config{
+		Providers: map[string]Provider{
+			"openai":  &mockProvider{id: "openai"},
+			"bedrock": &mockProvider{id: "bedrock"},
+			"ollama":  &mockProvider{id: "ollama"},
+		},
+		DefaultProvider: "ollama",
+		CBConfig:        circuitbreaker.DefaultConfig(),
+		HeaderRules: []HeaderRule{
+			{Header: "X-Region", Value: "eu", Provider: "bedrock"},
+			{Header: "X-Region", Provider: "openai"},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		model   string
+		want    string
+	}{
+		{"first match wins over model", http.Header{"X-Region": {"eu"}}, "gpt-4", "bedrock"},
+		{"catch-all rule", http.Header{"X-Region": {"us"}}, "llama3", "openai"},
+		{"no match falls through to model", http.Header{"X-Other": {"eu"}}, "gpt-4", "openai"},
+		{"no match falls through to default", http.Header{}, "llama3", "ollama"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithRequestHeaders(context.Background(), tt.headers)
+			p, err := r.SelectProvider(ctx, "", tt.model)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.ID() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, p.ID())
+			}
+		})
+	}
+
+	// A hint still wins over header rules.
+	ctx := WithRequestHeaders(context.Background(), http.Header{"X-Region": {"eu"}})
+	if p, _ := r.SelectProvider(ctx, "openai", "gpt-4"); p == nil || p.ID() != "openai" {
+		t.Errorf("expected hint to select openai, got %v", p)
+	}
+
+	// A matched rule's provider is the only candidate.
+	if providers, err := r.SelectProviderWithFallback(ctx, "", "gpt-4"); err != nil || len(providers) != 1 || providers[0].ID() != "bedrock" {
+		t.Errorf("fallback chain = %v, %v; want only bedrock", providers, err)
+	}
+}
+
+func TestRouter_HeaderRuleProviderUnavailable(t *testing.T) {
+	r := NewWithConfig(Config{
+		Providers: map[string]Provider{
+			"openai":  &mockProvider{id: "openai"},
+			"bedrock": &mockProvider{id: "bedrock"},
+		},
+		DefaultProvider: "openai",
+		CBConfig:        circuitbreaker.DefaultConfig(),
+		HeaderRules:     []HeaderRule{{Header: "X-Region", Value: "eu", Provider: "bedrock"}},
+	})
+	eu := WithRequestHeaders(context.Background(), http.Header{"X-Region": {"eu"}})
+
+	// The tenant may not use the rule's provider.
+	disallowed := WithAllowedProviders(eu, []string{"openai"})
+	if p, err := r.SelectProvider(disallowed, "", "gpt-4"); !errors.Is(err, domain.ErrRouteUnavailable) {
+		t.Errorf("SelectProvider() = %v, %v; want ErrRouteUnavailable", p, err)
+	}
+	if ps, err := r.SelectProviderWithFallback(disallowed, "", "gpt-4"); !errors.Is(err, domain.ErrRouteUnavailable) {
+		t.Errorf("SelectProviderWithFallback() = %v, %v; want ErrRouteUnavailable", ps, err)
+	}
+
+	// The rule's provider has an open circuit.
+	for i := 0; i < 5; i++ {
+		r.RecordFailure("bedrock", "gpt-4")
+	}
+	if p, err := r.SelectProvider(eu, "", "gpt-4"); !errors.Is(err, domain.ErrRouteUnavailable) {
+		t.Errorf("SelectProvider() = %v, %v; want ErrRouteUnavailable", p, err)
+	}
+	if ps, err := r.SelectProviderWithFallback(eu, "", "gpt-4"); !errors.Is(err, domain.ErrRouteUnavailable) {
+		t.Errorf("SelectProviderWithFallback() = %v, %v; want ErrRouteUnavailable", ps, err)
+	}
+
+	// Requests no rule matches are unaffected.
+	if p, err := r.SelectProvider(context.Background(), "", "gpt-4"); err != nil || p.ID() != "openai" {
+		t.Errorf("unmatched request = %v, %v; want openai", p, err)
+	}
+}
+
+func TestValidateHeaderRules(t *testing.T) {
+	providers := map[string]Provider{"bedrock": &mockProvider{id: "bedrock"}}
+
+	if err := ValidateHeaderRules([]HeaderRule{{Header: "X-Region", Value: "eu", Provider: "bedrock"}}, providers); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateHeaderRules([]HeaderRule{{Header: "X-Region", Provider: "vertex"}}, providers); err == nil {
+		t.Error("expected error for unregistered provider")
+	}
+	if err := ValidateHeaderRules([]HeaderRule{{Provider: "bedrock"}}, providers); err == nil {
+		t.Error("expected error for missing header")
+	}
+}
+
+func TestRouter_RecordLatencyOpensCircuit(t *testing.T) {
+	cbConfig := circuitbreaker.DefaultConfig()
+	cbConfig.LatencyThreshold = 100 * time.Millisecond
+	cbConfig.LatencyMinSamples = 5
+
+	r := NewWithConfig(Config{
+		Providers: map[string]Provider{
+			"openai": &mockProvider{id: "openai"},
+		},
+		DefaultProvider: "openai",
+		CBConfig:        cbConfig,
+	})
+
+	for i := 0; i < 5; i++ {
+		r.RecordLatency("openai", "", time.Second)
+	}
+
+	if _, err := r.SelectProvider(context.Background(), "openai", "gpt-4"); err != domain.ErrCircuitBreakerOpen {
+		t.Errorf("expected ErrCircuitBreakerOpen, got %v", err)
+	}
 }
 
 func TestRouter_CircuitBreakerStates(t *testing.T) {
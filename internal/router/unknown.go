@@ -0,0 +1,226 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// UnknownModels decides where a request goes when neither a provider hint,
+// a header rule nor the model's name picks a provider.
+type UnknownModels string
+
+const (
+	// UnknownModelsDefault sends the request to the default provider, then
+	// the fallbacks, whether or not they serve the model.
+	UnknownModelsDefault UnknownModels = "default"
+	// UnknownModelsReject fails with domain.ErrUnknownModel unless the
+	// model is priced or a provider lists it; known models continue as with
+	// UnknownModelsDefault.
+	UnknownModelsReject UnknownModels = "reject"
+	// UnknownModelsProbe asks every provider for its models and routes to
+	// the first, in fallback order, that lists the model. Lists are cached.
+	// Models nobody lists are rejected unless priced.
+	UnknownModelsProbe UnknownModels = "broadcast-probe"
+)
+
+// Valid reports whether u is a known strategy; empty means the default.
+func (u UnknownModels) Valid() bool {
+	switch u {
+	case "", UnknownModelsDefault, UnknownModelsReject, UnknownModelsProbe:
+		return true
+	}
+	return false
+}
+
+// DefaultProbeTTL is how long a provider's model list is trusted by the
+// broadcast-probe strategy when no TTL is configured.
+const DefaultProbeTTL = 5 * time.Minute
+
+// DefaultProbeTimeout bounds one provider's model listing when no timeout is
+// configured.
+const DefaultProbeTimeout = 5 * time.Second
+
+// probeRetryDelay is how long a failed listing is remembered, so a provider
+// that is down is not asked again by every request for an unknown model.
+const probeRetryDelay = 30 * time.Second
+
+// modelProbe caches each provider's model list for unknown-model routing.
+type modelProbe struct {
+	ttl     time.Duration
+	timeout time.Duration
+	retry   time.Duration
+
+	mu    sync.Mutex
+	lists map[string]probedModels
+	// listing holds a channel per provider being listed, closed when the
+	// listing is stored, so concurrent misses share one call.
+	listing map[string]chan struct{}
+}
+
+type probedModels struct {
+	models   map[string]bool
+	failed   bool
+	listedAt time.Time
+}
+
+func newModelProbe(ttl, timeout time.Duration) *modelProbe {
+	if ttl <= 0 {
+		ttl = DefaultProbeTTL
+	}
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	return &modelProbe{
+		ttl:     ttl,
+		timeout: timeout,
+		retry:   min(probeRetryDelay, ttl),
+		lists:   make(map[string]probedModels),
+		listing: make(map[string]chan struct{}),
+	}
+}
+
+// fresh reports whether a stored listing may still be used; failures are
+// kept for less time than lists.
+func (m *modelProbe) fresh(l probedModels) bool {
+	ttl := m.ttl
+	if l.failed {
+		ttl = m.retry
+	}
+	return time.Since(l.listedAt) < ttl
+}
+
+// list returns p's models, asking p only when its stored listing is missing
+// or stale. The call is bounded by the probe timeout rather than ctx, so a
+// caller that gives up does not fail the others waiting on it.
+func (m *modelProbe) list(ctx context.Context, p Provider) probedModels {
+	id := p.ID()
+	m.mu.Lock()
+	if l, ok := m.lists[id]; ok && m.fresh(l) {
+		m.mu.Unlock()
+		return l
+	}
+	done, ok := m.listing[id]
+	if !ok {
+		done = make(chan struct{})
+		m.listing[id] = done
+		go m.fetch(context.WithoutCancel(ctx), p, done)
+	}
+	m.mu.Unlock()
+
+	wait := time.NewTimer(m.timeout)
+	defer wait.Stop()
+	select {
+	case <-done:
+	case <-wait.C:
+		return probedModels{failed: true}
+	case <-ctx.Done():
+		return probedModels{failed: true}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lists[id]
+}
+
+func (m *modelProbe) fetch(ctx context.Context, p Provider, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	l := probedModels{listedAt: time.Now()}
+	models, err := p.Models(ctx)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		slog.Warn("model probe failed", "provider", p.ID(), "error", err)
+		l.failed = true
+	} else {
+		l.models = make(map[string]bool, len(models))
+		for _, mdl := range models {
+			l.models[mdl.ID] = true
+		}
+	}
+
+	m.mu.Lock()
+	m.lists[p.ID()] = l
+	delete(m.listing, p.ID())
+	m.mu.Unlock()
+	close(done)
+}
+
+// listed reports whether the provider's last successful listing included
+// model, without asking the provider.
+func (m *modelProbe) listed(providerID, model string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lists[providerID].models[model]
+}
+
+// listAll lists every provider in ids at once.
+func (m *modelProbe) listAll(ctx context.Context, providers map[string]Provider, ids []string) map[string]probedModels {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	lists := make(map[string]probedModels, len(ids))
+	for _, id := range ids {
+		p, ok := providers[id]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := m.list(ctx, p)
+			mu.Lock()
+			lists[id] = l
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return lists
+}
+
+// routeUnknownModel applies the unknown-model strategy. A nil provider and
+// nil error leave the request to the default provider and fallbacks.
+//
+// A model counts as known when it is priced or some provider lists it.
+// Models are only rejected when every provider answered and none lists
+// them; an unreachable provider might.
+func (r *Router) routeUnknownModel(ctx context.Context, model string) (Provider, error) {
+	if r.unknownModels != UnknownModelsReject && r.unknownModels != UnknownModelsProbe {
+		return nil, nil
+	}
+	priced := r.priced != nil && r.priced(model)
+	if priced && r.unknownModels == UnknownModelsReject {
+		return nil, nil
+	}
+
+	lists := r.probe.listAll(ctx, r.providers, r.fallbackOrder)
+	listedBy, complete := 0, true
+	for _, id := range r.fallbackOrder {
+		l, ok := lists[id]
+		if !ok {
+			continue
+		}
+		if l.failed {
+			complete = false
+			continue
+		}
+		if !l.models[model] {
+			continue
+		}
+		listedBy++
+		if r.unknownModels == UnknownModelsProbe && providerAllowed(ctx, id) && r.breaker(id, model).Allow(ctx) == nil {
+			return r.providers[id], nil
+		}
+	}
+
+	if listedBy == 0 && complete && !priced {
+		return nil, fmt.Errorf("%w: %q", domain.ErrUnknownModel, model)
+	}
+	return nil, nil
+}
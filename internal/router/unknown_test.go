@@ -0,0 +1,217 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// listingProvider is a mockProvider that lists models and counts listings.
+type listingProvider struct {
+	mockProvider
+	models []string
+	err    error
+	calls  int
+}
+
+func (p *listingProvider) Models(ctx context.Context) ([]domain.Model, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	models := make([]domain.Model, len(p.models))
+	for i, id := range p.models {
+		models[i] = domain.Model{ID: id}
+	}
+	return models, nil
+}
+
+func TestRouter_UnknownModelsReject(t *testing.T) {
+	r := NewWithConfig(Config{
+		Providers: map[string]Provider{
+			"openai": &mockProvider{id: "openai"},
+			"ollama": &mockProvider{id: "ollama"},
+		},
+		DefaultProvider: "ollama",
+		UnknownModels:   UnknownModelsReject,
+	})
+	ctx := context.Background()
+
+	if _, err := r.SelectProvider(ctx, "", "llama3.2"); !errors.Is(err, domain.ErrUnknownModel) {
+		t.Errorf("SelectProvider(unknown) error = %v, want ErrUnknownModel", err)
+	}
+	if _, err := r.SelectProviderWithFallback(ctx, "", "llama3.2"); !errors.Is(err, domain.ErrUnknownModel) {
+		t.Errorf("SelectProviderWithFallback(unknown) error = %v, want ErrUnknownModel", err)
+	}
+	if p, err := r.SelectProvider(ctx, "", "gpt-4"); err != nil || p.ID() != "openai" {
+		t.Errorf("SelectProvider(gpt-4) = %v, %v; want openai", p, err)
+	}
+	if p, err := r.SelectProvider(ctx, "ollama", "llama3.2"); err != nil || p.ID() != "ollama" {
+		t.Errorf("SelectProvider with hint = %v, %v; want ollama", p, err)
+	}
+}
+
+func TestRouter_UnknownModelsRejectKnownModels(t *testing.T) {
+	newRouter := func(priced func(string) bool) *Router {
+		return NewWithConfig(Config{
+			Providers: map[string]Provider{
+				"openai": &listingProvider{mockProvider: mockProvider{id: "openai"}, models: []string{"gpt-4o-mini"}},
+				"ollama": &listingProvider{mockProvider: mockProvider{id: "ollama"}, models: []string{"llama3"}},
+			},
+			DefaultProvider: "ollama",
+			UnknownModels:   UnknownModelsReject,
+			Priced:          priced,
+		})
+	}
+	ctx := context.Background()
+
+	priced := newRouter(func(model string) bool { return model == "gpt-4o" })
+	if _, err := priced.SelectProvider(ctx, "", "gpt-4o"); err != nil {
+		t.Errorf("SelectProvider(gpt-4o) error = %v, want a priced model accepted", err)
+	}
+
+	listed := newRouter(nil)
+	for _, model := range []string{"gpt-4o-mini", "llama3"} {
+		if _, err := listed.SelectProvider(ctx, "", model); err != nil {
+			t.Errorf("SelectProvider(%s) error = %v, want a listed model accepted", model, err)
+		}
+	}
+	if _, err := listed.SelectProvider(ctx, "", "gpt-4o"); !errors.Is(err, domain.ErrUnknownModel) {
+		t.Errorf("SelectProvider(gpt-4o) error = %v, want ErrUnknownModel when neither priced nor listed", err)
+	}
+}
+
+// blockingProvider lists its models only once release is closed, counting
+// how often it is asked.
+type blockingProvider struct {
+	mockProvider
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (p *blockingProvider) Models(ctx context.Context) ([]domain.Model, error) {
+	p.calls.Add(1)
+	select {
+	case <-p.release:
+		return []domain.Model{{ID: "llama3.2"}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestModelProbe_SharesAndBoundsListings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("concurrent misses share one listing", func(t *testing.T) {
+		ollama := &blockingProvider{mockProvider: mockProvider{id: "ollama"}, release: make(chan struct{})}
+		probe := newModelProbe(time.Hour, time.Second)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if l := probe.list(ctx, ollama); l.failed || !l.models["llama3.2"] {
+					t.Errorf("list() = %+v, want llama3.2", l)
+				}
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(ollama.release)
+		wg.Wait()
+		if n := ollama.calls.Load(); n != 1 {
+			t.Errorf("ollama listed %d times, want 1", n)
+		}
+	})
+
+	t.Run("a hanging provider times out and is not asked again at once", func(t *testing.T) {
+		ollama := &blockingProvider{mockProvider: mockProvider{id: "ollama"}, release: make(chan struct{})}
+		probe := newModelProbe(time.Hour, 20*time.Millisecond)
+
+		start := time.Now()
+		if l := probe.list(ctx, ollama); !l.failed {
+			t.Errorf("list() = %+v, want failed", l)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("list() took %v, want it bounded by the probe timeout", elapsed)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if l := probe.list(ctx, ollama); !l.failed {
+			t.Errorf("second list() = %+v, want the cached failure", l)
+		}
+		if n := ollama.calls.Load(); n != 1 {
+			t.Errorf("ollama listed %d times, want 1 (failure cached)", n)
+		}
+	})
+}
+
+func TestRouter_UnknownModelsProbe(t *testing.T) {
+	newRouter := func(openai, ollama, mistral *listingProvider) *Router {
+		openai.id, ollama.id, mistral.id = "openai", "ollama", "mistral"
+		return NewWithConfig(Config{
+			Providers:       map[string]Provider{"openai": openai, "ollama": ollama, "mistral": mistral},
+			DefaultProvider: "openai",
+			UnknownModels:   UnknownModelsProbe,
+			ProbeTTL:        time.Hour,
+		})
+	}
+	ctx := context.Background()
+
+	t.Run("routes to the provider listing the model", func(t *testing.T) {
+		ollama := &listingProvider{models: []string{"llama3.2", "qwen2"}}
+		r := newRouter(&listingProvider{models: []string{"gpt-4o"}}, ollama, &listingProvider{})
+
+		for i := 0; i < 3; i++ {
+			p, err := r.SelectProvider(ctx, "", "llama3.2")
+			if err != nil || p.ID() != "ollama" {
+				t.Fatalf("SelectProvider() = %v, %v; want ollama", p, err)
+			}
+		}
+		if p, err := r.SelectProvider(ctx, "", "qwen2"); err != nil || p.ID() != "ollama" {
+			t.Fatalf("SelectProvider(qwen2) = %v, %v; want ollama", p, err)
+		}
+		if ollama.calls != 1 {
+			t.Errorf("ollama listed %d times, want 1 (cached)", ollama.calls)
+		}
+	})
+
+	t.Run("rejects a model nobody lists", func(t *testing.T) {
+		r := newRouter(&listingProvider{models: []string{"gpt-4o"}}, &listingProvider{models: []string{"llama3.2"}}, &listingProvider{})
+		if _, err := r.SelectProviderWithFallback(ctx, "", "no-such-model"); !errors.Is(err, domain.ErrUnknownModel) {
+			t.Errorf("error = %v, want ErrUnknownModel", err)
+		}
+	})
+
+	t.Run("falls back to default when a provider cannot be listed", func(t *testing.T) {
+		r := newRouter(&listingProvider{models: []string{"gpt-4o"}}, &listingProvider{err: errors.New("connection refused")}, &listingProvider{})
+		if p, err := r.SelectProvider(ctx, "", "llama3.2"); err != nil || p.ID() != "openai" {
+			t.Errorf("SelectProvider() = %v, %v; want default provider", p, err)
+		}
+	})
+
+	t.Run("skips providers outside the tenant's set", func(t *testing.T) {
+		r := newRouter(&listingProvider{}, &listingProvider{models: []string{"llama3.2"}}, &listingProvider{models: []string{"llama3.2"}})
+		allowed := WithAllowedProviders(ctx, []string{"openai", "ollama"})
+		if p, err := r.SelectProvider(allowed, "", "llama3.2"); err != nil || p.ID() != "ollama" {
+			t.Errorf("SelectProvider() = %v, %v; want ollama", p, err)
+		}
+	})
+
+	t.Run("relists after the TTL", func(t *testing.T) {
+		ollama := &listingProvider{models: []string{"llama3.2"}}
+		r := newRouter(&listingProvider{}, ollama, &listingProvider{})
+		r.probe.ttl = time.Millisecond
+
+		r.SelectProvider(ctx, "", "llama3.2")
+		time.Sleep(5 * time.Millisecond)
+		r.SelectProvider(ctx, "", "llama3.2")
+		if ollama.calls != 2 {
+			t.Errorf("ollama listed %d times, want 2", ollama.calls)
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Strategy picks the primary provider when a request names neither a
+// provider nor a model the router maps to one. Candidates are the providers
+// whose circuit breakers currently allow traffic, in a stable order; states
+// maps each candidate to its breaker state.
+type Strategy interface {
+	Pick(candidates []string, states map[string]string) string
+}
+
+// latencyObserver is implemented by strategies that score on latency.
+type latencyObserver interface {
+	ObserveLatency(providerID string, latency time.Duration)
+}
+
+// latencyAlpha is the smoothing factor for the latency moving average.
+const latencyAlpha = 0.2
+
+// WeightedStrategy picks providers at random in proportion to a score built
+// from relative cost, observed latency and circuit breaker health, so traffic
+// spreads across providers instead of piling onto the single best one.
+//
+// A provider's score is
+//
+//	(cost * 1/(1+relativeCost) + latency * 1/(1+avgLatencySeconds)) * health^healthWeight
+//
+// where health is 1 for a closed breaker and 0.25 while half-open. Providers
+// without a configured cost count as relative cost 1, and providers with no
+// latency samples yet score as if they were instant so they receive traffic.
+type WeightedStrategy struct {
+	costWeight    float64
+	latencyWeight float64
+	healthWeight  float64
+	costs         map[string]float64
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	latency map[string]float64
+}
+
+// WeightedOption configures a WeightedStrategy.
+type WeightedOption func(*WeightedStrategy)
+
+// WithWeights sets how much cost, latency and health contribute to the score.
+// Negative weights are treated as zero.
+func WithWeights(cost, latency, health float64) WeightedOption {
+	return func(s *WeightedStrategy) {
+		s.costWeight = math.Max(cost, 0)
+		s.latencyWeight = math.Max(latency, 0)
+		s.healthWeight = math.Max(health, 0)
+	}
+}
+
+// WithProviderCosts sets the relative cost of each provider, e.g. average
+// USD per 1K tokens. Only the ratios between providers matter.
+func WithProviderCosts(costs map[string]float64) WeightedOption {
+	return func(s *WeightedStrategy) {
+		for id, c := range costs {
+			s.costs[id] = c
+		}
+	}
+}
+
+// WithSeed makes selection deterministic, for tests.
+func WithSeed(seed uint64) WeightedOption {
+	return func(s *WeightedStrategy) {
+		s.rng = rand.New(rand.NewPCG(seed, seed))
+	}
+}
+
+// NewWeightedStrategy creates a strategy that weighs cost, latency and
+// health equally unless configured otherwise.
+func NewWeightedStrategy(opts ...WeightedOption) *WeightedStrategy {
+	s := &WeightedStrategy{
+		costWeight:    1,
+		latencyWeight: 1,
+		healthWeight:  1,
+		costs:         make(map[string]float64),
+		rng:           rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+		latency:       make(map[string]float64),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ObserveLatency folds a completed request's latency into the provider's
+// moving average.
+func (s *WeightedStrategy) ObserveLatency(providerID string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seconds := latency.Seconds()
+	if prev, ok := s.latency[providerID]; ok {
+		seconds = latencyAlpha*seconds + (1-latencyAlpha)*prev
+	}
+	s.latency[providerID] = seconds
+}
+
+// Pick returns a candidate chosen with probability proportional to its score.
+func (s *WeightedStrategy) Pick(candidates []string, states map[string]string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scores := make([]float64, len(candidates))
+	total := 0.0
+	for i, id := range candidates {
+		scores[i] = s.score(id, states[id])
+		total += scores[i]
+	}
+	if total <= 0 {
+		return candidates[0]
+	}
+
+	target := s.rng.Float64() * total
+	for i, score := range scores {
+		target -= score
+		if target < 0 {
+			return candidates[i]
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// Score returns the provider's current selection weight.
+func (s *WeightedStrategy) Score(providerID, state string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.score(providerID, state)
+}
+
+func (s *WeightedStrategy) score(providerID, state string) float64 {
+	relativeCost, ok := s.costs[providerID]
+	if !ok {
+		relativeCost = 1
+	}
+	costScore := 1 / (1 + math.Max(relativeCost, 0))
+	latencyScore := 1 / (1 + s.latency[providerID])
+
+	base := s.costWeight*costScore + s.latencyWeight*latencyScore
+	if s.costWeight == 0 && s.latencyWeight == 0 {
+		base = 1
+	}
+
+	health := 1.0
+	if state == "half-open" {
+		health = 0.25
+	}
+	return base * math.Pow(health, s.healthWeight)
+}
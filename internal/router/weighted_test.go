@@ -0,0 +1,100 @@
+package router
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/felipepmaragno/ai-gateway/internal/circuitbreaker"
+)
+
+func TestWeightedStrategy_DistributionMatchesScores(t *testing.T) {
+	s := NewWeightedStrategy(
+		WithWeights(1, 0, 1),
+		WithProviderCosts(map[string]float64{"cheap": 0, "pricey": 3}),
+		WithSeed(42),
+	)
+	candidates := []string{"cheap", "pricey"}
+	states := map[string]string{"cheap": "closed", "pricey": "closed"}
+
+	// cheap scores 1/(1+0) = 1, pricey 1/(1+3) = 0.25, so cheap should win
+	// 80% of picks.
+	const n = 10000
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		counts[s.Pick(candidates, states)]++
+	}
+
+	got := float64(counts["cheap"]) / n
+	if math.Abs(got-0.8) > 0.02 {
+		t.Errorf("cheap share = %.3f, want ~0.80 (counts %v)", got, counts)
+	}
+}
+
+func TestWeightedStrategy_HalfOpenAndLatencyLowerScore(t *testing.T) {
+	s := NewWeightedStrategy(WithWeights(0, 1, 1))
+
+	if closed, half := s.Score("a", "closed"), s.Score("a", "half-open"); half >= closed {
+		t.Errorf("half-open score %.3f should be below closed %.3f", half, closed)
+	}
+
+	s.ObserveLatency("slow", 3*time.Second)
+	s.ObserveLatency("fast", 100*time.Millisecond)
+	if slow, fast := s.Score("slow", "closed"), s.Score("fast", "closed"); slow >= fast {
+		t.Errorf("slow score %.3f should be below fast %.3f", slow, fast)
+	}
+}
+
+func TestWeightedStrategy_SeedIsDeterministic(t *testing.T) {
+	candidates := []string{"a", "b", "c"}
+	pick := func() []string {
+		s := NewWeightedStrategy(WithSeed(7))
+		var picks []string
+		for i := 0; i < 20; i++ {
+			picks = append(picks, s.Pick(candidates, nil))
+		}
+		return picks
+	}
+
+	first, second := pick(), pick()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("pick %d differs between identically seeded strategies: %v vs %v", i, first, second)
+		}
+	}
+}
+
+func TestRouter_WeightedStrategySkipsOpenCircuits(t *testing.T) {
+	providers := map[string]Provider{
+		"openai":    &mockProvider{id: "openai"},
+		"anthropic": &mockProvider{id: "anthropic"},
+	}
+	cbConfig := circuitbreaker.DefaultConfig()
+	r := NewWithConfig(Config{
+		Providers:       providers,
+		DefaultProvider: "openai",
+		CBConfig:        cbConfig,
+		Strategy:        NewWeightedStrategy(WithSeed(1)),
+	})
+
+	for i := 0; i < cbConfig.FailureThreshold; i++ {
+		r.RecordFailure("anthropic", "")
+	}
+
+	for i := 0; i < 50; i++ {
+		p, err := r.SelectProvider(context.Background(), "", "some-model")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if p.ID() != "openai" {
+			t.Fatalf("selected %s with its circuit open", p.ID())
+		}
+	}
+
+	// A hint still pins the provider regardless of strategy.
+	p, err := r.SelectProvider(context.Background(), "openai", "some-model")
+	if err != nil || p.ID() != "openai" {
+		t.Errorf("hinted selection = %v, %v", p, err)
+	}
+}
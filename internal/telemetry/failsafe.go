@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// failsafeExporter stops exporting after maxFailures consecutive export
+// errors. A collector that is down or misconfigured then costs nothing
+// instead of producing an error log line for every batch.
+type failsafeExporter struct {
+	sdktrace.SpanExporter
+	maxFailures int32
+	failures    atomic.Int32
+	disabled    atomic.Bool
+}
+
+func newFailsafeExporter(exporter sdktrace.SpanExporter, maxFailures int) *failsafeExporter {
+	return &failsafeExporter{
+		SpanExporter: exporter,
+		maxFailures:  int32(maxFailures),
+	}
+}
+
+func (e *failsafeExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.disabled.Load() {
+		return nil
+	}
+
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err == nil {
+		e.failures.Store(0)
+		return nil
+	}
+
+	if e.failures.Add(1) >= e.maxFailures && e.disabled.CompareAndSwap(false, true) {
+		slog.Warn("telemetry export keeps failing, disabling tracing",
+			"consecutive_failures", e.maxFailures,
+			"error", err,
+		)
+		return nil
+	}
+	return err
+}
+
+// Disabled reports whether the exporter has given up.
+func (e *failsafeExporter) Disabled() bool {
+	return e.disabled.Load()
+}
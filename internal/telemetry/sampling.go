@@ -0,0 +1,155 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures Init.
+type Option func(*options)
+
+type options struct {
+	sampleRatio        float64
+	alwaysSampleErrors bool
+	maxExportFailures  int
+}
+
+func defaultOptions() options {
+	return options{
+		sampleRatio:        1.0,
+		alwaysSampleErrors: true,
+		maxExportFailures:  5,
+	}
+}
+
+// WithSampleRatio sets the fraction of new traces to sample (0.0 to 1.0).
+// Values >= 1 sample everything, which is convenient in development.
+// Traces with a sampled remote parent are always kept.
+func WithSampleRatio(ratio float64) Option {
+	return func(o *options) {
+		o.sampleRatio = ratio
+	}
+}
+
+// WithAlwaysSampleErrors controls whether spans ending with an error status
+// are exported even when their trace was not selected by the ratio sampler.
+func WithAlwaysSampleErrors(enabled bool) Option {
+	return func(o *options) {
+		o.alwaysSampleErrors = enabled
+	}
+}
+
+// WithMaxExportFailures sets how many consecutive failed exports are
+// tolerated before tracing is switched off for the life of the process.
+// Values <= 0 keep the default.
+func WithMaxExportFailures(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxExportFailures = n
+		}
+	}
+}
+
+// newSampler builds a parent-based sampler. Root spans are sampled by trace ID
+// ratio; child spans follow their parent's decision.
+func newSampler(o options) sdktrace.Sampler {
+	if o.sampleRatio >= 1 {
+		return sdktrace.AlwaysSample()
+	}
+
+	root := sdktrace.TraceIDRatioBased(o.sampleRatio)
+	if o.alwaysSampleErrors {
+		// Unsampled spans must still be recorded so that an error seen later
+		// in the request can be exported by errorSpanProcessor.
+		root = recordingSampler{root}
+	}
+
+	return sdktrace.ParentBased(root,
+		sdktrace.WithLocalParentNotSampled(localNotSampled(o)),
+	)
+}
+
+func localNotSampled(o options) sdktrace.Sampler {
+	if o.alwaysSampleErrors {
+		return recordingSampler{sdktrace.NeverSample()}
+	}
+	return sdktrace.NeverSample()
+}
+
+// recordingSampler upgrades Drop decisions to RecordOnly. Recorded but
+// unsampled spans are skipped by the batch processor, so only spans picked
+// up by errorSpanProcessor are exported.
+type recordingSampler struct {
+	sdktrace.Sampler
+}
+
+func (s recordingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	res := s.Sampler.ShouldSample(p)
+	if res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s recordingSampler) Description() string {
+	return "Recording{" + s.Sampler.Description() + "}"
+}
+
+// errorSpanProcessor exports spans that ended with an error status but were
+// not sampled. Sampled spans are left to the batch processor.
+type errorSpanProcessor struct {
+	exporter sdktrace.SpanExporter
+	timeout  time.Duration
+	wg       sync.WaitGroup
+}
+
+func newErrorSpanProcessor(exporter sdktrace.SpanExporter) *errorSpanProcessor {
+	return &errorSpanProcessor{
+		exporter: exporter,
+		timeout:  5 * time.Second,
+	}
+}
+
+func (p *errorSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *errorSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() || s.Status().Code != codes.Error {
+		return
+	}
+
+	// Export off the request path; errors are rare so no batching is needed.
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		defer cancel()
+		_ = p.exporter.ExportSpans(ctx, []sdktrace.ReadOnlySpan{s})
+	}()
+}
+
+func (p *errorSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.wait(ctx)
+}
+
+func (p *errorSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.wait(ctx)
+}
+
+func (p *errorSpanProcessor) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,33 +2,59 @@ package telemetry
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 var tracer trace.Tracer
 
-func Init(ctx context.Context, serviceName, otlpEndpoint string) (func(context.Context) error, error) {
+// exportRetry bounds how long a single batch is retried against an
+// unreachable collector before it is dropped.
+var exportRetry = otlptracegrpc.RetryConfig{
+	Enabled:         true,
+	InitialInterval: time.Second,
+	MaxInterval:     5 * time.Second,
+	MaxElapsedTime:  15 * time.Second,
+}
+
+func noopShutdown(context.Context) error { return nil }
+
+// Init sets up tracing against the OTLP collector at otlpEndpoint. The
+// collector connection is established lazily, so an unreachable endpoint
+// does not delay startup. On error, tracing falls back to a no-op tracer and
+// the returned shutdown func is still safe to call.
+func Init(ctx context.Context, serviceName, otlpEndpoint string, opts ...Option) (func(context.Context) error, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	if otlpEndpoint == "" {
 		tracer = otel.Tracer(serviceName)
 		slog.Info("telemetry disabled, no OTLP endpoint configured")
-		return func(ctx context.Context) error { return nil }, nil
+		return noopShutdown, nil
 	}
 
 	exporter, err := otlptracegrpc.New(ctx,
 		otlptracegrpc.WithEndpoint(otlpEndpoint),
 		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithRetry(exportRetry),
 	)
 	if err != nil {
-		return nil, err
+		tracer = noop.NewTracerProvider().Tracer(serviceName)
+		return noopShutdown, fmt.Errorf("create OTLP exporter: %w", err)
 	}
 
 	res, err := resource.New(ctx,
@@ -38,14 +64,11 @@ func Init(ctx context.Context, serviceName, otlpEndpoint string) (func(context.C
 		),
 	)
 	if err != nil {
-		return nil, err
+		tracer = noop.NewTracerProvider().Tracer(serviceName)
+		return noopShutdown, fmt.Errorf("create resource: %w", err)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-	)
+	tp := newTracerProvider(newFailsafeExporter(exporter, o.maxExportFailures), res, o)
 
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
@@ -55,11 +78,29 @@ func Init(ctx context.Context, serviceName, otlpEndpoint string) (func(context.C
 
 	tracer = tp.Tracer(serviceName)
 
-	slog.Info("telemetry initialized", "endpoint", otlpEndpoint)
+	slog.Info("telemetry initialized",
+		"endpoint", otlpEndpoint,
+		"sample_ratio", o.sampleRatio,
+		"always_sample_errors", o.alwaysSampleErrors,
+	)
 
 	return tp.Shutdown, nil
 }
 
+func newTracerProvider(exporter sdktrace.SpanExporter, res *resource.Resource, o options) *sdktrace.TracerProvider {
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(newSampler(o)),
+	}
+	if o.sampleRatio < 1 && o.alwaysSampleErrors {
+		// Registered before the batcher so it shuts down before the exporter does.
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(newErrorSpanProcessor(exporter)))
+	}
+	tpOpts = append(tpOpts, sdktrace.WithBatcher(exporter))
+
+	return sdktrace.NewTracerProvider(tpOpts...)
+}
+
 func Tracer() trace.Tracer {
 	if tracer == nil {
 		tracer = otel.Tracer("ai-gateway")
@@ -105,6 +146,7 @@ func AddErrorAttribute(span trace.Span, err error) {
 		attribute.String("error.message", err.Error()),
 	)
 	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
 }
 
 func GetTraceID(ctx context.Context) string {
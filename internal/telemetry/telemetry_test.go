@@ -0,0 +1,172 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     options
+		contains string
+	}{
+		{"always sample", options{sampleRatio: 1.0}, "AlwaysOnSampler"},
+		{"ratio", options{sampleRatio: 0.05}, "TraceIDRatioBased{0.05}"},
+		{"ratio is parent based", options{sampleRatio: 0.05}, "ParentBased"},
+		{"ratio with errors", options{sampleRatio: 0.05, alwaysSampleErrors: true}, "Recording{TraceIDRatioBased{0.05}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desc := newSampler(tt.opts).Description()
+			if !strings.Contains(desc, tt.contains) {
+				t.Errorf("Description() = %q, want it to contain %q", desc, tt.contains)
+			}
+		})
+	}
+}
+
+func TestTracerProvider_RatioSampler(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(exporter, resource.Empty(), options{sampleRatio: 0, alwaysSampleErrors: false})
+	defer tp.Shutdown(context.Background())
+
+	tr := tp.Tracer("test")
+	for i := 0; i < 10; i++ {
+		_, span := tr.Start(context.Background(), "request")
+		span.End()
+	}
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+	if got := len(exporter.GetSpans()); got != 0 {
+		t.Errorf("expected no spans exported at ratio 0, got %d", got)
+	}
+}
+
+func TestTracerProvider_AlwaysSampleErrors(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(exporter, resource.Empty(), options{sampleRatio: 0, alwaysSampleErrors: true})
+	defer tp.Shutdown(context.Background())
+
+	tr := tp.Tracer("test")
+
+	_, ok := tr.Start(context.Background(), "ok")
+	ok.End()
+
+	_, failed := tr.Start(context.Background(), "failed")
+	AddErrorAttribute(failed, errors.New("provider timeout"))
+	failed.End()
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected only the error span exported, got %d", len(spans))
+	}
+	if spans[0].Name != "failed" {
+		t.Errorf("exported span = %q, want %q", spans[0].Name, "failed")
+	}
+}
+
+func TestTracerProvider_AlwaysSample(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := newTracerProvider(exporter, resource.Empty(), defaultOptions())
+	defer tp.Shutdown(context.Background())
+
+	_, span := tp.Tracer("test").Start(context.Background(), "request")
+	span.End()
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush failed: %v", err)
+	}
+	if got := len(exporter.GetSpans()); got != 1 {
+		t.Errorf("expected 1 span exported, got %d", got)
+	}
+}
+
+// scriptedExporter returns results in order, then fails every call after.
+type scriptedExporter struct {
+	results []error
+	calls   int
+}
+
+func (e *scriptedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	defer func() { e.calls++ }()
+	if e.calls < len(e.results) {
+		return e.results[e.calls]
+	}
+	return errors.New("connection refused")
+}
+
+func (e *scriptedExporter) Shutdown(ctx context.Context) error { return nil }
+
+func TestFailsafeExporter_DisablesAfterRepeatedFailures(t *testing.T) {
+	inner := &scriptedExporter{}
+	exporter := newFailsafeExporter(inner, 3)
+
+	for i := 0; i < 2; i++ {
+		if err := exporter.ExportSpans(context.Background(), nil); err == nil {
+			t.Fatalf("export %d: expected error before the limit", i)
+		}
+	}
+	if exporter.Disabled() {
+		t.Fatal("expected exporter to stay enabled below the limit")
+	}
+
+	if err := exporter.ExportSpans(context.Background(), nil); err != nil {
+		t.Errorf("expected the disabling export to swallow the error, got %v", err)
+	}
+	if !exporter.Disabled() {
+		t.Fatal("expected exporter to be disabled after 3 failures")
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := exporter.ExportSpans(context.Background(), nil); err != nil {
+			t.Errorf("expected no error once disabled, got %v", err)
+		}
+	}
+	if inner.calls != 3 {
+		t.Errorf("expected 3 calls to the inner exporter, got %d", inner.calls)
+	}
+}
+
+func TestFailsafeExporter_SuccessResetsFailures(t *testing.T) {
+	refused := errors.New("connection refused")
+	inner := &scriptedExporter{results: []error{refused, nil, refused}}
+	exporter := newFailsafeExporter(inner, 2)
+
+	for i := 0; i < 3; i++ {
+		_ = exporter.ExportSpans(context.Background(), nil)
+	}
+
+	if exporter.Disabled() {
+		t.Error("expected non-consecutive failures not to disable the exporter")
+	}
+}
+
+func TestInit_UnreachableEndpointDoesNotBlock(t *testing.T) {
+	start := time.Now()
+	shutdown, err := Init(context.Background(), "test", "127.0.0.1:1")
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Init took %v, expected it not to wait for the collector", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_ = shutdown(ctx)
+}
@@ -0,0 +1,130 @@
+package transform
+
+import (
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// DefaultReplacement is substituted for redacted matches when a rule sets no
+// replacement.
+const DefaultReplacement = "[REDACTED]"
+
+// streamHoldback is how much trailing streamed content is held back so a
+// match split across chunks is still caught. Patterns whose matches become
+// recognisable within this many bytes are redacted reliably when streaming.
+const streamHoldback = 64
+
+type redactor struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// newRedactor compiles a rule that has already passed validation.
+func newRedactor(rule domain.TransformRule) redactor {
+	replacement := rule.Replacement
+	if replacement == "" {
+		replacement = DefaultReplacement
+	}
+	return redactor{re: regexp.MustCompile(rule.Pattern), replacement: replacement}
+}
+
+func (e *Engine) redact(s string) string {
+	for _, r := range e.redactors {
+		s = r.re.ReplaceAllLiteralString(s, r.replacement)
+	}
+	return s
+}
+
+// redactChoices returns copies of choices with message content redacted, so
+// the originals (possibly cached) stay untouched.
+func (e *Engine) redactChoices(choices []domain.Choice) []domain.Choice {
+	out := make([]domain.Choice, len(choices))
+	for i, c := range choices {
+		if c.Message != nil {
+			msg := *c.Message
+			msg.Content = e.redact(msg.Content)
+			c.Message = &msg
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// redactChunk appends each choice's delta to its unredacted pending buffer
+// and releases the redacted front of it, keeping a trailing holdback in case
+// a match continues into the next chunk. The whole buffer is released once
+// the choice finishes.
+func (e *Engine) redactChunk(chunk *domain.StreamChunk) {
+	if e.pending == nil {
+		e.pending = make(map[int]string)
+	}
+
+	for i := range chunk.Choices {
+		c := &chunk.Choices[i]
+		if c.Delta == nil {
+			continue
+		}
+
+		buf := e.pending[c.Index] + c.Delta.Content
+		cut := len(buf)
+		if c.FinishReason == "" {
+			cut = e.safeCut(buf)
+		}
+
+		c.Delta.Content = e.redact(buf[:cut])
+		if cut < len(buf) {
+			e.pending[c.Index] = buf[cut:]
+		} else {
+			delete(e.pending, c.Index)
+		}
+	}
+}
+
+// safeCut returns how much of buf can be redacted and released now. The cut
+// leaves streamHoldback bytes behind and is moved back to the start of any
+// match crossing it, since that match may still grow.
+func (e *Engine) safeCut(buf string) int {
+	cut := len(buf) - streamHoldback
+	if cut <= 0 {
+		return 0
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, r := range e.redactors {
+			for _, loc := range r.re.FindAllStringIndex(buf, -1) {
+				if loc[0] < cut && loc[1] > cut {
+					cut = loc[0]
+					moved = true
+				}
+			}
+		}
+	}
+	for cut > 0 && !utf8.RuneStart(buf[cut]) {
+		cut--
+	}
+	return cut
+}
+
+func (e *Engine) Flush(chunk *domain.StreamChunk) bool {
+	if len(e.pending) == 0 {
+		return false
+	}
+
+	indexes := make([]int, 0, len(e.pending))
+	for idx := range e.pending {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	for _, idx := range indexes {
+		chunk.Choices = append(chunk.Choices, domain.Choice{
+			Index: idx,
+			Delta: &domain.Delta{Content: e.redact(e.pending[idx])},
+		})
+	}
+	e.pending = nil
+	return true
+}
@@ -0,0 +1,92 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func newRedactEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine, err := New([]domain.TransformRule{
+		{Type: RuleRedact, Stage: StageResponse, Pattern: `sk-[A-Za-z0-9]{8,}`},
+		{Type: RuleRedact, Stage: StageResponse, Pattern: `[\w.]+@[\w.]+\.\w+`, Replacement: "<email>"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return engine
+}
+
+func TestEngine_RedactResponse(t *testing.T) {
+	engine := newRedactEngine(t)
+
+	resp := &domain.ChatResponse{Choices: []domain.Choice{
+		{Message: &domain.Message{Role: "assistant", Content: "key sk-abcdef123456 belongs to jane@example.com"}},
+	}}
+	out := engine.TransformResponse(resp)
+
+	want := "key [REDACTED] belongs to <email>"
+	if got := out.Choices[0].Message.Content; got != want {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	if !strings.Contains(resp.Choices[0].Message.Content, "sk-abcdef123456") {
+		t.Error("TransformResponse must not modify its input")
+	}
+}
+
+func TestEngine_RedactStreamAcrossChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		finish bool
+	}{
+		{"split secret, flushed at end", []string{"your key is sk-abc", "def123456", " and that's it"}, false},
+		{"split secret, finish reason", []string{"your key is sk-abc", "def123456", " and that's it"}, true},
+		{"long text before secret", []string{strings.Repeat("lorem ipsum ", 12), "contact jane@exa", "mple.com today"}, false},
+		{"one byte at a time", strings.Split("mail jane@example.com or use sk-abcdef123456 please", ""), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newRedactEngine(t)
+			var out strings.Builder
+			for i, d := range tt.deltas {
+				chunk := domain.StreamChunk{Choices: []domain.Choice{{Delta: &domain.Delta{Content: d}}}}
+				if tt.finish && i == len(tt.deltas)-1 {
+					chunk.Choices[0].FinishReason = "stop"
+				}
+				engine.TransformChunk(&chunk)
+				out.WriteString(chunk.Choices[0].Delta.Content)
+			}
+			var tail domain.StreamChunk
+			if engine.Flush(&tail) {
+				if tt.finish {
+					t.Error("nothing should be held back after a finish reason")
+				}
+				out.WriteString(tail.Choices[0].Delta.Content)
+			}
+
+			want := engine.redact(strings.Join(tt.deltas, ""))
+			if out.String() != want {
+				t.Errorf("streamed = %q, want %q", out.String(), want)
+			}
+			if strings.Contains(out.String(), "sk-abc") || strings.Contains(out.String(), "@") {
+				t.Errorf("secret leaked: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestEngine_NoRedactorsPassesChunksThrough(t *testing.T) {
+	engine, _ := New(nil)
+	chunk := domain.StreamChunk{Choices: []domain.Choice{{Delta: &domain.Delta{Content: "sk-abcdef123456"}}}}
+	engine.TransformChunk(&chunk)
+	if chunk.Choices[0].Delta.Content != "sk-abcdef123456" {
+		t.Errorf("content = %q, want unchanged", chunk.Choices[0].Delta.Content)
+	}
+	if engine.Flush(&domain.StreamChunk{}) {
+		t.Error("Flush should report nothing held back")
+	}
+}
@@ -0,0 +1,262 @@
+// Package transform applies per-tenant declarative rewrite rules to chat
+// requests and responses. Rules are stored with the tenant and evaluated in
+// order, so a later rule sees the effect of an earlier one.
+//
+// Supported rules:
+//   - rename_model: replace the model name (From -> To)
+//   - drop_field:   remove an optional parameter or response field
+//   - clamp_param:  bound a numeric parameter to [Min, Max]
+//   - redact:       replace matches of a regular expression in response content
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+// Rule types.
+const (
+	RuleRenameModel = "rename_model"
+	RuleDropField   = "drop_field"
+	RuleClampParam  = "clamp_param"
+	RuleRedact      = "redact"
+)
+
+// Stages a rule can apply to. Rules default to the request stage.
+const (
+	StageRequest  = "request"
+	StageResponse = "response"
+)
+
+var ErrInvalidRule = errors.New("invalid transform rule")
+
+// Transformer rewrites traffic between the client and the provider.
+type Transformer interface {
+	// TransformRequest rewrites the inbound request in place.
+	TransformRequest(req *domain.ChatRequest)
+
+	// TransformResponse returns the response to send to the client. The input
+	// is never modified because it may be shared with the cache.
+	TransformResponse(resp *domain.ChatResponse) *domain.ChatResponse
+
+	// TransformChunk rewrites a streaming chunk in place. It may hold back
+	// trailing content to be released by a later chunk or by Flush.
+	TransformChunk(chunk *domain.StreamChunk)
+
+	// Flush fills chunk's choices with any streamed content still held back
+	// and reports whether there was any.
+	Flush(chunk *domain.StreamChunk) bool
+}
+
+var requestFields = map[string]bool{
+	"temperature": true,
+	"max_tokens":  true,
+	"top_p":       true,
+	"stop":        true,
+}
+
+var responseFields = map[string]bool{
+	"usage": true,
+}
+
+var clampParams = map[string]bool{
+	"temperature": true,
+	"max_tokens":  true,
+	"top_p":       true,
+}
+
+// Engine evaluates a tenant's rules. It implements Transformer.
+type Engine struct {
+	request   []domain.TransformRule
+	response  []domain.TransformRule
+	redactors []redactor
+
+	// pending holds streamed content per choice index that has not been
+	// released yet because a redaction could still match across it.
+	pending map[int]string
+}
+
+// New validates rules and returns an engine that applies them.
+func New(rules []domain.TransformRule) (*Engine, error) {
+	if err := Validate(rules); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{}
+	for _, rule := range rules {
+		if rule.Type == RuleRedact {
+			e.redactors = append(e.redactors, newRedactor(rule))
+			continue
+		}
+		if stageOf(rule) == StageResponse {
+			e.response = append(e.response, rule)
+		} else {
+			e.request = append(e.request, rule)
+		}
+	}
+	return e, nil
+}
+
+// Validate checks that every rule is well-formed for its stage.
+func Validate(rules []domain.TransformRule) error {
+	for i, rule := range rules {
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateRule(rule domain.TransformRule) error {
+	stage := stageOf(rule)
+	if stage != StageRequest && stage != StageResponse {
+		return fmt.Errorf("%w: unknown stage %q", ErrInvalidRule, rule.Stage)
+	}
+
+	switch rule.Type {
+	case RuleRenameModel:
+		if rule.From == "" || rule.To == "" {
+			return fmt.Errorf("%w: rename_model requires from and to", ErrInvalidRule)
+		}
+	case RuleDropField:
+		fields := requestFields
+		if stage == StageResponse {
+			fields = responseFields
+		}
+		if !fields[rule.Field] {
+			return fmt.Errorf("%w: cannot drop %s field %q", ErrInvalidRule, stage, rule.Field)
+		}
+	case RuleClampParam:
+		if stage != StageRequest {
+			return fmt.Errorf("%w: clamp_param only applies to requests", ErrInvalidRule)
+		}
+		if !clampParams[rule.Field] {
+			return fmt.Errorf("%w: cannot clamp %q", ErrInvalidRule, rule.Field)
+		}
+		if rule.Min == nil && rule.Max == nil {
+			return fmt.Errorf("%w: clamp_param requires min or max", ErrInvalidRule)
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("%w: min greater than max", ErrInvalidRule)
+		}
+	case RuleRedact:
+		if stage != StageResponse {
+			return fmt.Errorf("%w: redact only applies to responses", ErrInvalidRule)
+		}
+		if rule.Pattern == "" {
+			return fmt.Errorf("%w: redact requires pattern", ErrInvalidRule)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("%w: bad pattern: %v", ErrInvalidRule, err)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidRule, rule.Type)
+	}
+
+	return nil
+}
+
+func stageOf(rule domain.TransformRule) string {
+	if rule.Stage == "" {
+		return StageRequest
+	}
+	return rule.Stage
+}
+
+func (e *Engine) TransformRequest(req *domain.ChatRequest) {
+	for _, rule := range e.request {
+		switch rule.Type {
+		case RuleRenameModel:
+			if req.Model == rule.From {
+				req.Model = rule.To
+			}
+		case RuleDropField:
+			dropRequestField(req, rule.Field)
+		case RuleClampParam:
+			clampRequestParam(req, rule)
+		}
+	}
+}
+
+func (e *Engine) TransformResponse(resp *domain.ChatResponse) *domain.ChatResponse {
+	if (len(e.response) == 0 && len(e.redactors) == 0) || resp == nil {
+		return resp
+	}
+
+	out := *resp
+	if len(e.redactors) > 0 {
+		out.Choices = e.redactChoices(resp.Choices)
+	}
+	for _, rule := range e.response {
+		switch rule.Type {
+		case RuleRenameModel:
+			if out.Model == rule.From {
+				out.Model = rule.To
+			}
+		case RuleDropField:
+			if rule.Field == "usage" {
+				out.Usage = domain.Usage{}
+			}
+		}
+	}
+	return &out
+}
+
+func (e *Engine) TransformChunk(chunk *domain.StreamChunk) {
+	for _, rule := range e.response {
+		if rule.Type == RuleRenameModel && chunk.Model == rule.From {
+			chunk.Model = rule.To
+		}
+	}
+	if len(e.redactors) > 0 {
+		e.redactChunk(chunk)
+	}
+}
+
+func dropRequestField(req *domain.ChatRequest, field string) {
+	switch field {
+	case "temperature":
+		req.Temperature = nil
+	case "max_tokens":
+		req.MaxTokens = nil
+	case "top_p":
+		req.TopP = nil
+	case "stop":
+		req.Stop = nil
+	}
+}
+
+func clampRequestParam(req *domain.ChatRequest, rule domain.TransformRule) {
+	switch rule.Field {
+	case "temperature":
+		req.Temperature = clampFloat(req.Temperature, rule.Min, rule.Max)
+	case "top_p":
+		req.TopP = clampFloat(req.TopP, rule.Min, rule.Max)
+	case "max_tokens":
+		if req.MaxTokens == nil {
+			return
+		}
+		v := float64(*req.MaxTokens)
+		clamped := int(*clampFloat(&v, rule.Min, rule.Max))
+		req.MaxTokens = &clamped
+	}
+}
+
+// clampFloat returns a new pointer so callers never alias client-supplied values.
+// Unset parameters stay unset; providers apply their own defaults.
+func clampFloat(v *float64, min, max *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	out := *v
+	if min != nil && out < *min {
+		out = *min
+	}
+	if max != nil && out > *max {
+		out = *max
+	}
+	return &out
+}
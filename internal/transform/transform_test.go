@@ -0,0 +1,148 @@
+package transform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/felipepmaragno/ai-gateway/internal/domain"
+)
+
+func float64Ptr(v float64) *float64 { return &v }
+func intPtr(v int) *int             { return &v }
+
+func TestEngine_RenameModel(t *testing.T) {
+	engine, err := New([]domain.TransformRule{
+		{Type: RuleRenameModel, From: "gpt-4", To: "gpt-4o-mini"},
+		{Type: RuleRenameModel, Stage: StageResponse, From: "gpt-4o-mini", To: "gpt-4"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	req := domain.ChatRequest{Model: "gpt-4"}
+	engine.TransformRequest(&req)
+	if req.Model != "gpt-4o-mini" {
+		t.Errorf("request model = %q, want %q", req.Model, "gpt-4o-mini")
+	}
+
+	other := domain.ChatRequest{Model: "claude-3"}
+	engine.TransformRequest(&other)
+	if other.Model != "claude-3" {
+		t.Errorf("unmatched model should be untouched, got %q", other.Model)
+	}
+
+	resp := &domain.ChatResponse{Model: "gpt-4o-mini"}
+	out := engine.TransformResponse(resp)
+	if out.Model != "gpt-4" {
+		t.Errorf("response model = %q, want %q", out.Model, "gpt-4")
+	}
+	if resp.Model != "gpt-4o-mini" {
+		t.Error("TransformResponse must not modify its input")
+	}
+
+	chunk := domain.StreamChunk{Model: "gpt-4o-mini"}
+	engine.TransformChunk(&chunk)
+	if chunk.Model != "gpt-4" {
+		t.Errorf("chunk model = %q, want %q", chunk.Model, "gpt-4")
+	}
+}
+
+func TestEngine_ClampTemperature(t *testing.T) {
+	engine, err := New([]domain.TransformRule{
+		{Type: RuleClampParam, Field: "temperature", Min: float64Ptr(0.2), Max: float64Ptr(1.0)},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   *float64
+		want *float64
+	}{
+		{"above max", float64Ptr(1.8), float64Ptr(1.0)},
+		{"below min", float64Ptr(0.0), float64Ptr(0.2)},
+		{"within range", float64Ptr(0.7), float64Ptr(0.7)},
+		{"unset stays unset", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := domain.ChatRequest{Temperature: tt.in}
+			engine.TransformRequest(&req)
+
+			if (req.Temperature == nil) != (tt.want == nil) {
+				t.Fatalf("temperature = %v, want %v", req.Temperature, tt.want)
+			}
+			if tt.want != nil && *req.Temperature != *tt.want {
+				t.Errorf("temperature = %v, want %v", *req.Temperature, *tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_ClampMaxTokens(t *testing.T) {
+	engine, _ := New([]domain.TransformRule{
+		{Type: RuleClampParam, Field: "max_tokens", Max: float64Ptr(512)},
+	})
+
+	req := domain.ChatRequest{MaxTokens: intPtr(4096)}
+	engine.TransformRequest(&req)
+
+	if *req.MaxTokens != 512 {
+		t.Errorf("max_tokens = %d, want 512", *req.MaxTokens)
+	}
+}
+
+func TestEngine_DropField(t *testing.T) {
+	engine, _ := New([]domain.TransformRule{
+		{Type: RuleDropField, Field: "top_p"},
+		{Type: RuleDropField, Stage: StageResponse, Field: "usage"},
+	})
+
+	req := domain.ChatRequest{TopP: float64Ptr(0.9), Temperature: float64Ptr(0.5)}
+	engine.TransformRequest(&req)
+	if req.TopP != nil {
+		t.Error("top_p should be dropped")
+	}
+	if req.Temperature == nil {
+		t.Error("temperature should be kept")
+	}
+
+	out := engine.TransformResponse(&domain.ChatResponse{Usage: domain.Usage{TotalTokens: 30}})
+	if out.Usage.TotalTokens != 0 {
+		t.Error("usage should be dropped from response")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    domain.TransformRule
+		wantErr bool
+	}{
+		{"valid rename", domain.TransformRule{Type: RuleRenameModel, From: "a", To: "b"}, false},
+		{"rename missing to", domain.TransformRule{Type: RuleRenameModel, From: "a"}, true},
+		{"unknown type", domain.TransformRule{Type: "regex"}, true},
+		{"unknown stage", domain.TransformRule{Type: RuleDropField, Stage: "both", Field: "stop"}, true},
+		{"drop unknown field", domain.TransformRule{Type: RuleDropField, Field: "messages"}, true},
+		{"clamp without bounds", domain.TransformRule{Type: RuleClampParam, Field: "temperature"}, true},
+		{"clamp min above max", domain.TransformRule{Type: RuleClampParam, Field: "temperature", Min: float64Ptr(2), Max: float64Ptr(1)}, true},
+		{"clamp on response", domain.TransformRule{Type: RuleClampParam, Stage: StageResponse, Field: "temperature", Max: float64Ptr(1)}, true},
+		{"valid redact", domain.TransformRule{Type: RuleRedact, Stage: StageResponse, Pattern: `sk-\w+`}, false},
+		{"redact on request", domain.TransformRule{Type: RuleRedact, Pattern: `sk-\w+`}, true},
+		{"redact bad pattern", domain.TransformRule{Type: RuleRedact, Stage: StageResponse, Pattern: `(`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]domain.TransformRule{tt.rule})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("expected ErrInvalidRule, got %v", err)
+			}
+		})
+	}
+}
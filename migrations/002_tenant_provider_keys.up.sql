@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS provider_keys JSONB DEFAULT '{}';
+
+COMMENT ON COLUMN tenants.provider_keys IS 'Per-provider API keys, AES-256-GCM encrypted with ENCRYPTION_KEY';
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS transform_rules JSONB DEFAULT '[]';
+
+COMMENT ON COLUMN tenants.transform_rules IS 'Declarative request/response rewrite rules (rename_model, drop_field, clamp_param)';
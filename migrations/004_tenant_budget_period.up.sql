@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS budget_period VARCHAR(16) DEFAULT 'monthly';
+
+COMMENT ON COLUMN tenants.budget_period IS 'Budget reset window (daily, weekly, monthly)';
@@ -0,0 +1,3 @@
+ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+COMMENT ON COLUMN usage_records.metadata IS 'Client-supplied request metadata (OpenAI metadata field) for attribution';
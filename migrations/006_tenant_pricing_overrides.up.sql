@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS pricing_overrides JSONB DEFAULT '{}';
+
+COMMENT ON COLUMN tenants.pricing_overrides IS 'Per-model negotiated prices per 1K tokens, overriding list pricing';
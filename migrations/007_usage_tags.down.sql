@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_usage_records_tags;
+ALTER TABLE usage_records DROP COLUMN IF EXISTS tags;
@@ -0,0 +1,5 @@
+ALTER TABLE usage_records ADD COLUMN IF NOT EXISTS tags JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_usage_records_tags ON usage_records USING GIN (tags);
+
+COMMENT ON COLUMN usage_records.tags IS 'Request tags from the X-Tags header (key=value pairs) for cost attribution';
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS allowed_providers TEXT[] DEFAULT '{}';
+
+COMMENT ON COLUMN tenants.allowed_providers IS 'Providers the tenant may be routed to (empty allows all), e.g. for data residency';
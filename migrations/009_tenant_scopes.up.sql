@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS scopes TEXT[] DEFAULT '{}';
+
+COMMENT ON COLUMN tenants.scopes IS 'API key scopes, e.g. chat:write or usage:read (empty grants all)';
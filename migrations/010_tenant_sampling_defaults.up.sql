@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS sampling_defaults JSONB;
+
+COMMENT ON COLUMN tenants.sampling_defaults IS 'Default temperature, top_p and max_tokens for requests that omit them';
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS webhook_url TEXT;
+
+COMMENT ON COLUMN tenants.webhook_url IS 'URL that receives signed budget alert webhooks for the tenant';
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS default_model TEXT;
+
+COMMENT ON COLUMN tenants.default_model IS 'Model used for the tenant''s requests that do not name one';
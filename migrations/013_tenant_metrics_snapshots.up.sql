@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tenant_metrics_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    instance VARCHAR(255) NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    input_tokens BIGINT NOT NULL DEFAULT 0,
+    output_tokens BIGINT NOT NULL DEFAULT 0,
+    cost_usd DECIMAL(16, 6) NOT NULL DEFAULT 0,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenant_metrics_snapshots_tenant_taken ON tenant_metrics_snapshots(tenant_id, taken_at);
+CREATE INDEX IF NOT EXISTS idx_tenant_metrics_snapshots_instance_tenant_taken ON tenant_metrics_snapshots(instance, tenant_id, taken_at DESC);
+
+COMMENT ON TABLE tenant_metrics_snapshots IS 'Cumulative per-tenant usage totals of one gateway instance, written periodically';
@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS webhook_secret TEXT;
+
+COMMENT ON COLUMN tenants.webhook_secret IS 'Encrypted secret that signs the tenant''s budget alert webhooks';
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenants_name ON tenants(name);
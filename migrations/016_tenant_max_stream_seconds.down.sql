@@ -0,0 +1 @@
+ALTER TABLE tenants DROP COLUMN IF EXISTS max_stream_seconds;
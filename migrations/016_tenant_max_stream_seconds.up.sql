@@ -0,0 +1,3 @@
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS max_stream_seconds INTEGER NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN tenants.max_stream_seconds IS 'Maximum duration of the tenant''s streaming responses; 0 uses the gateway default';